@@ -0,0 +1,110 @@
+package terradep
+
+import "path/filepath"
+
+// Nodes returns all the nodes of the graph, including nodes of external states and local modules, ordered like [Node.Children]
+func (g *Graph) Nodes() []*Node {
+	var out []*Node
+	// visit does not return errors
+	_ = g.Walk(func(n *Node) error {
+		out = append(out, n)
+		return nil
+	})
+	sortNodes(out)
+
+	return out
+}
+
+// IsDeployment checks whether the node is a deployment found by the [Scanner], not an external state nor a local module
+func (g *Graph) IsDeployment(n *Node) bool {
+	_, ok := g.states[deployment{path: n.Path, workspace: n.Workspace, overlay: n.Overlay}]
+	return ok
+}
+
+// Dependents returns nodes depending on the node through [Node.Children] or [Node.Modules], ordered like [Node.Children]
+func (g *Graph) Dependents(n *Node) []*Node {
+	return g.dependents()[n]
+}
+
+// Owners returns deployments and local modules owning any of the paths. Path is owned by the deployment or the module
+// with the longest path containing it, all the workspaces and overlays of the deployment own the path.
+// Paths must be relative, when the graph was scanned with [WithRelativePaths]. Nodes are ordered like [Node.Children]
+func (g *Graph) Owners(paths ...string) []*Node {
+	nodes := g.Nodes()
+	owners := make(map[string]struct{})
+	for _, path := range paths {
+		path = filepath.Clean(filepath.FromSlash(path))
+		owner := ""
+		for _, n := range nodes {
+			nodePath := filepath.FromSlash(n.Path)
+			if g.owns(n) && isSubPath(nodePath, path) && len(nodePath) > len(owner) {
+				owner = nodePath
+			}
+		}
+		if owner != "" {
+			owners[owner] = struct{}{}
+		}
+	}
+
+	var out []*Node
+	for _, n := range nodes {
+		if _, ok := owners[filepath.FromSlash(n.Path)]; ok && g.owns(n) {
+			out = append(out, n)
+		}
+	}
+
+	return out
+}
+
+// Affected returns nodes affected by the change of the files or directories: [Graph.Owners] of the paths
+// and all the nodes depending on them, directly or transitively. Nodes are ordered like [Node.Children]
+func (g *Graph) Affected(paths ...string) []*Node {
+	dependents := g.dependents()
+	affected := make(map[*Node]struct{})
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		if _, ok := affected[n]; ok {
+			return
+		}
+		affected[n] = struct{}{}
+		for _, dependent := range dependents[n] {
+			visit(dependent)
+		}
+	}
+	for _, owner := range g.Owners(paths...) {
+		visit(owner)
+	}
+
+	var out []*Node
+	for _, n := range g.Nodes() {
+		if _, ok := affected[n]; ok {
+			out = append(out, n)
+		}
+	}
+
+	return out
+}
+
+// owns checks whether the node has a directory owning the files, which is true for deployments and local modules
+func (g *Graph) owns(n *Node) bool {
+	if _, ok := n.State.(LocalModule); ok {
+		return true
+	}
+
+	return g.IsDeployment(n)
+}
+
+// dependents returns reversed edges of the graph
+func (g *Graph) dependents() map[*Node][]*Node {
+	out := make(map[*Node][]*Node)
+	for _, n := range g.Nodes() {
+		for _, child := range n.Children {
+			out[child] = append(out[child], n)
+		}
+		for _, module := range n.Modules {
+			out[module] = append(out[module], n)
+		}
+	}
+
+	return out
+}
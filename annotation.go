@@ -0,0 +1,72 @@
+package terradep
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"go.interactor.dev/terradep/inspect"
+)
+
+// AnnotationPrefix starts the comment which declares the dependency of the deployment, which cannot be found in Terraform code.
+// The value has the same format as [Manifest.DependsOn], e.g.
+//
+//	# terradep:depends-on=s3://bucket/dns.tfstate
+//	data "aws_route53_zone" "main" {
+//	  name = "example.com"
+//	}
+//
+// Comments in files using JSON syntax are not supported
+const AnnotationPrefix = "terradep:depends-on="
+
+// findAnnotations returns dependencies declared in the comments of Terraform files in dir, reusing the source of the files parsed by the parser
+func findAnnotations(parser *inspect.Parser, dir string) ([]declaredDependency, error) {
+	files, diags := inspect.DirFiles(parser.FS(), dir)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var out []declaredDependency
+	for _, filename := range files {
+		if strings.HasSuffix(filename, ".json") {
+			continue
+		}
+
+		file, diags := parser.ParseFile(filename)
+		if file == nil {
+			return nil, diags
+		}
+
+		// lexer is used instead of plain text search, so the prefix inside strings or heredocs is not an annotation
+		tokens, _ := hclsyntax.LexConfig(file.Bytes, filename, hcl.InitialPos)
+		for _, token := range tokens {
+			if token.Type != hclsyntax.TokenComment {
+				continue
+			}
+
+			for _, target := range parseAnnotations(string(token.Bytes)) {
+				out = append(out, declaredDependency{Target: target, Range: rangePtr(token.Range)})
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// parseAnnotations returns values of all annotations in the comment, every line of the comment can have one
+func parseAnnotations(comment string) []string {
+	var out []string
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(line)
+		for _, marker := range []string{"#", "//", "/*", "*"} {
+			line = strings.TrimPrefix(line, marker)
+		}
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), "*/"))
+
+		if target, ok := strings.CutPrefix(line, AnnotationPrefix); ok && target != "" {
+			out = append(out, target)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,119 @@
+package terradep
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"go.interactor.dev/terradep/inspect"
+	"golang.org/x/exp/slog"
+)
+
+// terraformBlockSchema is the schema of blocks of the block terraform declaring where the state is stored, e.g.
+//
+//	terraform {
+//	  required_version = "1.2.7"
+//
+//	  backend "someBackend" {
+//	    some  = "data"
+//	    other = ["list"]
+//	  }
+//	}
+//
+// or with Terraform Cloud:
+//
+//	terraform {
+//	  required_version = "1.2.7"
+//
+//	  cloud {
+//	    organization = "some-org"
+//	    workspaces {
+//	      name = "some-workspace"
+//	    }
+//	  }
+//	}
+var terraformBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "backend", LabelNames: []string{"type"}},
+		{Type: CloudBackend},
+	},
+}
+
+// backendBlock is block backend or cloud found inside the block terraform
+type backendBlock struct {
+	// Type is the type of the backend or [CloudBackend] for block cloud
+	Type  string
+	Body  hcl.Body
+	Range hcl.Range
+}
+
+// resolveBackend finds the backend of the module following the [override rules] of Terraform:
+// primary files can define at most one backend or cloud block and the block found in the override file replaces it.
+// Returns nil when the module does not have a backend
+//
+// [override rules]: https://developer.hashicorp.com/terraform/language/files/override#merging-terraform-blocks
+func (s *Scanner) resolveBackend(blocks inspect.TerraformBlocks) (*backendBlock, error) {
+	primary, err := findBackendBlocks(blocks.Primary)
+	if err != nil {
+		return nil, err
+	}
+	if len(primary) > 1 {
+		return nil, fmt.Errorf("conflicting backend or cloud blocks, only one is allowed: %s", backendRanges(primary))
+	}
+
+	var out *backendBlock
+	if len(primary) == 1 {
+		out = &primary[0]
+	}
+
+	// every override block is applied separately, the last one wins
+	for _, block := range blocks.Override {
+		overrides, err := findBackendBlocks([]*hcl.Block{block})
+		if err != nil {
+			return nil, err
+		}
+		if len(overrides) > 1 {
+			return nil, fmt.Errorf("conflicting backend or cloud blocks in override file, only one is allowed: %s", backendRanges(overrides))
+		}
+		if len(overrides) == 0 {
+			continue
+		}
+
+		if out != nil {
+			s.log.Debug("backend overridden", slog.String("primary", out.Range.String()), slog.String("override", overrides[0].Range.String()))
+		}
+		out = &overrides[0]
+	}
+
+	return out, nil
+}
+
+// findBackendBlocks returns all backend and cloud blocks defined in the terraform blocks
+func findBackendBlocks(terraformBlocks []*hcl.Block) ([]backendBlock, error) {
+	var out []backendBlock
+	for _, tb := range terraformBlocks {
+		content, _, diags := tb.Body.PartialContent(terraformBlockSchema)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("decoding terraform block: %s, %w", tb.DefRange, diags)
+		}
+
+		for _, block := range content.Blocks {
+			backend := backendBlock{Type: CloudBackend, Body: block.Body, Range: block.DefRange}
+			if block.Type == "backend" {
+				backend.Type = block.Labels[0]
+			}
+			out = append(out, backend)
+		}
+	}
+
+	return out, nil
+}
+
+func backendRanges(blocks []backendBlock) string {
+	ranges := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		ranges = append(ranges, block.Range.String())
+	}
+
+	return strings.Join(ranges, ", ")
+}
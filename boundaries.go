@@ -0,0 +1,73 @@
+package terradep
+
+import "fmt"
+
+// StateRegion returns the region of the state, i.e. field region of the state encoded as JSON, e.g. region of S3 state.
+// Returns empty string when the region is not known
+func StateRegion(state State) string {
+	return stateField(state, "region")
+}
+
+// StateAccount returns the cloud account of the state, i.e. field account of the state encoded as JSON, e.g. AWS account of the role
+// assumed by S3 backend. Returns empty string when the account is not known
+func StateAccount(state State) string {
+	return stateField(state, "account")
+}
+
+// CheckRegions finds dependencies on states stored in other region than the state of the deployment ([RuleCrossRegion]),
+// which fail together with the region. Deployments and states without known region, see [StateRegion], are not checked.
+// All the diagnostics have [SeverityWarning]
+func CheckRegions(deployments []Deployment) Diagnostics {
+	return checkBoundary(deployments, RuleCrossRegion, "region", StateRegion)
+}
+
+// CheckAccounts finds dependencies on states read from other account than the state of the deployment ([RuleCrossAccount]),
+// which need access granted across the accounts. Deployments and states without known account, see [StateAccount], are not checked.
+// All the diagnostics have [SeverityWarning]
+func CheckAccounts(deployments []Deployment) Diagnostics {
+	return checkBoundary(deployments, RuleCrossAccount, "account", StateAccount)
+}
+
+// checkBoundary reports dependencies which property, e.g. region, differs from the property of the deployment
+func checkBoundary(deployments []Deployment, rule, name string, property func(State) string) Diagnostics {
+	var diags Diagnostics
+	for _, d := range deployments {
+		value := property(d.State)
+		if value == "" {
+			continue
+		}
+
+		for _, dep := range d.Dependencies {
+			depValue := property(dep)
+			if depValue == "" || depValue == value {
+				continue
+			}
+
+			diag := Diagnostic{
+				Severity: SeverityWarning,
+				Rule:     rule,
+				Summary:  fmt.Sprintf("state in %s: %s depends on state in %s: %s", name, value, name, depValue),
+				Detail:   fmt.Sprintf("state: %s", dep),
+				Module:   d.Path,
+				Range:    edgeRange(d, dep),
+			}
+			if d.Workspace != "" || d.Overlay != "" {
+				diag.Detail += fmt.Sprintf(", workspace: %q, overlay: %q", d.Workspace, d.Overlay)
+			}
+			diags = append(diags, diag)
+		}
+	}
+
+	return diags
+}
+
+// stateField returns the string field of the state encoded as JSON, empty string when it is not set
+func stateField(state State, name string) string {
+	fields, err := stateFields(state)
+	if err != nil {
+		return ""
+	}
+
+	value, _ := fields[name].(string)
+	return value
+}
@@ -0,0 +1,522 @@
+package terradep
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"go.interactor.dev/terradep/inspect"
+	"go.interactor.dev/terradep/internal/perm"
+	"golang.org/x/exp/slog"
+)
+
+// ScanCache stores results of scanning the modules, so the [Scanner] can skip parsing of modules which did not change
+type ScanCache interface {
+	// Load returns result of scanning the module stored under the path, if there is any
+	Load(path string) (CachedModule, bool)
+	// Store saves the result of scanning the module under the path
+	Store(path string, module CachedModule)
+}
+
+// CachedModule is the result of scanning single module stored in [ScanCache]
+type CachedModule struct {
+	// Hash of content of Terraform files in Dirs
+	Hash string `json:"hash"`
+	// Dirs are the module directory and directories of local modules followed by the [Scanner], see [WithFollowModules]
+	Dirs []string `json:"dirs"`
+	// Workspaces contains one entry per workspace, see [WithWorkspaces]
+	Workspaces []CachedWorkspace `json:"workspaces"`
+	// Annotations are dependencies declared in the comments, see [AnnotationPrefix]
+	Annotations []string        `json:"annotations,omitempty"`
+	Metadata    *ModuleMetadata `json:"metadata,omitempty"`
+	// BackendRange and Edges are [Node.BackendRange] and [Node.Edges] of the deployments of the module
+	BackendRange *hcl.Range              `json:"backendRange,omitempty"`
+	Edges        map[string]EdgeMetadata `json:"edges,omitempty"`
+	// Calls are local modules called by the module
+	Calls []string `json:"calls,omitempty"`
+	// Diagnostics are warnings reported while the module was scanned, reported again when the module is loaded from the cache
+	Diagnostics []CachedDiagnostic `json:"diagnostics,omitempty"`
+}
+
+// CachedWorkspace is the result of scanning the module in single workspace.
+// State and Deps are encoded with [MarshalState], so they can be restored by the [Stater] implementing [StateDecoder]
+type CachedWorkspace struct {
+	Workspace string            `json:"workspace"`
+	Overlay   string            `json:"overlay,omitempty"`
+	State     json.RawMessage   `json:"state"`
+	Deps      []json.RawMessage `json:"deps"`
+	Modules   []string          `json:"modules,omitempty"`
+}
+
+// CachedDiagnostic is [Diagnostic] with [SeverityWarning] stored in [CachedModule]
+type CachedDiagnostic struct {
+	Rule    string     `json:"rule,omitempty"`
+	Summary string     `json:"summary"`
+	Detail  string     `json:"detail,omitempty"`
+	Module  string     `json:"module,omitempty"`
+	Range   *hcl.Range `json:"range,omitempty"`
+}
+
+// WithCache makes the [Scanner] reuse results of scanning modules which files did not change since they were stored in the cache
+func WithCache(cache ScanCache) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.cache = cache
+	}
+}
+
+// cachedState is a [State] restored from JSON, see [Graph.UnmarshalJSON], or from [ScanCache] when the [Stater] cannot decode it, see [StateDecoder].
+// It is equal to other states with the same String representation. Only the identity is kept, so the backend is not known
+type cachedState string
+
+// String implements State
+func (s cachedState) String() string {
+	return string(s)
+}
+
+// Backend implements State
+func (s cachedState) Backend() string {
+	return ""
+}
+
+// Identity implements State
+func (s cachedState) Identity() string {
+	return string(s)
+}
+
+// MarshalJSON implements [json.Marshaler]
+func (s cachedState) MarshalJSON() ([]byte, error) {
+	return MarshalState(s, nil)
+}
+
+// loadCached adds results of scanning the module from the cache to the scan. Returns false, when module must be scanned
+func (s *Scanner) loadCached(sc *scan, path string) bool {
+	if s.cache == nil {
+		return false
+	}
+
+	cached, ok := s.cache.Load(path)
+	if !ok {
+		return false
+	}
+
+	hash, err := hashDirs(sc.fs, cached.Dirs)
+	if err != nil || hash != cached.Hash {
+		s.log.Debug("cached module changed", slog.String("path", path))
+		return false
+	}
+
+	states := make([]State, len(cached.Workspaces))
+	deps := make([][]State, len(cached.Workspaces))
+	for i, ws := range cached.Workspaces {
+		if states[i], err = s.decodeState(ws.State); err != nil {
+			s.log.Debug("cached state cannot be decoded", slog.String("path", path), slog.String("error", err.Error()))
+			return false
+		}
+		deps[i] = make([]State, 0, len(ws.Deps))
+		for _, d := range ws.Deps {
+			state, err := s.decodeState(d)
+			if err != nil {
+				s.log.Debug("cached state cannot be decoded", slog.String("path", path), slog.String("error", err.Error()))
+				return false
+			}
+			deps[i] = append(deps[i], state)
+		}
+	}
+
+	s.log.Info("using cached module", slog.String("path", path))
+	for i, ws := range cached.Workspaces {
+		dep := deployment{path: path, workspace: ws.Workspace, overlay: ws.Overlay}
+		sc.states[dep] = states[i]
+		sc.deps[dep] = deps[i]
+
+		if len(ws.Modules) != 0 {
+			sc.modules[dep] = ws.Modules
+		}
+	}
+	sc.calls[path] = cached.Calls
+	for _, d := range cached.Diagnostics {
+		s.warn(sc, Diagnostic{Rule: d.Rule, Summary: d.Summary, Detail: d.Detail, Module: d.Module, Range: d.Range})
+	}
+
+	if cached.Metadata != nil {
+		sc.detailsOf(path).metadata = cached.Metadata
+	}
+	sc.detailsOf(path).backendRange = cached.BackendRange
+	sc.detailsOf(path).edges = cached.Edges
+
+	for _, target := range cached.Annotations {
+		sc.annotations[path] = append(sc.annotations[path], declaredDependency{Target: target})
+	}
+
+	return true
+}
+
+// decodeState restores the state encoded with [MarshalState]. States of workspaces and local modules are restored by the [Scanner],
+// other states by the [Stater] implementing [StateDecoder]. When the [Stater] cannot decode the state, it keeps only the identity, see [cachedState]
+func (s *Scanner) decodeState(b json.RawMessage) (State, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("decoding state: %s, %w", b, err)
+	}
+
+	return s.decodeFields(fields)
+}
+
+func (s *Scanner) decodeFields(fields map[string]any) (State, error) {
+	identity, _ := fields["identity"].(string)
+	if identity == "" {
+		return nil, fmt.Errorf("state does not have identity: %v", fields)
+	}
+
+	// fields of the state in the default workspace are nested, see [WorkspaceState.MarshalJSON]
+	if nested, ok := fields["state"].(map[string]any); ok {
+		workspace, _ := fields["workspace"].(string)
+		state, err := s.decodeFields(nested)
+		if err != nil {
+			return nil, err
+		}
+		return WorkspaceState{State: state, Workspace: workspace}, nil
+	}
+	if path, ok := fields["path"].(string); ok && identity == LocalModule(path).Identity() {
+		return LocalModule(path), nil
+	}
+
+	decoder, ok := s.stater.(StateDecoder)
+	if !ok {
+		return cachedState(identity), nil
+	}
+	state, err := decoder.DecodeState(fields)
+	if errors.Is(err, ErrUnsupportedBackend) {
+		return cachedState(identity), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding state: %s, %w", identity, err)
+	}
+	if state.Identity() != identity {
+		return nil, fmt.Errorf("decoded state: %s has different identity than cached state: %s", state.Identity(), identity)
+	}
+
+	return state, nil
+}
+
+// encodeState returns the state encoded with [MarshalState], the state which does not implement [json.Marshaler] is encoded without the fields
+func encodeState(state State) (json.RawMessage, error) {
+	if _, ok := state.(json.Marshaler); ok {
+		return json.Marshal(state)
+	}
+
+	return MarshalState(state, nil)
+}
+
+// storeCached puts results of scanning the module to the cache, with the warnings reported since the scan had first diagnostics.
+// Warnings about skipped files are not stored, they are reported again when [hashDirs] lists the files of the cached module
+func (s *Scanner) storeCached(sc *scan, module *tfconfig.Module, first int) {
+	if s.cache == nil {
+		return
+	}
+
+	for _, overlay := range sc.overlaysOf(module.Path) {
+		if overlay != nil && len(overlay.BackendConfigFiles) != 0 {
+			// files can be anywhere, so it is not possible to tell whether they changed
+			s.log.Debug("module with backend config files will not be cached", slog.String("path", module.Path))
+			return
+		}
+	}
+
+	dirs := []string{module.Path}
+	if s.followModules {
+		dirs = append(dirs, followedModuleDirs(sc.parser, module, map[string]struct{}{module.Path: {}})...)
+	}
+
+	hash, err := hashDirs(sc.fs, dirs)
+	if err != nil {
+		s.log.Warn("module will not be cached", slog.String("path", module.Path), slog.String("error", err.Error()))
+		return
+	}
+
+	details := sc.detailsOf(module.Path)
+	cached := CachedModule{Hash: hash, Dirs: dirs, Metadata: details.metadata, BackendRange: details.backendRange, Edges: details.edges, Calls: sc.calls[module.Path]}
+	for _, a := range sc.annotations[module.Path] {
+		cached.Annotations = append(cached.Annotations, a.Target)
+	}
+	sc.mu.Lock()
+	for _, d := range sc.diags[first:] {
+		if d.Severity == SeverityWarning && d.Rule != RuleSkippedFile {
+			cached.Diagnostics = append(cached.Diagnostics, CachedDiagnostic{Rule: d.Rule, Summary: d.Summary, Detail: d.Detail, Module: d.Module, Range: d.Range})
+		}
+	}
+	sc.mu.Unlock()
+	for _, dep := range sortedDeployments(sc.states) {
+		if dep.path != module.Path {
+			continue
+		}
+		ws := CachedWorkspace{
+			Workspace: dep.workspace,
+			Overlay:   dep.overlay,
+			Deps:      make([]json.RawMessage, 0, len(sc.deps[dep])),
+			Modules:   sc.modules[dep],
+		}
+		if ws.State, err = encodeState(sc.states[dep]); err != nil {
+			s.log.Warn("module will not be cached", slog.String("path", module.Path), slog.String("error", err.Error()))
+			return
+		}
+		for _, d := range sc.deps[dep] {
+			encoded, err := encodeState(d)
+			if err != nil {
+				s.log.Warn("module will not be cached", slog.String("path", module.Path), slog.String("error", err.Error()))
+				return
+			}
+			ws.Deps = append(ws.Deps, encoded)
+		}
+		cached.Workspaces = append(cached.Workspaces, ws)
+	}
+
+	s.cache.Store(module.Path, cached)
+}
+
+// followedModuleDirs returns directories of local modules called by the module, recursively
+func followedModuleDirs(parser *inspect.Parser, module *tfconfig.Module, visited map[string]struct{}) []string {
+	var out []string
+	for _, call := range localModuleCalls(module) {
+		if _, ok := visited[call]; ok {
+			continue
+		}
+		visited[call] = struct{}{}
+		out = append(out, call)
+
+		child, diags := inspect.LoadModule(parser, call)
+		if diags.HasErrors() {
+			continue
+		}
+		out = append(out, followedModuleDirs(parser, child, visited)...)
+	}
+
+	return out
+}
+
+// hashDirs returns hash of names and content of Terraform files in the fs and manifests in the directories
+func hashDirs(fs tfconfig.FS, dirs []string) (string, error) {
+	h := sha256.New()
+	for _, dir := range dirs {
+		files, diags := inspect.DirFiles(fs, dir)
+		if diags.HasErrors() {
+			return "", diags
+		}
+		sort.Strings(files)
+		for _, name := range ManifestFiles {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				files = append(files, filepath.Join(dir, name))
+			}
+		}
+
+		for _, file := range files {
+			b, err := fs.ReadFile(file)
+			if err != nil {
+				return "", fmt.Errorf("reading file: %s, %w", file, err)
+			}
+
+			h.Write([]byte(filepath.ToSlash(file)))
+			h.Write([]byte{0})
+			h.Write(b)
+			h.Write([]byte{0})
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FileCache is a [ScanCache] stored in single JSON file.
+// Entries not used since the cache was opened are dropped when it is saved
+type FileCache struct {
+	path string
+	key  string
+
+	mu      sync.Mutex
+	entries map[string]CachedModule
+	used    map[string]CachedModule
+}
+
+// fileCacheVersion changes every time format of the cache file changes in incompatible way
+const fileCacheVersion = 4
+
+type fileCacheContent struct {
+	Version int                     `json:"version"`
+	Key     string                  `json:"key"`
+	Modules map[string]CachedModule `json:"modules"`
+}
+
+// OpenFileCache reads the cache from the file. If the file does not exist, cache is empty.
+// Key should describe configuration of the [Scanner] and [Stater], cache stored with different key is ignored
+func OpenFileCache(path, key string) (*FileCache, error) {
+	c := &FileCache{
+		path:    path,
+		key:     key,
+		entries: map[string]CachedModule{},
+		used:    map[string]CachedModule{},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cache file: %s, %w", path, err)
+	}
+
+	content := fileCacheContent{}
+	if err := json.Unmarshal(b, &content); err != nil {
+		return nil, fmt.Errorf("decoding cache file: %s, %w", path, err)
+	}
+
+	if content.Version == fileCacheVersion && content.Key == key && content.Modules != nil {
+		c.entries = content.Modules
+	}
+
+	return c, nil
+}
+
+// Load implements [ScanCache]
+func (c *FileCache) Load(path string) (CachedModule, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	module, ok := c.entries[path]
+	if ok {
+		c.used[path] = module
+	}
+
+	return module, ok
+}
+
+// Store implements [ScanCache]
+func (c *FileCache) Store(path string, module CachedModule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[path] = module
+	c.used[path] = module
+}
+
+// Save writes used entries of the cache to the file
+func (c *FileCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, err := json.Marshal(fileCacheContent{Version: fileCacheVersion, Key: c.key, Modules: c.used})
+	if err != nil {
+		return fmt.Errorf("encoding cache: %w", err)
+	}
+
+	if err := os.WriteFile(c.path, b, perm.UserRW); err != nil {
+		return fmt.Errorf("writing cache file: %s, %w", c.path, err)
+	}
+
+	return nil
+}
+
+// DirCache is a [ScanCache] storing every module in a separate file in the directory, named after the hash of the path of the module.
+// Only entries of modules which were scanned again are written when it is saved, so the directory can be cheaply persisted between runs, e.g. by CI.
+// Entries of removed modules are not dropped
+type DirCache struct {
+	dir string
+	key string
+
+	mu      sync.Mutex
+	changed map[string]CachedModule
+}
+
+type dirCacheEntry struct {
+	Version int          `json:"version"`
+	Key     string       `json:"key"`
+	Path    string       `json:"path"`
+	Module  CachedModule `json:"module"`
+}
+
+// OpenDirCache creates the directory if it does not exist and returns cache stored in it.
+// Key should describe configuration of the [Scanner] and [Stater], entries stored with different key are ignored
+func OpenDirCache(dir, key string) (*DirCache, error) {
+	if err := os.MkdirAll(dir, perm.UserRWX); err != nil {
+		return nil, fmt.Errorf("creating cache dir: %s, %w", dir, err)
+	}
+
+	return &DirCache{dir: dir, key: key, changed: map[string]CachedModule{}}, nil
+}
+
+// Load implements [ScanCache]
+func (c *DirCache) Load(path string) (CachedModule, bool) {
+	c.mu.Lock()
+	module, ok := c.changed[path]
+	c.mu.Unlock()
+	if ok {
+		return module, true
+	}
+
+	b, err := os.ReadFile(c.entryPath(path))
+	if err != nil {
+		return CachedModule{}, false
+	}
+
+	entry := dirCacheEntry{}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return CachedModule{}, false
+	}
+	if entry.Version != fileCacheVersion || entry.Key != c.key || entry.Path != path {
+		return CachedModule{}, false
+	}
+
+	return entry.Module, true
+}
+
+// Store implements [ScanCache]
+func (c *DirCache) Store(path string, module CachedModule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.changed[path] = module
+}
+
+// Save writes entries stored since the cache was opened to the directory
+func (c *DirCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for path, module := range c.changed {
+		b, err := json.Marshal(dirCacheEntry{Version: fileCacheVersion, Key: c.key, Path: path, Module: module})
+		if err != nil {
+			return fmt.Errorf("encoding cache entry of module: %s, %w", path, err)
+		}
+
+		// entry is renamed, so concurrent runs sharing the directory never read partially written file
+		tmp, err := os.CreateTemp(c.dir, "entry-*.tmp")
+		if err != nil {
+			return fmt.Errorf("creating cache entry of module: %s, %w", path, err)
+		}
+		_, err = tmp.Write(b)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), c.entryPath(path))
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+			return fmt.Errorf("writing cache entry of module: %s, %w", path, err)
+		}
+	}
+	c.changed = map[string]CachedModule{}
+
+	return nil
+}
+
+func (c *DirCache) entryPath(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,219 @@
+package terradep_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/state"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+var cachedFiles = terradeptest.Files{
+	"network/main.tf": `
+terraform {
+  backend "s3" {
+    bucket = "b"
+    key    = "network"
+  }
+}
+`,
+	"app/main.tf": `
+terraform {
+  backend "s3" {
+    bucket = "b"
+    key    = "app"
+  }
+}
+
+data "terraform_remote_state" "network" {
+  backend = "s3"
+  config = {
+    bucket = "b"
+    key    = "network"
+  }
+}
+
+data "terraform_remote_state" "self" {
+  backend = "s3"
+  config = {
+    bucket = "b"
+    key    = "app"
+  }
+}
+
+output "self" {
+  value = data.terraform_remote_state.self.outputs
+}
+`,
+}
+
+func TestCacheReportsDiagnosticsOfCachedModules(t *testing.T) {
+	for _, cache := range []struct {
+		name string
+		open func(t *testing.T, path string) savedCache
+	}{
+		{name: "file", open: func(t *testing.T, path string) savedCache {
+			c, err := terradep.OpenFileCache(path, "key")
+			if err != nil {
+				t.Fatalf("opening file cache: %v", err)
+			}
+			return c
+		}},
+		{name: "dir", open: func(t *testing.T, path string) savedCache {
+			c, err := terradep.OpenDirCache(path, "key")
+			if err != nil {
+				t.Fatalf("opening dir cache: %v", err)
+			}
+			return c
+		}},
+	} {
+		t.Run(cache.name, func(t *testing.T) {
+			dir := terradeptest.Dir(t, cachedFiles)
+			cachePath := filepath.Join(t.TempDir(), "cache")
+
+			cold, coldDiags := scanWithCache(t, dir, cache.open(t, cachePath))
+			want := []string{terradep.RuleSelfDependency, terradep.RuleUnusedRemoteState}
+			if got := diagnosticRules(coldDiags); !equalStrings(got, want) {
+				t.Fatalf("unexpected diagnostics of cold run: %v, want: %v", got, want)
+			}
+
+			warm, warmDiags := scanWithCache(t, dir, cache.open(t, cachePath))
+			if got := diagnosticRules(warmDiags); !equalStrings(got, want) {
+				t.Errorf("unexpected diagnostics of warm run: %v, want: %v", got, want)
+			}
+			if terradeptest.Describe(cold) != terradeptest.Describe(warm) {
+				t.Errorf("graph of warm run differs\ncold:\n%s\nwarm:\n%s", terradeptest.Describe(cold), terradeptest.Describe(warm))
+			}
+			for i := range coldDiags {
+				if coldDiags[i].String() != warmDiags[i].String() {
+					t.Errorf("diagnostic of warm run differs: %s, cold: %s", warmDiags[i], coldDiags[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCacheRestoresStatesWithFieldsOfBackend(t *testing.T) {
+	dir := terradeptest.Dir(t, terradeptest.Files{
+		"network/main.tf": `
+terraform {
+  backend "s3" {
+    bucket   = "b"
+    key      = "network"
+    region   = "eu-west-1"
+    encrypt  = true
+    role_arn = "arn:aws:iam::111111111111:role/terraform"
+  }
+}
+`,
+		"app/main.tf": `
+terraform {
+  backend "s3" {
+    bucket  = "b"
+    key     = "app"
+    region  = "us-east-1"
+    encrypt = true
+    profile = "app"
+  }
+}
+
+data "terraform_remote_state" "network" {
+  backend = "s3"
+  config = {
+    bucket   = "b"
+    key      = "network"
+    region   = "eu-west-1"
+    role_arn = "arn:aws:iam::111111111111:role/terraform"
+  }
+}
+
+output "network" {
+  value = data.terraform_remote_state.network.outputs
+}
+`,
+	})
+	cachePath := filepath.Join(t.TempDir(), "cache.json")
+	stater := state.NewByTypeStater(map[string]terradep.Stater{state.S3Backend: state.NewS3Stater()})
+
+	scan := func() []byte {
+		cache, err := terradep.OpenFileCache(cachePath, "key")
+		if err != nil {
+			t.Fatalf("opening file cache: %v", err)
+		}
+		graph, _, err := terradep.NewScanner(nil, stater, terradep.WithRelativePaths(), terradep.WithCache(cache)).Scan(dir)
+		if err != nil {
+			t.Fatalf("scanning directory: %s, %v", dir, err)
+		}
+		if err := cache.Save(); err != nil {
+			t.Fatalf("saving cache: %v", err)
+		}
+		states := make(map[string]terradep.State)
+		for _, n := range graph.Nodes() {
+			if _, ok := n.State.(state.S3State); !ok {
+				t.Errorf("state of deployment: %s is %T, want: %T", n.Path, n.State, state.S3State{})
+			}
+			states[n.Path] = n.State
+		}
+
+		b, err := json.Marshal(states)
+		if err != nil {
+			t.Fatalf("encoding states: %v", err)
+		}
+		return b
+	}
+
+	cold, warm := scan(), scan()
+	if !bytes.Contains(cold, []byte(`"region":"eu-west-1"`)) || !bytes.Contains(cold, []byte(`"account":"111111111111"`)) {
+		t.Fatalf("states do not contain fields of the backend: %s", cold)
+	}
+	if !bytes.Equal(cold, warm) {
+		t.Errorf("states of warm run differ\ncold: %s\nwarm: %s", cold, warm)
+	}
+}
+
+type savedCache interface {
+	terradep.ScanCache
+	Save() error
+}
+
+func scanWithCache(t *testing.T, dir string, cache savedCache) (*terradep.Graph, terradep.Diagnostics) {
+	t.Helper()
+
+	s := terradep.NewScanner(nil, terradeptest.NewStater(), terradep.WithRelativePaths(), terradep.WithCache(cache))
+	graph, diags, err := s.Scan(dir)
+	if err != nil {
+		t.Fatalf("scanning directory: %s, %v", dir, err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("saving cache: %v", err)
+	}
+	sort.Slice(diags, func(i, j int) bool { return diags[i].Rule < diags[j].Rule })
+
+	return graph, diags
+}
+
+func diagnosticRules(diags terradep.Diagnostics) []string {
+	out := make([]string, 0, len(diags))
+	for _, d := range diags {
+		out = append(out, d.Rule)
+	}
+
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
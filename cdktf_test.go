@@ -0,0 +1,97 @@
+package terradep_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/state"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+func TestScanLinksStacksByRelativePathsOfLocalStates(t *testing.T) {
+	dir := terradeptest.Dir(t, terradeptest.Files{
+		"cdktf.out/stacks/network/cdk.tf.json": `{
+  "terraform": {
+    "backend": {
+      "local": {
+        "path": "terraform.network.tfstate"
+      }
+    }
+  }
+}`,
+		"cdktf.out/stacks/app/cdk.tf.json": `{
+  "terraform": {
+    "backend": {
+      "local": {
+        "path": "terraform.app.tfstate"
+      }
+    }
+  },
+  "data": {
+    "terraform_remote_state": {
+      "network": {
+        "backend": "local",
+        "config": {
+          "path": "../network/terraform.network.tfstate"
+        }
+      }
+    }
+  },
+  "output": {
+    "network": {
+      "value": "${data.terraform_remote_state.network.outputs}"
+    }
+  }
+}`,
+		// stacks of CDKTF and HCL modules without path use the default terraform.tfstate in their own directories
+		"live/dns/main.tf": `
+terraform {
+  backend "local" {}
+}
+`,
+		"live/cdn/main.tf": `
+terraform {
+  backend "local" {}
+}
+`,
+	})
+	stater := state.NewByTypeStater(map[string]terradep.Stater{state.LocalBackend: state.NewLocalStater()})
+
+	graph, diags, err := terradep.NewScanner(nil, stater).Scan(dir)
+	if err != nil {
+		t.Fatalf("scanning directory: %s, %v", dir, err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diags)
+	}
+
+	stateOf := func(path string) string {
+		return filepath.ToSlash(filepath.Join(dir, path))
+	}
+	want := map[string]string{
+		"cdktf.out/stacks/network": stateOf("cdktf.out/stacks/network/terraform.network.tfstate"),
+		"cdktf.out/stacks/app":     stateOf("cdktf.out/stacks/app/terraform.app.tfstate"),
+		"live/dns":                 stateOf("live/dns/terraform.tfstate"),
+		"live/cdn":                 stateOf("live/cdn/terraform.tfstate"),
+	}
+	network := state.LocalState{Path: want["cdktf.out/stacks/network"]}
+	got := make(map[string]string)
+	for _, n := range graph.Nodes() {
+		path, _ := filepath.Rel(dir, n.Path)
+		local, ok := n.State.(state.LocalState)
+		if !ok {
+			t.Fatalf("state of deployment: %s is %T, want: %T", path, n.State, state.LocalState{})
+		}
+		got[filepath.ToSlash(path)] = local.Path
+
+		if filepath.ToSlash(path) == "cdktf.out/stacks/app" && (len(n.Children) != 1 || n.Children[0].State.Identity() != network.Identity()) {
+			t.Errorf("expected stack app depending on stack network, got: %v", n.Children)
+		}
+	}
+	for path, state := range want {
+		if got[path] != state {
+			t.Errorf("unexpected path of state of deployment: %s, got: %s, want: %s", path, got[path], state)
+		}
+	}
+}
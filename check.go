@@ -0,0 +1,80 @@
+package terradep
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// CheckDeployments finds problems in the deployments, e.g. streamed by [Scanner.Stream], which make the [Graph] invalid or incomplete:
+// dependencies on states not produced by any of the deployments ([RuleExternalDependency]), deployments depending on each other ([RuleCycle])
+// and deployments sharing the state ([RuleDuplicateState]). All the diagnostics have [SeverityError].
+// Diagnostics point to the backend block of the deployment or the first data source referencing the state, when it is known
+func CheckDeployments(deployments []Deployment) Diagnostics {
+	states := make(map[deployment]State, len(deployments))
+	deps := make(map[deployment][]State, len(deployments))
+	byKey := make(map[deployment]Deployment, len(deployments))
+	for _, d := range deployments {
+		key := deployment{path: d.Path, workspace: d.Workspace, overlay: d.Overlay}
+		byKey[key] = d
+		states[key] = d.State
+		if len(d.Dependencies) != 0 {
+			deps[key] = d.Dependencies
+		}
+	}
+
+	var diags Diagnostics
+	for _, group := range findDuplicateStates(states) {
+		for _, dup := range group[1:] {
+			diag := Diagnostic{
+				Severity: SeverityError,
+				Rule:     RuleDuplicateState,
+				Summary:  fmt.Sprintf("state is the same as state of deployment: %s", group[0].path),
+				Detail:   fmt.Sprintf("state: %s", states[dup]),
+				Module:   dup.path,
+				Range:    byKey[dup].BackendRange,
+			}
+			if dup.workspace != "" || dup.overlay != "" {
+				diag.Detail += fmt.Sprintf(", workspace: %q, overlay: %q", dup.workspace, dup.overlay)
+			}
+			diags = append(diags, diag)
+		}
+	}
+
+	for _, cycle := range findCycles(states, deps) {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Rule:     RuleCycle,
+			Summary:  "deployments depend on each other",
+			Detail:   cycleString(states, cycle),
+			Module:   cycle[0].path,
+			Range:    edgeRange(byKey[cycle[0]], states[cycle[1]]),
+		})
+	}
+
+	for _, ext := range externalDependencies(states, deps) {
+		diag := Diagnostic{
+			Severity: SeverityError,
+			Rule:     RuleExternalDependency,
+			Summary:  fmt.Sprintf("depends on state not produced by any deployment: %s", ext.State),
+			Module:   ext.Path,
+			Range:    edgeRange(byKey[deployment{path: ext.Path, workspace: ext.Workspace, overlay: ext.Overlay}], ext.State),
+		}
+		if ext.Workspace != "" || ext.Overlay != "" {
+			diag.Detail = fmt.Sprintf("workspace: %q, overlay: %q", ext.Workspace, ext.Overlay)
+		}
+		diags = append(diags, diag)
+	}
+
+	return diags
+}
+
+// edgeRange returns the first range referencing the state in the deployment, nil when it is not known
+func edgeRange(d Deployment, state State) *hcl.Range {
+	ranges := d.EdgeMetadata(state).Ranges
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	return rangePtr(ranges[0])
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"fmt"
+
+	"go.interactor.dev/terradep"
+)
+
+// checkExternal fails when the graph depends on states not produced by any scanned deployment. Every such dependency is printed as diagnostic
+func (c *rootCfg) checkExternal(graph *terradep.Graph) error {
+	external := graph.ExternalDependencies()
+	if len(external) == 0 {
+		return nil
+	}
+
+	diags := make(terradep.Diagnostics, 0, len(external))
+	for _, ext := range external {
+		diag := terradep.Diagnostic{
+			Severity: terradep.SeverityError,
+			Rule:     terradep.RuleExternalDependency,
+			Summary:  fmt.Sprintf("depends on state not produced by any scanned deployment: %s", ext.State),
+			Module:   ext.Path,
+		}
+		if ext.Workspace != "" || ext.Overlay != "" {
+			diag.Detail = fmt.Sprintf("workspace: %q, overlay: %q", ext.Workspace, ext.Overlay)
+		}
+		diags = append(diags, diag)
+	}
+
+	if err := c.printDiagnostics(diags); err != nil {
+		return err
+	}
+
+	return withExitCode(ExitMissingDependency, fmt.Errorf("graph depends on %d states outside of scanned directories", len(external)))
+}
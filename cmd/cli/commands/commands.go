@@ -2,15 +2,26 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/spf13/cobra"
 	"go.interactor.dev/terradep"
 	"go.interactor.dev/terradep/encoding"
+	"go.interactor.dev/terradep/internal/perm"
+	"go.interactor.dev/terradep/plugin"
 	"go.interactor.dev/terradep/state"
 	"golang.org/x/exp/slog"
 )
@@ -18,9 +29,36 @@ import (
 const (
 	// it is illegal name of the file, so if this value will not be handled properly, application should blow up
 	defaultLogFile = string(os.PathSeparator)
-	userRW         = 0o600
 	// CLIName is the name of CLI application (root command)
 	CLIName = "terradep"
+	// workspacesEnv is comma-separated list of workspaces used when flag --workspace is not set
+	workspacesEnv = "TERRADEP_WORKSPACES"
+	// tfWorkspaceEnv is used by Terraform to select the workspace, usually set by CI
+	tfWorkspaceEnv = "TF_WORKSPACE"
+	// stdoutFile passed to flag --out writes the output to standard output
+	stdoutFile = "-"
+	// stdinFile passed to flag --dir or --dirs-from reads the directories from standard input
+	stdinFile = "-"
+	// findCodeOwners is set when flag --codeowners is set without the path, it is illegal name of the file like defaultLogFile
+	findCodeOwners = string(os.PathSeparator)
+	// formats of the graph set with flag --format
+	graphDOT        = "DOT"
+	graphCypher     = "CYPHER"
+	graphJSON       = "JSON"
+	graphDrawIO     = "DRAWIO"
+	graphExcalidraw = "EXCALIDRAW"
+	graphNDJSON     = "NDJSON"
+	graphASCII      = "ASCII"
+	graphGantt      = "GANTT"
+	// properties of the deployments which group them into clusters, set with flag --cluster-by
+	clusterByOwner   = "OWNER"
+	clusterByLayer   = "LAYER"
+	clusterByRegion  = "REGION"
+	clusterByAccount = "ACCOUNT"
+	clusterByEnv     = "ENV"
+	// metrics scaling the nodes, set with flag --node-weight
+	nodeWeightResources  = "RESOURCES"
+	nodeWeightDependents = "DEPENDENTS"
 )
 
 // version is expected to be set with -ldflags="-X main.version=1.2.3"
@@ -32,13 +70,76 @@ type rootCfg struct {
 	logLevel string
 	logFmt   string
 	logFile  string
+	// configFile is the path of the configuration file, empty when it should be looked up
+	configFile string
+	diagFmt    string
+	diagFile   string
+	// diagOut is where diagnostics are written, opened before running the command
+	diagOut io.Writer
+	// otlpEndpoint is the base URL of OpenTelemetry collector, telemetry is not collected when it is empty
+	otlpEndpoint string
+	telemetry    *telemetry
+	// pluginPaths are executables started as plugins, see package plugin
+	pluginPaths []string
+	plugins     []*plugin.Client
 }
 
-type graphCfg struct {
+// scanCfg contains flags changing the behaviour of the scanner, shared by all commands scanning the directories
+type scanCfg struct {
 	*rootCfg
-	dirs    []string
-	outFile string
-	force   bool
+	dirs            []string
+	dirsFrom        string
+	workspaces      []string
+	moduleEdges     bool
+	followModules   bool
+	dataSourceRules string
+	layerRules      string
+	stateKeyRules   string
+	skipPaths       []string
+	includePaths    []string
+	maxDepth        int
+	symlinks        string
+	relativePaths   bool
+	maxFileSize     int64
+	preFilter       bool
+	concurrency     int
+	codeOwners      string
+	// envPatterns extract the environment from the states, see [terradep.StateEnvironment]
+	envPatterns []string
+	// layers are read from the file set with flag --layer-rules by scannerOpts
+	layers []terradep.LayerRule
+}
+
+type graphCfg struct {
+	*scanCfg
+	format    string
+	outFile   string
+	force     bool
+	cacheFile string
+	cacheDir  string
+	lenient   bool
+	// report prints what the scan covered to standard error
+	report    bool
+	metadata  bool
+	git       bool
+	clusterBy string
+	// nodeWeight is the metric scaling and coloring the nodes of DOT graph
+	nodeWeight string
+	legend     bool
+	// edgeOutputs labels the edges of DOT graph with consumed outputs
+	edgeOutputs bool
+	// reverse draws edges from dependencies to deployments depending on them
+	reverse bool
+	// failOnExternal fails the command when the graph depends on states outside of scanned directories
+	failOnExternal bool
+	githubSummary  bool
+	// changed are paths changed e.g. by the pull request, deployments affected by them are listed in the GitHub summary
+	changed []string
+	// publish are destinations the graph is uploaded to, see [publisher.publish]
+	publish        []string
+	publishVersion string
+	// durations of applying the deployments shown in the Gantt chart, in format glob=duration
+	durations []string
 }
 
 // NewCommand returns main CLI cobra.Command of terradep
@@ -47,10 +148,25 @@ func NewCommand() *cobra.Command {
 		Use:     CLIName + " [--dry run] [--log-format (TEXT|JSON)] [--log-level (DEBUG|INFO|WARN|ERROR)] [--log-file[=fileName.log]] <subCommand>",
 		Example: CLIName + " graph",
 		Short:   CLIName + " is cli tool which generates dependency graph of Terraform deployments",
+		Long:    CLIName + " is cli tool which generates dependency graph of Terraform deployments\n\n" + exitCodesHelp,
 		Version: version,
 	}
 
 	rc := &rootCfg{}
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		if err := applyConfig(cmd, rc.configFile); err != nil {
+			return err
+		}
+		if err := rc.openTelemetry(); err != nil {
+			return err
+		}
+		if err := rc.openDiagnostics(); err != nil {
+			return err
+		}
+		return rc.openPlugins()
+	}
+	cobra.OnFinalize(rc.exportTelemetry)
+	cobra.OnFinalize(rc.closePlugins)
 	rF := rootCmd.PersistentFlags()
 	rF.BoolVar(&rc.dryRun, "dry-run", false, "Does not produce the output when enabled. Can be used as a 'linter' for the input")
 	rF.BoolVarP(&rc.quiet, "quiet", "q", false, "Does not produce logs when enabled. Overrides log-level.")
@@ -58,8 +174,17 @@ func NewCommand() *cobra.Command {
 	rF.StringVar(&rc.logFile, "log-file", "", "Writes logs to specified file. If file does not exist - creates it, otherwise appends to existing one. When flag is set without parameter, name of the file is generated based on current time. If not set logs are written to standard error")
 	rF.Lookup("log-file").NoOptDefVal = defaultLogFile
 	rF.StringVar(&rc.logFmt, "log-format", "TEXT", "Sets log format. Allowed values: TEXT, JSON")
-
-	gc := &graphCfg{rootCfg: rc}
+	rF.StringVar(&rc.diagFmt, "diagnostics", diagnosticsText, fmt.Sprintf("Sets format of the diagnostics, i.e. warnings and skipped modules found by the scan. Allowed values: %s, %s, %s. %s writes one JSON object per line with severity, rule, summary, module, file and line. %s writes GitHub Actions workflow commands annotating the files", diagnosticsText, diagnosticsJSON, diagnosticsGitHub, diagnosticsJSON, diagnosticsGitHub))
+	rF.StringVar(&rc.diagFile, "diagnostics-file", "", "Writes diagnostics to specified file, which is overwritten. If not set diagnostics are written to standard error, set to '-' to write them to standard output")
+	rF.StringVar(&rc.otlpEndpoint, "otlp-endpoint", "", fmt.Sprintf("Exports OpenTelemetry spans of scanned directories, modules, parsed files and read states, and counters like %s, to the collector, "+
+		"e.g. http://localhost:4318, with OTLP over HTTP when the command finishes. If not set, environment variable %s is used. Headers, e.g. authorization, are read from %s", terradep.CounterFiles, otlpEndpointEnv, otlpHeadersEnv))
+	rF.StringSliceVar(&rc.pluginPaths, "plugin", nil, "Starts the executable as a plugin reading states of its backends and writing the graph in its formats, which can be set with graph --format. "+
+		"Plugin can also provide attributes of the nodes and the edges, e.g. labels, colors and links, added by graph to formats "+graphDOT+", "+graphCypher+", "+graphJSON+", "+graphDrawIO+" and "+graphExcalidraw+". "+
+		"Backends of the plugin override built-in ones. Can be set many times, backends and formats of later plugins override earlier ones")
+	markPathFlags(rF, "log-file", "diagnostics-file", "plugin")
+	rF.StringVar(&rc.configFile, "config", "", fmt.Sprintf("Reads default values of flags from YAML or HCL file, which keys are names of the flags, e.g. 'skip: [\"**/examples/**\"]'. Relative paths in the file are relative to its directory. Flags set in command line override the file. If not set, the first of %v found in current directory or its parents, up to the root of git repository, is read. Set to '%s' to not read any file", configFiles, noConfig))
+
+	gc := &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}
 	graphCmd := &cobra.Command{
 		Use:     `graph [--force] [--out fileName.dot] --dir analyzeMe`,
 		Example: `graph --log-file --dir analyzeMe > graph.dot`,
@@ -67,17 +192,214 @@ func NewCommand() *cobra.Command {
 		RunE:    generateGraph(gc),
 	}
 
+	addScanFlags(graphCmd, gc.scanCfg)
 	gF := graphCmd.Flags()
-	gF.StringSliceVarP(&gc.dirs, "dir", "d", nil, "Recursively analyzes specified directories.")
-	gF.StringVarP(&gc.outFile, "out", "o", "", "Writes output to specified file. Fails when file already exists unless you set flag --force")
+	gF.StringVar(&gc.format, "format", graphDOT, fmt.Sprintf("Sets format of the graph. Allowed values: %s, %s, %s, %s, %s, %s, %s, %s. %s writes statements loading the graph into Neo4j, e.g. with cypher-shell. "+
+		"%s writes versioned representation of the graph, which can be read back e.g. by diff --base, without scanning again. "+
+		"%s writes JSON Lines, one line per deployment, external state and local module with its edges, as soon as it is encoded, e.g. to filter large graphs with jq. "+
+		"%s writes XML file which can be imported into draw.io and enriched manually. "+
+		"%s writes scene which can be opened in Excalidraw as a starting point of the sketch. %s draws boxes and arrows with box-drawing characters, to view small and medium graphs in the terminal. "+
+		"%s writes Mermaid gantt chart of applying the deployments batch by batch, with the critical path marked, see --duration. "+
+		"Formats of the plugins set with --plugin are allowed too and override built-in ones",
+		graphDOT, graphCypher, graphJSON, graphNDJSON, graphDrawIO, graphExcalidraw, graphASCII, graphGantt, graphCypher, graphJSON, graphNDJSON, graphDrawIO, graphExcalidraw, graphASCII, graphGantt))
+	gF.StringVarP(&gc.outFile, "out", "o", "", "Writes output to specified file. Fails when file already exists unless you set flag --force. If not set or set to '-', output is written to standard output")
 	gF.BoolVarP(&gc.force, "force", "f", false, "Writes output to file specified with --out even if it already exists. Existing file content WILL BE LOST")
+	gF.BoolVar(&gc.edgeOutputs, "edge-outputs", false, "Labels the edges with the outputs of the states consumed by the deployments, e.g. vpc_id referenced as data.terraform_remote_state.net.outputs.vpc_id. "+
+		"* means that the outputs are referenced as a whole. Outputs are always written in format "+graphJSON)
+	gF.BoolVar(&gc.metadata, "node-metadata", false, "Adds tooltips to the nodes with metadata of the deployments, e.g. backend type, required providers or owner from the manifest")
+	gF.StringVar(&gc.clusterBy, "cluster-by", "", fmt.Sprintf("Groups deployments of the DOT graph into clusters. Allowed values: %s, %s, %s, %s, %s. "+
+		"%s groups by the owner from the manifest or, when it is not set, the first owner read from --codeowners. %s groups by the region of the state, e.g. of S3 bucket, "+
+		"%s by AWS account of the role assumed by S3 backend, %s by the environment extracted with --env-pattern",
+		clusterByOwner, clusterByLayer, clusterByRegion, clusterByAccount, clusterByEnv, clusterByOwner, clusterByRegion, clusterByAccount, clusterByEnv))
+	addEnvPatternFlag(graphCmd, gc.scanCfg)
+	gF.StringVar(&gc.nodeWeight, "node-weight", "", fmt.Sprintf("Scales and colors nodes of the DOT graph by the metric, so the heaviest deployments stand out. Allowed values: %s, %s. "+
+		"%s is the number of resources of the deployment, %s the number of deployments depending on it", nodeWeightResources, nodeWeightDependents, nodeWeightResources, nodeWeightDependents))
+	gF.BoolVar(&gc.legend, "legend", false, "Adds to the DOT graph the legend explaining the arrows, clusters set with --cluster-by and colors set with --node-weight")
+	gF.BoolVar(&gc.reverse, "reverse", false, "Draws edges from dependencies to deployments depending on them, i.e. in the direction outputs flow, instead of from deployments to their dependencies. "+
+		"Applies to formats "+graphDOT+", "+graphCypher+", "+graphDrawIO+", "+graphExcalidraw+" and "+graphASCII+" and the graph of --github-summary")
+	gF.StringArrayVar(&gc.durations, "duration", nil, fmt.Sprintf("Sets duration of applying the deployments which path relative to scanned directory matches the glob, in format glob=duration, e.g. 'live/eks/**=25m', "+
+		"shown in format %s. Can be set many times, the last matching value wins. Durations of other deployments are estimated: %s and %s for every resource",
+		graphGantt, encoding.DefaultApplyDuration, encoding.DefaultResourceDuration))
+	gF.BoolVar(&gc.git, "git-metadata", false, "Reads last commit and top contributors of every deployment from git history. Shown in tooltips enabled with --node-metadata")
+	gF.BoolVar(&gc.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+	gF.BoolVar(&gc.report, "report", false, "Writes to standard error the report of every scanned directory: visited directories with the reasons why they were skipped, number of modules, deployments and parsed files, and how long scanning of every module took")
+	gF.BoolVar(&gc.failOnExternal, "fail-on-external", false, fmt.Sprintf("Fails with exit code %d when deployments depend on states not produced by any scanned deployment, e.g. because of a typo in bucket or key of terraform_remote_state. The graph is not written then", ExitMissingDependency))
+	gF.BoolVar(&gc.githubSummary, "github-summary", false, fmt.Sprintf("Appends Mermaid graph and table of the deployments affected by paths set with --changed, or all the deployments, to the GitHub Actions job summary, i.e. file %s. "+
+		"Unless --diagnostics is set, diagnostics are written as %s workflow commands annotating the files", gitHubSummaryEnv, diagnosticsGitHub))
+	gF.StringSliceVar(&gc.changed, "changed", nil, "Sets paths changed e.g. by the pull request, deployments owning them and all the deployments depending on them are listed in the GitHub summary")
+	gF.StringSliceVar(&gc.publish, "publish", nil, "Uploads the graph to s3://bucket/prefix, using the default AWS credential chain like AWS CLI, or with PUT request to http(s)://host/prefix. "+
+		"The graph is uploaded twice: to prefix/<version>/<name> and prefix/"+publishLatest+"/<name>, where name is the name of the file set with --out or graph.<format>")
+	gF.StringVar(&gc.publishVersion, "publish-version", "", fmt.Sprintf("Sets version of the published graph. If not set, it is the commit read from environment variables %v set by CI or the current time", publishVersionEnvs))
+	gF.StringVar(&gc.cacheFile, "cache", "", "Reads and writes results of scanning to the file, so modules without changes in Terraform files are not parsed again")
+	gF.StringVar(&gc.cacheDir, "cache-dir", "", "Reads and writes results of scanning to the directory, one file per module, so modules without changes in Terraform files are not parsed again and only changed modules are written. Suitable for caching between CI runs")
+	graphCmd.MarkFlagsMutuallyExclusive("cache", "cache-dir")
+	markPathFlags(gF, "out", "cache")
+	markDirFlags(gF, "cache-dir")
+	rootCmd.AddCommand(graphCmd)
+
+	rootCmd.AddCommand(newDoctorCommand(rc))
+	rootCmd.AddCommand(newStreamCommand(rc))
+	rootCmd.AddCommand(newValidateCommand(rc))
+	rootCmd.AddCommand(newListCommand(rc))
+	rootCmd.AddCommand(newServeCommand(rc))
+	rootCmd.AddCommand(newRunCommand(rc))
+	rootCmd.AddCommand(newPlanAllCommand(rc))
+	rootCmd.AddCommand(newApplyAllCommand(rc))
+	rootCmd.AddCommand(newGenerateCommand(rc))
+	rootCmd.AddCommand(newDiffCommand(rc))
+	rootCmd.AddCommand(newCheckCommand(rc))
+	rootCmd.AddCommand(newDocsCommand(rc))
+	rootCmd.AddCommand(newVersionsCommand(rc))
+	rootCmd.AddCommand(newWhyCommand(rc))
+	rootCmd.AddCommand(newExplainCommand(rc))
+	rootCmd.AddCommand(newPolicyCommand(rc))
+	rootCmd.AddCommand(newTriggersCommand(rc))
+	return rootCmd
+}
+
+// addScanFlags registers flags of the scanner on the command, flag --dir or --dirs-from is required
+func addScanFlags(cmd *cobra.Command, c *scanCfg) {
+	f := cmd.Flags()
+	f.StringSliceVarP(&c.dirs, "dir", "d", nil, "Recursively analyzes specified directories. Set to '-' to read newline-separated directories from standard input")
+	f.StringVar(&c.dirsFrom, "dirs-from", "", "Recursively analyzes also directories read from the file, one per line, e.g. written by find or git diff. Empty lines and lines starting with '#' are ignored. Set to '-' to read standard input")
+	f.StringSliceVar(&c.skipPaths, "skip", nil, "Skips directories which path relative to scanned directory matches the glob, e.g. '**/examples/**'")
+	f.StringSliceVar(&c.includePaths, "include", nil, "Analyzes only deployments which path relative to scanned directory matches the glob, e.g. 'live/prod/**'")
+	f.IntVar(&c.maxDepth, "max-depth", -1, "Limits how deep directories are analyzed, scanned directory has depth 0. Negative value means no limit")
+	f.StringVar(&c.symlinks, "symlinks", string(terradep.SymlinkSkip), fmt.Sprintf("Sets how symbolic links to directories are handled. Allowed values: %v", terradep.SymlinkPolicies))
+	f.Int64Var(&c.maxFileSize, "max-file-size", terradep.DefaultMaxFileSize, "Skips Terraform files larger than the limit in bytes instead of parsing them. Files with binary content are always skipped. Non-positive value means no limit")
+	f.IntVar(&c.concurrency, "concurrency", runtime.NumCPU(), "Sets how many modules are read and parsed at the same time. Lower it on slow network filesystems, e.g. EFS or NFS checkouts in CI")
+	f.BoolVar(&c.preFilter, "pre-filter", false, "Parses only Terraform files containing tokens like terraform_remote_state or backend, which speeds up scanning of modules with many resources. Metadata of the deployments then describes only the parsed files")
+	f.BoolVar(&c.relativePaths, "relative-paths", false, "Makes paths of deployments and local modules relative to the scanned directory and uses forward slashes, so the output does not depend on the location of the repository nor operating system")
+	f.BoolVar(&c.moduleEdges, "module-edges", false, "Adds dashed edges from deployments to local modules they call, including modules inside other deployments")
+	f.BoolVar(&c.followModules, "follow-modules", false, "Finds terraform_remote_state also in local modules called by the deployments")
+	f.StringVar(&c.dataSourceRules, "data-source-rules", "", "Reads JSON file with rules describing data sources other than terraform_remote_state which imply dependency, e.g. aws_ssm_parameter")
+	f.StringVar(&c.layerRules, "layer-rules", "", "Reads JSON file with layers of the deployments matched by path or state and layers they may depend on, e.g. network, platform and apps. "+
+		"Deployments get the layer unless declared in the manifest, command validate checks the dependencies between layers")
+	f.StringVar(&c.stateKeyRules, "state-key-rules", "", "Reads JSON file with rules matching paths of the deployments with regular expressions and expected fields of their states, e.g. key ${env}/${deployment}/terraform.tfstate. "+
+		"Mismatches are reported as diagnostics, command validate fails on them")
+	f.StringVar(&c.codeOwners, "codeowners", "", fmt.Sprintf("Reads owners of the deployments from CODEOWNERS file, the owner of main.tf owns the deployment. "+
+		"Set without value to read the first of %v in the git repository containing the first scanned directory", terradep.CodeOwnersFiles))
+	f.Lookup("codeowners").NoOptDefVal = findCodeOwners
+	f.StringSliceVarP(&c.workspaces, "workspace", "w", nil, "Expands every deployment into one node per workspace. If not set, workspaces are read from comma-separated environment variable "+workspacesEnv+" or "+tfWorkspaceEnv)
+	markDirFlags(f, "dir")
+	markPathFlags(f, "dirs-from", "data-source-rules", "layer-rules", "state-key-rules", "codeowners")
+
+	cmd.PreRunE = func(cmd *cobra.Command, _ []string) error {
+		return c.readDirs(cmd.InOrStdin())
+	}
+}
+
+// addEnvPatternFlag registers flag --env-pattern on the commands reading the environments of the deployments
+func addEnvPatternFlag(cmd *cobra.Command, c *scanCfg) {
+	cmd.Flags().StringSliceVar(&c.envPatterns, "env-pattern", nil, "Extracts the environment of the deployments from their states with the regular expression, e.g. 'env:/(\\w+)/' or '^s3://[^/]+/(?P<env>[^/]+)/' "+
+		"matching states like s3://bucket/key. The environment is the submatch env or the first submatch of the first matching pattern, or the workspace when no pattern matches. Can be set many times")
+}
+
+// environmentPatterns compiles patterns set with flag --env-pattern
+func (c *scanCfg) environmentPatterns() ([]*regexp.Regexp, error) {
+	return terradep.CompileEnvironmentPatterns(c.envPatterns...)
+}
+
+// matchesDir checks whether the path relative to any of the scanned directories matches the glob.
+// Paths made relative with --relative-paths are matched as they are
+func (c *scanCfg) matchesDir(glob, path string) bool {
+	if c.relativePaths && !filepath.IsAbs(path) {
+		ok, _ := doublestar.Match(glob, filepath.ToSlash(path))
+		return ok
+	}
 
-	err := graphCmd.MarkFlagRequired("dir")
+	abs, err := filepath.Abs(path)
 	if err != nil {
-		panic(fmt.Errorf("marking flag dir as required, %w", err))
+		return false
 	}
-	rootCmd.AddCommand(graphCmd)
-	return rootCmd
+
+	for _, dir := range c.dirs {
+		root, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if ok, _ := doublestar.Match(glob, filepath.ToSlash(rel)); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readDirs replaces '-' set with flag --dir with directories read from standard input and adds directories read from the file set with --dirs-from.
+// Every directory is scanned once, even if it was set many times
+func (c *scanCfg) readDirs(stdin io.Reader) error {
+	var dirs []string
+	for _, dir := range c.dirs {
+		if dir != stdinFile {
+			dirs = append(dirs, dir)
+			continue
+		}
+		read, err := readDirList(stdin)
+		if err != nil {
+			return fmt.Errorf("reading directories from standard input: %w", err)
+		}
+		dirs = append(dirs, read...)
+	}
+
+	if c.dirsFrom != "" {
+		in := stdin
+		if c.dirsFrom != stdinFile {
+			file, err := os.Open(c.dirsFrom)
+			if err != nil {
+				return fmt.Errorf("opening file with directories: %s, %w", c.dirsFrom, err)
+			}
+			defer file.Close()
+			in = file
+		}
+		read, err := readDirList(in)
+		if err != nil {
+			return fmt.Errorf("reading directories from: %s, %w", c.dirsFrom, err)
+		}
+		dirs = append(dirs, read...)
+	}
+
+	c.dirs = uniqueDirs(dirs)
+	if len(c.dirs) == 0 {
+		return errors.New(`required flag(s) "dir" or "dirs-from" not set or no directories were read`)
+	}
+
+	return nil
+}
+
+// readDirList reads directories, one per line, skipping empty lines and comments
+func readDirList(r io.Reader) ([]string, error) {
+	var dirs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dirs = append(dirs, line)
+	}
+
+	return dirs, scanner.Err()
+}
+
+// uniqueDirs removes repeated directories, keeping the first occurrence
+func uniqueDirs(dirs []string) []string {
+	seen := make(map[string]struct{}, len(dirs))
+	out := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		key := filepath.Clean(dir)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, dir)
+	}
+
+	return out
 }
 
 func generateGraph(c *graphCfg) func(*cobra.Command, []string) error {
@@ -91,54 +413,459 @@ func generateGraph(c *graphCfg) func(*cobra.Command, []string) error {
 			return fmt.Errorf("no directories to scan")
 		}
 
+		format := strings.ToUpper(c.format)
+		encoder := c.encoderPlugin(format)
+		if encoder == nil && format != graphDOT && format != graphCypher && format != graphJSON && format != graphNDJSON && format != graphDrawIO &&
+			format != graphExcalidraw && format != graphASCII && format != graphGantt {
+			return fmt.Errorf("unsupported graph format: %s, allowed values: %s, %s, %s, %s, %s, %s, %s, %s or formats of the plugins",
+				c.format, graphDOT, graphCypher, graphJSON, graphNDJSON, graphDrawIO, graphExcalidraw, graphASCII, graphGantt)
+		}
+
+		envs, err := c.environmentPatterns()
+		if err != nil {
+			return err
+		}
+		cluster, err := clusterKey(c.clusterBy, envs)
+		if err != nil {
+			return err
+		}
+
+		weight, err := nodeWeight(c.nodeWeight)
+		if err != nil {
+			return err
+		}
+
+		durations, err := c.ganttDurations()
+		if err != nil {
+			return err
+		}
+
 		out, err := buildOutput(log, c)
 		if err != nil {
 			return fmt.Errorf("building output: %w", err)
 		}
 
-		stater := state.NewByTypeStater(map[string]terradep.Stater{
-			state.S3Backend: state.NewS3Stater(state.WithS3Region(), state.WithS3Encryption()),
-		})
+		if c.githubSummary && !cmd.Flags().Changed("diagnostics") {
+			c.diagFmt = diagnosticsGitHub
+		}
+
+		graph, err := scanGraph(log, c)
+		if err != nil {
+			return err
+		}
+
+		direction := encoding.DependencyDirection
+		if c.reverse {
+			direction = encoding.DataFlowDirection
+		}
+
+		if c.githubSummary {
+			if err := writeGitHubSummary(graph, c.changed, direction); err != nil {
+				return err
+			}
+		}
+
+		log.Info("scan successful", slog.Int("nodes", len(graph.Nodes())))
+
+		if c.failOnExternal {
+			if err := c.checkExternal(graph); err != nil {
+				return err
+			}
+		}
 
-		s := terradep.NewScanner(log, stater)
-		graphs := make([]*terradep.Graph, len(c.dirs))
-		for i, dir := range c.dirs {
-			log.Info("scanning directory", slog.String("dir", dir))
-			graph, err := s.Scan(dir)
+		decorations, err := c.pluginDecorations(graph)
+		if err != nil {
+			return err
+		}
+
+		var encoded []byte
+		// streamed is true when the output was already written by the encoder
+		streamed := false
+		switch {
+		case encoder != nil:
+			encoded, err = encoder.Encode(c.format, graph)
+			if err != nil {
+				return fmt.Errorf("failed to encode the graph: %w", err)
+			}
+		case format == graphCypher:
+			cypherOpts := []encoding.CypherOpt{encoding.WithCypherDirection(direction)}
+			for _, d := range decorations {
+				cypherOpts = append(cypherOpts, encoding.WithCypherNodeDecorator(d), encoding.WithCypherEdgeDecorator(d))
+			}
+			encoded = encoding.BuildCypher(graph, cypherOpts...)
+		case format == graphJSON:
+			var jsonOpts []encoding.JSONOpt
+			for _, d := range decorations {
+				jsonOpts = append(jsonOpts, encoding.WithJSONNodeDecorator(d), encoding.WithJSONEdgeDecorator(d))
+			}
+			encoded, err = encoding.BuildGraphJSON(graph, jsonOpts...)
 			if err != nil {
-				return fmt.Errorf("failed to scan path: %s, error was: %w", dir, err)
+				return fmt.Errorf("failed to encode the graph: %w", err)
+			}
+		case format == graphNDJSON:
+			// lines are written as soon as they are encoded, they are kept only to be published
+			w, published := out, &bytes.Buffer{}
+			if len(c.publish) != 0 && !c.dryRun {
+				w = io.MultiWriter(out, published)
+			}
+			if err := encoding.NewNodeEncoder(w).EncodeGraph(graph); err != nil {
+				return fmt.Errorf("failed to encode the graph: %w", err)
+			}
+			encoded, streamed = published.Bytes(), true
+		case format == graphDrawIO:
+			drawIOOpts := []encoding.DrawIOOpt{encoding.WithDrawIODirection(direction)}
+			for _, d := range decorations {
+				drawIOOpts = append(drawIOOpts, encoding.WithDrawIONodeDecorator(d), encoding.WithDrawIOEdgeDecorator(d))
+			}
+			encoded, err = encoding.BuildDrawIO(graph, drawIOOpts...)
+			if err != nil {
+				return fmt.Errorf("failed to encode the graph: %w", err)
+			}
+		case format == graphGantt:
+			encoded = encoding.BuildMermaidGantt(graph, encoding.WithGanttDurations(durations))
+		case format == graphASCII:
+			encoded = encoding.BuildASCII(graph, encoding.WithASCIIDirection(direction))
+		case format == graphExcalidraw:
+			excalidrawOpts := []encoding.ExcalidrawOpt{encoding.WithExcalidrawDirection(direction)}
+			for _, d := range decorations {
+				excalidrawOpts = append(excalidrawOpts, encoding.WithExcalidrawNodeDecorator(d), encoding.WithExcalidrawEdgeDecorator(d))
+			}
+			encoded, err = encoding.BuildExcalidraw(graph, excalidrawOpts...)
+			if err != nil {
+				return fmt.Errorf("failed to encode the graph: %w", err)
+			}
+		default:
+			dotOpts := []encoding.DOTOpt{encoding.WithDOTDirection(direction)}
+			if c.metadata {
+				dotOpts = append(dotOpts, encoding.WithNodeMetadata())
+			}
+			if cluster != nil {
+				dotOpts = append(dotOpts, encoding.WithClusters(cluster))
+			}
+			if c.edgeOutputs {
+				dotOpts = append(dotOpts, encoding.WithEdgeOutputs())
+			}
+			if weight != nil {
+				dotOpts = append(dotOpts, encoding.WithNodeWeight(strings.ToLower(c.nodeWeight), weight(graph)))
+			}
+			if c.legend {
+				dotOpts = append(dotOpts, encoding.WithLegend())
+			}
+			for _, d := range decorations {
+				dotOpts = append(dotOpts, encoding.WithDOTNodeDecorator(d), encoding.WithDOTEdgeDecorator(d))
+			}
+
+			encoded, err = encoding.BuildDOTGraph(graph, dotOpts...)
+			if err != nil {
+				return fmt.Errorf("failed to encode the graph: %w", err)
 			}
-			graphs[i] = graph
 		}
 
-		graph, err := terradep.MergeGraphs(log, graphs...)
+		if !streamed {
+			n, err := out.Write(encoded)
+			if err != nil {
+				return fmt.Errorf("failed to write dot graph to output: %s, written: %d bytes, %w", out, n, err)
+			}
+		}
+
+		if len(c.publish) != 0 && !c.dryRun {
+			name := "graph." + strings.ToLower(format)
+			if c.outFile != "" && c.outFile != stdoutFile {
+				name = filepath.Base(c.outFile)
+			}
+			p := newPublisher(log, c.publishVersion)
+			for _, destination := range c.publish {
+				if err := p.publish(destination, name, encoded); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+// clusterKey returns the property of the node set with flag --cluster-by, nil when the flag is not set
+func clusterKey(clusterBy string, envs []*regexp.Regexp) (func(*terradep.Node) string, error) {
+	switch strings.ToUpper(clusterBy) {
+	case "":
+		return nil, nil
+	case clusterByOwner:
+		return (*terradep.Node).Team, nil
+	case clusterByLayer:
+		return func(n *terradep.Node) string { return n.Layer }, nil
+	case clusterByRegion:
+		return func(n *terradep.Node) string { return terradep.StateRegion(n.State) }, nil
+	case clusterByAccount:
+		return func(n *terradep.Node) string { return terradep.StateAccount(n.State) }, nil
+	case clusterByEnv:
+		return func(n *terradep.Node) string { return terradep.StateEnvironment(n.State, envs...) }, nil
+	default:
+		return nil, fmt.Errorf("unsupported cluster property: %s, allowed values: %s, %s, %s, %s, %s", clusterBy, clusterByOwner, clusterByLayer, clusterByRegion, clusterByAccount, clusterByEnv)
+	}
+}
+
+// nodeWeight returns the metric of the node set with flag --node-weight, nil when the flag is not set
+func nodeWeight(metric string) (func(*terradep.Graph) func(*terradep.Node) int, error) {
+	switch strings.ToUpper(metric) {
+	case "":
+		return nil, nil
+	case nodeWeightResources:
+		return func(*terradep.Graph) func(*terradep.Node) int {
+			return func(n *terradep.Node) int {
+				if n.Metadata == nil {
+					return 0
+				}
+				return n.Metadata.Resources
+			}
+		}, nil
+	case nodeWeightDependents:
+		return func(g *terradep.Graph) func(*terradep.Node) int {
+			return func(n *terradep.Node) int { return len(g.Dependents(n)) }
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported node weight: %s, allowed values: %s, %s", metric, nodeWeightResources, nodeWeightDependents)
+	}
+}
+
+// scanGraph scans all the directories and merges results into single graph
+func scanGraph(log *slog.Logger, c *graphCfg) (*terradep.Graph, error) {
+	opts, err := c.scannerOpts(log)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.lenient {
+		opts = append(opts, terradep.WithContinueOnError())
+	}
+
+	if c.git {
+		opts = append(opts, terradep.WithGitMetadata())
+	}
+
+	cache, err := c.openCache()
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		opts = append(opts, terradep.WithCache(cache))
+	}
+
+	s := terradep.NewScanner(log, c.newStater(), opts...)
+	graphs := make([]*terradep.Graph, len(c.dirs))
+	for i, dir := range c.dirs {
+		log.Info("scanning directory", slog.String("dir", dir))
+		graph, report, diags, err := s.ScanWithReport(dir)
+		if c.report && report != nil {
+			if err := writeScanReport(os.Stderr, report); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.printDiagnostics(diags); err != nil {
+			return nil, err
+		}
 		if err != nil {
-			return fmt.Errorf("failed to merge graphs, error was: %w", err)
+			return nil, scanError(fmt.Errorf("failed to scan path: %s, error was: %w", dir, err))
+		}
+		graphs[i] = graph
+	}
+
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			return nil, err
 		}
+	}
 
-		log.Info("scan successful", slog.Any("graph", graph))
+	graph, err := terradep.MergeGraphs(log, graphs...)
+	if err != nil {
+		return nil, scanError(fmt.Errorf("failed to merge graphs, error was: %w", err))
+	}
 
-		encoded, err := encoding.BuildDOTGraph(graph)
+	return graph, nil
+}
+
+// newStater returns stater supporting all the backends known to the cli and read by the plugins, which reads the same terraform_remote_state only once
+func (c *rootCfg) newStater() terradep.Stater {
+	tfcStater := state.NewTFCStater()
+	staters := map[string]terradep.Stater{
+		state.S3Backend:       state.NewS3Stater(state.WithS3Region(), state.WithS3Encryption()),
+		terradep.CloudBackend: tfcStater,
+		state.RemoteBackend:   tfcStater,
+		state.LocalBackend:    state.NewLocalStater(),
+	}
+	for backend, stater := range c.pluginStaters() {
+		staters[backend] = stater
+	}
+
+	return state.Cached(state.NewByTypeStater(staters))
+}
+
+func (c *scanCfg) scannerOpts(log *slog.Logger) ([]terradep.ScannerOpt, error) {
+	var opts []terradep.ScannerOpt
+	if workspaces := c.scanWorkspaces(); len(workspaces) != 0 {
+		log.Info("expanding deployments into workspaces", slog.Any("workspaces", workspaces))
+		opts = append(opts, terradep.WithWorkspaces(workspaces...))
+	}
+
+	if len(c.skipPaths) != 0 {
+		opts = append(opts, terradep.WithSkipPaths(c.skipPaths...))
+	}
+
+	if len(c.includePaths) != 0 {
+		opts = append(opts, terradep.WithIncludePaths(c.includePaths...))
+	}
+
+	if c.maxDepth >= 0 {
+		opts = append(opts, terradep.WithMaxDepth(c.maxDepth))
+	}
+
+	symlinks, err := terradep.ParseSymlinkPolicy(c.symlinks)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, terradep.WithSymlinkPolicy(symlinks))
+	opts = append(opts, terradep.WithMaxFileSize(c.maxFileSize))
+	opts = append(opts, terradep.WithIOConcurrency(c.concurrency))
+	if c.preFilter {
+		opts = append(opts, terradep.WithPreFilter())
+	}
+
+	if c.relativePaths {
+		opts = append(opts, terradep.WithRelativePaths())
+	}
+
+	if c.telemetry != nil {
+		opts = append(opts, terradep.WithTracer(c.telemetry))
+	}
+
+	if c.moduleEdges {
+		opts = append(opts, terradep.WithModuleEdges())
+	}
+
+	if c.codeOwners != "" {
+		owners, err := c.readCodeOwners(log)
 		if err != nil {
-			log.Error("failed to encode the graph", err)
+			return nil, err
 		}
+		if owners != nil {
+			opts = append(opts, terradep.WithCodeOwners(owners))
+		}
+	}
 
-		n, err := out.Write(encoded)
+	if c.followModules {
+		opts = append(opts, terradep.WithFollowModules())
+	}
+
+	if len(c.dataSourceRules) != 0 {
+		rules, err := readDataSourceRules(c.dataSourceRules)
 		if err != nil {
-			return fmt.Errorf("failed to write dot graph to output: %s, written: %d bytes, %w", out, n, err)
+			return nil, err
 		}
+		opts = append(opts, terradep.WithDataSourceRules(rules...))
+	}
 
-		return nil
+	if len(c.layerRules) != 0 {
+		rules, err := readLayerRules(c.layerRules)
+		if err != nil {
+			return nil, err
+		}
+		c.layers = rules
+		opts = append(opts, terradep.WithLayerRules(rules...))
+	}
+
+	if len(c.stateKeyRules) != 0 {
+		rules, err := readStateKeyRules(c.stateKeyRules)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, terradep.WithStateKeyRules(rules...))
+	}
+
+	return opts, nil
+}
+
+// readCodeOwners reads CODEOWNERS set with flag --codeowners or, when it was set without value, finds it in the repository
+func (c *scanCfg) readCodeOwners(log *slog.Logger) (*terradep.CodeOwners, error) {
+	if c.codeOwners != findCodeOwners {
+		return terradep.ReadCodeOwners(c.codeOwners)
+	}
+
+	owners, err := terradep.FindCodeOwners(c.dirs[0])
+	if err != nil {
+		return nil, err
+	}
+	if owners == nil {
+		log.Warn("CODEOWNERS not found, deployments do not have code owners", slog.String("dir", c.dirs[0]))
+	}
+
+	return owners, nil
+}
+
+// savedCache is a [terradep.ScanCache] which must be saved after scanning
+type savedCache interface {
+	terradep.ScanCache
+	Save() error
+}
+
+// openCache returns cache set with flag --cache or --cache-dir, nil if none of them was set
+func (c *graphCfg) openCache() (savedCache, error) {
+	switch {
+	case len(c.cacheFile) != 0:
+		return terradep.OpenFileCache(c.cacheFile, c.cacheKey())
+	case len(c.cacheDir) != 0:
+		return terradep.OpenDirCache(c.cacheDir, c.cacheKey())
+	default:
+		return nil, nil
 	}
 }
 
+// cacheKey describes flags changing results of scanning single module, cache created with different flags cannot be used
+func (c *graphCfg) cacheKey() string {
+	return fmt.Sprintf("version=%s;workspaces=%v;moduleEdges=%t;followModules=%t;preFilter=%t;maxFileSize=%d;plugins=%v;rules=%s;layerRules=%s;stateKeyRules=%s",
+		version, c.scanWorkspaces(), c.moduleEdges, c.followModules, c.preFilter, c.maxFileSize, c.pluginPaths,
+		fileHash(c.dataSourceRules), fileHash(c.layerRules), fileHash(c.stateKeyRules))
+}
+
+// fileHash returns hash of content of the file, e.g. with rules, which could change without changing the flags. Empty when path is empty or the file cannot be read
+func fileHash(path string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return sha256Hex(b)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// scanWorkspaces returns workspaces set with flag or discovered from environment variables set e.g. by CI
+func (c *scanCfg) scanWorkspaces() []string {
+	if len(c.workspaces) != 0 {
+		return c.workspaces
+	}
+
+	for _, env := range []string{workspacesEnv, tfWorkspaceEnv} {
+		if value := os.Getenv(env); value != "" {
+			return strings.Split(value, ",")
+		}
+	}
+
+	return nil
+}
+
 func buildOutput(log *slog.Logger, c *graphCfg) (io.Writer, error) {
 	if c.dryRun {
 		return io.Discard, nil
 	}
 
-	if len(c.outFile) == 0 {
-		return os.Stderr, nil
+	if len(c.outFile) == 0 || c.outFile == stdoutFile {
+		return os.Stdout, nil
 	}
 
 	_, err := os.Stat(c.outFile)
@@ -159,7 +886,7 @@ func buildOutput(log *slog.Logger, c *graphCfg) (io.Writer, error) {
 	}
 
 	log.Debug("force enabled, writing output to existing file", slog.String("path", c.outFile))
-	file, err := os.OpenFile(c.outFile, os.O_RDWR|os.O_TRUNC, userRW)
+	file, err := os.OpenFile(c.outFile, os.O_RDWR|os.O_TRUNC, perm.UserRW)
 	if err != nil {
 		return nil, fmt.Errorf("overwriting output file: %s, %w", c.outFile, err)
 	}
@@ -214,5 +941,5 @@ func buildLogDst(c rootCfg) (io.Writer, error) {
 		return os.Create(now.Format(CLIName + "_grap_" + time.RFC3339Nano + ".log"))
 	}
 
-	return os.OpenFile(c.logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, userRW)
+	return os.OpenFile(c.logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm.UserRW)
 }
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+func TestCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	rules := filepath.Join(dir, "rules.json")
+	writeFile(t, rules, `[]`)
+
+	newCfg := func() *graphCfg {
+		return &graphCfg{scanCfg: &scanCfg{rootCfg: &rootCfg{}}}
+	}
+	base := newCfg().cacheKey()
+
+	tests := []struct {
+		name   string
+		change func(c *graphCfg)
+	}{
+		{name: "workspaces", change: func(c *graphCfg) { c.workspaces = []string{"prod"} }},
+		{name: "module edges", change: func(c *graphCfg) { c.moduleEdges = true }},
+		{name: "follow modules", change: func(c *graphCfg) { c.followModules = true }},
+		{name: "pre-filter", change: func(c *graphCfg) { c.preFilter = true }},
+		{name: "max file size", change: func(c *graphCfg) { c.maxFileSize = 1024 }},
+		{name: "plugin", change: func(c *graphCfg) { c.pluginPaths = []string{"/usr/local/bin/terradep-gcs"} }},
+		{name: "data source rules", change: func(c *graphCfg) { c.dataSourceRules = rules }},
+		{name: "layer rules", change: func(c *graphCfg) { c.layerRules = rules }},
+		{name: "state key rules", change: func(c *graphCfg) { c.stateKeyRules = rules }},
+	}
+
+	keys := map[string]string{base: "defaults"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCfg()
+			tt.change(c)
+			key := c.cacheKey()
+			if other, ok := keys[key]; ok {
+				t.Fatalf("key is the same as key of: %s, %s", other, key)
+			}
+			keys[key] = tt.name
+		})
+	}
+
+	t.Run("content of rules", func(t *testing.T) {
+		for _, set := range []func(c *graphCfg){
+			func(c *graphCfg) { c.dataSourceRules = rules },
+			func(c *graphCfg) { c.layerRules = rules },
+			func(c *graphCfg) { c.stateKeyRules = rules },
+		} {
+			c := newCfg()
+			set(c)
+			writeFile(t, rules, `[]`)
+			before := c.cacheKey()
+			writeFile(t, rules, `[{"path": "live/**"}]`)
+			if c.cacheKey() == before {
+				t.Errorf("key did not change with content of rules: %s", before)
+			}
+		}
+	})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing file: %s, %v", path, err)
+	}
+}
+
+func TestGraphDoesNotDependOnConcurrency(t *testing.T) {
+	files := terradeptest.Files{}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("live/app%02d/main.tf", i)] = fmt.Sprintf(`
+terraform {
+  backend "s3" {
+    bucket  = "b"
+    key     = "app%02d"
+    region  = "eu-west-1"
+    encrypt = true
+  }
+}
+`, i)
+		if i == 0 {
+			continue
+		}
+		files[fmt.Sprintf("live/app%02d/remote.tf", i)] = fmt.Sprintf(`
+data "terraform_remote_state" "parent" {
+  backend = "s3"
+  config = {
+    bucket = "b"
+    key    = "app%02d"
+    region = "eu-west-1"
+  }
+}
+
+output "parent" {
+  value = data.terraform_remote_state.parent.outputs
+}
+`, (i-1)/2)
+	}
+	dir := terradeptest.Dir(t, files)
+
+	graph := func(concurrency int) string {
+		out := filepath.Join(t.TempDir(), "graph.json")
+		cmd := NewCommand()
+		cmd.SetArgs([]string{
+			"graph", "--config", noConfig, "--quiet", "--dir", dir, "--relative-paths", "--format", graphJSON,
+			"--concurrency", fmt.Sprint(concurrency), "--out", out,
+		})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("generating graph with concurrency: %d, %v", concurrency, err)
+		}
+
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("reading graph: %v", err)
+		}
+		return string(b)
+	}
+
+	if one, eight := graph(1), graph(8); one != eight {
+		t.Errorf("graph with --concurrency 8 differs from graph with --concurrency 1\n8:\n%s\n1:\n%s", eight, one)
+	}
+}
@@ -0,0 +1,278 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"gopkg.in/yaml.v3"
+)
+
+// configFiles are names of the configuration file looked up in the current directory and its parents, up to the root of git repository.
+// Name terradep.yaml is not used, because it is the name of the manifest of the deployment
+var configFiles = []string{".terradep.yaml", ".terradep.yml", ".terradep.hcl"}
+
+// noConfig passed to flag --config disables reading of the configuration file
+const noConfig = "none"
+
+// applyConfig sets flags of the cmd, which were not set in command line, to the values from the configuration file.
+// Keys of the file are names of the flags, e.g.
+//
+//	dir: [live]
+//	skip: ['**/examples/**']
+//	max-depth: 3
+//	relative-paths: true
+//
+// Keys which are flags of other commands are ignored, so the same file can be used by all of them.
+// Relative paths, e.g. of flag dir, are relative to the directory of the file
+func applyConfig(cmd *cobra.Command, path string) error {
+	if path == noConfig {
+		return nil
+	}
+
+	if path == "" {
+		found, err := findConfig()
+		if err != nil || found == "" {
+			return err
+		}
+		path = found
+	}
+
+	values, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+
+	known := allFlags(cmd.Root())
+	for _, name := range sortedNames(values) {
+		if _, ok := known[name]; !ok {
+			return fmt.Errorf("unknown key in config file: %s, key: %q, expected one of the flags: %v", path, name, sortedNames(known))
+		}
+
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed {
+			// flag of another command or overridden in command line
+			continue
+		}
+
+		value := values[name]
+		if isPathFlag(flag) {
+			value = resolvePaths(filepath.Dir(path), value)
+		}
+
+		if err := setFlag(cmd.Flags(), flag, value); err != nil {
+			return fmt.Errorf("setting flag from config file: %s, flag: %s, %w", path, name, err)
+		}
+	}
+
+	return nil
+}
+
+// findConfig looks for the configuration file in the current directory and its parents. Returns empty path when there is none
+func findConfig() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("getting current directory: %w", err)
+	}
+
+	for {
+		for _, name := range configFiles {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			// root of the repository
+			return "", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// readConfig returns values of the flags from the file in YAML or HCL format. Lists are returned as slices, other values as strings
+func readConfig(path string) (map[string][]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %s, %w", path, err)
+	}
+
+	if filepath.Ext(path) == ".hcl" {
+		return decodeHCLConfig(path, b)
+	}
+
+	return decodeYAMLConfig(path, b)
+}
+
+func decodeYAMLConfig(path string, b []byte) (map[string][]string, error) {
+	raw := map[string]any{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("decoding config file: %s, %w", path, err)
+	}
+
+	out := make(map[string][]string, len(raw))
+	for name, value := range raw {
+		switch v := value.(type) {
+		case []any:
+			values := make([]string, 0, len(v))
+			for _, item := range v {
+				values = append(values, fmt.Sprint(item))
+			}
+			out[name] = values
+		case map[string]any:
+			return nil, fmt.Errorf("value of key: %s in config file: %s must be a scalar or a list", name, path)
+		default:
+			out[name] = []string{fmt.Sprint(v)}
+		}
+	}
+
+	return out, nil
+}
+
+func decodeHCLConfig(path string, b []byte) (map[string][]string, error) {
+	file, diags := hclparse.NewParser().ParseHCL(b, path)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing config file: %s, %w", path, diags)
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("decoding config file: %s, %w", path, diags)
+	}
+
+	out := make(map[string][]string, len(attrs))
+	for name, attr := range attrs {
+		values, err := hclStrings(attr)
+		if err != nil {
+			return nil, fmt.Errorf("decoding config file: %s, %w", path, err)
+		}
+		out[name] = values
+	}
+
+	return out, nil
+}
+
+// hclStrings converts value of the attribute to strings, scalar value is converted to single string
+func hclStrings(attr *hcl.Attribute) ([]string, error) {
+	value, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	items := []cty.Value{value}
+	if value.Type().IsTupleType() || value.Type().IsListType() || value.Type().IsSetType() {
+		items = value.AsValueSlice()
+	}
+
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		str, err := convert.Convert(item, cty.String)
+		if err != nil || str.IsNull() {
+			return nil, fmt.Errorf("value of attribute: %s must be a scalar or a list of scalars, at: %s", attr.Name, attr.Range)
+		}
+		out = append(out, str.AsString())
+	}
+
+	return out, nil
+}
+
+// resolvePaths makes relative paths relative to the dir. Value '-' is kept, because it means standard output or input
+func resolvePaths(dir string, paths []string) []string {
+	out := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path != stdoutFile && !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		out = append(out, path)
+	}
+
+	return out
+}
+
+// markPathFlags marks flags which values are paths. Relative paths in the configuration file are relative to its directory,
+// so the file works the same in every directory of the repository. The mark is the annotation of cobra, so the shell also completes the flags with file names
+func markPathFlags(flags *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		if err := cobra.MarkFlagFilename(flags, name); err != nil {
+			panic(fmt.Errorf("marking flag %s as path, %w", name, err))
+		}
+	}
+}
+
+// markDirFlags is [markPathFlags] for flags which values are directories, the shell completes them with directory names
+func markDirFlags(flags *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		if err := cobra.MarkFlagDirname(flags, name); err != nil {
+			panic(fmt.Errorf("marking flag %s as directory, %w", name, err))
+		}
+	}
+}
+
+// isPathFlag reports whether the flag was marked with [markPathFlags] or [markDirFlags]
+func isPathFlag(flag *pflag.Flag) bool {
+	_, file := flag.Annotations[cobra.BashCompFilenameExt]
+	_, dir := flag.Annotations[cobra.BashCompSubdirsInDir]
+
+	return file || dir
+}
+
+// setFlag sets the flag like it was set in command line
+func setFlag(flags *pflag.FlagSet, flag *pflag.Flag, values []string) error {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		if err := slice.Replace(values); err != nil {
+			return err
+		}
+		flag.Changed = true
+		return nil
+	}
+
+	if len(values) != 1 {
+		return errors.New("expected single value, got a list")
+	}
+
+	return flags.Set(flag.Name, values[0])
+}
+
+// allFlags returns names of flags of the cmd and all its subcommands
+func allFlags(cmd *cobra.Command) map[string]struct{} {
+	out := map[string]struct{}{}
+	add := func(flag *pflag.Flag) {
+		out[flag.Name] = struct{}{}
+	}
+	cmd.Flags().VisitAll(add)
+	cmd.PersistentFlags().VisitAll(add)
+	for _, sub := range cmd.Commands() {
+		for name := range allFlags(sub) {
+			out[name] = struct{}{}
+		}
+	}
+	delete(out, "help")
+	delete(out, "version")
+	delete(out, "config")
+
+	return out
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for name := range m {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+
+	return out
+}
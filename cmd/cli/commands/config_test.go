@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func TestPathFlagsAreMarked(t *testing.T) {
+	paths := map[string]bool{
+		"dir": false, "dirs-from": false, "data-source-rules": false, "layer-rules": false, "state-key-rules": false, "codeowners": false,
+		"out": false, "cache": false, "cache-dir": false, "log-file": false, "diagnostics-file": false, "plugin": false, "policy": false,
+		"base": false, "against": false, "delta-file": false, "docs-dir": false, "root": false, "log-dir": false,
+	}
+
+	var visit func(cmd *cobra.Command)
+	visit = func(cmd *cobra.Command) {
+		check := func(flag *pflag.Flag) {
+			if _, ok := paths[flag.Name]; !ok {
+				return
+			}
+			paths[flag.Name] = true
+			if !isPathFlag(flag) {
+				t.Errorf("flag --%s of command: %s is not marked as path", flag.Name, cmd.CommandPath())
+			}
+		}
+		cmd.LocalFlags().VisitAll(check)
+		for _, sub := range cmd.Commands() {
+			visit(sub)
+		}
+	}
+	visit(NewCommand())
+
+	for name, found := range paths {
+		if !found {
+			t.Errorf("flag --%s is not defined by any command", name)
+		}
+	}
+}
+
+func TestApplyConfigResolvesPaths(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, ".terradep.yaml")
+	writeFile(t, config, `
+dir: [live, /abs/live]
+skip: ['**/examples/**']
+out: '-'
+log-file: logs/terradep.log
+cache-dir: .cache
+base: [base/live]
+against: snapshot.json
+delta-file: delta.json
+log-dir: logs
+`)
+
+	tests := []struct {
+		command []string
+		flag    string
+		want    []string
+	}{
+		{command: []string{"graph"}, flag: "dir", want: []string{filepath.Join(dir, "live"), "/abs/live"}},
+		{command: []string{"graph"}, flag: "skip", want: []string{"**/examples/**"}},
+		{command: []string{"graph"}, flag: "out", want: []string{"-"}},
+		{command: []string{"graph"}, flag: "log-file", want: []string{filepath.Join(dir, "logs/terradep.log")}},
+		{command: []string{"graph"}, flag: "cache-dir", want: []string{filepath.Join(dir, ".cache")}},
+		{command: []string{"diff"}, flag: "base", want: []string{filepath.Join(dir, "base/live")}},
+		{command: []string{"check"}, flag: "against", want: []string{filepath.Join(dir, "snapshot.json")}},
+		{command: []string{"check"}, flag: "delta-file", want: []string{filepath.Join(dir, "delta.json")}},
+		{command: []string{"run"}, flag: "log-dir", want: []string{filepath.Join(dir, "logs")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command[0]+" "+tt.flag, func(t *testing.T) {
+			cmd, _, err := NewCommand().Find(tt.command)
+			if err != nil {
+				t.Fatalf("finding command: %v, %v", tt.command, err)
+			}
+			if err := cmd.ParseFlags(nil); err != nil {
+				t.Fatalf("parsing flags: %v", err)
+			}
+			if err := applyConfig(cmd, config); err != nil {
+				t.Fatalf("applying config: %v", err)
+			}
+
+			flag := cmd.Flags().Lookup(tt.flag)
+			got := []string{flag.Value.String()}
+			if slice, ok := flag.Value.(pflag.SliceValue); ok {
+				got = slice.GetSlice()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unexpected value of flag --%s: %v, want: %v", tt.flag, got, tt.want)
+			}
+		})
+	}
+}
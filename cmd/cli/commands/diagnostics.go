@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/encoding"
+	"go.interactor.dev/terradep/internal/perm"
+)
+
+// formats of the diagnostics set with flag --diagnostics
+const (
+	diagnosticsText = "TEXT"
+	diagnosticsJSON = "JSON"
+	// diagnosticsGitHub writes GitHub Actions workflow commands, which annotate the files
+	diagnosticsGitHub = "GITHUB"
+	// gitHubWorkspaceEnv is set by GitHub Actions to the directory of the repository
+	gitHubWorkspaceEnv = "GITHUB_WORKSPACE"
+)
+
+// openDiagnostics opens the destination of the diagnostics set with flag --diagnostics-file.
+// The file is truncated, so it contains only diagnostics of the last run
+func (c *rootCfg) openDiagnostics() error {
+	switch strings.ToUpper(c.diagFmt) {
+	case diagnosticsText, diagnosticsJSON, diagnosticsGitHub:
+	default:
+		return fmt.Errorf("unsupported diagnostics format: %s, allowed values: %s, %s, %s", c.diagFmt, diagnosticsText, diagnosticsJSON, diagnosticsGitHub)
+	}
+
+	switch c.diagFile {
+	case "":
+		c.diagOut = os.Stderr
+	case stdoutFile:
+		c.diagOut = os.Stdout
+	default:
+		file, err := os.OpenFile(c.diagFile, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, perm.UserRW)
+		if err != nil {
+			return fmt.Errorf("opening diagnostics file: %s, %w", c.diagFile, err)
+		}
+		c.diagOut = file
+	}
+
+	return nil
+}
+
+// printDiagnostics writes diagnostics in the format set with flag --diagnostics. Unlike logs, they are printed also in quiet mode
+func (c *rootCfg) printDiagnostics(diags terradep.Diagnostics) error {
+	out := c.diagOut
+	if out == nil {
+		out = os.Stderr
+	}
+
+	switch strings.ToUpper(c.diagFmt) {
+	case diagnosticsJSON:
+		return encoding.NewDiagnosticEncoder(out).Encode(diags)
+	case diagnosticsGitHub:
+		return encoding.NewGitHubAnnotationEncoder(out, os.Getenv(gitHubWorkspaceEnv)).Encode(diags)
+	default:
+		return writeDiagnosticsText(out, diags)
+	}
+}
+
+// writeDiagnosticsText writes diagnostics one per line, see [terradep.Diagnostic.String]
+func writeDiagnosticsText(w io.Writer, diags terradep.Diagnostics) error {
+	for _, diag := range diags {
+		if _, err := fmt.Fprintln(w, diag.String()); err != nil {
+			return fmt.Errorf("writing diagnostics: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+)
+
+// formats of the diff set with flag --format
+const (
+	diffText = "TEXT"
+	diffJSON = "JSON"
+)
+
+type diffCfg struct {
+	*graphCfg
+	base   []string
+	format string
+	notify string
+}
+
+// diffView is [terradep.GraphDiff] written as JSON
+type diffView struct {
+	AddedDeployments    []string   `json:"addedDeployments"`
+	RemovedDeployments  []string   `json:"removedDeployments"`
+	AddedDependencies   [][]string `json:"addedDependencies"`
+	RemovedDependencies [][]string `json:"removedDependencies"`
+}
+
+func newDiffCommand(rc *rootCfg) *cobra.Command {
+	dc := &diffCfg{graphCfg: &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}}
+	diffCmd := &cobra.Command{
+		Use:     `diff --base baseDir --dir analyzeMe`,
+		Example: `git worktree add /tmp/main origin/main && diff --base /tmp/main/live --dir live --notify "$SLACK_WEBHOOK_URL"`,
+		Short: "Prints deployments and dependencies added and removed in analyzeMe comparing to baseDir, e.g. checkout of the main branch. " +
+			"Deployments are compared by their states, so directories can be at different locations. Flag --base must be set once for every --dir, unless it is a file with the graph written by graph --format json",
+		RunE: diffGraphs(dc),
+	}
+	addScanFlags(diffCmd, dc.scanCfg)
+	dF := diffCmd.Flags()
+	dF.StringSliceVar(&dc.base, "base", nil, "Sets directories with the base version of the code, compared with directories set with --dir in the same order, "+
+		"or single JSON file with the base graph written by graph --format json --relative-paths, e.g. stored as an artifact of the main branch")
+	dF.StringVar(&dc.format, "format", diffText, fmt.Sprintf("Sets format of the diff. Allowed values: %s, %s", diffText, diffJSON))
+	dF.BoolVar(&dc.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+	dF.StringVar(&dc.notify, "notify", "", "Posts summary of the changes to the webhook, e.g. Slack incoming webhook, in JSON with field text. Nothing is posted when there are no changes")
+	// base is a directory or a file with the graph
+	markPathFlags(dF, "base")
+	if err := diffCmd.MarkFlagRequired("base"); err != nil {
+		panic(fmt.Errorf("marking flag base as required, %w", err))
+	}
+
+	return diffCmd
+}
+
+func diffGraphs(c *diffCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		format := strings.ToUpper(c.format)
+		if format != diffText && format != diffJSON {
+			return fmt.Errorf("unsupported diff format: %s, allowed values: %s, %s", c.format, diffText, diffJSON)
+		}
+
+		baseFile := len(c.base) == 1 && isFile(c.base[0])
+		if len(c.base) != len(c.dirs) && !baseFile {
+			return fmt.Errorf("flag --base is set %d times, but flag --dir %d times", len(c.base), len(c.dirs))
+		}
+
+		// paths of the deployments must not depend on the location of the directories
+		c.relativePaths = true
+		head, err := scanGraph(log, c.graphCfg)
+		if err != nil {
+			return err
+		}
+
+		var base *terradep.Graph
+		if baseFile {
+			base, err = readGraph(c.base[0])
+		} else {
+			baseScan := *c.scanCfg
+			baseScan.dirs = c.base
+			baseCfg := *c.graphCfg
+			baseCfg.scanCfg = &baseScan
+			base, err = scanGraph(log, &baseCfg)
+		}
+		if err != nil {
+			return err
+		}
+
+		diff := terradep.DiffGraphs(base, head)
+		if err := writeDiff(cmd.OutOrStdout(), diff, format); err != nil {
+			return err
+		}
+
+		if c.notify != "" && !diff.IsEmpty() && !c.dryRun {
+			return notify(log, c.notify, "terradep: dependencies between deployments changed\n"+diffSummary(diff))
+		}
+
+		return nil
+	}
+}
+
+// writeDiff writes the diff in the format set with flag --format
+func writeDiff(w io.Writer, diff terradep.GraphDiff, format string) error {
+	var err error
+	if format == diffJSON {
+		err = writeDiffJSON(w, diff)
+	} else {
+		_, err = io.WriteString(w, diffSummary(diff))
+	}
+	if err != nil {
+		return fmt.Errorf("writing diff: %w", err)
+	}
+
+	return nil
+}
+
+// readGraph reads the graph written by graph --format json
+func readGraph(path string) (*terradep.Graph, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading graph: %s, %w", path, err)
+	}
+
+	graph := &terradep.Graph{}
+	if err := json.Unmarshal(b, graph); err != nil {
+		return nil, fmt.Errorf("decoding graph: %s, %w", path, err)
+	}
+
+	return graph, nil
+}
+
+func isFile(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.Mode().IsRegular()
+}
+
+// diffSummary describes every change in single line starting with + or -
+func diffSummary(diff terradep.GraphDiff) string {
+	sb := strings.Builder{}
+	for _, n := range diff.AddedDeployments {
+		fmt.Fprintf(&sb, "+ deployment %s\n", nodeLabel(n))
+	}
+	for _, n := range diff.RemovedDeployments {
+		fmt.Fprintf(&sb, "- deployment %s\n", nodeLabel(n))
+	}
+	for _, e := range diff.AddedDependencies {
+		fmt.Fprintf(&sb, "+ %s -> %s\n", nodeLabel(e.From), nodeLabel(e.To))
+	}
+	for _, e := range diff.RemovedDependencies {
+		fmt.Fprintf(&sb, "- %s -> %s\n", nodeLabel(e.From), nodeLabel(e.To))
+	}
+
+	return sb.String()
+}
+
+// nodeLabel returns label of the deployment or the state, when node is not a deployment
+func nodeLabel(n *terradep.Node) string {
+	if n.Metadata == nil {
+		return n.State.String()
+	}
+
+	return runLabel(n)
+}
+
+func writeDiffJSON(w io.Writer, diff terradep.GraphDiff) error {
+	view := diffView{
+		AddedDeployments:    nodeLabels(diff.AddedDeployments),
+		RemovedDeployments:  nodeLabels(diff.RemovedDeployments),
+		AddedDependencies:   edgeLabels(diff.AddedDependencies),
+		RemovedDependencies: edgeLabels(diff.RemovedDependencies),
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(view)
+}
+
+func nodeLabels(nodes []*terradep.Node) []string {
+	out := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, nodeLabel(n))
+	}
+
+	return out
+}
+
+func edgeLabels(edges []terradep.Edge) [][]string {
+	out := make([][]string, 0, len(edges))
+	for _, e := range edges {
+		out = append(out, []string{nodeLabel(e.From), nodeLabel(e.To)})
+	}
+
+	return out
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep/encoding"
+	"go.interactor.dev/terradep/internal/perm"
+	"golang.org/x/exp/slog"
+)
+
+type docsCfg struct {
+	*generatorCfg
+	docsDir string
+	check   bool
+}
+
+func newDocsCommand(rc *rootCfg) *cobra.Command {
+	c := &docsCfg{generatorCfg: newGeneratorCfg(rc)}
+	cmd := &cobra.Command{
+		Use:     `docs [--docs-dir docs/dependencies] [--check] --dir analyzeMe`,
+		Example: `docs --dir . --check`,
+		Short: "Writes " + encoding.DocsFile + " into the directory of every deployment, or into --docs-dir, listing deployments it depends on and deployments depending on it with links to their documentation. " +
+			"Only files which content changed are written, so the documentation can be regenerated and committed with the code. With --dry-run only logs the files which would be written",
+		RunE: writeDocs(c),
+	}
+	addScanFlags(cmd, c.scanCfg)
+	f := cmd.Flags()
+	f.BoolVar(&c.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+	f.StringVar(&c.root, "root", ".", "Sets the directory, usually the root of the repository, which paths of the deployments and --docs-dir are relative to")
+	f.StringVar(&c.docsDir, "docs-dir", "", "Writes the documentation into the directory relative to --root, mirroring the directories of the deployments, instead of the directories of the deployments")
+	markDirFlags(f, "root", "docs-dir")
+	f.BoolVar(&c.check, "check", false, "Fails when the documentation is missing or out of date instead of writing it, e.g. in CI")
+
+	return cmd
+}
+
+func writeDocs(c *docsCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		if err := c.resolvePaths(); err != nil {
+			return err
+		}
+
+		graph, opts, err := c.scan(log)
+		if err != nil {
+			return err
+		}
+		if c.docsDir != "" {
+			opts = append(opts, encoding.WithDocsDir(filepath.ToSlash(c.docsDir)))
+		}
+
+		docs := encoding.BuildDeploymentDocs(graph, opts...)
+		var outdated []string
+		for _, name := range sortedNames(docs) {
+			path := filepath.Join(c.root, filepath.FromSlash(name))
+			existing, err := os.ReadFile(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("reading documentation: %s, %w", path, err)
+			}
+			if bytes.Equal(existing, docs[name]) {
+				log.Debug("documentation up to date", slog.String("path", path))
+				continue
+			}
+
+			outdated = append(outdated, name)
+			if c.check || c.dryRun {
+				log.Info("documentation out of date", slog.String("path", path))
+				continue
+			}
+
+			log.Info("writing documentation", slog.String("path", path))
+			if err := os.MkdirAll(filepath.Dir(path), perm.UserRWX); err != nil {
+				return fmt.Errorf("creating documentation directory: %s, %w", filepath.Dir(path), err)
+			}
+			if err := os.WriteFile(path, docs[name], perm.UserRW); err != nil {
+				return fmt.Errorf("writing documentation: %s, %w", path, err)
+			}
+		}
+
+		if c.check && len(outdated) != 0 {
+			return fmt.Errorf("documentation of %d of %d deployment directories is out of date, regenerate it with %s docs: %v", len(outdated), len(docs), CLIName, outdated)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"golang.org/x/exp/slog"
+)
+
+func newDoctorCommand(rc *rootCfg) *cobra.Command {
+	dc := &scanCfg{rootCfg: rc}
+	doctorCmd := &cobra.Command{
+		Use:     `doctor --dir analyzeMe`,
+		Example: `doctor --dir analyzeMe --skip '**/examples/**'`,
+		Short:   "Explains for every directory in analyzeMe why it was or wasn't treated as a deployment. Accepts the same flags as graph, so it shows what graph would do",
+		RunE:    explainDirs(dc),
+	}
+	addScanFlags(doctorCmd, dc)
+
+	return doctorCmd
+}
+
+func explainDirs(c *scanCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		opts, err := c.scannerOpts(log)
+		if err != nil {
+			return err
+		}
+
+		s := terradep.NewScanner(log, c.newStater(), opts...)
+		out := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(out, "PATH\tSTATUS\tDETAIL")
+		for _, dir := range c.dirs {
+			log.Info("explaining directory", slog.String("dir", dir))
+			reports, err := s.Explain(dir)
+			for _, r := range reports {
+				fmt.Fprintf(out, "%s\t%s\t%s\n", r.Path, r.Status, r.Detail)
+			}
+			if err != nil {
+				return scanError(fmt.Errorf("failed to explain path: %s, error was: %w", dir, err))
+			}
+		}
+
+		return out.Flush()
+	}
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"go.interactor.dev/terradep"
+)
+
+// Exit codes of the cli, so pipelines can branch on the class of the failure
+const (
+	ExitOK = 0
+	// ExitFailure is returned for errors without more specific code, e.g. invalid flags
+	ExitFailure = 1
+	// ExitCycle is returned when deployments depend on each other
+	ExitCycle = 2
+	// ExitMissingDependency is returned when deployments depend on states which are not produced by any scanned deployment
+	ExitMissingDependency = 3
+	// ExitParseError is returned when a module cannot be scanned, e.g. because of invalid HCL or unsupported backend
+	ExitParseError = 4
+	// ExitPolicyViolation is returned when the graph breaks the policy checked by the command, e.g. deployments share the state
+	ExitPolicyViolation = 5
+	// ExitDrift is returned when the graph differs from the snapshot it is checked against
+	ExitDrift = 6
+)
+
+// exitCodesHelp documents exit codes in the help of the root command
+var exitCodesHelp = fmt.Sprintf(`Exit codes:
+  %d  success
+  %d  other failure, e.g. invalid flags
+  %d  dependency cycle detected
+  %d  missing or external dependencies
+  %d  module cannot be parsed
+  %d  policy violation
+  %d  graph differs from the snapshot`, ExitOK, ExitFailure, ExitCycle, ExitMissingDependency, ExitParseError, ExitPolicyViolation, ExitDrift)
+
+// exitError is an error with the exit code of the cli
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode makes the cli exit with the code when err is returned by the command
+func withExitCode(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// scanError returns error of the scan with the exit code of its class, errors which are not caused by the scanned modules,
+// e.g. the directory does not exist, make the cli exit with [ExitFailure]
+func scanError(err error) error {
+	if errors.Is(err, terradep.ErrCycle) {
+		return withExitCode(ExitCycle, err)
+	}
+
+	if errors.Is(err, terradep.ErrDuplicateState) {
+		return withExitCode(ExitPolicyViolation, err)
+	}
+
+	if errors.Is(err, terradep.ErrInvalidModule) {
+		return withExitCode(ExitParseError, err)
+	}
+
+	return withExitCode(ExitFailure, err)
+}
+
+// ExitCode returns exit code of the cli for the error returned by the command
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return exit.code
+	}
+
+	return ExitFailure
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+func TestExitCode(t *testing.T) {
+	dir := terradeptest.Dir(t, terradeptest.Files{
+		"invalid/main.tf": `terraform {`,
+		"file.tf":         ``,
+	})
+	scan := func(path string) error {
+		_, _, err := terradep.NewScanner(nil, terradeptest.NewStater()).Scan(path)
+		if err == nil {
+			t.Fatalf("expected error of the scan: %s", path)
+		}
+		return err
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: ExitOK},
+		{name: "plain error", err: errors.New("invalid flag"), want: ExitFailure},
+		{name: "explicit code", err: withExitCode(ExitDrift, errors.New("drift")), want: ExitDrift},
+		{name: "wrapped explicit code", err: fmt.Errorf("checking snapshot: %w", withExitCode(ExitDrift, errors.New("drift"))), want: ExitDrift},
+		{name: "cycle", err: scanError(&terradep.CycleError{}), want: ExitCycle},
+		{name: "duplicate state", err: scanError(&terradep.DuplicateStateError{}), want: ExitPolicyViolation},
+		{name: "module error", err: scanError(&terradep.ModuleError{Err: &terradep.NoBackendError{}}), want: ExitParseError},
+		{name: "not existing path", err: scanError(fmt.Errorf("path does not exist: x, %w", fs.ErrNotExist)), want: ExitFailure},
+		{name: "not a directory", err: scanError(&terradep.NotADirectoryError{}), want: ExitFailure},
+		{name: "other scan error", err: scanError(errors.New("invalid glob")), want: ExitFailure},
+		{name: "scan of invalid module", err: scanError(scan(filepath.Join(dir, "invalid"))), want: ExitParseError},
+		{name: "scan of not existing dir", err: scanError(scan(filepath.Join(dir, "missing"))), want: ExitFailure},
+		{name: "scan of file", err: scanError(scan(filepath.Join(dir, "file.tf"))), want: ExitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("unexpected exit code: %d, want: %d, error: %v", got, tt.want, tt.err)
+			}
+		})
+	}
+}
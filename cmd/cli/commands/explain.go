@@ -0,0 +1,221 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+)
+
+type explainCfg struct {
+	*graphCfg
+	format string
+}
+
+// explainedNode describes the deployment or the state printed by explain
+type explainedNode struct {
+	Path      string `json:"path,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	Overlay   string `json:"overlay,omitempty"`
+	Backend   string `json:"backend,omitempty"`
+	// BackendRange points to the backend or cloud block, empty for external states and states declared in the manifest
+	BackendRange string `json:"backendRange,omitempty"`
+	// BackendConfig are the settings of the backend the state is resolved from, e.g. bucket and key of S3 backend
+	BackendConfig map[string]any        `json:"backendConfig,omitempty"`
+	State         string                `json:"state"`
+	Dependencies  []explainedDependency `json:"dependencies"`
+	Dependents    []string              `json:"dependents"`
+	Modules       []string              `json:"modules,omitempty"`
+}
+
+// explainedDependency is the state the deployment depends on with the code referencing it
+type explainedDependency struct {
+	State string `json:"state"`
+	// Deployment produces the state, empty for external states
+	Deployment string   `json:"deployment,omitempty"`
+	Ranges     []string `json:"ranges"`
+	Outputs    []string `json:"outputs,omitempty"`
+}
+
+func newExplainCommand(rc *rootCfg) *cobra.Command {
+	c := &explainCfg{graphCfg: &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}}
+	cmd := &cobra.Command{
+		Use:     `explain [--format (TEXT|JSON)] --dir analyzeMe DEPLOYMENT`,
+		Example: `explain --dir . live/app`,
+		Short: "Shows everything terradep knows about DEPLOYMENT: its backend block and configuration, the state it is resolved to, " +
+			"every state it depends on with the file and line of the code referencing it and the consumed outputs, and the deployments depending on it. " +
+			"DEPLOYMENT is the path of the deployment or the state, e.g. s3://bucket/key. All workspaces and overlays of the deployment are shown",
+		Args: cobra.ExactArgs(1),
+		RunE: explainDeployment(c),
+	}
+	addScanFlags(cmd, c.scanCfg)
+	f := cmd.Flags()
+	f.StringVar(&c.format, "format", diffText, fmt.Sprintf("Sets format of the output. Allowed values: %s, %s", diffText, diffJSON))
+	f.BoolVar(&c.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+
+	return cmd
+}
+
+func explainDeployment(c *explainCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		format := strings.ToUpper(c.format)
+		if format != diffText && format != diffJSON {
+			return fmt.Errorf("unsupported format: %s, allowed values: %s, %s", c.format, diffText, diffJSON)
+		}
+
+		graph, err := scanGraph(log, c.graphCfg)
+		if err != nil {
+			return err
+		}
+
+		nodes := matchingNodes(graph, args[0])
+		if len(nodes) == 0 {
+			return fmt.Errorf("deployment or state not found: %s", args[0])
+		}
+
+		explained := make([]explainedNode, 0, len(nodes))
+		for _, n := range nodes {
+			e, err := explainNode(graph, n)
+			if err != nil {
+				return err
+			}
+			explained = append(explained, e)
+		}
+
+		if format == diffJSON {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(explained); err != nil {
+				return fmt.Errorf("encoding explanation: %w", err)
+			}
+			return nil
+		}
+
+		writeExplanation(cmd.OutOrStdout(), explained)
+		return nil
+	}
+}
+
+func explainNode(graph *terradep.Graph, n *terradep.Node) (explainedNode, error) {
+	e := explainedNode{
+		State:        n.State.String(),
+		Dependencies: []explainedDependency{},
+		Dependents:   []string{},
+	}
+	if graph.IsDeployment(n) {
+		e.Path, e.Workspace, e.Overlay = n.Path, n.Workspace, n.Overlay
+	}
+	if n.BackendRange != nil {
+		e.BackendRange = n.BackendRange.String()
+	}
+
+	// workspace is a part of the label, the backend configuration is of the state of the default workspace
+	s, _ := unwrapWorkspace(n.State)
+	e.Backend = s.Backend()
+	if e.Backend == "" && n.Metadata != nil {
+		e.Backend = n.Metadata.Backend
+	}
+	if e.Backend != "" {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return e, fmt.Errorf("encoding state: %s, %w", n.State, err)
+		}
+		if err := json.Unmarshal(b, &e.BackendConfig); err != nil {
+			return e, fmt.Errorf("decoding state: %s, %w", n.State, err)
+		}
+		delete(e.BackendConfig, "backend")
+		delete(e.BackendConfig, "identity")
+	}
+
+	for _, child := range n.Children {
+		edge := n.EdgeMetadata(child)
+		dep := explainedDependency{State: child.State.String(), Ranges: []string{}, Outputs: edge.Outputs}
+		if graph.IsDeployment(child) {
+			dep.Deployment = runLabel(child)
+		}
+		for _, r := range edge.Ranges {
+			dep.Ranges = append(dep.Ranges, r.String())
+		}
+		e.Dependencies = append(e.Dependencies, dep)
+	}
+	for _, dependent := range graph.Dependents(n) {
+		e.Dependents = append(e.Dependents, nodeLabel(dependent))
+	}
+	for _, module := range n.Modules {
+		e.Modules = append(e.Modules, nodeLabel(module))
+	}
+
+	return e, nil
+}
+
+// writeExplanation writes every node as indented sections, separated by empty lines
+func writeExplanation(w io.Writer, explained []explainedNode) {
+	for i, e := range explained {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		if e.Path != "" {
+			label := e.Path
+			if e.Workspace != "" {
+				label += ":" + e.Workspace
+			}
+			if e.Overlay != "" {
+				label += "#" + e.Overlay
+			}
+			fmt.Fprintln(w, label)
+		} else {
+			fmt.Fprintf(w, "%s (not produced by any deployment)\n", e.State)
+		}
+
+		if e.Backend != "" {
+			backend := "  backend: " + e.Backend
+			if e.BackendRange != "" {
+				backend += " (" + e.BackendRange + ")"
+			}
+			fmt.Fprintln(w, backend)
+			for _, key := range sortedNames(e.BackendConfig) {
+				fmt.Fprintf(w, "    %s = %v\n", key, e.BackendConfig[key])
+			}
+		}
+		fmt.Fprintln(w, "  state:", e.State)
+
+		fmt.Fprintf(w, "  dependencies: %d\n", len(e.Dependencies))
+		for _, dep := range e.Dependencies {
+			target := dep.State
+			if dep.Deployment != "" {
+				target += " (" + dep.Deployment + ")"
+			}
+			fmt.Fprintln(w, "    ->", target)
+			if len(dep.Ranges) == 0 {
+				fmt.Fprintln(w, "       declared in manifest")
+			}
+			for _, r := range dep.Ranges {
+				fmt.Fprintln(w, "       at", r)
+			}
+			if len(dep.Outputs) != 0 {
+				fmt.Fprintln(w, "       outputs:", strings.Join(dep.Outputs, ", "))
+			}
+		}
+
+		fmt.Fprintf(w, "  dependents: %d\n", len(e.Dependents))
+		for _, dependent := range e.Dependents {
+			fmt.Fprintln(w, "    <-", dependent)
+		}
+
+		if len(e.Modules) != 0 {
+			fmt.Fprintf(w, "  modules: %d\n", len(e.Modules))
+			for _, module := range e.Modules {
+				fmt.Fprintln(w, "    ->", module)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/encoding"
+)
+
+// applyDuration is the duration of applying the deployments matching the glob, set with flag --duration
+type applyDuration struct {
+	glob     string
+	duration time.Duration
+}
+
+// parseDurations parses durations set with --duration
+func parseDurations(values []string) ([]applyDuration, error) {
+	out := make([]applyDuration, 0, len(values))
+	for _, value := range values {
+		glob, raw, ok := strings.Cut(value, "=")
+		if !ok || glob == "" {
+			return nil, fmt.Errorf("invalid duration: %q, expected format glob=duration", value)
+		}
+		if !doublestar.ValidatePattern(glob) {
+			return nil, fmt.Errorf("invalid glob pattern of duration: %q", value)
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration: %q, expected duration like 90s or 5m, %w", value, err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("invalid duration: %q, duration cannot be negative", value)
+		}
+		out = append(out, applyDuration{glob: glob, duration: d})
+	}
+
+	return out, nil
+}
+
+// ganttDurations returns the duration of applying the deployment set with --duration, the last matching wins.
+// Durations of other deployments are estimated with [encoding.EstimateApplyDuration]
+func (c *graphCfg) ganttDurations() (func(*terradep.Node) time.Duration, error) {
+	durations, err := parseDurations(c.durations)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(n *terradep.Node) time.Duration {
+		for i := len(durations) - 1; i >= 0; i-- {
+			if c.matchesDir(durations[i].glob, n.Path) {
+				return durations[i].duration
+			}
+		}
+		return encoding.EstimateApplyDuration(n)
+	}, nil
+}
@@ -0,0 +1,291 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/encoding"
+	"golang.org/x/exp/slog"
+)
+
+// generatorCfg contains flags shared by all the generators of CI configuration
+type generatorCfg struct {
+	*graphCfg
+	root     string
+	affected []string
+	commands []string
+	owner    string
+}
+
+// generator builds the configuration from the graph
+type generator func(graph *terradep.Graph, opts ...encoding.GenerateOpt) ([]byte, error)
+
+func newGenerateCommand(rc *rootCfg) *cobra.Command {
+	generateCmd := &cobra.Command{
+		Use:   `generate <generator>`,
+		Short: "Generates configuration of CI and orchestration tools from the graph, so it does not drift from the dependencies between deployments",
+	}
+	generateCmd.AddCommand(newAtlantisCommand(rc))
+	generateCmd.AddCommand(newGitLabCommand(rc))
+	generateCmd.AddCommand(newGitHubCommand(rc))
+	generateCmd.AddCommand(newTerragruntCommand(rc))
+	generateCmd.AddCommand(newSpaceliftCommand(rc))
+	generateCmd.AddCommand(newDiggerCommand(rc))
+	generateCmd.AddCommand(newMakeCommand(rc))
+	generateCmd.AddCommand(newBackstageCommand(rc))
+
+	return generateCmd
+}
+
+func newAtlantisCommand(rc *rootCfg) *cobra.Command {
+	c := newGeneratorCfg(rc)
+	cmd := &cobra.Command{
+		Use:     `atlantis [--root .] [--out atlantis.yaml] --dir analyzeMe`,
+		Example: `generate atlantis --dir . --module-edges --out atlantis.yaml --force`,
+		Short: "Generates atlantis.yaml with one project per deployment. Every deployment is in execution order group greater than groups of its dependencies, " +
+			"so it is applied after them. Autoplan is triggered by changes of Terraform files in the directory of the deployment and, with --module-edges, of local modules it uses",
+		RunE: runGenerator(c, encoding.BuildAtlantisConfig),
+	}
+	addGeneratorFlags(cmd, c)
+
+	return cmd
+}
+
+func newGitLabCommand(rc *rootCfg) *cobra.Command {
+	c := newGeneratorCfg(rc)
+	cmd := &cobra.Command{
+		Use:     `gitlab [--only-affected path] [--command "terraform plan"] --dir analyzeMe`,
+		Example: `generate gitlab --dir . --only-affected "$(git diff --name-only $CI_MERGE_REQUEST_DIFF_BASE_SHA | paste -sd, -)" --out child-pipeline.yml`,
+		Short: "Generates GitLab CI pipeline, to be run as dynamic child pipeline, with one job per deployment. Every job needs jobs of the deployments it depends on " +
+			"and runs the commands in the directory of the deployment with variables TERRADEP_PATH, TERRADEP_OVERLAY and " + tfWorkspaceEnv + " set",
+		RunE: runGenerator(c, encoding.BuildGitLabPipeline),
+	}
+	addGeneratorFlags(cmd, c)
+	addCommandsFlag(cmd, c)
+
+	return cmd
+}
+
+const (
+	gitHubWorkflow = "WORKFLOW"
+	gitHubMatrix   = "MATRIX"
+)
+
+func newGitHubCommand(rc *rootCfg) *cobra.Command {
+	c := newGeneratorCfg(rc)
+	format := gitHubWorkflow
+	cmd := &cobra.Command{
+		Use:     `gha [--format (WORKFLOW|MATRIX)] [--command "terraform plan"] --dir analyzeMe`,
+		Example: `generate gha --dir . --out .github/workflows/terraform.yaml --force`,
+		Short: "Generates GitHub Actions workflow with one job per deployment. Every job needs jobs of the deployments it depends on and runs the commands " +
+			"in the directory of the deployment with variables TERRADEP_PATH, TERRADEP_OVERLAY and " + tfWorkspaceEnv + " set. " +
+			"With --format " + gitHubMatrix + " generates JSON with job matrices instead, one per batch of deployments depending only on the previous batches",
+		RunE: runFormatGenerator(c, &format, map[string]generator{
+			gitHubWorkflow: encoding.BuildGitHubWorkflow,
+			gitHubMatrix:   encoding.BuildGitHubMatrix,
+		}),
+	}
+	addGeneratorFlags(cmd, c)
+	addCommandsFlag(cmd, c)
+	cmd.Flags().StringVar(&format, "format", gitHubWorkflow, fmt.Sprintf("Sets format of the output. Allowed values: %s, %s", gitHubWorkflow, gitHubMatrix))
+
+	return cmd
+}
+
+const (
+	spaceliftTerraform = "TERRAFORM"
+	spaceliftYAML      = "YAML"
+)
+
+func newSpaceliftCommand(rc *rootCfg) *cobra.Command {
+	c := newGeneratorCfg(rc)
+	format := spaceliftTerraform
+	cmd := &cobra.Command{
+		Use:     `spacelift [--format (TERRAFORM|YAML)] --dir analyzeMe`,
+		Example: `generate spacelift --dir . --out stack-dependencies.tf.json --force`,
+		Short: "Generates Terraform JSON configuration with spacelift_stack_dependency resource of every dependency between the deployments. " +
+			"Stacks are expected to be named like the deployments, e.g. live/app:prod for deployment live/app in workspace prod, so their ids are derived from the names, e.g. live-app-prod. " +
+			"With --format " + spaceliftYAML + " generates YAML with stacks and ids of the stacks they depend on instead, to be read with yamldecode",
+		RunE: runFormatGenerator(c, &format, map[string]generator{
+			spaceliftTerraform: encoding.BuildSpaceliftDependencies,
+			spaceliftYAML:      encoding.BuildSpaceliftStacks,
+		}),
+	}
+	addGeneratorFlags(cmd, c)
+	cmd.Flags().StringVar(&format, "format", spaceliftTerraform, fmt.Sprintf("Sets format of the output. Allowed values: %s, %s", spaceliftTerraform, spaceliftYAML))
+
+	return cmd
+}
+
+func newDiggerCommand(rc *rootCfg) *cobra.Command {
+	c := newGeneratorCfg(rc)
+	cmd := &cobra.Command{
+		Use:     `digger [--root .] [--out digger.yml] --dir analyzeMe`,
+		Example: `generate digger --dir . --module-edges --out digger.yml --force`,
+		Short: "Generates digger.yml with one project per deployment, which depends on projects of the deployments it depends on. " +
+			"With --module-edges include patterns of the project match local modules used by the deployment, so changing the module plans the deployment",
+		RunE: runGenerator(c, encoding.BuildDiggerConfig),
+	}
+	addGeneratorFlags(cmd, c)
+
+	return cmd
+}
+
+func newMakeCommand(rc *rootCfg) *cobra.Command {
+	c := newGeneratorCfg(rc)
+	cmd := &cobra.Command{
+		Use:     `make [--command "terraform plan"] [--out Makefile] --dir analyzeMe`,
+		Example: `generate make --dir . --command 'terraform init -input=false' --command 'terraform apply -auto-approve' --out Makefile --force && make -j 4 all`,
+		Short: "Generates Makefile with one target per deployment, which depends on targets of the deployments it depends on, so make -j runs the deployments in the order of the dependencies. " +
+			"Commands are Go templates with fields .Path, .Workspace, .Overlay, .State and .Target, run in the directory of the deployment with variables TERRADEP_PATH, TERRADEP_OVERLAY and " + tfWorkspaceEnv + " exported",
+		RunE: runGenerator(c, encoding.BuildMakefile),
+	}
+	addGeneratorFlags(cmd, c)
+	addCommandsFlag(cmd, c)
+
+	return cmd
+}
+
+func newBackstageCommand(rc *rootCfg) *cobra.Command {
+	c := newGeneratorCfg(rc)
+	cmd := &cobra.Command{
+		Use:     `backstage [--default-owner group] [--out catalog-info.yaml] --dir analyzeMe`,
+		Example: `generate backstage --dir . --default-owner platform-team --out catalog-info.yaml --force`,
+		Short: "Generates Backstage catalog with Resource of type " + encoding.BackstageType + " for every deployment, which depends on resources of the deployments it depends on. " +
+			"Owner, layer and tags are read from the manifest of the deployment, layer becomes the system of the resource",
+		RunE: runGenerator(c, encoding.BuildBackstageCatalog),
+	}
+	addGeneratorFlags(cmd, c)
+	cmd.Flags().StringVar(&c.owner, "default-owner", encoding.DefaultOwner, "Sets owner of the deployments which do not declare the owner in the manifest")
+
+	return cmd
+}
+
+func newGeneratorCfg(rc *rootCfg) *generatorCfg {
+	return &generatorCfg{graphCfg: &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}}
+}
+
+// addGeneratorFlags registers flags of the scanner, the output and flags shared by the generators
+func addGeneratorFlags(cmd *cobra.Command, c *generatorCfg) {
+	addScanFlags(cmd, c.scanCfg)
+	f := cmd.Flags()
+	f.StringVarP(&c.outFile, "out", "o", "", "Writes output to specified file. Fails when file already exists unless you set flag --force. If not set or set to '-', output is written to standard output")
+	f.BoolVarP(&c.force, "force", "f", false, "Writes output to file specified with --out even if it already exists. Existing file content WILL BE LOST")
+	f.BoolVar(&c.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+	f.StringVar(&c.root, "root", ".", "Sets the directory, usually the root of the repository, which paths of the deployments in the output are relative to")
+	markPathFlags(f, "out")
+	markDirFlags(f, "root")
+	f.StringSliceVar(&c.affected, "only-affected", nil, "Generates only the deployments affected by the change of the paths, i.e. deployments owning them and all the deployments depending on them")
+}
+
+// addCommandsFlag registers flag setting commands run by the generated CI jobs
+func addCommandsFlag(cmd *cobra.Command, c *generatorCfg) {
+	cmd.Flags().StringArrayVar(&c.commands, "command", encoding.DefaultCommands, "Sets shell command run in the directory of every deployment. Can be repeated, commands run one after another")
+}
+
+func runGenerator(c *generatorCfg, generate generator) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		if err := c.resolvePaths(); err != nil {
+			return err
+		}
+
+		out, err := buildOutput(log, c.graphCfg)
+		if err != nil {
+			return fmt.Errorf("building output: %w", err)
+		}
+
+		graph, opts, err := c.scan(log)
+		if err != nil {
+			return err
+		}
+
+		generated, err := generate(graph, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to generate %s: %w", cmd.Name(), err)
+		}
+
+		n, err := out.Write(generated)
+		if err != nil {
+			return fmt.Errorf("failed to write %s to output: %s, written: %d bytes, %w", cmd.Name(), out, n, err)
+		}
+
+		return nil
+	}
+}
+
+// runFormatGenerator runs the generator of the format set with flag --format
+func runFormatGenerator(c *generatorCfg, format *string, generators map[string]generator) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		generate, ok := generators[strings.ToUpper(*format)]
+		if !ok {
+			return fmt.Errorf("unsupported format: %s, allowed values: %s", *format, strings.Join(sortedNames(generators), ", "))
+		}
+
+		return runGenerator(c, generate)(cmd, args)
+	}
+}
+
+// resolvePaths makes the root and, unless the paths of the deployments are relative, scanned and affected paths absolute
+func (c *generatorCfg) resolvePaths() error {
+	root, err := filepath.Abs(c.root)
+	if err != nil {
+		return fmt.Errorf("resolving root directory: %s, %w", c.root, err)
+	}
+	c.root = root
+
+	if !c.relativePaths {
+		// paths of the deployments are made relative to the root, which requires them to be absolute
+		if c.dirs, err = absPaths(c.dirs); err != nil {
+			return err
+		}
+		if c.affected, err = absPaths(c.affected); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scan returns the graph and options of the generator set with the flags
+func (c *generatorCfg) scan(log *slog.Logger) (*terradep.Graph, []encoding.GenerateOpt, error) {
+	graph, err := scanGraph(log, c.graphCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := []encoding.GenerateOpt{encoding.WithRootDir(c.root)}
+	if len(c.commands) != 0 {
+		opts = append(opts, encoding.WithCommands(c.commands...))
+	}
+	if c.owner != "" {
+		opts = append(opts, encoding.WithDefaultOwner(c.owner))
+	}
+	if len(c.affected) != 0 {
+		affected := graph.Affected(c.affected...)
+		log.Info("generating affected deployments", slog.Int("nodes", len(affected)))
+		opts = append(opts, encoding.WithOnly(affected...))
+	}
+
+	return graph, opts, nil
+}
+
+func absPaths(paths []string) ([]string, error) {
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("resolving path: %s, %w", p, err)
+		}
+		out = append(out, abs)
+	}
+
+	return out, nil
+}
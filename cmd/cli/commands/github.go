@@ -0,0 +1,64 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/encoding"
+	"go.interactor.dev/terradep/internal/perm"
+)
+
+// gitHubSummaryEnv is set by GitHub Actions to the file with markdown shown on the summary page of the job
+const gitHubSummaryEnv = "GITHUB_STEP_SUMMARY"
+
+// writeGitHubSummary appends to the job summary Mermaid graph and table of the deployments affected by the changed paths.
+// All the deployments are listed, when there are no changed paths
+func writeGitHubSummary(graph *terradep.Graph, changed []string, direction encoding.Direction) error {
+	path := os.Getenv(gitHubSummaryEnv)
+	if path == "" {
+		return fmt.Errorf("environment variable %s is not set, GitHub summary can be written only in GitHub Actions", gitHubSummaryEnv)
+	}
+
+	title := "Deployments"
+	var nodes []*terradep.Node
+	mermaidOpts := []encoding.MermaidOpt{encoding.WithMermaidDirection(direction)}
+	if len(changed) != 0 {
+		title = "Affected deployments"
+		nodes = graph.Affected(changed...)
+		mermaidOpts = append(mermaidOpts, encoding.WithHighlighted(nodes...))
+	} else {
+		nodes = graph.Nodes()
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("## Dependency graph\n\n```mermaid\n")
+	sb.Write(encoding.BuildMermaidGraph(graph, mermaidOpts...))
+	sb.WriteString("```\n\n")
+
+	var rows []string
+	for _, n := range nodes {
+		if graph.IsDeployment(n) {
+			rows = append(rows, fmt.Sprintf("| `%s` | %s | %s | `%s` |", n.Path, n.Workspace, n.Overlay, n.State))
+		}
+	}
+	fmt.Fprintf(&sb, "## %s: %d\n\n", title, len(rows))
+	if len(rows) != 0 {
+		sb.WriteString("| Path | Workspace | Overlay | State |\n| --- | --- | --- | --- |\n")
+		sb.WriteString(strings.Join(rows, "\n"))
+		sb.WriteString("\n")
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm.UserRW)
+	if err != nil {
+		return fmt.Errorf("opening github summary: %s, %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(sb.String()); err != nil {
+		return fmt.Errorf("writing github summary: %s, %w", path, err)
+	}
+
+	return nil
+}
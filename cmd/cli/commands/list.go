@@ -0,0 +1,193 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"golang.org/x/exp/slog"
+)
+
+// formats of the list set with flag --format
+const (
+	listTable = "TABLE"
+	listJSON  = "JSON"
+)
+
+type listCfg struct {
+	*scanCfg
+	format  string
+	lenient bool
+	team    string
+	env     string
+}
+
+// listedDeployment is a row of the list
+type listedDeployment struct {
+	Path      string `json:"path"`
+	Workspace string `json:"workspace,omitempty"`
+	Overlay   string `json:"overlay,omitempty"`
+	// Environment is extracted from the state with flag --env-pattern, see [terradep.StateEnvironment]
+	Environment string `json:"environment,omitempty"`
+	Backend     string `json:"backend"`
+	State       string `json:"state"`
+	// Team is the owner from the manifest or the first code owner, see [terradep.Deployment.Team]
+	Team         string   `json:"team,omitempty"`
+	CodeOwners   []string `json:"codeOwners,omitempty"`
+	Dependencies int      `json:"dependencies"`
+	Dependents   int      `json:"dependents"`
+}
+
+func newListCommand(rc *rootCfg) *cobra.Command {
+	lc := &listCfg{scanCfg: &scanCfg{rootCfg: rc}}
+	listCmd := &cobra.Command{
+		Use:     `list [--format (TABLE|JSON)] --dir analyzeMe`,
+		Example: `list --dir analyzeMe --format json | jq -r '.[] | select(.dependents == 0) | .path'`,
+		Short:   "Lists every deployment found in analyzeMe with its backend type, state and numbers of dependencies and dependents, without rendering the graph",
+		RunE:    listDeployments(lc),
+	}
+	addScanFlags(listCmd, lc.scanCfg)
+	lF := listCmd.Flags()
+	lF.StringVar(&lc.format, "format", listTable, fmt.Sprintf("Sets format of the list. Allowed values: %s, %s", listTable, listJSON))
+	lF.StringVar(&lc.team, "team", "", "Lists only deployments of the team, i.e. the owner from the manifest or any of the owners read from --codeowners, e.g. @org/network. Dependents are still counted in all the deployments")
+	lF.StringVar(&lc.env, "env", "", "Lists only deployments of the environment, see --env-pattern. Dependents are still counted in all the deployments")
+	addEnvPatternFlag(listCmd, lc.scanCfg)
+	lF.BoolVar(&lc.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+
+	return listCmd
+}
+
+func listDeployments(c *listCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		format := strings.ToUpper(c.format)
+		if format != listTable && format != listJSON {
+			return fmt.Errorf("unsupported list format: %s, allowed values: %s, %s", c.format, listTable, listJSON)
+		}
+
+		envs, err := c.environmentPatterns()
+		if err != nil {
+			return err
+		}
+
+		opts, err := c.scannerOpts(log)
+		if err != nil {
+			return err
+		}
+		if c.lenient {
+			opts = append(opts, terradep.WithContinueOnError())
+		}
+
+		s := terradep.NewScanner(log, c.newStater(), opts...)
+		var deployments []terradep.Deployment
+		for _, dir := range c.dirs {
+			log.Info("listing directory", slog.String("dir", dir))
+			diags, err := s.Stream(dir, func(d terradep.Deployment) error {
+				deployments = append(deployments, d)
+				return nil
+			})
+			if err := c.printDiagnostics(diags); err != nil {
+				return err
+			}
+			if err != nil {
+				return scanError(fmt.Errorf("failed to scan path: %s, error was: %w", dir, err))
+			}
+		}
+
+		var out io.Writer = cmd.OutOrStdout()
+		if c.dryRun {
+			out = io.Discard
+		}
+
+		rows := listRows(deployments, listFilter{team: c.team, env: c.env, envs: envs})
+		if format == listJSON {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(rows); err != nil {
+				return fmt.Errorf("encoding list: %w", err)
+			}
+			return nil
+		}
+
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PATH\tWORKSPACE\tOVERLAY\tENVIRONMENT\tBACKEND\tSTATE\tTEAM\tDEPENDENCIES\tDEPENDENTS")
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n", r.Path, r.Workspace, r.Overlay, r.Environment, r.Backend, r.State, r.Team, r.Dependencies, r.Dependents)
+		}
+
+		return w.Flush()
+	}
+}
+
+// listFilter selects the listed deployments, empty fields match all the deployments
+type listFilter struct {
+	team string
+	env  string
+	// envs extract the environment of the deployment, see [terradep.StateEnvironment]
+	envs []*regexp.Regexp
+}
+
+// listRows returns rows matching the filter ordered by path, workspace and overlay.
+// Dependents are deployments depending on the state of the deployment
+func listRows(deployments []terradep.Deployment, filter listFilter) []listedDeployment {
+	dependents := make(map[string]int, len(deployments))
+	for _, d := range deployments {
+		seen := make(map[string]struct{}, len(d.Dependencies))
+		for _, dep := range d.Dependencies {
+			if _, ok := seen[dep.String()]; ok {
+				continue
+			}
+			seen[dep.String()] = struct{}{}
+			dependents[dep.String()]++
+		}
+	}
+
+	rows := make([]listedDeployment, 0, len(deployments))
+	for _, d := range deployments {
+		if filter.team != "" && !d.HasTeam(filter.team) {
+			continue
+		}
+		env := terradep.StateEnvironment(d.State, filter.envs...)
+		if filter.env != "" && env != filter.env {
+			continue
+		}
+		row := listedDeployment{
+			Path:         d.Path,
+			Workspace:    d.Workspace,
+			Overlay:      d.Overlay,
+			Environment:  env,
+			State:        d.State.String(),
+			Team:         d.Team(),
+			CodeOwners:   d.CodeOwners,
+			Dependencies: len(d.Dependencies),
+			Dependents:   dependents[d.State.String()],
+		}
+		if d.Metadata != nil {
+			row.Backend = d.Metadata.Backend
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Workspace != b.Workspace {
+			return a.Workspace < b.Workspace
+		}
+		return a.Overlay < b.Overlay
+	})
+
+	return rows
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const notifyTimeout = 10 * time.Second
+
+// notify posts the message to the webhook in JSON with field text, which is accepted e.g. by Slack and Mattermost incoming webhooks
+func notify(log *slog.Logger, webhook, message string) error {
+	body, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return fmt.Errorf("encoding notification: %w", err)
+	}
+
+	client := &http.Client{Timeout: notifyTimeout}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(body))
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// url may contain a secret, e.g. Slack webhook token, so it is not a part of the error
+		err = urlErr.Err
+	}
+	if err != nil {
+		return fmt.Errorf("posting notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("posting notification, unexpected status: %s", resp.Status)
+	}
+	log.Info("notification posted")
+
+	return nil
+}
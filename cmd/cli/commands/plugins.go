@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/plugin"
+)
+
+// openPlugins starts the plugins set with flag --plugin, they are stopped by closePlugins
+func (c *rootCfg) openPlugins() error {
+	for _, path := range c.pluginPaths {
+		client, err := plugin.Start(path)
+		if err != nil {
+			return err
+		}
+		c.plugins = append(c.plugins, client)
+	}
+
+	return nil
+}
+
+// closePlugins stops the plugins, also when the command failed. Plugin which did not stop does not change the result of the command
+func (c *rootCfg) closePlugins() {
+	for _, client := range c.plugins {
+		if err := client.Close(); err != nil && !c.quiet {
+			fmt.Fprintf(os.Stderr, "terradep could not stop plugin: %s\n", err)
+		}
+	}
+	c.plugins = nil
+}
+
+// pluginStaters returns staters of the backends read by the plugins, backends of later plugins override earlier ones
+func (c *rootCfg) pluginStaters() map[string]terradep.Stater {
+	out := make(map[string]terradep.Stater)
+	for _, client := range c.plugins {
+		for _, backend := range client.Backends() {
+			out[backend] = client
+		}
+	}
+
+	return out
+}
+
+// encoderPlugin returns the plugin writing the graph in the format, nil when no plugin supports it
+func (c *rootCfg) encoderPlugin(format string) *plugin.Client {
+	var found *plugin.Client
+	for _, client := range c.plugins {
+		for _, f := range client.Formats() {
+			if strings.EqualFold(f, format) {
+				found = client
+			}
+		}
+	}
+
+	return found
+}
+
+// pluginDecorations returns attributes of the nodes and the edges of the graph provided by the plugins, attributes of later plugins override earlier ones
+func (c *rootCfg) pluginDecorations(graph *terradep.Graph) ([]*plugin.Decorations, error) {
+	var out []*plugin.Decorations
+	for _, client := range c.plugins {
+		if !client.Decorates() {
+			continue
+		}
+		d, err := client.Decorate(graph)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+
+	return out, nil
+}
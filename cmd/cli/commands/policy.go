@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep/policy"
+	"golang.org/x/exp/slog"
+)
+
+type policyCfg struct {
+	*graphCfg
+	policies []string
+	opa      string
+	query    string
+}
+
+func newPolicyCommand(rc *rootCfg) *cobra.Command {
+	c := &policyCfg{graphCfg: &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}}
+	cmd := &cobra.Command{
+		Use:     `policy --dir analyzeMe --policy policies/`,
+		Example: `policy --dir . --policy policies/ --diagnostics github`,
+		Short: "Evaluates Rego policies against the graph of analyzeMe with Open Policy Agent, e.g. that app layer must not depend on states of other app layer deployments. " +
+			"The input of the policies is the graph written by graph --format JSON. Every violation is reported as diagnostic and the command fails with exit code of policy violation",
+		RunE: evaluatePolicies(c),
+	}
+	addScanFlags(cmd, c.scanCfg)
+	f := cmd.Flags()
+	f.StringSliceVar(&c.policies, "policy", nil, "Reads Rego policies and data from the file or directory, can be set many times")
+	f.StringVar(&c.opa, "opa", "opa", "Sets path of the opa executable evaluating the policies, opa "+policy.MinOPAVersion+" or newer is required")
+	f.StringVar(&c.query, "query", policy.DefaultQuery, "Sets the query returning the violations, i.e. set of messages or objects with fields msg and optional path of the deployment")
+	f.BoolVar(&c.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+	markPathFlags(f, "policy")
+	_ = cmd.MarkFlagRequired("policy")
+
+	return cmd
+}
+
+func evaluatePolicies(c *policyCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		// opa is checked before scanning, so the command fails fast
+		if _, err := policy.LookupOPA(c.opa); err != nil {
+			return err
+		}
+
+		graph, err := scanGraph(log, c.graphCfg)
+		if err != nil {
+			return err
+		}
+
+		diags, err := policy.Evaluate(graph, c.policies, policy.WithOPA(c.opa), policy.WithQuery(c.query))
+		if err != nil {
+			return err
+		}
+		if err := c.printDiagnostics(diags); err != nil {
+			return err
+		}
+
+		log.Info("policies evaluated", slog.Int("violations", len(diags)))
+		if len(diags) != 0 {
+			return withExitCode(ExitPolicyViolation, fmt.Errorf("graph violates the policies, violations: %d", len(diags)))
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	publishTimeout = 30 * time.Second
+	// publishLatest is the version overwritten by every publication, so the newest artifact has well known location
+	publishLatest = "latest"
+)
+
+// publishVersionEnvs are environment variables set by CI to the commit, used as the version of the published artifact
+var publishVersionEnvs = []string{"GITHUB_SHA", "CI_COMMIT_SHA", "BUILD_SOURCEVERSION", "GIT_COMMIT"}
+
+// publisher uploads artifacts to remote storage
+type publisher struct {
+	log     *slog.Logger
+	client  *http.Client
+	version string
+	now     func() time.Time
+}
+
+func newPublisher(log *slog.Logger, version string) *publisher {
+	p := &publisher{log: log, client: &http.Client{Timeout: publishTimeout}, version: version, now: time.Now}
+	if p.version == "" {
+		p.version = defaultPublishVersion(p.now())
+	}
+
+	return p
+}
+
+// defaultPublishVersion returns the commit set by CI or the current time
+func defaultPublishVersion(now time.Time) string {
+	for _, env := range publishVersionEnvs {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+
+	return now.UTC().Format("20060102T150405Z")
+}
+
+// publish uploads the artifact twice under the destination: to <version>/<name> and to latest/<name>.
+// Destination is s3://bucket/prefix or http(s)://host/prefix, which receives PUT requests
+func (p *publisher) publish(destination, name string, artifact []byte) error {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return fmt.Errorf("parsing publish destination: %s, %w", destination, err)
+	}
+
+	for _, version := range []string{p.version, publishLatest} {
+		target := *u
+		target.Path = path.Join("/", u.Path, version, name)
+		switch u.Scheme {
+		case "s3":
+			err = p.putS3(target.Host, strings.TrimPrefix(target.Path, "/"), artifact)
+		case "http", "https":
+			err = p.put(&target, artifact)
+		default:
+			return fmt.Errorf("unsupported publish destination: %s, allowed schemes: s3, http, https", destination)
+		}
+		if err != nil {
+			return err
+		}
+		p.log.Info("artifact published", slog.String("scheme", u.Scheme), slog.String("host", target.Host), slog.String("path", target.Path))
+	}
+
+	return nil
+}
+
+// put sends the artifact with PUT request
+func (p *publisher) put(target *url.URL, artifact []byte) error {
+	req, err := http.NewRequest(http.MethodPut, target.String(), bytes.NewReader(artifact))
+	if err != nil {
+		return fmt.Errorf("creating publish request: %s, %w", target.Path, err)
+	}
+	req.Header.Set("Content-Type", contentType(target.Path))
+
+	resp, err := p.client.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// url may contain a secret, e.g. presigned query, so it is not a part of the error
+		err = urlErr.Err
+	}
+	if err != nil {
+		return fmt.Errorf("publishing artifact: %s, %w", target.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("publishing artifact: %s, unexpected status: %s, %s", target.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+func contentType(name string) string {
+	switch path.Ext(name) {
+	case ".dot", ".gv":
+		return "text/vnd.graphviz"
+	case ".json":
+		return "application/json"
+	case ".html":
+		return "text/html; charset=utf-8"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// putS3 uploads the artifact to S3 bucket, see [s3Client]
+func (p *publisher) putS3(bucket, key string, artifact []byte) error {
+	ctx := context.Background()
+	client, err := newS3Client(ctx, publishTimeout)
+	if err != nil {
+		return fmt.Errorf("publishing to s3://%s/%s, %w", bucket, key, err)
+	}
+
+	_, err = client.regional("").PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(artifact),
+		ContentType: aws.String(contentType(key)),
+	})
+	if err != nil {
+		return fmt.Errorf("publishing artifact: s3://%s/%s, %w", bucket, key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/exp/slog"
+)
+
+// s3Request is the request received by the stub of S3
+type s3Request struct {
+	method        string
+	path          string
+	authorization string
+	body          string
+}
+
+// newS3Stub starts the server which records the requests and replies with the status and the body returned by the reply,
+// AWS configuration of the test points to it
+func newS3Stub(t *testing.T, reply func(r *http.Request) (int, string)) func() []s3Request {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		requests []s3Request
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, s3Request{method: r.Method, path: r.URL.Path, authorization: r.Header.Get("Authorization"), body: string(body)})
+		mu.Unlock()
+		status, reply := reply(r)
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, reply)
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+
+	return func() []s3Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]s3Request(nil), requests...)
+	}
+}
+
+func replyStatus(status int) func(r *http.Request) (int, string) {
+	return func(r *http.Request) (int, string) {
+		return status, ""
+	}
+}
+
+func TestPublishS3(t *testing.T) {
+	requests := newS3Stub(t, replyStatus(http.StatusOK))
+
+	p := newPublisher(slog.New(slog.NewTextHandler(io.Discard, nil)), "abc123")
+	if err := p.publish("s3://artifacts/graphs/", "graph dot.dot", []byte("digraph {}")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := requests()
+	want := []string{"/artifacts/graphs/abc123/graph dot.dot", "/artifacts/graphs/latest/graph dot.dot"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d requests, got: %+v", len(want), got)
+	}
+	for i, r := range got {
+		if r.method != http.MethodPut || r.path != want[i] || r.body != "digraph {}" {
+			t.Errorf("unexpected request: %+v, want PUT: %s", r, want[i])
+		}
+		if !strings.HasPrefix(r.authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(r.authorization, "/eu-west-1/s3/aws4_request") {
+			t.Errorf("request is not signed with the credentials of the environment: %s", r.authorization)
+		}
+	}
+}
+
+func TestPublishS3FailsOnErrorStatus(t *testing.T) {
+	newS3Stub(t, replyStatus(http.StatusForbidden))
+
+	p := newPublisher(slog.New(slog.NewTextHandler(io.Discard, nil)), "abc123")
+	err := p.publish("s3://artifacts/graphs", "graph.dot", []byte("digraph {}"))
+	if err == nil || !strings.Contains(err.Error(), "s3://artifacts/graphs/abc123/graph.dot") {
+		t.Errorf("expected error of the object, got: %v", err)
+	}
+}
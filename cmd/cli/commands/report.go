@@ -0,0 +1,28 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"go.interactor.dev/terradep"
+)
+
+// writeScanReport writes summary of the scan followed by every visited directory, like doctor does
+func writeScanReport(w io.Writer, report *terradep.ScanReport) error {
+	fmt.Fprintf(w, "scanned %s in %s: %d directories, %d modules, %d deployments, %d files (%d bytes)\n",
+		report.Root, report.Duration.Round(time.Millisecond), len(report.Dirs), report.Modules, report.Deployments, report.Files, report.Bytes)
+
+	out := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "PATH\tSTATUS\tDURATION\tDETAIL")
+	for _, d := range report.Dirs {
+		duration := ""
+		if d.Duration != 0 {
+			duration = d.Duration.Round(time.Microsecond).String()
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", d.Path, d.Status, duration, d.Detail)
+	}
+
+	return out.Flush()
+}
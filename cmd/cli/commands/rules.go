@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"go.interactor.dev/terradep"
+)
+
+/*
+example:
+
+	[
+	  {
+	    "type": "aws_ssm_parameter",
+	    "attribute": "name",
+	    "pattern": "^/outputs/(?P<deployment>[^/]+)/",
+	    "backend": "s3",
+	    "config": {
+	      "bucket": "tf-state",
+	      "key": "${deployment}/terraform.tfstate"
+	    }
+	  }
+	]
+*/
+type dataSourceRule struct {
+	Type      string          `json:"type"`
+	Attribute string          `json:"attribute"`
+	Pattern   string          `json:"pattern"`
+	Backend   string          `json:"backend"`
+	Config    json.RawMessage `json:"config"`
+}
+
+// readDataSourceRules reads JSON file with the list of data source rules, see [terradep.DataSourceRule]
+func readDataSourceRules(path string) ([]terradep.DataSourceRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading data source rules file: %s, %w", path, err)
+	}
+
+	var rules []dataSourceRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("decoding data source rules file: %s, %w", path, err)
+	}
+
+	out := make([]terradep.DataSourceRule, 0, len(rules))
+	for i, rule := range rules {
+		parsed, err := rule.parse()
+		if err != nil {
+			return nil, fmt.Errorf("data source rule: %d in file: %s, %w", i, path, err)
+		}
+		out = append(out, parsed)
+	}
+
+	return out, nil
+}
+
+func (r dataSourceRule) parse() (terradep.DataSourceRule, error) {
+	if r.Type == "" || r.Attribute == "" || r.Backend == "" {
+		return terradep.DataSourceRule{}, fmt.Errorf("type, attribute and backend are required")
+	}
+
+	pattern, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return terradep.DataSourceRule{}, fmt.Errorf("compiling pattern: %w", err)
+	}
+
+	ty, err := ctyjson.ImpliedType(r.Config)
+	if err != nil {
+		return terradep.DataSourceRule{}, fmt.Errorf("reading config type: %w", err)
+	}
+
+	cfg, err := ctyjson.Unmarshal(r.Config, ty)
+	if err != nil {
+		return terradep.DataSourceRule{}, fmt.Errorf("decoding config: %w", err)
+	}
+
+	if !cfg.Type().IsObjectType() {
+		return terradep.DataSourceRule{}, fmt.Errorf("config must be an object")
+	}
+
+	return terradep.DataSourceRule{
+		Type:      r.Type,
+		Attribute: r.Attribute,
+		Pattern:   pattern,
+		Backend:   r.Backend,
+		Config:    cfg.AsValueMap(),
+	}, nil
+}
+
+/*
+example:
+
+	[
+	  {"name": "network", "paths": ["network/**"]},
+	  {"name": "platform", "paths": ["platform/**"], "dependsOn": ["network"]},
+	  {"name": "apps", "paths": ["apps/**"], "dependsOn": ["platform", "network"]}
+	]
+*/
+
+// readLayerRules reads JSON file with the list of layer rules, see [terradep.LayerRule]
+func readLayerRules(path string) ([]terradep.LayerRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading layer rules file: %s, %w", path, err)
+	}
+
+	var rules []terradep.LayerRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("decoding layer rules file: %s, %w", path, err)
+	}
+	if err := terradep.ValidateLayerRules(rules); err != nil {
+		return nil, fmt.Errorf("layer rules file: %s, %w", path, err)
+	}
+
+	return rules, nil
+}
+
+/*
+example:
+
+	[
+	  {
+	    "path": "^(?P<env>[^/]+)/(?P<deployment>[^/]+)$",
+	    "backend": "s3",
+	    "fields": {
+	      "bucket": "tf-state-${env}",
+	      "key": "${env}/${deployment}/terraform.tfstate"
+	    }
+	  }
+	]
+*/
+type stateKeyRule struct {
+	Path    string            `json:"path"`
+	Backend string            `json:"backend"`
+	Fields  map[string]string `json:"fields"`
+}
+
+// readStateKeyRules reads JSON file with the list of state key rules, see [terradep.StateKeyRule]
+func readStateKeyRules(path string) ([]terradep.StateKeyRule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading state key rules file: %s, %w", path, err)
+	}
+
+	var rules []stateKeyRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("decoding state key rules file: %s, %w", path, err)
+	}
+
+	out := make([]terradep.StateKeyRule, 0, len(rules))
+	for i, rule := range rules {
+		if len(rule.Fields) == 0 {
+			return nil, fmt.Errorf("state key rule: %d in file: %s, fields are required", i, path)
+		}
+		pattern, err := regexp.Compile(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("state key rule: %d in file: %s, compiling path: %w", i, path, err)
+		}
+		out = append(out, terradep.StateKeyRule{Path: pattern, Backend: rule.Backend, Fields: rule.Fields})
+	}
+
+	return out, nil
+}
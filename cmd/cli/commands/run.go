@@ -0,0 +1,446 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/internal/perm"
+	"golang.org/x/exp/slog"
+)
+
+// environment variables set for the command run in the deployment
+const (
+	runPathEnv    = "TERRADEP_PATH"
+	runOverlayEnv = "TERRADEP_OVERLAY"
+)
+
+type runCfg struct {
+	*graphCfg
+	parallel        int
+	continueOnError bool
+	logDir          string
+	// templates are commands set with --cmd, rendered for every deployment
+	templates []string
+	// env are variables set with --env in format glob=NAME=value
+	env []string
+}
+
+func newRunCommand(rc *rootCfg) *cobra.Command {
+	c := &runCfg{graphCfg: &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}}
+	runCmd := &cobra.Command{
+		Use:     `run [--parallel N] [--continue-on-error] --dir analyzeMe (-- command [args...] | --cmd template)`,
+		Example: `run --dir analyzeMe --parallel 4 --cmd 'terraform -chdir={{.Path}} plan -out={{.Name}}.plan'`,
+		Short: "Runs the command in the directory of every deployment found in analyzeMe, after the command succeeded in all the deployments it depends on. " +
+			"Workspace of the deployment is passed in environment variable " + tfWorkspaceEnv + ", its path in " + runPathEnv + " and overlay in " + runOverlayEnv + ". " +
+			"With --dry-run only prints the deployments in the order they would be run",
+		RunE: runInDeployments(c),
+	}
+	addScanFlags(runCmd, c.scanCfg)
+	rF := runCmd.Flags()
+	rF.IntVar(&c.parallel, "parallel", 1, "Sets how many commands run at the same time")
+	rF.BoolVar(&c.continueOnError, "continue-on-error", false, "Keeps running the command in deployments which do not depend on the failed one. By default no new commands are started after the first failure")
+	rF.StringVar(&c.logDir, "log-dir", "", "Writes output of the command in every deployment to its own file in the directory. If not set output is written to standard output, every line prefixed with path of the deployment")
+	markDirFlags(rF, "log-dir")
+	rF.StringArrayVar(&c.templates, "cmd", nil, "Sets the command run with the shell in every deployment instead of the command passed after --. "+
+		"It is a Go template with fields of the deployment: "+runTemplateFields+". Can be set many times, commands are run one by one until one of them fails")
+	addEnvFlag(runCmd, c)
+
+	return runCmd
+}
+
+// runStatus is the result of running the command in the deployment
+type runStatus string
+
+const (
+	runPending   runStatus = "not started"
+	runSucceeded runStatus = "succeeded"
+	runFailed    runStatus = "failed"
+	runSkipped   runStatus = "skipped, dependency failed"
+)
+
+// runTask runs the command in single deployment
+type runTask struct {
+	node       *terradep.Node
+	dependents []*runTask
+	// waiting is the number of dependencies which did not succeed yet
+	waiting  int
+	status   runStatus
+	duration time.Duration
+	err      error
+	// summary is the last line of the output matching [runner.summary]
+	summary string
+}
+
+func runInDeployments(c *runCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		if c.relativePaths {
+			return fmt.Errorf("flag --relative-paths is not supported by run, commands are run in the directories of the deployments")
+		}
+
+		r := &runner{cfg: c, log: log, out: cmd.OutOrStdout()}
+		switch {
+		case len(args) != 0 && len(c.templates) != 0:
+			return fmt.Errorf("flag --cmd cannot be used together with the command passed after --")
+		case len(args) != 0:
+			r.commands = [][]string{args}
+		case len(c.templates) != 0:
+			if r.templates, err = parseCommandTemplates(c.templates); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("no command to run, pass it after -- or set flag --cmd")
+		}
+		if r.env, err = parseRunEnv(c.env); err != nil {
+			return err
+		}
+
+		graph, err := scanGraph(log, c.graphCfg)
+		if err != nil {
+			return err
+		}
+
+		return r.execute(cmd, runTasks(graph, nil))
+	}
+}
+
+// execute runs the tasks and prints the summary to standard error. With --dry-run only prints the order of the tasks
+func (r *runner) execute(cmd *cobra.Command, tasks []*runTask) error {
+	if r.cfg.dryRun {
+		return printRunOrder(cmd.OutOrStdout(), tasks)
+	}
+
+	if r.cfg.logDir != "" {
+		if err := os.MkdirAll(r.cfg.logDir, perm.UserRWX); err != nil {
+			return fmt.Errorf("creating log directory: %s, %w", r.cfg.logDir, err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	r.run(ctx, tasks)
+
+	summary := tabwriter.NewWriter(cmd.ErrOrStderr(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(summary, "PATH\tWORKSPACE\tOVERLAY\tSTATUS\tDURATION\tSUMMARY")
+	failed := 0
+	for _, t := range tasks {
+		if t.status != runSucceeded {
+			failed++
+		}
+		fmt.Fprintf(summary, "%s\t%s\t%s\t%s\t%s\t%s\n", t.node.Path, t.node.Workspace, t.node.Overlay, t.status, t.duration.Round(time.Millisecond), t.summary)
+	}
+	if err := summary.Flush(); err != nil {
+		return err
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("command did not succeed in %d of %d deployments", failed, len(tasks))
+	}
+
+	return nil
+}
+
+// runTasks returns tasks of the deployments, batch by batch returned by [terradep.Levels], so deployments closer to the roots of the graph start first.
+// When selected is not nil, only selected deployments are run and dependencies on not selected ones are ignored
+func runTasks(graph *terradep.Graph, selected map[*terradep.Node]struct{}) []*runTask {
+	var opts []terradep.LevelsOpt
+	if selected != nil {
+		nodes := make([]*terradep.Node, 0, len(selected))
+		for n := range selected {
+			nodes = append(nodes, n)
+		}
+		opts = append(opts, terradep.WithLevelsOnly(nodes...))
+	}
+
+	var tasks []*runTask
+	byNode := make(map[*terradep.Node]*runTask)
+	batches, _ := terradep.Levels(graph, opts...)
+	for _, batch := range batches {
+		for _, n := range batch {
+			t := &runTask{node: n, status: runPending}
+			tasks = append(tasks, t)
+			byNode[n] = t
+		}
+	}
+
+	for _, t := range tasks {
+		for _, child := range t.node.Children {
+			// external states and not selected deployments are not run, so they are never waited for
+			if dep, ok := byNode[child]; ok {
+				dep.dependents = append(dep.dependents, t)
+				t.waiting++
+			}
+		}
+	}
+
+	return tasks
+}
+
+// printRunOrder prints the deployments in the order they would be run with --parallel 1
+func printRunOrder(w io.Writer, tasks []*runTask) error {
+	waiting := make(map[*runTask]int, len(tasks))
+	for _, t := range tasks {
+		waiting[t] = t.waiting
+	}
+
+	for done := 0; done < len(tasks); {
+		for _, t := range tasks {
+			if waiting[t] != 0 {
+				continue
+			}
+			waiting[t] = -1
+			done++
+			if _, err := fmt.Fprintln(w, runLabel(t.node)); err != nil {
+				return fmt.Errorf("writing run order: %w", err)
+			}
+			for _, dependent := range t.dependents {
+				waiting[dependent]--
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+// runner runs the tasks in dependency order
+type runner struct {
+	cfg *runCfg
+	log *slog.Logger
+	// commands are run one by one in every deployment, until one of them fails
+	commands [][]string
+	// templates are rendered for every deployment and run with the shell after the commands
+	templates []*template.Template
+	// env are variables set for the deployments matching their globs
+	env []runEnv
+	// summary matches lines of the output summarizing the result of the commands, nil when the output is not summarized
+	summary *regexp.Regexp
+	// out is the destination of output of the commands when it is not written to the log directory
+	out   io.Writer
+	outMu sync.Mutex
+}
+
+func (r *runner) run(ctx context.Context, tasks []*runTask) {
+	parallel := r.cfg.parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	done := make(chan *runTask)
+	running := 0
+	stopped := false
+	started := make(map[*runTask]struct{}, len(tasks))
+	for {
+		for _, t := range tasks {
+			if stopped || running == parallel {
+				break
+			}
+			if _, ok := started[t]; ok || t.waiting != 0 || t.status != runPending {
+				continue
+			}
+			started[t] = struct{}{}
+			running++
+			go func(t *runTask) {
+				start := time.Now()
+				t.err = r.runTask(ctx, t)
+				t.duration = time.Since(start)
+				done <- t
+			}(t)
+		}
+
+		if running == 0 {
+			return
+		}
+
+		t := <-done
+		running--
+		if t.err != nil {
+			t.status = runFailed
+			r.log.Error("command failed", slog.String("path", t.node.Path), slog.String("workspace", t.node.Workspace), slog.String("error", t.err.Error()))
+			if !r.cfg.continueOnError || ctx.Err() != nil {
+				stopped = true
+			}
+			skipDependents(t)
+			continue
+		}
+
+		t.status = runSucceeded
+		r.log.Info("command succeeded", slog.String("path", t.node.Path), slog.String("workspace", t.node.Workspace), slog.Duration("duration", t.duration))
+		for _, dependent := range t.dependents {
+			dependent.waiting--
+		}
+	}
+}
+
+// skipDependents marks all the tasks depending on the failed one as skipped
+func skipDependents(t *runTask) {
+	for _, dependent := range t.dependents {
+		if dependent.status == runPending {
+			dependent.status = runSkipped
+			skipDependents(dependent)
+		}
+	}
+}
+
+func (r *runner) runTask(ctx context.Context, t *runTask) error {
+	var out io.Writer
+	if r.cfg.logDir != "" {
+		path := filepath.Join(r.cfg.logDir, logFileName(t.node))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, perm.UserRW)
+		if err != nil {
+			return fmt.Errorf("creating log file: %s, %w", path, err)
+		}
+		defer file.Close()
+		out = file
+	} else {
+		prefixed := &prefixWriter{prefix: "[" + runLabel(t.node) + "] ", dst: r.out, mu: &r.outMu}
+		defer prefixed.flush()
+		out = prefixed
+	}
+
+	if r.summary != nil {
+		summary := &summaryWriter{re: r.summary}
+		defer func() {
+			summary.flush()
+			t.summary = summary.last
+		}()
+		out = io.MultiWriter(out, summary)
+	}
+
+	data, err := r.commandData(t.node)
+	if err != nil {
+		return err
+	}
+	commands, err := r.nodeCommands(data)
+	if err != nil {
+		return err
+	}
+
+	for _, args := range commands {
+		r.log.Info("running command", slog.String("path", t.node.Path), slog.String("workspace", t.node.Workspace), slog.Any("command", args))
+
+		command := exec.CommandContext(ctx, args[0], args[1:]...)
+		command.Dir = t.node.Path
+		command.Env = append(os.Environ(), runPathEnv+"="+t.node.Path, runOverlayEnv+"="+t.node.Overlay)
+		if t.node.Workspace != "" {
+			command.Env = append(command.Env, tfWorkspaceEnv+"="+t.node.Workspace)
+		}
+		for _, name := range sortedNames(data.Env) {
+			command.Env = append(command.Env, name+"="+data.Env[name])
+		}
+		command.Stdout, command.Stderr = out, out
+
+		if err := command.Run(); err != nil {
+			return fmt.Errorf("running command: %v, %w", args, err)
+		}
+	}
+
+	return nil
+}
+
+// runLabel identifies the deployment in the output
+func runLabel(n *terradep.Node) string {
+	label := n.Path
+	if n.Workspace != "" {
+		label += ":" + n.Workspace
+	}
+	if n.Overlay != "" {
+		label += "#" + n.Overlay
+	}
+
+	return label
+}
+
+// logFileName returns name of the log file unique for every deployment
+func logFileName(n *terradep.Node) string {
+	replacer := strings.NewReplacer(string(filepath.Separator), "_", "/", "_", ":", "_", "#", "_")
+	return strings.TrimLeft(replacer.Replace(filepath.Clean(runLabel(n))), "_") + ".log"
+}
+
+// prefixWriter writes lines prefixed with the prefix, so output of commands running at the same time is not interleaved within a line
+type prefixWriter struct {
+	prefix string
+	dst    io.Writer
+	mu     *sync.Mutex
+	buf    bytes.Buffer
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.buf.Next(i + 1)); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// flush writes the last line, which did not end with new line
+func (w *prefixWriter) flush() {
+	if w.buf.Len() != 0 {
+		_ = w.writeLine(append(w.buf.Bytes(), '\n'))
+		w.buf.Reset()
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := io.WriteString(w.dst, w.prefix); err != nil {
+		return err
+	}
+	_, err := w.dst.Write(line)
+	return err
+}
+
+// summaryWriter remembers the last line matching the regular expression
+type summaryWriter struct {
+	re   *regexp.Regexp
+	buf  bytes.Buffer
+	last string
+}
+
+func (w *summaryWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.match(w.buf.Next(i + 1))
+	}
+}
+
+// flush matches the last line, which did not end with new line
+func (w *summaryWriter) flush() {
+	w.match(w.buf.Bytes())
+	w.buf.Reset()
+}
+
+func (w *summaryWriter) match(line []byte) {
+	if trimmed := strings.TrimSpace(string(line)); w.re.MatchString(trimmed) {
+		w.last = trimmed
+	}
+}
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+)
+
+// runTemplateFields lists fields of [runCommandData] in help of the flags
+const runTemplateFields = "{{.Path}}, {{.Name}}, {{.Workspace}}, {{.Overlay}}, {{.State}}, {{.Owner}}, {{.Layer}}, {{.Tags}} and {{.Env.NAME}}"
+
+// runCommandData is the deployment passed to templates of the commands set with --cmd and values of variables set with --env
+type runCommandData struct {
+	Path      string
+	Workspace string
+	Overlay   string
+	// Name is the name of the directory of the deployment followed by the workspace and the overlay, e.g. app_prod, usable in names of files
+	Name  string
+	State string
+	// Owner, Layer and Tags are read from the manifest of the deployment
+	Owner string
+	Layer string
+	Tags  []string
+	// Env contains variables set for the deployment with --env, it is empty when values of the variables are rendered
+	Env map[string]string
+}
+
+// runEnv is the environment variable set for the deployments matching the glob
+type runEnv struct {
+	glob  string
+	name  string
+	value *template.Template
+}
+
+// addEnvFlag registers flag --env setting environment variables of the deployments, which can be set also in the configuration file
+func addEnvFlag(cmd *cobra.Command, c *runCfg) {
+	cmd.Flags().StringArrayVar(&c.env, "env", nil, "Sets environment variable for the deployments which path relative to scanned directory matches the glob, in format glob=NAME=value, "+
+		"e.g. 'live/legacy/**=TF_CLI_ARGS=-lock=false'. Value is a Go template like --cmd. Can be set many times, the last matching value wins. "+
+		"Usually set in the configuration file, so deployments driven by different tools, e.g. terragrunt and terraform, are described once")
+}
+
+// parseCommandTemplates parses templates of the commands set with --cmd
+func parseCommandTemplates(commands []string) ([]*template.Template, error) {
+	out := make([]*template.Template, 0, len(commands))
+	for i, command := range commands {
+		tmpl, err := template.New(fmt.Sprintf("command %d", i)).Option("missingkey=zero").Parse(command)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template of the command: %q, %w", command, err)
+		}
+		out = append(out, tmpl)
+	}
+
+	return out, nil
+}
+
+// parseRunEnv parses variables set with --env
+func parseRunEnv(values []string) ([]runEnv, error) {
+	out := make([]runEnv, 0, len(values))
+	for _, value := range values {
+		glob, variable, ok := strings.Cut(value, "=")
+		name, tmpl, hasValue := strings.Cut(variable, "=")
+		if !ok || !hasValue || glob == "" || name == "" {
+			return nil, fmt.Errorf("invalid environment variable: %q, expected format glob=NAME=value", value)
+		}
+		if !doublestar.ValidatePattern(glob) {
+			return nil, fmt.Errorf("invalid glob pattern of environment variable: %q", value)
+		}
+
+		parsed, err := template.New(name).Option("missingkey=zero").Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template of environment variable: %q, %w", value, err)
+		}
+		out = append(out, runEnv{glob: glob, name: name, value: parsed})
+	}
+
+	return out, nil
+}
+
+// commandData returns the deployment passed to the templates, with environment variables matching the deployment rendered
+func (r *runner) commandData(n *terradep.Node) (runCommandData, error) {
+	data := runCommandData{
+		Path:      n.Path,
+		Workspace: n.Workspace,
+		Overlay:   n.Overlay,
+		Name:      runName(n),
+		State:     n.State.String(),
+		Owner:     n.Owner,
+		Layer:     n.Layer,
+		Tags:      n.Tags,
+		Env:       map[string]string{},
+	}
+
+	env := make(map[string]string)
+	for _, e := range r.env {
+		if !r.cfg.matchesDir(e.glob, n.Path) {
+			continue
+		}
+		value, err := renderTemplate(e.value, data)
+		if err != nil {
+			return data, fmt.Errorf("rendering environment variable: %s of deployment: %s, %w", e.name, runLabel(n), err)
+		}
+		env[e.name] = value
+	}
+	data.Env = env
+
+	return data, nil
+}
+
+// nodeCommands returns the commands run in the deployment, templates are run with the shell
+func (r *runner) nodeCommands(data runCommandData) ([][]string, error) {
+	commands := append([][]string(nil), r.commands...)
+	for _, tmpl := range r.templates {
+		line, err := renderTemplate(tmpl, data)
+		if err != nil {
+			return nil, fmt.Errorf("rendering %s of deployment: %s, %w", tmpl.Name(), data.Path, err)
+		}
+		commands = append(commands, shellCommand(line))
+	}
+
+	return commands, nil
+}
+
+func renderTemplate(tmpl *template.Template, data runCommandData) (string, error) {
+	sb := strings.Builder{}
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// runName returns the name of the directory of the deployment followed by the workspace and the overlay
+func runName(n *terradep.Node) string {
+	name := filepath.Base(n.Path)
+	for _, suffix := range []string{n.Workspace, n.Overlay} {
+		if suffix != "" {
+			name += "_" + suffix
+		}
+	}
+
+	return name
+}
+
+// shellCommand returns arguments running the command line with the shell of the operating system
+func shellCommand(line string) []string {
+	if runtime.GOOS == "windows" {
+		return []string{"cmd", "/C", line}
+	}
+
+	return []string{"sh", "-c", line}
+}
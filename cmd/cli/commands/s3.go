@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// s3Client sends requests to S3 with the region and the credentials resolved like by AWS CLI, e.g. from environment variables,
+// shared config and credentials files with AWS_PROFILE, SSO or the role of the instance.
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL change the endpoint of S3, e.g. to MinIO, which is then addressed with path style,
+// AWS_ENDPOINT_URL_STS or AWS_ENDPOINT_URL change the endpoint of STS used to assume the roles
+type s3Client struct {
+	cfg         aws.Config
+	client      *awshttp.BuildableClient
+	endpoint    string
+	stsEndpoint string
+
+	mu sync.Mutex
+	// configs are the configurations of the credentials of the states, see [s3Client.credentials]
+	configs map[s3Credentials]aws.Config
+}
+
+// s3Credentials are the credentials of the state set with profile and role_arn of S3 backend or terraform_remote_state
+type s3Credentials struct {
+	profile string
+	roleARN string
+}
+
+// newS3Client loads the default AWS configuration, requests time out after the timeout
+func newS3Client(ctx context.Context, timeout time.Duration) (*s3Client, error) {
+	// buildable client keeps AWS_CA_BUNDLE working, the configuration adds the bundle to its transport
+	client := awshttp.NewBuildableClient().WithTimeout(timeout)
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	return &s3Client{
+		cfg:         cfg,
+		client:      client,
+		endpoint:    firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		stsEndpoint: firstEnv("AWS_ENDPOINT_URL_STS", "AWS_ENDPOINT_URL"),
+		configs:     make(map[s3Credentials]aws.Config),
+	}, nil
+}
+
+// regional returns client of S3 in the region with the default credentials, the region of the configuration is used when it is empty
+func (c *s3Client) regional(region string) *s3.Client {
+	return c.s3(c.cfg, region)
+}
+
+// forState returns client of S3 in the region with the credentials of the state: the profile is loaded from shared configuration
+// and the role is assumed with the credentials of the profile, or the default credentials when the profile is not set, like by Terraform
+func (c *s3Client) forState(ctx context.Context, region, profile, roleARN string) (*s3.Client, error) {
+	cfg, err := c.credentials(ctx, s3Credentials{profile: profile, roleARN: roleARN})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.s3(cfg, region), nil
+}
+
+// credentials returns the configuration with the credentials, configurations are loaded once and reused by the states sharing the credentials
+func (c *s3Client) credentials(ctx context.Context, creds s3Credentials) (aws.Config, error) {
+	if creds == (s3Credentials{}) {
+		return c.cfg, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cfg, ok := c.configs[creds]; ok {
+		return cfg, nil
+	}
+
+	cfg := c.cfg.Copy()
+	if creds.profile != "" {
+		var err error
+		cfg, err = config.LoadDefaultConfig(ctx, config.WithHTTPClient(c.client), config.WithSharedConfigProfile(creds.profile))
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("loading AWS profile: %s, %w", creds.profile, err)
+		}
+		if cfg.Region == "" {
+			cfg.Region = c.cfg.Region
+		}
+	}
+	if creds.roleARN != "" {
+		client := sts.NewFromConfig(cfg, func(o *sts.Options) {
+			if c.stsEndpoint != "" {
+				o.EndpointResolver = sts.EndpointResolverFromURL(c.stsEndpoint)
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, creds.roleARN))
+	}
+	c.configs[creds] = cfg
+
+	return cfg, nil
+}
+
+func (c *s3Client) s3(cfg aws.Config, region string) *s3.Client {
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if region != "" {
+			o.Region = region
+		}
+		if c.endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(c.endpoint)
+			o.UsePathStyle = true
+		}
+	})
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
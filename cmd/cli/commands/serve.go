@@ -0,0 +1,584 @@
+package commands
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"golang.org/x/exp/slog"
+)
+
+//go:embed serve.html
+var viewerHTML []byte
+
+//go:embed serve.openapi.json
+var openAPISpec []byte
+
+const shutdownTimeout = 5 * time.Second
+
+// watchInterval is the interval of checking the files for changes, when the directories are watched
+const watchInterval = time.Second
+
+// graphUpdated is pushed to the viewers through /events after the graph is replaced
+var graphUpdated = []byte(`{"type":"graph"}`)
+
+type serveCfg struct {
+	*graphCfg
+	listen string
+	rescan time.Duration
+	watch  bool
+}
+
+func newServeCommand(rc *rootCfg) *cobra.Command {
+	sc := &serveCfg{graphCfg: &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}}
+	serveCmd := &cobra.Command{
+		Use:     `serve [--listen 127.0.0.1:8080] --dir analyzeMe`,
+		Example: `serve --dir analyzeMe --listen :8080 --rescan 5m`,
+		Short: "Serves interactive viewer of the graph of analyzeMe and JSON API described by OpenAPI document /openapi.json, so deploy bots and dashboards can query the graph: /graph returns all the nodes, " +
+			"/node/{id} single node with id derived from its state, which is the same after every scan, /affected?path=modules/vpc nodes affected by the change of the path or /affected?id={id} by the change of the node, " +
+			"/ancestors?id={id} nodes the node depends on, directly or transitively, /order batches of deployments in order of apply, limited to the deployments affected by the change of the paths set with query parameter path, " +
+			"and /search?q=network nodes with id, path, state, owner, layer or tag containing the text. Query parameters path and id can be repeated. " +
+			"Endpoints returning many nodes return only nodes of the team set with query parameter team, e.g. team=@org/network, and of the environment set with query parameter env, see --env-pattern. " +
+			"Websocket /events receives message {\"type\":\"graph\"} after every rescan, see --rescan and --watch",
+		RunE: serveGraph(sc),
+	}
+	addScanFlags(serveCmd, sc.scanCfg)
+	sF := serveCmd.Flags()
+	sF.StringVar(&sc.listen, "listen", "127.0.0.1:8080", "Sets address the server listens on")
+	sF.DurationVar(&sc.rescan, "rescan", 0, "Scans the directories again in the interval, so served graph follows changes in the code. Non-positive value means the graph is scanned only at start")
+	sF.BoolVar(&sc.watch, "watch", false, "Watches the directories and scans them again as soon as any file changes, so the server can run as a daemon next to the code being edited. Open viewers are updated through websocket /events")
+	sF.BoolVar(&sc.git, "git-metadata", false, "Reads last commit and top contributors of every deployment from git history")
+	addEnvPatternFlag(serveCmd, sc.scanCfg)
+	sF.BoolVar(&sc.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+
+	return serveCmd
+}
+
+func serveGraph(c *serveCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		envs, err := c.environmentPatterns()
+		if err != nil {
+			return err
+		}
+
+		graph, err := scanGraph(log, c.graphCfg)
+		if err != nil {
+			return err
+		}
+		srv := &graphServer{log: log, envs: envs}
+		srv.set(graph)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		if c.rescan > 0 {
+			go srv.rescan(ctx, c.graphCfg, c.rescan)
+		}
+		if c.watch {
+			go srv.watch(ctx, c.graphCfg)
+		}
+
+		httpSrv := &http.Server{Addr: c.listen, Handler: srv.handler(), ReadHeaderTimeout: 10 * time.Second}
+		// websocket connections are hijacked, so they are not closed by Shutdown
+		httpSrv.RegisterOnShutdown(srv.closeWatchers)
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+				log.Error("shutting down the server", slog.String("error", err.Error()))
+			}
+		}()
+
+		log.Info("serving the graph", slog.String("address", c.listen))
+		if c.dryRun {
+			return nil
+		}
+		if err := httpSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("serving the graph: %s, %w", c.listen, err)
+		}
+
+		return nil
+	}
+}
+
+// graphServer serves the latest scanned graph
+type graphServer struct {
+	log *slog.Logger
+	// envs extract the environment of the nodes, see [terradep.StateEnvironment]
+	envs []*regexp.Regexp
+
+	// mu guards replacing the graph after rescan, the scanned graph is read-only, so it is safe to read it concurrently
+	mu    sync.RWMutex
+	graph *terradep.Graph
+	nodes []nodeView
+	byID  map[string]nodeView
+
+	// watchersMu guards watchers, which are notified after the graph is replaced, one per websocket connection
+	watchersMu sync.Mutex
+	watchers   map[chan struct{}]struct{}
+	// closed is set on shutdown, the channels of the watchers are closed then
+	closed bool
+}
+
+// nodeView is [terradep.Node] returned by the API. Nodes are linked by ids, see [terradep.Node.ID]
+type nodeView struct {
+	ID string `json:"id"`
+	// Kind is one of: deployment, external or module
+	Kind      string `json:"kind"`
+	Path      string `json:"path"`
+	Workspace string `json:"workspace,omitempty"`
+	Overlay   string `json:"overlay,omitempty"`
+	// Environment is extracted from the state with flag --env-pattern
+	Environment string   `json:"environment,omitempty"`
+	State       string   `json:"state"`
+	Owner       string   `json:"owner,omitempty"`
+	CodeOwners  []string `json:"codeOwners,omitempty"`
+	// Team is the owner or the first of code owners, see [terradep.Node.Team]
+	Team         string                   `json:"team,omitempty"`
+	Layer        string                   `json:"layer,omitempty"`
+	Tags         []string                 `json:"tags,omitempty"`
+	Dependencies []string                 `json:"dependencies"`
+	Modules      []string                 `json:"modules"`
+	Dependents   []string                 `json:"dependents"`
+	Metadata     *terradep.ModuleMetadata `json:"metadata,omitempty"`
+	Git          *terradep.GitInfo        `json:"git,omitempty"`
+
+	node *terradep.Node
+}
+
+func (s *graphServer) set(graph *terradep.Graph) {
+	nodes := graph.Nodes()
+	views := make([]nodeView, 0, len(nodes))
+	byID := make(map[string]nodeView, len(nodes))
+	for _, n := range nodes {
+		v := nodeView{
+			ID:           n.ID(),
+			Kind:         nodeKind(graph, n),
+			Path:         n.Path,
+			Workspace:    n.Workspace,
+			Overlay:      n.Overlay,
+			Environment:  terradep.StateEnvironment(n.State, s.envs...),
+			State:        n.State.String(),
+			Owner:        n.Owner,
+			CodeOwners:   n.CodeOwners,
+			Team:         n.Team(),
+			Layer:        n.Layer,
+			Tags:         n.Tags,
+			Dependencies: nodeIDs(n.Children),
+			Modules:      nodeIDs(n.Modules),
+			Dependents:   nodeIDs(graph.Dependents(n)),
+			Metadata:     n.Metadata,
+			Git:          n.Git,
+			node:         n,
+		}
+		views = append(views, v)
+		byID[v.ID] = v
+	}
+
+	s.mu.Lock()
+	s.graph, s.nodes, s.byID = graph, views, byID
+	s.mu.Unlock()
+
+	s.notifyWatchers()
+}
+
+// subscribe returns the channel notified after the graph is replaced and closed on shutdown, and the function to unsubscribe
+func (s *graphServer) subscribe() (<-chan struct{}, func()) {
+	s.watchersMu.Lock()
+	defer s.watchersMu.Unlock()
+	// single pending notification is enough, the latest graph is read after it
+	ch := make(chan struct{}, 1)
+	if s.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if s.watchers == nil {
+		s.watchers = make(map[chan struct{}]struct{})
+	}
+	s.watchers[ch] = struct{}{}
+
+	return ch, func() {
+		s.watchersMu.Lock()
+		defer s.watchersMu.Unlock()
+		delete(s.watchers, ch)
+	}
+}
+
+func (s *graphServer) notifyWatchers() {
+	s.watchersMu.Lock()
+	defer s.watchersMu.Unlock()
+	for ch := range s.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *graphServer) closeWatchers() {
+	s.watchersMu.Lock()
+	defer s.watchersMu.Unlock()
+	for ch := range s.watchers {
+		close(ch)
+	}
+	s.watchers, s.closed = nil, true
+}
+
+func nodeKind(graph *terradep.Graph, n *terradep.Node) string {
+	if _, ok := n.State.(terradep.LocalModule); ok {
+		return "module"
+	}
+	if graph.IsDeployment(n) {
+		return "deployment"
+	}
+
+	return "external"
+}
+
+func nodeIDs(nodes []*terradep.Node) []string {
+	out := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, n.ID())
+	}
+
+	return out
+}
+
+// rescan scans the directories in the interval until the context is done. Failed scan keeps the previous graph
+func (s *graphServer) rescan(ctx context.Context, c *graphCfg, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s.scan(c)
+	}
+}
+
+// watch scans the directories again after any file changes, until the context is done. Files are checked in [watchInterval]
+func (s *graphServer) watch(ctx context.Context, c *graphCfg) {
+	fingerprint, err := fingerprintDirs(c.dirs)
+	if err != nil {
+		s.log.Error("watching the directories", slog.String("error", err.Error()))
+	}
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := fingerprintDirs(c.dirs)
+		if err != nil {
+			s.log.Error("watching the directories", slog.String("error", err.Error()))
+			continue
+		}
+		if current == fingerprint {
+			continue
+		}
+		fingerprint = current
+		s.log.Debug("files changed, rescanning the graph")
+		s.scan(c)
+	}
+}
+
+// scan replaces the graph with the scanned one. Failed scan keeps the previous graph
+func (s *graphServer) scan(c *graphCfg) {
+	graph, err := scanGraph(s.log, c)
+	if err != nil {
+		s.log.Error("rescanning the graph, serving the previous one", slog.String("error", err.Error()))
+		return
+	}
+	s.set(graph)
+	s.log.Info("graph rescanned")
+}
+
+// events pushes [graphUpdated] to the websocket connection after the graph is replaced, until the client or the server closes it
+func (s *graphServer) events(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		s.log.Debug("upgrading to websocket", slog.String("error", err.Error()))
+		return
+	}
+	defer ws.Close()
+
+	updated, unsubscribe := s.subscribe()
+	defer unsubscribe()
+
+	// the client only pings the server and closes the connection
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			opcode, payload, err := ws.readFrame()
+			if err != nil {
+				return
+			}
+			switch opcode {
+			case wsClose:
+				_ = ws.writeFrame(wsClose, payload)
+				return
+			case wsPing:
+				if err := ws.writeFrame(wsPong, payload); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case _, ok := <-updated:
+			if !ok {
+				// status 1001: the server is going away
+				_ = ws.writeFrame(wsClose, []byte{0x03, 0xe9})
+				return
+			}
+			if err := ws.writeFrame(wsText, graphUpdated); err != nil {
+				s.log.Debug("pushing to websocket", slog.String("error", err.Error()))
+				return
+			}
+		}
+	}
+}
+
+func (s *graphServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(viewerHTML)
+	})
+	mux.HandleFunc("/graph", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		s.writeJSON(w, http.StatusOK, map[string]any{"nodes": queryNodes(s.nodes, r.URL.Query())})
+	})
+	nodeHandler := func(w http.ResponseWriter, r *http.Request) {
+		// the id is a state, which contains slashes, so it is read from escaped path
+		id, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/node/"))
+		if err != nil {
+			s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		node, ok := s.byID[id]
+		if !ok {
+			s.writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found: " + id})
+			return
+		}
+		s.writeJSON(w, http.StatusOK, node)
+	}
+	mux.HandleFunc("/events", s.events)
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(openAPISpec)
+	})
+	mux.HandleFunc("/affected", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		paths, ids := query["path"], query["id"]
+		if len(paths) == 0 && len(ids) == 0 {
+			s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "query parameter path or id is required"})
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		from, ok := s.nodesOf(w, ids)
+		if !ok {
+			return
+		}
+		affected := make(map[string]struct{})
+		for _, n := range s.graph.Affected(paths...) {
+			affected[n.ID()] = struct{}{}
+		}
+		for id := range s.reachable(from, func(v nodeView) []string { return v.Dependents }) {
+			affected[id] = struct{}{}
+		}
+		for _, id := range ids {
+			affected[id] = struct{}{}
+		}
+		s.writeJSON(w, http.StatusOK, queryNodes(s.nodesIn(affected), query))
+	})
+	mux.HandleFunc("/ancestors", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if len(query["id"]) == 0 {
+			s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "query parameter id is required"})
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		from, ok := s.nodesOf(w, query["id"])
+		if !ok {
+			return
+		}
+		ancestors := s.reachable(from, func(v nodeView) []string { return append(append([]string{}, v.Dependencies...), v.Modules...) })
+		s.writeJSON(w, http.StatusOK, queryNodes(s.nodesIn(ancestors), query))
+	})
+	mux.HandleFunc("/order", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		var opts []terradep.LevelsOpt
+		if paths := query["path"]; len(paths) != 0 {
+			opts = append(opts, terradep.WithLevelsOnly(s.graph.Affected(paths...)...))
+		}
+		levels, _ := terradep.Levels(s.graph, opts...)
+		batches := make([][]nodeView, 0, len(levels))
+		for _, level := range levels {
+			batch := make([]nodeView, 0, len(level))
+			for _, n := range level {
+				batch = append(batch, s.byID[n.ID()])
+			}
+			if batch = queryNodes(batch, query); len(batch) != 0 {
+				batches = append(batches, batch)
+			}
+		}
+		s.writeJSON(w, http.StatusOK, map[string]any{"batches": batches})
+	})
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		text := strings.ToLower(query.Get("q"))
+		if text == "" {
+			s.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "query parameter q is required"})
+			return
+		}
+
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		out := make([]nodeView, 0)
+		for _, n := range s.nodes {
+			if n.matches(text) {
+				out = append(out, n)
+			}
+		}
+		s.writeJSON(w, http.StatusOK, queryNodes(out, query))
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// ServeMux would redirect paths with escaped states to cleaned ones, e.g. s3:// to s3:/
+		if strings.HasPrefix(r.URL.Path, "/node/") {
+			nodeHandler(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// nodesOf returns views of the nodes with the ids, writes the error and returns false when any of them is not found
+func (s *graphServer) nodesOf(w http.ResponseWriter, ids []string) ([]nodeView, bool) {
+	out := make([]nodeView, 0, len(ids))
+	for _, id := range ids {
+		v, ok := s.byID[id]
+		if !ok {
+			s.writeJSON(w, http.StatusNotFound, map[string]string{"error": "node not found: " + id})
+			return nil, false
+		}
+		out = append(out, v)
+	}
+
+	return out, true
+}
+
+// reachable returns ids of the nodes reachable from the nodes through the edges returned by next, directly or transitively,
+// without the nodes themselves unless they are reachable from each other
+func (s *graphServer) reachable(from []nodeView, next func(v nodeView) []string) map[string]struct{} {
+	out := make(map[string]struct{})
+	queue := append([]nodeView{}, from...)
+	for len(queue) != 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, id := range next(v) {
+			if _, ok := out[id]; ok {
+				continue
+			}
+			out[id] = struct{}{}
+			queue = append(queue, s.byID[id])
+		}
+	}
+
+	return out
+}
+
+// nodesIn returns views of the nodes with the ids, ordered like [terradep.Graph.Nodes]
+func (s *graphServer) nodesIn(ids map[string]struct{}) []nodeView {
+	out := make([]nodeView, 0, len(ids))
+	for _, n := range s.nodes {
+		if _, ok := ids[n.ID]; ok {
+			out = append(out, n)
+		}
+	}
+
+	return out
+}
+
+// matches checks whether the id, the path, the state, the owners, the layer or any of the tags of the node contains the lowercase text, ignoring case
+func (v nodeView) matches(text string) bool {
+	fields := append([]string{v.ID, v.Path, v.State, v.Owner, v.Layer}, v.CodeOwners...)
+	for _, field := range append(fields, v.Tags...) {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// queryNodes returns nodes of the team and the environment set with query parameters team and env, all the nodes when they are empty
+func queryNodes(nodes []nodeView, query url.Values) []nodeView {
+	team, env := query.Get("team"), query.Get("env")
+	if team == "" && env == "" {
+		return nodes
+	}
+
+	out := make([]nodeView, 0)
+	for _, n := range nodes {
+		if (team == "" || n.node.HasTeam(team)) && (env == "" || n.Environment == env) {
+			out = append(out, n)
+		}
+	}
+
+	return out
+}
+
+func (s *graphServer) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.log.Error("writing response", slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,217 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/terradeptest"
+	"golang.org/x/exp/slog"
+)
+
+func newTestServer(t *testing.T) (*graphServer, *terradep.Graph, *httptest.Server) {
+	t.Helper()
+
+	graph := terradeptest.NewGraph().
+		Deployment("network", "s3://?bucket=b&key=network").
+		Deployment("app", "s3://?bucket=b&key=app", "s3://?bucket=b&key=network").
+		Deployment("web", "s3://?bucket=b&key=web", "s3://?bucket=b&key=app").
+		Deployment("dns", "s3://?bucket=b&key=dns").
+		MustBuild(t)
+	s := &graphServer{log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	s.set(graph)
+	server := httptest.NewServer(s.handler())
+	t.Cleanup(server.Close)
+
+	return s, graph, server
+}
+
+func TestServeAPI(t *testing.T) {
+	_, graph, server := newTestServer(t)
+	id := func(path string) string {
+		for _, n := range graph.Nodes() {
+			if n.Path == path {
+				return n.ID()
+			}
+		}
+		t.Fatalf("node not found: %s", path)
+		return ""
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		// paths of the nodes in the response, sorted, or batches of the order separated with |
+		want string
+	}{
+		{path: "/graph", status: http.StatusOK, want: "app,dns,network,web"},
+		{path: "/node/" + id("app"), status: http.StatusOK, want: "app"},
+		{path: "/node/missing", status: http.StatusNotFound},
+		{path: "/affected?id=" + id("network"), status: http.StatusOK, want: "app,network,web"},
+		{path: "/affected?path=app/main.tf", status: http.StatusOK, want: "app,web"},
+		{path: "/affected", status: http.StatusBadRequest},
+		{path: "/affected?id=missing", status: http.StatusNotFound},
+		{path: "/ancestors?id=" + id("web"), status: http.StatusOK, want: "app,network"},
+		{path: "/ancestors", status: http.StatusBadRequest},
+		{path: "/order", status: http.StatusOK, want: "dns,network|app|web"},
+		{path: "/order?path=app", status: http.StatusOK, want: "app|web"},
+		{path: "/search?q=NET", status: http.StatusOK, want: "network"},
+		{path: "/search", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			status, body := get(t, server.URL+tt.path)
+			if status != tt.status {
+				t.Fatalf("unexpected status: %d, want: %d, body: %s", status, tt.status, body)
+			}
+			if tt.want == "" {
+				return
+			}
+			if got := responsePaths(t, body); got != tt.want {
+				t.Errorf("unexpected nodes: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeOpenAPIDocumentsEndpoints(t *testing.T) {
+	_, _, server := newTestServer(t)
+	status, body := get(t, server.URL+"/openapi.json")
+	if status != http.StatusOK {
+		t.Fatalf("unexpected status: %d", status)
+	}
+
+	spec := struct {
+		Paths map[string]any `json:"paths"`
+	}{}
+	if err := json.Unmarshal(body, &spec); err != nil {
+		t.Fatalf("decoding OpenAPI spec: %v", err)
+	}
+	for _, path := range []string{"/graph", "/node/{id}", "/affected", "/ancestors", "/order", "/search"} {
+		if _, ok := spec.Paths[path]; !ok {
+			t.Errorf("endpoint is not documented: %s", path)
+		}
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	s, graph, server := newTestServer(t)
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("connecting to server: %v", err)
+	}
+	defer conn.Close()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/events", http.NoBody)
+	if err != nil {
+		t.Fatalf("building request: %v", err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("writing request: %v", err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatalf("reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected status: %d", resp.StatusCode)
+	}
+
+	// the pong proves that the server reads frames, so it has subscribed before the graph is replaced
+	if _, err := conn.Write(clientFrame(wsPing, []byte("1"), true)); err != nil {
+		t.Fatalf("writing ping: %v", err)
+	}
+	if opcode, payload := readServerFrame(t, r); opcode != wsPong || string(payload) != "1" {
+		t.Fatalf("unexpected reply to ping: %x, %q", opcode, payload)
+	}
+
+	s.set(graph)
+	if opcode, payload := readServerFrame(t, r); opcode != wsText || string(payload) != string(graphUpdated) {
+		t.Errorf("unexpected frame after graph was replaced: %x, %q", opcode, payload)
+	}
+
+	s.closeWatchers()
+	if opcode, payload := readServerFrame(t, r); opcode != wsClose || string(payload) != "\x03\xe9" {
+		t.Errorf("unexpected frame on shutdown: %x, %q", opcode, payload)
+	}
+}
+
+func get(t *testing.T, url string) (int, []byte) {
+	t.Helper()
+
+	resp, err := http.Get(url) //nolint:noctx // test server
+	if err != nil {
+		t.Fatalf("requesting: %s, %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading response: %s, %v", url, err)
+	}
+
+	return resp.StatusCode, body
+}
+
+// responsePaths returns sorted paths of the nodes in the body, which is a node, a list of nodes, the graph or the batches of the order
+func responsePaths(t *testing.T, body []byte) string {
+	t.Helper()
+
+	type node struct {
+		Path string `json:"path"`
+	}
+	paths := func(nodes []node) string {
+		out := make([]string, 0, len(nodes))
+		for _, n := range nodes {
+			out = append(out, n.Path)
+		}
+		sort.Strings(out)
+		return strings.Join(out, ",")
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("decoding response: %v, %s", err, body)
+	}
+	switch v := v.(type) {
+	case []any:
+		var nodes []node
+		_ = json.Unmarshal(body, &nodes)
+		return paths(nodes)
+	case map[string]any:
+		if _, ok := v["batches"]; ok {
+			var order struct {
+				Batches [][]node `json:"batches"`
+			}
+			_ = json.Unmarshal(body, &order)
+			out := make([]string, 0, len(order.Batches))
+			for _, batch := range order.Batches {
+				out = append(out, paths(batch))
+			}
+			return strings.Join(out, "|")
+		}
+		if _, ok := v["nodes"]; ok {
+			var graph struct {
+				Nodes []node `json:"nodes"`
+			}
+			_ = json.Unmarshal(body, &graph)
+			return paths(graph.Nodes)
+		}
+	}
+
+	var n node
+	_ = json.Unmarshal(body, &n)
+	return n.Path
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/internal/perm"
+	"golang.org/x/exp/slog"
+)
+
+type checkCfg struct {
+	*graphCfg
+	against   string
+	format    string
+	deltaFile string
+}
+
+func newCheckCommand(rc *rootCfg) *cobra.Command {
+	c := &checkCfg{graphCfg: &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}}
+	cmd := &cobra.Command{
+		Use:     `check --against snapshot.json --dir analyzeMe`,
+		Example: `check --against dependencies.json --dir . --delta-file delta.json`,
+		Short: fmt.Sprintf("Compares the graph of analyzeMe with the snapshot written by graph --format json --relative-paths and committed with the code, e.g. as a required status of pull requests. "+
+			"Prints deployments and dependencies added and removed since the snapshot, like diff, and fails with exit code %d when there are any, "+
+			"so changes of the dependencies are reviewed together with the updated snapshot", ExitDrift),
+		RunE: checkSnapshot(c),
+	}
+	addScanFlags(cmd, c.scanCfg)
+	f := cmd.Flags()
+	f.StringVar(&c.against, "against", "", "Sets JSON file with the snapshot of the graph written by graph --format json --relative-paths")
+	f.StringVar(&c.format, "format", diffText, fmt.Sprintf("Sets format of the changes written to standard output. Allowed values: %s, %s", diffText, diffJSON))
+	f.StringVar(&c.deltaFile, "delta-file", "", "Writes the changes also to the file in format "+diffJSON+", e.g. to be uploaded as an artifact. The file is written even when the graph matches the snapshot")
+	f.BoolVar(&c.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+	markPathFlags(f, "against", "delta-file")
+	if err := cmd.MarkFlagRequired("against"); err != nil {
+		panic(fmt.Errorf("marking flag against as required, %w", err))
+	}
+
+	return cmd
+}
+
+func checkSnapshot(c *checkCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		format := strings.ToUpper(c.format)
+		if format != diffText && format != diffJSON {
+			return fmt.Errorf("unsupported format: %s, allowed values: %s, %s", c.format, diffText, diffJSON)
+		}
+
+		snapshot, err := readGraph(c.against)
+		if err != nil {
+			return err
+		}
+
+		// snapshot has relative paths, so it does not depend on the location of the repository
+		c.relativePaths = true
+		graph, err := scanGraph(log, c.graphCfg)
+		if err != nil {
+			return err
+		}
+
+		diff := terradep.DiffGraphs(snapshot, graph)
+		if err := writeDiff(cmd.OutOrStdout(), diff, format); err != nil {
+			return err
+		}
+		if c.deltaFile != "" {
+			if err := writeDeltaFile(c.deltaFile, diff); err != nil {
+				return err
+			}
+		}
+
+		if diff.IsEmpty() {
+			log.Info("graph matches the snapshot", slog.String("snapshot", c.against))
+			return nil
+		}
+
+		changes := len(diff.AddedDeployments) + len(diff.RemovedDeployments) + len(diff.AddedDependencies) + len(diff.RemovedDependencies)
+		return withExitCode(ExitDrift, fmt.Errorf("graph differs from the snapshot: %s, changes: %d, update it with: %s graph --format json --relative-paths --out %s --force",
+			c.against, changes, CLIName, c.against))
+	}
+}
+
+// writeDeltaFile writes the diff as JSON to the file, which is overwritten
+func writeDeltaFile(path string, diff terradep.GraphDiff) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, perm.UserRW)
+	if err != nil {
+		return fmt.Errorf("opening delta file: %s, %w", path, err)
+	}
+	defer file.Close()
+
+	if err := writeDiffJSON(file, diff); err != nil {
+		return fmt.Errorf("writing delta file: %s, %w", path, err)
+	}
+
+	return nil
+}
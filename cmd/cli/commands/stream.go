@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/encoding"
+	"golang.org/x/exp/slog"
+)
+
+type streamCfg struct {
+	*scanCfg
+	lenient bool
+	git     bool
+}
+
+func newStreamCommand(rc *rootCfg) *cobra.Command {
+	sc := &streamCfg{scanCfg: &scanCfg{rootCfg: rc}}
+	streamCmd := &cobra.Command{
+		Use:     `stream --dir analyzeMe`,
+		Example: `stream --dir analyzeMe | jq -r .state`,
+		Short:   "Writes every deployment found in analyzeMe to stdout as a JSON line as soon as it is scanned, without building the graph in memory. Suitable for very large repositories",
+		RunE:    streamDeployments(sc),
+	}
+	addScanFlags(streamCmd, sc.scanCfg)
+	sF := streamCmd.Flags()
+	sF.BoolVar(&sc.git, "git-metadata", false, "Reads last commit and top contributors of every deployment from git history")
+	sF.BoolVar(&sc.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+
+	return streamCmd
+}
+
+func streamDeployments(c *streamCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		opts, err := c.scannerOpts(log)
+		if err != nil {
+			return err
+		}
+		if c.lenient {
+			opts = append(opts, terradep.WithContinueOnError())
+		}
+		if c.git {
+			opts = append(opts, terradep.WithGitMetadata())
+		}
+
+		var out io.Writer = cmd.OutOrStdout()
+		if c.dryRun {
+			out = io.Discard
+		}
+		enc := encoding.NewDeploymentEncoder(out)
+		// encodeErr tells failure of writing the output from failure of the scan
+		var encodeErr error
+		emit := func(d terradep.Deployment) error {
+			encodeErr = enc.Encode(d)
+			return encodeErr
+		}
+
+		s := terradep.NewScanner(log, c.newStater(), opts...)
+		for _, dir := range c.dirs {
+			log.Info("streaming directory", slog.String("dir", dir))
+			diags, err := s.Stream(dir, emit)
+			if err := c.printDiagnostics(diags); err != nil {
+				return err
+			}
+			if err != nil {
+				err = fmt.Errorf("failed to stream path: %s, error was: %w", dir, err)
+				if encodeErr != nil {
+					return err
+				}
+				return scanError(err)
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,338 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.interactor.dev/terradep"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// otlpEndpointEnv and otlpHeadersEnv are standard OpenTelemetry variables, used when flag --otlp-endpoint is not set
+	otlpEndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	otlpHeadersEnv  = "OTEL_EXPORTER_OTLP_HEADERS"
+	otlpTimeout     = 30 * time.Second
+	// otlpBatchSize is the maximal number of spans sent in single request, so scans of large estates do not produce huge requests
+	otlpBatchSize = 1000
+	// values of the enums defined by OTLP
+	otlpSpanKindInternal      = 1
+	otlpStatusError           = 2
+	otlpTemporalityCumulative = 2
+	// otlpScopeName is the name of the instrumentation scope, the library recording the spans
+	otlpScopeName      = "go.interactor.dev/terradep"
+	otlpServiceName    = "service.name"
+	otlpServiceVersion = "service.version"
+)
+
+// telemetry is [terradep.Tracer] which keeps spans and counters in memory and exports them with OTLP over HTTP,
+// encoded as JSON, when the command finishes
+type telemetry struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	start    time.Time
+
+	mu       sync.Mutex
+	spans    []*otlpSpan
+	counters map[string]*otlpCounter
+}
+
+func newTelemetry(endpoint string) (*telemetry, error) {
+	headers, err := parseOTLPHeaders(os.Getenv(otlpHeadersEnv))
+	if err != nil {
+		return nil, err
+	}
+
+	return &telemetry{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		headers:  headers,
+		client:   &http.Client{Timeout: otlpTimeout},
+		start:    time.Now(),
+		counters: map[string]*otlpCounter{},
+	}, nil
+}
+
+// parseOTLPHeaders parses comma-separated list of key=value pairs with URL encoded values
+func parseOTLPHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("parsing %s, expected key=value, got: %q", otlpHeadersEnv, pair)
+		}
+		val, err := url.QueryUnescape(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s, invalid value of header: %s, %w", otlpHeadersEnv, key, err)
+		}
+		headers[strings.TrimSpace(key)] = val
+	}
+
+	return headers, nil
+}
+
+type otlpSpan struct {
+	t        *telemetry
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    []slog.Attr
+	err      error
+}
+
+// Start implements [terradep.Tracer], span without the parent starts a new trace
+func (t *telemetry) Start(parent terradep.Span, name string, attrs ...slog.Attr) terradep.Span {
+	span := &otlpSpan{t: t, spanID: randomHex(8), name: name, start: time.Now(), attrs: attrs}
+	if p, ok := parent.(*otlpSpan); ok {
+		span.traceID, span.parentID = p.traceID, p.spanID
+	} else {
+		span.traceID = randomHex(16)
+	}
+
+	return span
+}
+
+// End implements [terradep.Span], only ended spans are exported
+func (s *otlpSpan) End(err error) {
+	s.end, s.err = time.Now(), err
+	s.t.mu.Lock()
+	s.t.spans = append(s.t.spans, s)
+	s.t.mu.Unlock()
+}
+
+type otlpCounter struct {
+	name  string
+	attrs []slog.Attr
+	value int64
+}
+
+// Add implements [terradep.Tracer], every combination of the attributes has its own value
+func (t *telemetry) Add(name string, n int64, attrs ...slog.Attr) {
+	key := name + fmt.Sprint(attrs)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.counters[key]
+	if !ok {
+		c = &otlpCounter{name: name, attrs: attrs}
+		t.counters[key] = c
+	}
+	c.value += n
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	// error is not expected, it would only make the ids collide
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// export sends spans to <endpoint>/v1/traces and counters to <endpoint>/v1/metrics
+func (t *telemetry) export() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := 0; i < len(t.spans); i += otlpBatchSize {
+		end := i + otlpBatchSize
+		if end > len(t.spans) {
+			end = len(t.spans)
+		}
+		if err := t.post("/v1/traces", t.tracesRequest(t.spans[i:end])); err != nil {
+			return err
+		}
+	}
+	t.spans = nil
+
+	if len(t.counters) == 0 {
+		return nil
+	}
+
+	return t.post("/v1/metrics", t.metricsRequest())
+}
+
+func (t *telemetry) tracesRequest(spans []*otlpSpan) map[string]any {
+	encoded := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		span := map[string]any{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              otlpSpanKindInternal,
+			"startTimeUnixNano": otlpTime(s.start),
+			"endTimeUnixNano":   otlpTime(s.end),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parentID != "" {
+			span["parentSpanId"] = s.parentID
+		}
+		if s.err != nil {
+			span["status"] = map[string]any{"code": otlpStatusError, "message": s.err.Error()}
+		}
+		encoded = append(encoded, span)
+	}
+
+	return map[string]any{"resourceSpans": []any{map[string]any{
+		"resource":   otlpResource(),
+		"scopeSpans": []any{map[string]any{"scope": otlpScope(), "spans": encoded}},
+	}}}
+}
+
+func (t *telemetry) metricsRequest() map[string]any {
+	keys := make([]string, 0, len(t.counters))
+	for key := range t.counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var names []string
+	points := make(map[string][]any)
+	now := time.Now()
+	for _, key := range keys {
+		c := t.counters[key]
+		if _, ok := points[c.name]; !ok {
+			names = append(names, c.name)
+		}
+		points[c.name] = append(points[c.name], map[string]any{
+			"attributes":        otlpAttributes(c.attrs),
+			"startTimeUnixNano": otlpTime(t.start),
+			"timeUnixNano":      otlpTime(now),
+			"asInt":             strconv.FormatInt(c.value, 10),
+		})
+	}
+
+	metrics := make([]any, 0, len(names))
+	for _, name := range names {
+		metrics = append(metrics, map[string]any{
+			"name": name,
+			"sum": map[string]any{
+				"aggregationTemporality": otlpTemporalityCumulative,
+				"isMonotonic":            true,
+				"dataPoints":             points[name],
+			},
+		})
+	}
+
+	return map[string]any{"resourceMetrics": []any{map[string]any{
+		"resource":     otlpResource(),
+		"scopeMetrics": []any{map[string]any{"scope": otlpScope(), "metrics": metrics}},
+	}}}
+}
+
+func (t *telemetry) post(path string, request map[string]any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("encoding telemetry: %s, %w", path, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating telemetry request: %s, %w", path, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.client.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// url may contain a secret, e.g. token of the collector
+		err = urlErr.Err
+	}
+	if err != nil {
+		return fmt.Errorf("exporting telemetry: %s, %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("exporting telemetry: %s, unexpected status: %s, %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+func otlpResource() map[string]any {
+	return map[string]any{"attributes": otlpAttributes([]slog.Attr{
+		slog.String(otlpServiceName, CLIName),
+		slog.String(otlpServiceVersion, version),
+	})}
+}
+
+func otlpScope() map[string]any {
+	return map[string]any{"name": otlpScopeName, "version": version}
+}
+
+// otlpTime encodes the time as nanoseconds since epoch, in a string like all 64-bit integers in OTLP JSON
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpAttributes(attrs []slog.Attr) []any {
+	encoded := make([]any, 0, len(attrs))
+	for _, attr := range attrs {
+		var value map[string]any
+		switch attr.Value.Kind() {
+		case slog.KindInt64:
+			value = map[string]any{"intValue": strconv.FormatInt(attr.Value.Int64(), 10)}
+		case slog.KindBool:
+			value = map[string]any{"boolValue": attr.Value.Bool()}
+		case slog.KindFloat64:
+			value = map[string]any{"doubleValue": attr.Value.Float64()}
+		default:
+			value = map[string]any{"stringValue": attr.Value.String()}
+		}
+		encoded = append(encoded, map[string]any{"key": attr.Key, "value": value})
+	}
+
+	return encoded
+}
+
+// openTelemetry starts collecting telemetry when flag --otlp-endpoint or environment variable is set
+func (c *rootCfg) openTelemetry() error {
+	endpoint := c.otlpEndpoint
+	if endpoint == "" {
+		endpoint = os.Getenv(otlpEndpointEnv)
+	}
+	if endpoint == "" {
+		return nil
+	}
+
+	t, err := newTelemetry(endpoint)
+	if err != nil {
+		return err
+	}
+	c.telemetry = t
+
+	return nil
+}
+
+// exportTelemetry exports collected telemetry, also when the command failed. Failed export does not change the result of the command
+func (c *rootCfg) exportTelemetry() {
+	if c.telemetry == nil {
+		return
+	}
+
+	if err := c.telemetry.export(); err != nil && !c.quiet {
+		fmt.Fprintf(os.Stderr, "terradep could not export telemetry: %s\n", err)
+	}
+}
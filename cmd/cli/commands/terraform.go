@@ -0,0 +1,108 @@
+package commands
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+)
+
+// terraformSummary matches lines of Terraform, OpenTofu and Terragrunt output summarizing plan or apply
+var terraformSummary = regexp.MustCompile(`^(Plan: |No changes\.|Apply complete!|Changes to Outputs:)`)
+
+type terraformCfg struct {
+	*runCfg
+	binary      string
+	init        bool
+	targets     []string
+	affected    []string
+	autoApprove bool
+}
+
+func newPlanAllCommand(rc *rootCfg) *cobra.Command {
+	return newTerraformCommand(rc, "plan", "Plans changes of every deployment found in analyzeMe, after planning all the deployments it depends on")
+}
+
+func newApplyAllCommand(rc *rootCfg) *cobra.Command {
+	return newTerraformCommand(rc, "apply", "Applies every deployment found in analyzeMe, after all the deployments it depends on were applied. Requires --auto-approve, because many deployments are applied at the same time")
+}
+
+// newTerraformCommand returns command running Terraform subcommand, e.g. plan, in every deployment
+func newTerraformCommand(rc *rootCfg, subcommand, short string) *cobra.Command {
+	c := &terraformCfg{runCfg: &runCfg{graphCfg: &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}}}
+	cmd := &cobra.Command{
+		Use:     subcommand + `-all [--binary terraform] [--only-affected path] --dir analyzeMe [-- extra args]`,
+		Example: subcommand + `-all --dir analyzeMe --parallel 4 --only-affected "$(git diff --name-only origin/main | paste -sd, -)"`,
+		Short: short + ". Every deployment is run in its workspace set with " + tfWorkspaceEnv + ". Arguments after -- are passed to " + subcommand + ". " +
+			"Summary of the changes of every deployment is printed at the end. With --dry-run only prints the deployments in the order they would be run",
+		RunE: runTerraform(c, subcommand),
+	}
+	addScanFlags(cmd, c.scanCfg)
+	f := cmd.Flags()
+	f.StringVar(&c.binary, "binary", "terraform", "Sets the binary run in the deployments, e.g. tofu or terragrunt")
+	f.BoolVar(&c.init, "init", false, "Runs init before "+subcommand+" in every deployment")
+	f.StringSliceVar(&c.targets, "target", nil, "Runs only the deployments owning the paths, i.e. deployments with the longest path containing them")
+	f.StringSliceVar(&c.affected, "only-affected", nil, "Runs only the deployments affected by the change of the paths, i.e. deployments owning them and all the deployments depending on them. "+
+		"Local modules are taken into account only with --module-edges")
+	f.IntVar(&c.parallel, "parallel", 1, "Sets how many deployments run at the same time")
+	f.BoolVar(&c.continueOnError, "continue-on-error", false, "Keeps running the deployments which do not depend on the failed one. By default no new deployments are started after the first failure")
+	f.StringVar(&c.logDir, "log-dir", "", "Writes output of every deployment to its own file in the directory. If not set output is written to standard output, every line prefixed with path of the deployment")
+	markDirFlags(f, "log-dir")
+	addEnvFlag(cmd, c.runCfg)
+	if subcommand == "apply" {
+		f.BoolVar(&c.autoApprove, "auto-approve", false, "Applies the changes without asking for approval, which is required")
+	}
+
+	return cmd
+}
+
+func runTerraform(c *terraformCfg, subcommand string) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		if subcommand == "apply" && !c.autoApprove && !c.dryRun {
+			return fmt.Errorf("flag --auto-approve is required, the deployments are applied without asking for approval")
+		}
+
+		if c.relativePaths {
+			return fmt.Errorf("flag --relative-paths is not supported by %s-all, commands are run in the directories of the deployments", subcommand)
+		}
+
+		env, err := parseRunEnv(c.env)
+		if err != nil {
+			return err
+		}
+
+		graph, err := scanGraph(log, c.graphCfg)
+		if err != nil {
+			return err
+		}
+
+		var selected map[*terradep.Node]struct{}
+		if len(c.targets) != 0 || len(c.affected) != 0 {
+			selected = make(map[*terradep.Node]struct{})
+			for _, n := range graph.Owners(c.targets...) {
+				selected[n] = struct{}{}
+			}
+			for _, n := range graph.Affected(c.affected...) {
+				selected[n] = struct{}{}
+			}
+		}
+
+		run := []string{c.binary, subcommand, "-input=false", "-no-color"}
+		if c.autoApprove {
+			run = append(run, "-auto-approve")
+		}
+		commands := [][]string{append(run, args...)}
+		if c.init {
+			commands = append([][]string{{c.binary, "init", "-input=false", "-no-color"}}, commands...)
+		}
+
+		r := &runner{cfg: c.runCfg, log: log, commands: commands, env: env, summary: terraformSummary, out: cmd.OutOrStdout()}
+		return r.execute(cmd, runTasks(graph, selected))
+	}
+}
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep/encoding"
+	"go.interactor.dev/terradep/internal/perm"
+	"golang.org/x/exp/slog"
+)
+
+func newTerragruntCommand(rc *rootCfg) *cobra.Command {
+	c := newGeneratorCfg(rc)
+	write := false
+	cmd := &cobra.Command{
+		Use:     `terragrunt [--write [--force]] --dir analyzeMe`,
+		Example: `generate terragrunt --dir . --write`,
+		Short: "Generates " + encoding.TerragruntFile + " of every deployment with dependency blocks of the deployments it depends on, which helps migrating to Terragrunt. " +
+			"By default all the files are written to the output, every one preceded by comment with its path. With --write every file is written to the directory of the deployment",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log, err := buildLogger(*c.rootCfg)
+			if err != nil {
+				return fmt.Errorf("failed to build logger: %w", err)
+			}
+
+			if err := c.resolvePaths(); err != nil {
+				return err
+			}
+
+			var out io.Writer
+			if !write {
+				if out, err = buildOutput(log, c.graphCfg); err != nil {
+					return fmt.Errorf("building output: %w", err)
+				}
+			}
+
+			graph, opts, err := c.scan(log)
+			if err != nil {
+				return err
+			}
+
+			configs := encoding.BuildTerragruntConfigs(graph, opts...)
+			for _, path := range sortedNames(configs) {
+				if write {
+					if err := writeTerragruntConfig(log, filepath.Join(c.root, filepath.FromSlash(path)), configs[path], c.force, c.dryRun); err != nil {
+						return err
+					}
+					continue
+				}
+
+				if _, err := fmt.Fprintf(out, "# %s\n%s\n", path, configs[path]); err != nil {
+					return fmt.Errorf("writing terragrunt config: %s, %w", path, err)
+				}
+			}
+
+			return nil
+		},
+	}
+	addGeneratorFlags(cmd, c)
+	cmd.Flags().BoolVar(&write, "write", false, "Writes every "+encoding.TerragruntFile+" to the directory of the deployment, relative to --root. Fails when the file already exists unless you set flag --force")
+	cmd.MarkFlagsMutuallyExclusive("write", "out")
+
+	return cmd
+}
+
+// writeTerragruntConfig writes the file unless it already exists and force is disabled. In dry run nothing is written
+func writeTerragruntConfig(log *slog.Logger, path string, config []byte, force, dryRun bool) error {
+	_, err := os.Stat(path)
+	switch {
+	case err == nil && !force:
+		return fmt.Errorf("terragrunt config already exists and force is disabled: %s", path)
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("stating terragrunt config: %s, %w", path, err)
+	}
+
+	log.Info("writing terragrunt config", slog.String("path", path))
+	if dryRun {
+		return nil
+	}
+
+	if err := os.WriteFile(path, config, perm.UserRW); err != nil {
+		return fmt.Errorf("writing terragrunt config: %s, %w", path, err)
+	}
+
+	return nil
+}
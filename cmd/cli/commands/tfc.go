@@ -0,0 +1,285 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/state"
+)
+
+const (
+	tfcTimeout   = 30 * time.Second
+	tfcMediaType = "application/vnd.api+json"
+	// tfcTokenEnv is read when there is no token for the hostname, see [tfcToken]
+	tfcTokenEnv = "TFE_TOKEN"
+	// tfcAddressEnv overrides the address of the API, e.g. https://tfe.example.com, like in go-tfe
+	tfcAddressEnv = "TFE_ADDRESS"
+)
+
+// tfcClient calls API of Terraform Cloud or Terraform Enterprise at the hostname
+type tfcClient struct {
+	client   *http.Client
+	hostname string
+	address  string
+	token    string
+}
+
+func newTFCClient(hostname string) (*tfcClient, error) {
+	token := tfcToken(hostname)
+	if token == "" {
+		return nil, fmt.Errorf("token of Terraform Cloud: %s not found, set environment variable %s or %s", hostname, tfcTokenEnvOf(hostname), tfcTokenEnv)
+	}
+
+	address := "https://" + hostname
+	if env := os.Getenv(tfcAddressEnv); env != "" {
+		address = strings.TrimSuffix(env, "/")
+	}
+
+	return &tfcClient{client: &http.Client{Timeout: tfcTimeout}, hostname: hostname, address: address, token: token}, nil
+}
+
+// tfcClients creates the client of every hostname once, it is safe for concurrent use
+type tfcClients struct {
+	mu      sync.Mutex
+	clients map[string]*tfcClient
+}
+
+// client returns the client of the hostname
+func (c *tfcClients) client(hostname string) (*tfcClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.clients[hostname]; ok {
+		return client, nil
+	}
+	client, err := newTFCClient(hostname)
+	if err != nil {
+		return nil, err
+	}
+	if c.clients == nil {
+		c.clients = make(map[string]*tfcClient)
+	}
+	c.clients[hostname] = client
+
+	return client, nil
+}
+
+// tfcWorkspace identifies the workspace of Terraform Cloud
+type tfcWorkspace struct {
+	hostname     string
+	organization string
+	name         string
+}
+
+func (w tfcWorkspace) String() string {
+	return w.organization + "/" + w.name
+}
+
+// tfcWorkspaceOf returns the workspace storing the state, false when the state is not stored in Terraform Cloud
+// or the workspace is selected by tags or by the prefix without the workspace of the deployment
+func tfcWorkspaceOf(s terradep.State) (tfcWorkspace, bool) {
+	s, workspace := unwrapWorkspace(s)
+	tfcState, ok := s.(state.TFCState)
+	if !ok {
+		return tfcWorkspace{}, false
+	}
+
+	w := tfcWorkspace{hostname: tfcState.Hostname, organization: tfcState.Organization, name: tfcState.Workspace}
+	if w.name == "" && tfcState.Prefix != "" && workspace != "" {
+		w.name = tfcState.Prefix + workspace
+	}
+
+	return w, w.name != ""
+}
+
+// tfcToken returns the token of the hostname read from environment variable TF_TOKEN_<hostname>, like Terraform does, or TFE_TOKEN
+func tfcToken(hostname string) string {
+	return firstEnv(tfcTokenEnvOf(hostname), tfcTokenEnv)
+}
+
+// tfcTokenEnvOf returns the name of the variable with the token of the hostname, dots are replaced with underscores and dashes with double underscores
+func tfcTokenEnvOf(hostname string) string {
+	return "TF_TOKEN_" + strings.NewReplacer(".", "_", "-", "__").Replace(hostname)
+}
+
+// tfcResource is the resource of JSON:API document returned by the API
+type tfcResource struct {
+	ID            string                     `json:"id,omitempty"`
+	Type          string                     `json:"type"`
+	Attributes    map[string]json.RawMessage `json:"attributes,omitempty"`
+	Relationships map[string]tfcRelationship `json:"relationships,omitempty"`
+}
+
+type tfcRelationship struct {
+	Data tfcResource `json:"data"`
+}
+
+// workspaceID returns ID of the workspace of the organization, e.g. ws-123
+func (c *tfcClient) workspaceID(organization, workspace string) (string, error) {
+	var doc struct {
+		Data tfcResource `json:"data"`
+	}
+	path := fmt.Sprintf("/api/v2/organizations/%s/workspaces/%s", url.PathEscape(organization), url.PathEscape(workspace))
+	if err := c.do(http.MethodGet, path, nil, &doc); err != nil {
+		return "", fmt.Errorf("reading workspace: %s/%s, %w", organization, workspace, err)
+	}
+
+	return doc.Data.ID, nil
+}
+
+// outputs returns names of the outputs of the current state of the workspace
+func (c *tfcClient) outputs(workspaceID string) ([]string, error) {
+	var names []string
+	path := fmt.Sprintf("/api/v2/workspaces/%s/current-state-version-outputs", url.PathEscape(workspaceID))
+	err := c.list(path, func(output tfcResource) error {
+		var name string
+		if err := json.Unmarshal(output.Attributes["name"], &name); err != nil {
+			return fmt.Errorf("decoding output, %w", err)
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading outputs of workspace: %s, %w", workspaceID, err)
+	}
+
+	return names, nil
+}
+
+// tfcRunTrigger queues run of the workspace after run of the source workspace is applied
+type tfcRunTrigger struct {
+	id string
+	// source is the name of the source workspace
+	source string
+}
+
+// runTriggers returns inbound run triggers of the workspace, i.e. run triggers with the workspace as the target
+func (c *tfcClient) runTriggers(workspaceID string) ([]tfcRunTrigger, error) {
+	var triggers []tfcRunTrigger
+	path := fmt.Sprintf("/api/v2/workspaces/%s/run-triggers?filter%%5Brun-trigger%%5D%%5Btype%%5D=inbound", url.PathEscape(workspaceID))
+	err := c.list(path, func(trigger tfcResource) error {
+		var source string
+		if err := json.Unmarshal(trigger.Attributes["sourceable-name"], &source); err != nil {
+			return fmt.Errorf("decoding run trigger: %s, %w", trigger.ID, err)
+		}
+		triggers = append(triggers, tfcRunTrigger{id: trigger.ID, source: source})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading run triggers of workspace: %s, %w", workspaceID, err)
+	}
+
+	return triggers, nil
+}
+
+// createRunTrigger creates run trigger of the workspace, so it is run after the source workspace is applied
+func (c *tfcClient) createRunTrigger(workspaceID, sourceID string) error {
+	body := struct {
+		Data tfcResource `json:"data"`
+	}{Data: tfcResource{Type: "run-triggers", Relationships: map[string]tfcRelationship{
+		"sourceable": {Data: tfcResource{ID: sourceID, Type: "workspaces"}},
+	}}}
+	path := fmt.Sprintf("/api/v2/workspaces/%s/run-triggers", url.PathEscape(workspaceID))
+	if err := c.do(http.MethodPost, path, body, nil); err != nil {
+		return fmt.Errorf("creating run trigger of workspace: %s, source: %s, %w", workspaceID, sourceID, err)
+	}
+
+	return nil
+}
+
+// deleteRunTrigger deletes the run trigger by its ID, e.g. rt-123
+func (c *tfcClient) deleteRunTrigger(id string) error {
+	if err := c.do(http.MethodDelete, "/api/v2/run-triggers/"+url.PathEscape(id), nil, nil); err != nil {
+		return fmt.Errorf("deleting run trigger: %s, %w", id, err)
+	}
+
+	return nil
+}
+
+// list calls the function for every resource of the paginated collection at the path
+func (c *tfcClient) list(path string, fn func(tfcResource) error) error {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	path += sep + "page%5Bsize%5D=100"
+
+	for path != "" {
+		var doc struct {
+			Data  []tfcResource `json:"data"`
+			Links struct {
+				Next string `json:"next"`
+			} `json:"links"`
+		}
+		if err := c.do(http.MethodGet, path, nil, &doc); err != nil {
+			return err
+		}
+
+		for _, r := range doc.Data {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		path = doc.Links.Next
+	}
+
+	return nil
+}
+
+// do sends the request to the path, which can be absolute URL, e.g. next page, and decodes the response to out, when it is not nil
+func (c *tfcClient) do(method, path string, body, out any) error {
+	target := path
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		target = c.address + path
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", tfcMediaType)
+
+	resp, err := c.client.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status: %s, %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"golang.org/x/exp/slog"
+)
+
+type triggersCfg struct {
+	*graphCfg
+	apply bool
+	prune bool
+}
+
+func newTriggersCommand(rc *rootCfg) *cobra.Command {
+	c := &triggersCfg{graphCfg: &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}}
+	cmd := &cobra.Command{
+		Use:     `tfc-triggers [--apply [--prune]] --dir analyzeMe`,
+		Example: `tfc-triggers --dir live --workspace prod --apply`,
+		Short: fmt.Sprintf("Compares run triggers of Terraform Cloud workspaces with the graph, so the workspace is run after the workspaces it depends on are applied. "+
+			"Deployment is mapped to the workspace of its cloud or remote backend, selected by name or by prefix and --workspace. "+
+			"Missing run trigger is reported as error and run trigger from the workspace which is not a dependency as warning. The command fails with exit code %d when run triggers are missing. "+
+			"Token is read from %s or %s", ExitPolicyViolation, tfcTokenEnvOf("<hostname>"), tfcTokenEnv),
+		RunE: syncTriggers(c),
+	}
+	addScanFlags(cmd, c.scanCfg)
+	tF := cmd.Flags()
+	tF.BoolVar(&c.apply, "apply", false, "Creates missing run triggers instead of reporting them. With --dry-run run triggers are only logged")
+	tF.BoolVar(&c.prune, "prune", false, "Deletes run triggers from the workspaces which are not dependencies of the deployment. Requires --apply")
+	tF.BoolVar(&c.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+
+	return cmd
+}
+
+func syncTriggers(c *triggersCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		if c.prune && !c.apply {
+			return fmt.Errorf("flag --prune requires flag --apply")
+		}
+
+		graph, err := scanGraph(log, c.graphCfg)
+		if err != nil {
+			return err
+		}
+
+		s := &triggerSync{log: log, apply: c.apply, prune: c.prune, dryRun: c.dryRun}
+		seen := make(map[tfcWorkspace]struct{})
+		for _, n := range graph.Nodes() {
+			target, ok := tfcWorkspaceOf(n.State)
+			if !graph.IsDeployment(n) || !ok {
+				continue
+			}
+			if _, ok := seen[target]; ok {
+				continue
+			}
+			seen[target] = struct{}{}
+
+			if err := s.sync(n, target); err != nil {
+				return err
+			}
+		}
+
+		if err := c.printDiagnostics(s.diags); err != nil {
+			return err
+		}
+
+		log.Info("run triggers compared", slog.Int("workspaces", len(seen)), slog.Int("missing", s.missing))
+		if s.missing == 0 {
+			return nil
+		}
+
+		return withExitCode(ExitPolicyViolation, fmt.Errorf("run triggers do not match the graph, missing: %d", s.missing))
+	}
+}
+
+// triggerSync compares run triggers of the workspaces with the dependencies of the deployments and optionally changes them
+type triggerSync struct {
+	log     *slog.Logger
+	clients tfcClients
+	apply   bool
+	prune   bool
+	dryRun  bool
+
+	diags   terradep.Diagnostics
+	missing int
+}
+
+// sync compares inbound run triggers of the target, i.e. the workspace of the deployment, with the workspaces of its dependencies.
+// Dependencies on states in other organizations are skipped, because run triggers cannot cross them
+func (s *triggerSync) sync(n *terradep.Node, target tfcWorkspace) error {
+	expected := make(map[string]*terradep.Node)
+	for _, child := range n.Children {
+		source, ok := tfcWorkspaceOf(child.State)
+		if !ok || source.name == target.name && source.organization == target.organization {
+			continue
+		}
+		if source.hostname != target.hostname || source.organization != target.organization {
+			s.log.Warn("run trigger cannot be created across organizations", slog.String("workspace", target.String()), slog.String("source", source.String()))
+			continue
+		}
+		expected[source.name] = child
+	}
+
+	client, err := s.clients.client(target.hostname)
+	if err != nil {
+		return err
+	}
+	targetID, err := client.workspaceID(target.organization, target.name)
+	if err != nil {
+		return err
+	}
+	triggers, err := client.runTriggers(targetID)
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]struct{}, len(triggers))
+	for _, trigger := range triggers {
+		existing[trigger.source] = struct{}{}
+		if _, ok := expected[trigger.source]; ok {
+			continue
+		}
+
+		if !s.prune {
+			s.diags = append(s.diags, terradep.Diagnostic{
+				Severity: terradep.SeverityWarning,
+				Rule:     terradep.RuleUnexpectedRunTrigger,
+				Summary:  fmt.Sprintf("workspace: %s has run trigger from workspace: %s, which is not its dependency", target, trigger.source),
+				Detail:   "delete the run trigger, e.g. with --apply --prune, or declare the dependency in the manifest",
+				Module:   n.Path,
+				Range:    n.BackendRange,
+			})
+			continue
+		}
+
+		s.log.Info("deleting run trigger", slog.String("workspace", target.String()), slog.String("source", trigger.source), slog.Bool("dryRun", s.dryRun))
+		if !s.dryRun {
+			if err := client.deleteRunTrigger(trigger.id); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range sortedNames(expected) {
+		if _, ok := existing[name]; ok {
+			continue
+		}
+		source := tfcWorkspace{hostname: target.hostname, organization: target.organization, name: name}
+
+		if !s.apply {
+			s.missing++
+			diag := terradep.Diagnostic{
+				Severity: terradep.SeverityError,
+				Rule:     terradep.RuleMissingRunTrigger,
+				Summary:  fmt.Sprintf("workspace: %s has no run trigger from workspace: %s", target, source),
+				Detail:   fmt.Sprintf("deployment depends on state: %s", expected[name].State),
+				Module:   n.Path,
+			}
+			if ranges := n.EdgeMetadata(expected[name]).Ranges; len(ranges) != 0 {
+				diag.Range = &ranges[0]
+			}
+			s.diags = append(s.diags, diag)
+			continue
+		}
+
+		s.log.Info("creating run trigger", slog.String("workspace", target.String()), slog.String("source", source.String()), slog.Bool("dryRun", s.dryRun))
+		if s.dryRun {
+			continue
+		}
+		sourceID, err := client.workspaceID(source.organization, source.name)
+		if err != nil {
+			return err
+		}
+		if err := client.createRunTrigger(targetID, sourceID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"golang.org/x/exp/slog"
+)
+
+// validation check enabled with flag --checks
+type check struct {
+	// rule of the diagnostics failing the check
+	rule string
+	code int
+	// optIn checks run only when set with flag --checks, they report dependencies which are valid in some repositories
+	optIn bool
+	// warns is set for opt-in checks reported as warnings when they are not set
+	warns bool
+	// description tells when the check fails, it is listed in the help of the command
+	description string
+}
+
+// checks are validation checks by name, every check fails the command with its own exit code
+var checks = map[string]check{
+	"parse":            {rule: terradep.RuleModuleError, code: ExitParseError, description: "module cannot be scanned"},
+	"cycles":           {rule: terradep.RuleCycle, code: ExitCycle, description: "deployments depend on each other"},
+	"external":         {rule: terradep.RuleExternalDependency, code: ExitMissingDependency, description: "deployment depends on state not produced by any scanned deployment"},
+	"declared":         {rule: terradep.RuleMissingDeclaredTarget, code: ExitMissingDependency, description: "dependency declared in the manifest or annotation points to unknown deployment"},
+	"duplicate-states": {rule: terradep.RuleDuplicateState, code: ExitPolicyViolation, description: "deployments share the state"},
+	"layers":           {rule: terradep.RuleLayerViolation, code: ExitPolicyViolation, description: "deployment depends on layer not allowed by flag --layer-rules"},
+	"state-keys":       {rule: terradep.RuleStateKeyMismatch, code: ExitPolicyViolation, description: "state does not follow flag --state-key-rules"},
+	"cross-region":     {rule: terradep.RuleCrossRegion, code: ExitPolicyViolation, optIn: true, description: "deployment depends on state in other region, e.g. of S3 bucket"},
+	"cross-account":    {rule: terradep.RuleCrossAccount, code: ExitPolicyViolation, optIn: true, description: "deployment depends on state in other AWS account, read from role_arn, assume_role or profile of S3 backend and terraform_remote_state"},
+	"thresholds":       {rule: terradep.RuleThreshold, code: ExitPolicyViolation, description: "deployment exceeds limits set with --max-graph-depth, --max-fan-in or --max-dependencies"},
+	"state-objects": {
+		rule: terradep.RuleMissingState, code: ExitMissingDependency, optIn: true,
+		description: "S3 state the deployment depends on does not exist or cannot be read, checked with HEAD request using the default AWS credential chain like AWS CLI, " +
+			"profile and role_arn of the state and its workspace_key_prefix",
+	},
+	"state-outputs": {
+		rule: terradep.RuleMissingOutput, code: ExitMissingDependency, optIn: true,
+		description: "output consumed by the deployment is not in the state, read from S3 or Terraform Cloud with token from TF_TOKEN_<hostname> or TFE_TOKEN",
+	},
+	"unused":            {rule: terradep.RuleUnusedRemoteState, code: ExitPolicyViolation, optIn: true, description: "terraform_remote_state is never referenced"},
+	"remote-states":     {rule: terradep.RuleRemoteStateMismatch, code: ExitPolicyViolation, optIn: true, warns: true, description: "terraform_remote_state sets key, region or encrypt other than the backend of the deployment producing the state, e.g. hard-codes the key of the workspace"},
+	"self-dependencies": {rule: terradep.RuleSelfDependency, code: ExitCycle, description: "deployment reads its own state, the dependency is ignored in the graph"},
+	"redundant":         {rule: terradep.RuleRedundantRemoteState, code: ExitPolicyViolation, optIn: true, warns: true, description: "module has more than one terraform_remote_state reading the same state"},
+}
+
+// defaultChecks returns names of the checks which are not opt-in
+func defaultChecks() []string {
+	var out []string
+	for _, name := range sortedNames(checks) {
+		if !checks[name].optIn {
+			out = append(out, name)
+		}
+	}
+
+	return out
+}
+
+// checksHelp documents the checks in the help of the command, opt-in checks run only when set with flag --checks
+func checksHelp() string {
+	sb := strings.Builder{}
+	sb.WriteString("Checks fail when:")
+	for _, name := range sortedNames(checks) {
+		c := checks[name]
+		fmt.Fprintf(&sb, "\n  %-18s %s", name, c.description)
+		switch {
+		case c.warns:
+			sb.WriteString(" (reported as warning unless set)")
+		case c.optIn:
+			sb.WriteString(" (run only when set)")
+		}
+	}
+
+	return sb.String()
+}
+
+type validateCfg struct {
+	*scanCfg
+	checks     []string
+	thresholds terradep.Thresholds
+}
+
+func newValidateCommand(rc *rootCfg) *cobra.Command {
+	vc := &validateCfg{scanCfg: &scanCfg{rootCfg: rc}}
+	validateCmd := &cobra.Command{
+		Use:     `validate [--checks cycles,external] --dir analyzeMe`,
+		Example: `validate --dir analyzeMe --diagnostics json`,
+		Short:   "Scans analyzeMe and runs the checks without producing the graph, so it can be used as a pre-merge gate. Every problem is reported as diagnostic and the command fails with exit code of the check, the lowest one when more than one check failed",
+		Long:    "Scans analyzeMe and runs the checks without producing the graph, so it can be used as a pre-merge gate. Every problem is reported as diagnostic and the command fails with exit code of the check, the lowest one when more than one check failed\n\n" + checksHelp(),
+		RunE:    validateDirs(vc),
+	}
+	addScanFlags(validateCmd, vc.scanCfg)
+	validateCmd.Flags().StringSliceVar(&vc.checks, "checks", defaultChecks(), fmt.Sprintf("Sets checks to run, allowed values: %v, see the help of the command for what every check reports", sortedNames(checks)))
+
+	validateCmd.Flags().IntVar(&vc.thresholds.MaxDepth, "max-graph-depth", 0, "Fails check thresholds when deployment depends on longer chain of deployments, e.g. 3 allows apps -> platform -> network -> account. Zero means no limit")
+	validateCmd.Flags().IntVar(&vc.thresholds.MaxFanIn, "max-fan-in", 0, "Fails check thresholds when more deployments depend on single deployment. Zero means no limit")
+	validateCmd.Flags().IntVar(&vc.thresholds.MaxDependencies, "max-dependencies", 0, "Fails check thresholds when deployment depends on more states. Zero means no limit")
+
+	return validateCmd
+}
+
+func validateDirs(c *validateCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		enabled := make(map[string]check, len(c.checks))
+		for _, name := range c.checks {
+			ch, ok := checks[name]
+			if !ok {
+				return fmt.Errorf("unsupported check: %s, allowed values: %v", name, sortedNames(checks))
+			}
+			enabled[ch.rule] = ch
+		}
+
+		var verifier *stateVerifier
+		_, verifyObjects := enabled[terradep.RuleMissingState]
+		_, verifyOutputs := enabled[terradep.RuleMissingOutput]
+		if verifyObjects || verifyOutputs {
+			verifier = newStateVerifier(log, c.concurrency)
+			// credentials are checked before scanning, so the command fails fast
+			if verifyObjects && verifier.s3Err != nil {
+				return verifier.s3Err
+			}
+		}
+
+		opts, err := c.scannerOpts(log)
+		if err != nil {
+			return err
+		}
+		// all the modules are scanned, so every problem is reported at once
+		opts = append(opts, terradep.WithContinueOnError())
+
+		s := terradep.NewScanner(log, c.newStater(), opts...)
+		var deployments []terradep.Deployment
+		var diags terradep.Diagnostics
+		for _, dir := range c.dirs {
+			log.Info("validating directory", slog.String("dir", dir))
+			dirDiags, err := s.Stream(dir, func(d terradep.Deployment) error {
+				deployments = append(deployments, d)
+				return nil
+			})
+			diags = append(diags, dirDiags...)
+			if err != nil {
+				return scanError(fmt.Errorf("failed to scan path: %s, error was: %w", dir, err))
+			}
+		}
+		diags = append(diags, terradep.CheckDeployments(deployments)...)
+		diags = append(diags, terradep.CheckLayers(deployments, c.layers)...)
+		diags = append(diags, terradep.CheckThresholds(deployments, c.thresholds)...)
+		diags = append(diags, terradep.CheckRemoteStates(deployments)...)
+		if _, ok := enabled[terradep.RuleCrossRegion]; ok {
+			diags = append(diags, terradep.CheckRegions(deployments)...)
+		}
+		if _, ok := enabled[terradep.RuleCrossAccount]; ok {
+			diags = append(diags, terradep.CheckAccounts(deployments)...)
+		}
+		if verifyObjects {
+			diags = append(diags, verifier.verify(deployments)...)
+		}
+		if verifyOutputs {
+			diags = append(diags, verifier.verifyOutputs(deployments)...)
+		}
+
+		// reported are warnings and failures of enabled checks
+		var reported terradep.Diagnostics
+		var codes []int
+		for _, diag := range diags {
+			ch, ok := enabled[diag.Rule]
+			if !ok {
+				if diag.Severity == terradep.SeverityWarning {
+					reported = append(reported, diag)
+				}
+				continue
+			}
+			diag.Severity = terradep.SeverityError
+			reported = append(reported, diag)
+			codes = append(codes, ch.code)
+		}
+
+		if err := c.printDiagnostics(reported); err != nil {
+			return err
+		}
+
+		log.Info("validation finished", slog.Int("deployments", len(deployments)), slog.Int("failures", len(codes)))
+		if len(codes) == 0 {
+			return nil
+		}
+
+		sort.Ints(codes)
+		return withExitCode(codes[0], fmt.Errorf("validation failed, problems found: %d", len(codes)))
+	}
+}
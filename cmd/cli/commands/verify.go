@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/state"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	verifyTimeout = 10 * time.Second
+	// maxStateSize limits the size of the state downloaded to read its outputs
+	maxStateSize = 256 << 20
+)
+
+// errNotVerified is returned for the states of the backends which cannot be read by [stateVerifier]
+var errNotVerified = errors.New("backend of the state is not supported")
+
+// s3CredentialsErrorCodes are the codes of S3 errors returned when the credentials are rejected, not because of the permissions of the object
+var s3CredentialsErrorCodes = map[string]struct{}{
+	"InvalidAccessKeyId":    {},
+	"SignatureDoesNotMatch": {},
+	"ExpiredToken":          {},
+	"InvalidToken":          {},
+	"TokenRefreshRequired":  {},
+	"RequestTimeTooSkewed":  {},
+}
+
+// stateVerifier checks whether the states exist and contain the outputs consumed by the deployments, before terraform fails at plan time.
+// States are read from S3 and Terraform Cloud
+type stateVerifier struct {
+	log         *slog.Logger
+	concurrency int
+	// s3 is nil when AWS configuration cannot be loaded, then s3Err is returned for the states in S3
+	s3    *s3Client
+	s3Err error
+
+	tfc tfcClients
+}
+
+func newStateVerifier(log *slog.Logger, concurrency int) *stateVerifier {
+	s3, err := newS3Client(context.Background(), verifyTimeout)
+	if err != nil {
+		err = fmt.Errorf("verifying states, %w", err)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &stateVerifier{log: log, concurrency: concurrency, s3: s3, s3Err: err}
+}
+
+// verify sends HEAD request for every S3 state the deployments depend on and reports every dependency on missing or unreadable state
+// as [terradep.RuleMissingState]. States of other backends are not verified
+func (v *stateVerifier) verify(deployments []terradep.Deployment) terradep.Diagnostics {
+	problems := v.forEachState(uniqueDependencies(deployments), func(s terradep.State) error {
+		s3State, workspace, ok := s3StateOf(s)
+		if !ok {
+			return errNotVerified
+		}
+		return v.headS3(s3State, workspace)
+	})
+
+	var diags terradep.Diagnostics
+	for _, d := range deployments {
+		for _, dep := range d.Dependencies {
+			err, ok := problems[dep.Identity()]
+			if !ok || errors.Is(err, errNotVerified) {
+				continue
+			}
+			diags = append(diags, edgeDiagnostic(d, dep, terradep.RuleMissingState, fmt.Sprintf("cannot read state: %s", dep), err.Error()))
+		}
+	}
+
+	return diags
+}
+
+// verifyOutputs reads outputs of every state the deployments consume outputs of and reports every consumed output missing in the state
+// as [terradep.RuleMissingOutput]. Outputs referenced as a whole and states of backends other than S3 and Terraform Cloud are not verified
+func (v *stateVerifier) verifyOutputs(deployments []terradep.Deployment) terradep.Diagnostics {
+	var consumed []terradep.State
+	seen := make(map[string]struct{})
+	for _, d := range deployments {
+		for _, dep := range d.Dependencies {
+			if _, ok := seen[dep.Identity()]; ok || len(d.EdgeMetadata(dep).Outputs) == 0 {
+				continue
+			}
+			seen[dep.Identity()] = struct{}{}
+			consumed = append(consumed, dep)
+		}
+	}
+
+	outputs := make(map[string]map[string]struct{}, len(consumed))
+	mu := sync.Mutex{}
+	problems := v.forEachState(consumed, func(s terradep.State) error {
+		names, err := v.stateOutputs(s)
+		if err != nil {
+			return err
+		}
+		set := make(map[string]struct{}, len(names))
+		for _, name := range names {
+			set[name] = struct{}{}
+		}
+		mu.Lock()
+		outputs[s.Identity()] = set
+		mu.Unlock()
+		return nil
+	})
+
+	var diags terradep.Diagnostics
+	for _, d := range deployments {
+		for _, dep := range d.Dependencies {
+			if err, ok := problems[dep.Identity()]; ok {
+				if !errors.Is(err, errNotVerified) {
+					diags = append(diags, edgeDiagnostic(d, dep, terradep.RuleMissingOutput, fmt.Sprintf("cannot read outputs of state: %s", dep), err.Error()))
+				}
+				continue
+			}
+
+			available, ok := outputs[dep.Identity()]
+			if !ok {
+				continue
+			}
+			for _, output := range d.EdgeMetadata(dep).Outputs {
+				if _, ok := available[output]; ok || output == terradep.AllOutputs {
+					continue
+				}
+				diags = append(diags, edgeDiagnostic(d, dep, terradep.RuleMissingOutput, fmt.Sprintf("output: %s is not in state: %s", output, dep), fmt.Sprintf("available outputs: %v", sortedNames(available))))
+			}
+		}
+	}
+
+	return diags
+}
+
+// forEachState calls the function for every state, at most concurrency at the same time, and returns errors by [terradep.State.Identity]
+func (v *stateVerifier) forEachState(states []terradep.State, fn func(terradep.State) error) map[string]error {
+	problems := make(map[string]error)
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, v.concurrency)
+	for _, s := range states {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(s terradep.State) {
+			defer func() { <-sem; wg.Done() }()
+			if err := fn(s); err != nil {
+				mu.Lock()
+				problems[s.Identity()] = err
+				mu.Unlock()
+			}
+		}(s)
+	}
+	wg.Wait()
+
+	return problems
+}
+
+// stateOutputs returns names of the outputs of the state, errNotVerified when the backend is not supported or the workspace is not known
+func (v *stateVerifier) stateOutputs(s terradep.State) ([]string, error) {
+	if s3State, workspace, ok := s3StateOf(s); ok {
+		return v.s3Outputs(s3State, workspace)
+	}
+	if workspace, ok := tfcWorkspaceOf(s); ok {
+		return v.tfcOutputs(workspace)
+	}
+
+	return nil, errNotVerified
+}
+
+// headS3 checks whether the state object exists and is readable
+func (v *stateVerifier) headS3(s state.S3State, workspace string) error {
+	client, err := v.s3ForState(s)
+	if err != nil {
+		return err
+	}
+
+	key := s.WorkspaceKey(workspace)
+	v.log.Debug("reading state", slog.String("method", http.MethodHead), slog.String("bucket", s.Bucket), slog.String("key", key))
+	_, err = client.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+
+	return s3ObjectError(err, http.MethodHead, s.Bucket, key)
+}
+
+// s3Outputs downloads the state object and returns names of its outputs
+func (v *stateVerifier) s3Outputs(s state.S3State, workspace string) ([]string, error) {
+	client, err := v.s3ForState(s)
+	if err != nil {
+		return nil, err
+	}
+
+	key := s.WorkspaceKey(workspace)
+	v.log.Debug("reading state", slog.String("method", http.MethodGet), slog.String("bucket", s.Bucket), slog.String("key", key))
+	out, err := client.GetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, s3ObjectError(err, http.MethodGet, s.Bucket, key)
+	}
+	defer out.Body.Close()
+
+	var tfState struct {
+		Outputs map[string]json.RawMessage `json:"outputs"`
+	}
+	if err := json.NewDecoder(io.LimitReader(out.Body, maxStateSize)).Decode(&tfState); err != nil {
+		return nil, fmt.Errorf("decoding state: s3://%s/%s, %w", s.Bucket, key, err)
+	}
+
+	return sortedNames(tfState.Outputs), nil
+}
+
+// s3ForState returns client of S3 with the region, the profile and the role of the state
+func (v *stateVerifier) s3ForState(s state.S3State) (*s3.Client, error) {
+	if v.s3 == nil {
+		return nil, v.s3Err
+	}
+
+	client, err := v.s3.forState(context.Background(), s.Region, s.Profile, s.RoleARN)
+	if err != nil {
+		return nil, fmt.Errorf("reading state: s3://%s/%s, %w", s.Bucket, s.Key, err)
+	}
+
+	return client, nil
+}
+
+// s3ObjectError returns error describing failed request for the object, nil when the request succeeded.
+// Response to HEAD request has no body with the code of the error, so 403 may also mean that the object does not exist
+// and listing the bucket is not allowed
+func s3ObjectError(err error, method, bucket, key string) error {
+	if err == nil {
+		return nil
+	}
+
+	status := 0
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		status = respErr.HTTPStatusCode()
+	}
+	code := ""
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code = apiErr.ErrorCode()
+	}
+	_, rejected := s3CredentialsErrorCodes[code]
+
+	switch {
+	case status == http.StatusNotFound:
+		return fmt.Errorf("object does not exist: s3://%s/%s", bucket, key)
+	case rejected:
+		return fmt.Errorf("credentials rejected by S3: %s, reading object: s3://%s/%s", code, bucket, key)
+	case status == http.StatusForbidden && method == http.MethodHead:
+		return fmt.Errorf("access denied to object, or it does not exist and s3:ListBucket is not allowed: s3://%s/%s", bucket, key)
+	case status == http.StatusForbidden:
+		return fmt.Errorf("access denied to object: s3://%s/%s", bucket, key)
+	default:
+		return fmt.Errorf("requesting object: s3://%s/%s, %w", bucket, key, err)
+	}
+}
+
+// tfcOutputs returns names of the outputs of the current state of the workspace
+func (v *stateVerifier) tfcOutputs(workspace tfcWorkspace) ([]string, error) {
+	client, err := v.tfc.client(workspace.hostname)
+	if err != nil {
+		return nil, err
+	}
+	id, err := client.workspaceID(workspace.organization, workspace.name)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.outputs(id)
+}
+
+// edgeDiagnostic returns the error pointing to the code of the deployment referencing the state
+func edgeDiagnostic(d terradep.Deployment, dep terradep.State, rule, summary, detail string) terradep.Diagnostic {
+	diag := terradep.Diagnostic{Severity: terradep.SeverityError, Rule: rule, Summary: summary, Detail: detail, Module: d.Path}
+	if ranges := d.EdgeMetadata(dep).Ranges; len(ranges) != 0 {
+		diag.Range = &ranges[0]
+	}
+
+	return diag
+}
+
+// s3StateOf returns the S3 state and its workspace, false when the state is not stored in S3
+func s3StateOf(s terradep.State) (state.S3State, string, bool) {
+	s, workspace := unwrapWorkspace(s)
+	s3State, ok := s.(state.S3State)
+
+	return s3State, workspace, ok
+}
+
+func unwrapWorkspace(s terradep.State) (terradep.State, string) {
+	if ws, ok := s.(terradep.WorkspaceState); ok {
+		return ws.State, ws.Workspace
+	}
+
+	return s, ""
+}
+
+// uniqueDependencies returns the states the deployments depend on, every state once
+func uniqueDependencies(deployments []terradep.Deployment) []terradep.State {
+	seen := make(map[string]struct{})
+	var out []terradep.State
+	for _, d := range deployments {
+		for _, dep := range d.Dependencies {
+			if _, ok := seen[dep.Identity()]; ok {
+				continue
+			}
+			seen[dep.Identity()] = struct{}{}
+			out = append(out, dep)
+		}
+	}
+
+	return out
+}
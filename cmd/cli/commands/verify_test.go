@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/state"
+	"golang.org/x/exp/slog"
+)
+
+// assumeRoleResponse is the reply of STS stub to AssumeRole, credentials of the role have access key assumedAccessKey
+const assumeRoleResponse = `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult>
+<Credentials><AccessKeyId>` + assumedAccessKey + `</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>2100-01-01T00:00:00Z</Expiration></Credentials>
+<AssumedRoleUser><Arn>arn:aws:sts::222222222222:assumed-role/reader/session</Arn><AssumedRoleId>id:session</AssumedRoleId></AssumedRoleUser>
+</AssumeRoleResult></AssumeRoleResponse>`
+
+const assumedAccessKey = "ASIAASSUMED"
+
+// accessKey returns the access key of the credentials which signed the request
+func accessKey(r *http.Request) string {
+	_, credential, _ := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+	key, _, _ := strings.Cut(credential, "/")
+
+	return key
+}
+
+// s3Object replies to the requests of the object at the path signed with the access key, to others with 404 and to AssumeRole with the role
+func s3Object(path, key string) func(r *http.Request) (int, string) {
+	return func(r *http.Request) (int, string) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/":
+			return http.StatusOK, assumeRoleResponse
+		case r.URL.Path != path:
+			return http.StatusNotFound, ""
+		case accessKey(r) != key:
+			return http.StatusForbidden, ""
+		default:
+			return http.StatusOK, `{"outputs": {}}`
+		}
+	}
+}
+
+func TestVerifyS3States(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       map[string]cty.Value
+		workspace string
+		reply     func(r *http.Request) (int, string)
+		profile   string
+		want      string
+	}{
+		{
+			name:  "existing object",
+			cfg:   map[string]cty.Value{"bucket": cty.StringVal("b"), "key": cty.StringVal("network")},
+			reply: s3Object("/b/network", "AKIDEXAMPLE"),
+		},
+		{
+			name:      "default workspace key prefix",
+			cfg:       map[string]cty.Value{"bucket": cty.StringVal("b"), "key": cty.StringVal("network")},
+			workspace: "staging",
+			reply:     s3Object("/b/env:/staging/network", "AKIDEXAMPLE"),
+		},
+		{
+			name:      "workspace key prefix",
+			cfg:       map[string]cty.Value{"bucket": cty.StringVal("b"), "key": cty.StringVal("network"), "workspace_key_prefix": cty.StringVal("workspaces")},
+			workspace: "staging",
+			reply:     s3Object("/b/workspaces/staging/network", "AKIDEXAMPLE"),
+		},
+		{
+			name:  "role of the state",
+			cfg:   map[string]cty.Value{"bucket": cty.StringVal("b"), "key": cty.StringVal("network"), "role_arn": cty.StringVal("arn:aws:iam::222222222222:role/reader")},
+			reply: s3Object("/b/network", assumedAccessKey),
+		},
+		{
+			name:    "profile of the state",
+			cfg:     map[string]cty.Value{"bucket": cty.StringVal("b"), "key": cty.StringVal("network"), "profile": cty.StringVal("reader")},
+			profile: "[profile reader]\naws_access_key_id = AKIDPROFILE\naws_secret_access_key = secret\n",
+			reply:   s3Object("/b/network", "AKIDPROFILE"),
+		},
+		{
+			name:  "missing object",
+			cfg:   map[string]cty.Value{"bucket": cty.StringVal("b"), "key": cty.StringVal("network")},
+			reply: s3Object("/b/other", "AKIDEXAMPLE"),
+			want:  "object does not exist: s3://b/network",
+		},
+		{
+			name:  "forbidden object",
+			cfg:   map[string]cty.Value{"bucket": cty.StringVal("b"), "key": cty.StringVal("network")},
+			reply: s3Object("/b/network", "AKIDOTHER"),
+			want:  "or it does not exist and s3:ListBucket is not allowed: s3://b/network",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newS3Stub(t, tt.reply)
+			if tt.profile != "" {
+				if err := os.WriteFile(os.Getenv("AWS_CONFIG_FILE"), []byte(tt.profile), 0o600); err != nil {
+					t.Fatalf("writing AWS config: %v", err)
+				}
+			}
+
+			s, err := state.NewS3Stater().RemoteState(state.S3Backend, tt.cfg)
+			if err != nil {
+				t.Fatalf("reading state: %v", err)
+			}
+			if tt.workspace != "" {
+				s = terradep.WorkspaceState{State: s, Workspace: tt.workspace}
+			}
+
+			v := newStateVerifier(slog.New(slog.NewTextHandler(io.Discard, nil)), 1)
+			diags := v.verify([]terradep.Deployment{{Path: "app", Dependencies: []terradep.State{s}}})
+
+			switch {
+			case tt.want == "" && len(diags) != 0:
+				t.Errorf("unexpected diagnostics: %+v", diags)
+			case tt.want != "" && (len(diags) != 1 || !strings.Contains(diags[0].Detail, tt.want)):
+				t.Errorf("expected diagnostic with detail: %s, got: %+v", tt.want, diags)
+			}
+		})
+	}
+}
+
+func TestS3OutputsReportsRejectedCredentials(t *testing.T) {
+	newS3Stub(t, func(r *http.Request) (int, string) {
+		return http.StatusForbidden, `<Error><Code>SignatureDoesNotMatch</Code><Message>signature does not match</Message></Error>`
+	})
+
+	s, err := state.NewS3Stater().RemoteState(state.S3Backend, map[string]cty.Value{"bucket": cty.StringVal("b"), "key": cty.StringVal("network")})
+	if err != nil {
+		t.Fatalf("reading state: %v", err)
+	}
+
+	v := newStateVerifier(slog.New(slog.NewTextHandler(io.Discard, nil)), 1)
+	_, err = v.s3Outputs(s.(state.S3State), "")
+	if err == nil || !strings.Contains(err.Error(), "credentials rejected by S3: SignatureDoesNotMatch") {
+		t.Errorf("expected rejected credentials, got: %v", err)
+	}
+}
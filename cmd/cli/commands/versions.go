@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+	"golang.org/x/exp/slog"
+)
+
+type versionsCfg struct {
+	*scanCfg
+	format  string
+	lenient bool
+	strict  bool
+}
+
+func newVersionsCommand(rc *rootCfg) *cobra.Command {
+	vc := &versionsCfg{scanCfg: &scanCfg{rootCfg: rc}}
+	versionsCmd := &cobra.Command{
+		Use:     `versions [--format (TABLE|JSON)] [--strict] --dir analyzeMe`,
+		Example: `versions --dir analyzeMe --format json | jq '.constraints'`,
+		Short: "Reports Terraform versions required by every deployment found in analyzeMe with required_version, how strictly they are pinned and whether they differ from the constraint used by the most of the deployments. " +
+			"Pinning is one of: exact, bounded (has upper bound, e.g. ~> 1.5.0), unbounded (only lower bound) or missing",
+		RunE: reportVersions(vc),
+	}
+	addScanFlags(versionsCmd, vc.scanCfg)
+	vF := versionsCmd.Flags()
+	vF.StringVar(&vc.format, "format", listTable, fmt.Sprintf("Sets format of the report. Allowed values: %s, %s", listTable, listJSON))
+	vF.BoolVar(&vc.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+	vF.BoolVar(&vc.strict, "strict", false, fmt.Sprintf("Fails with exit code %d when any deployment is unpinned, i.e. its version is unbounded or missing, or inconsistent with the other deployments", ExitPolicyViolation))
+
+	return versionsCmd
+}
+
+func reportVersions(c *versionsCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		format := strings.ToUpper(c.format)
+		if format != listTable && format != listJSON {
+			return fmt.Errorf("unsupported report format: %s, allowed values: %s, %s", c.format, listTable, listJSON)
+		}
+
+		opts, err := c.scannerOpts(log)
+		if err != nil {
+			return err
+		}
+		if c.lenient {
+			opts = append(opts, terradep.WithContinueOnError())
+		}
+
+		s := terradep.NewScanner(log, c.newStater(), opts...)
+		var deployments []terradep.Deployment
+		for _, dir := range c.dirs {
+			log.Info("reading versions of directory", slog.String("dir", dir))
+			diags, err := s.Stream(dir, func(d terradep.Deployment) error {
+				deployments = append(deployments, d)
+				return nil
+			})
+			if err := c.printDiagnostics(diags); err != nil {
+				return err
+			}
+			if err != nil {
+				return scanError(fmt.Errorf("failed to scan path: %s, error was: %w", dir, err))
+			}
+		}
+
+		var out io.Writer = cmd.OutOrStdout()
+		if c.dryRun {
+			out = io.Discard
+		}
+
+		report := terradep.NewVersionReport(deployments)
+		if err := writeVersionReport(out, format, report); err != nil {
+			return err
+		}
+
+		unpinned, inconsistent := report.Unpinned(), report.Inconsistent()
+		if c.strict && (len(unpinned) != 0 || len(inconsistent) != 0) {
+			return withExitCode(ExitPolicyViolation, fmt.Errorf("required_version of %d of %d deployments is not pinned and of %d deployments is inconsistent, distinct constraints: %d",
+				len(unpinned), len(report.Deployments), len(inconsistent), len(report.Constraints)))
+		}
+
+		return nil
+	}
+}
+
+func writeVersionReport(out io.Writer, format string, report *terradep.VersionReport) error {
+	if format == listJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		// constraints contain operators like >=, which are escaped by default
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("encoding version report: %w", err)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tREQUIRED VERSION\tPINNING\tCONSISTENT")
+	for _, d := range report.Deployments {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", d.Path, d.Constraint, d.Pinning, !d.Inconsistent)
+	}
+
+	return w.Flush()
+}
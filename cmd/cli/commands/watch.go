@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path/filepath"
+)
+
+// watchedDirsSkipped are not fingerprinted, they change without changes of the code
+var watchedDirsSkipped = map[string]struct{}{".git": {}, ".terraform": {}, ".terragrunt-cache": {}}
+
+// fingerprintDirs returns hash of paths, sizes and modification times of the files in the directories, which changes when any file is added,
+// removed or modified
+func fingerprintDirs(dirs []string) (uint64, error) {
+	h := fnv.New64a()
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// removed while walking, the next fingerprint will differ
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if _, ok := watchedDirsSkipped[d.Name()]; ok && d.IsDir() {
+				return filepath.SkipDir
+			}
+			info, err := d.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+
+			_, _ = h.Write([]byte(path))
+			_, _ = h.Write(binary.BigEndian.AppendUint64(nil, uint64(info.Size())))
+			_, _ = h.Write(binary.BigEndian.AppendUint64(nil, uint64(info.ModTime().UnixNano())))
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("fingerprinting directory: %s, %w", dir, err)
+		}
+	}
+
+	return h.Sum64(), nil
+}
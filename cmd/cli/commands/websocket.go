@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // required by the WebSocket handshake, not used for security
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// opcodes of the WebSocket frames, see RFC 6455
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+// wsGUID is appended to the key of the client to accept the handshake
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxPayload limits frames read from the clients, which only close the connection or ping the server
+const wsMaxPayload = 1 << 16
+
+// webSocket is the server side of the WebSocket connection, which pushes messages to the browser.
+// Frames can be written concurrently, but read by single goroutine
+type webSocket struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	// mu guards writing the frames
+	mu sync.Mutex
+}
+
+// upgradeWebSocket completes the handshake of the WebSocket connection. When it fails before the connection is hijacked, the error is written to the response
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*webSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "websocket connection expected", http.StatusBadRequest)
+		return nil, errors.New("not a websocket handshake")
+	}
+	if version := r.Header.Get("Sec-WebSocket-Version"); version != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("unsupported websocket version: %s", version)
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("response does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("hijacking connection: %w", err)
+	}
+	accept := sha1.Sum([]byte(key + wsGUID)) //nolint:gosec // required by the WebSocket handshake
+	_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " +
+		base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("completing websocket handshake: %w", err)
+	}
+
+	return &webSocket{conn: conn, rw: rw}, nil
+}
+
+// headerContains checks whether any of comma-separated values of the header is the value, ignoring case
+func headerContains(header http.Header, name, value string) bool {
+	for _, values := range header.Values(name) {
+		for _, v := range strings.Split(values, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// writeFrame writes single unmasked frame with the payload, as servers do
+func (ws *webSocket) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	_, _ = ws.rw.Write(header)
+	_, _ = ws.rw.Write(payload)
+	if err := ws.rw.Flush(); err != nil {
+		return fmt.Errorf("writing websocket frame: %w", err)
+	}
+
+	return nil
+}
+
+// readFrame reads the next frame sent by the client, fragmented messages are returned frame by frame
+func (ws *webSocket) readFrame() (opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.rw, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("reading websocket frame: %w", err)
+	}
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, fmt.Errorf("reading websocket frame: %w", err)
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.rw, ext[:]); err != nil {
+			return 0, nil, fmt.Errorf("reading websocket frame: %w", err)
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+	if !masked {
+		return 0, nil, errors.New("websocket frame of the client is not masked")
+	}
+	if size > wsMaxPayload {
+		return 0, nil, fmt.Errorf("websocket frame too large: %d bytes", size)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+		return 0, nil, fmt.Errorf("reading websocket frame: %w", err)
+	}
+	payload = make([]byte, size)
+	if _, err := io.ReadFull(ws.rw, payload); err != nil {
+		return 0, nil, fmt.Errorf("reading websocket frame: %w", err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return opcode, payload, nil
+}
+
+// Close closes the connection without the closing handshake
+func (ws *webSocket) Close() error {
+	return ws.conn.Close()
+}
@@ -0,0 +1,226 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUpgradeWebSocket(t *testing.T) {
+	upgraded := make(chan *webSocket, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ws, err := upgradeWebSocket(w, r); err == nil {
+			upgraded <- ws
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{name: "plain request", headers: map[string]string{}, status: http.StatusBadRequest},
+		{name: "without key", headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Version": "13"}, status: http.StatusBadRequest},
+		{
+			name:    "unsupported version",
+			headers: map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Sec-WebSocket-Version": "8"},
+			status:  http.StatusUpgradeRequired,
+		},
+		{
+			name:    "handshake",
+			headers: map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "WebSocket", "Sec-WebSocket-Key": "dGhlIHNhbXBsZSBub25jZQ==", "Sec-WebSocket-Version": "13"},
+			status:  http.StatusSwitchingProtocols,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", server.Listener.Addr().String())
+			if err != nil {
+				t.Fatalf("connecting to server: %v", err)
+			}
+			defer conn.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/events", http.NoBody)
+			if err != nil {
+				t.Fatalf("building request: %v", err)
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if err := req.Write(conn); err != nil {
+				t.Fatalf("writing request: %v", err)
+			}
+			r := bufio.NewReader(conn)
+			resp, err := http.ReadResponse(r, req)
+			if err != nil {
+				t.Fatalf("reading response: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("unexpected status: %d, want: %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == http.StatusUpgradeRequired && resp.Header.Get("Sec-WebSocket-Version") != "13" {
+				t.Errorf("expected supported version in response, got headers: %v", resp.Header)
+			}
+			if tt.status != http.StatusSwitchingProtocols {
+				return
+			}
+
+			// accept key from the example of RFC 6455
+			if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+				t.Errorf("unexpected accept key: %s", accept)
+			}
+			ws := <-upgraded
+			defer ws.Close()
+			go func() { _ = ws.writeFrame(wsText, []byte("hello")) }()
+			if opcode, payload := readServerFrame(t, r); opcode != wsText || string(payload) != "hello" {
+				t.Errorf("unexpected frame after handshake: %x, %q", opcode, payload)
+			}
+		})
+	}
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	t.Run("write", func(t *testing.T) {
+		// lengths encoded in 7 bits, 16 bits and 64 bits
+		for _, size := range []int{0, 125, 126, 0xffff, 0x10000} {
+			ws, client := pipeWebSocket(t)
+			payload := bytes.Repeat([]byte("x"), size)
+			go func() { _ = ws.writeFrame(wsText, payload) }()
+
+			opcode, got := readServerFrame(t, bufio.NewReader(client))
+			if opcode != wsText || !bytes.Equal(got, payload) {
+				t.Errorf("unexpected frame of %d bytes: opcode %x, %d bytes", size, opcode, len(got))
+			}
+		}
+	})
+
+	t.Run("read", func(t *testing.T) {
+		for _, size := range []int{0, 125, 126, 0xffff, wsMaxPayload} {
+			ws, client := pipeWebSocket(t)
+			payload := bytes.Repeat([]byte("ping"), size/4+1)[:size]
+			go func() { _, _ = client.Write(clientFrame(wsPing, payload, true)) }()
+
+			opcode, got, err := ws.readFrame()
+			if err != nil {
+				t.Fatalf("reading frame of %d bytes: %v", size, err)
+			}
+			if opcode != wsPing || !bytes.Equal(got, payload) {
+				t.Errorf("unexpected frame of %d bytes: opcode %x, %d bytes", size, opcode, len(got))
+			}
+		}
+	})
+
+	for _, tt := range []struct {
+		name  string
+		frame []byte
+		err   string
+	}{
+		{name: "unmasked", frame: clientFrame(wsText, []byte("hello"), false), err: "not masked"},
+		{name: "too large", frame: clientFrame(wsText, make([]byte, wsMaxPayload+1), true), err: "too large"},
+		{name: "truncated", frame: clientFrame(wsText, []byte("hello"), true)[:8], err: "reading websocket frame"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, client := pipeWebSocket(t)
+			go func() {
+				_, _ = client.Write(tt.frame)
+				client.Close()
+			}()
+
+			if _, _, err := ws.readFrame(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error: %q, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// pipeWebSocket returns the server side of the connection and the client side
+func pipeWebSocket(t *testing.T) (*webSocket, net.Conn) {
+	t.Helper()
+
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+
+	return &webSocket{conn: server, rw: bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server))}, client
+}
+
+// clientFrame encodes the frame like the browser, with the payload masked
+func clientFrame(opcode byte, payload []byte, masked bool) []byte {
+	frame := []byte{0x80 | opcode}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if !masked {
+		return append(frame, payload...)
+	}
+
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	return frame
+}
+
+// readServerFrame decodes single unmasked frame written by the server
+func readServerFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatalf("reading frame: %v", err)
+	}
+	if header[0]&0x80 == 0 {
+		t.Errorf("frame is not final: %x", header[0])
+	}
+	if header[1]&0x80 != 0 {
+		t.Errorf("frame of the server is masked")
+	}
+
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			t.Fatalf("reading length of frame: %v", err)
+		}
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			t.Fatalf("reading length of frame: %v", err)
+		}
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("reading payload of frame: %v", err)
+	}
+
+	return header[0] & 0x0f, payload
+}
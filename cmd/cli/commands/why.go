@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/spf13/cobra"
+	"go.interactor.dev/terradep"
+)
+
+func newWhyCommand(rc *rootCfg) *cobra.Command {
+	c := &graphCfg{scanCfg: &scanCfg{rootCfg: rc}}
+	cmd := &cobra.Command{
+		Use:     `why --dir analyzeMe DEPLOYMENT TARGET`,
+		Example: `why --dir . live/app s3://tf-state/network/terraform.tfstate`,
+		Short: "Explains why DEPLOYMENT depends on TARGET, directly or transitively, printing the shortest chain of dependencies with the file and line of the code referencing every state and the outputs it consumes. " +
+			"DEPLOYMENT and TARGET are paths of the deployments or states, e.g. s3://bucket/key. Fails when DEPLOYMENT does not depend on TARGET",
+		Args: cobra.ExactArgs(2),
+		RunE: explainDependency(c),
+	}
+	addScanFlags(cmd, c.scanCfg)
+	cmd.Flags().BoolVar(&c.lenient, "lenient", false, "Skips modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend, instead of failing. Skipped modules are reported on standard error")
+
+	return cmd
+}
+
+func explainDependency(c *graphCfg) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		log, err := buildLogger(*c.rootCfg)
+		if err != nil {
+			return fmt.Errorf("failed to build logger: %w", err)
+		}
+
+		graph, err := scanGraph(log, c)
+		if err != nil {
+			return err
+		}
+
+		from, to := matchingNodes(graph, args[0]), matchingNodes(graph, args[1])
+		switch {
+		case len(from) == 0:
+			return fmt.Errorf("deployment or state not found: %s", args[0])
+		case len(to) == 0:
+			return fmt.Errorf("deployment or state not found: %s", args[1])
+		}
+
+		chain := shortestChain(from, to)
+		if chain == nil {
+			return fmt.Errorf("%s does not depend on %s", args[0], args[1])
+		}
+
+		writeChain(cmd.OutOrStdout(), chain)
+		return nil
+	}
+}
+
+// matchingNodes returns nodes with the state or the path, which can be relative to the working directory. All workspaces and overlays of the deployment match its path
+func matchingNodes(graph *terradep.Graph, arg string) []*terradep.Node {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		abs = arg
+	}
+
+	var out []*terradep.Node
+	for _, n := range graph.Nodes() {
+		if n.State.String() == arg {
+			return []*terradep.Node{n}
+		}
+		if filepath.Clean(n.Path) == filepath.Clean(arg) {
+			out = append(out, n)
+			continue
+		}
+		if path, err := filepath.Abs(n.Path); err == nil && path == abs {
+			out = append(out, n)
+		}
+	}
+
+	return out
+}
+
+// shortestChain returns the shortest chain of dependencies from any of the nodes to any of the targets, nil when there is none
+func shortestChain(from, targets []*terradep.Node) []*terradep.Node {
+	isTarget := make(map[*terradep.Node]struct{}, len(targets))
+	for _, n := range targets {
+		isTarget[n] = struct{}{}
+	}
+
+	prev := make(map[*terradep.Node]*terradep.Node)
+	queue := make([]*terradep.Node, 0, len(from))
+	for _, n := range from {
+		prev[n] = nil
+		queue = append(queue, n)
+	}
+
+	for len(queue) != 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if _, ok := isTarget[n]; ok && prev[n] != nil {
+			var chain []*terradep.Node
+			for ; n != nil; n = prev[n] {
+				chain = append([]*terradep.Node{n}, chain...)
+			}
+			return chain
+		}
+		for _, child := range n.Children {
+			if _, ok := prev[child]; !ok {
+				prev[child] = n
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeChain writes the first node with its backend, then every dependency with the code referencing it, one per line
+func writeChain(w io.Writer, chain []*terradep.Node) {
+	first := nodeLabel(chain[0])
+	if chain[0].BackendRange != nil {
+		first += " (backend: " + chain[0].BackendRange.String() + ")"
+	}
+	fmt.Fprintln(w, first)
+
+	for i := 1; i < len(chain); i++ {
+		edge := chain[i-1].EdgeMetadata(chain[i])
+		line := fmt.Sprintf("%s-> %s: %s", strings.Repeat("  ", i), nodeLabel(chain[i]), rangesString(edge.Ranges))
+		if len(edge.Outputs) != 0 {
+			line += " (outputs: " + strings.Join(edge.Outputs, ", ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func rangesString(ranges []hcl.Range) string {
+	if len(ranges) == 0 {
+		return "declared in manifest"
+	}
+
+	out := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		out = append(out, r.String())
+	}
+
+	return strings.Join(out, ", ")
+}
@@ -11,7 +11,7 @@ import (
 func main() {
 	command := commands.NewCommand()
 	if err := command.Execute(); err != nil {
-		fmt.Printf("terradep failed: %s\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "terradep failed: %s\n", err)
+		os.Exit(commands.ExitCode(err))
 	}
 }
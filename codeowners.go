@@ -0,0 +1,233 @@
+package terradep
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// CodeOwnersFiles are locations of CODEOWNERS file relative to the root of the repository, in the order they are looked up by [FindCodeOwners]
+var CodeOwnersFiles = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+// CodeOwners are owners of the paths of the repository read from CODEOWNERS file, like used by GitHub and GitLab
+type CodeOwners struct {
+	// root is the directory of the repository, patterns are relative to it
+	root  string
+	rules []codeOwnersRule
+}
+
+type codeOwnersRule struct {
+	// globs are patterns converted to doublestar syntax, the rule applies when any of them matches
+	globs  []string
+	owners []string
+}
+
+// FindCodeOwners reads the first of [CodeOwnersFiles] found in the root of git repository containing the dir.
+// Returns nil, when the dir is not in a repository or the repository does not have CODEOWNERS
+func FindCodeOwners(dir string) (*CodeOwners, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolving directory: %s, %w", dir, err)
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+
+	for _, name := range CodeOwnersFiles {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(path); err == nil {
+			return ReadCodeOwners(path)
+		}
+	}
+
+	return nil, nil
+}
+
+// ReadCodeOwners reads CODEOWNERS file. Patterns are relative to the directory of the file or,
+// when the file is in directory .github, .gitlab or docs, to its parent
+func ReadCodeOwners(path string) (*CodeOwners, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolving path of CODEOWNERS: %s, %w", path, err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening CODEOWNERS: %s, %w", path, err)
+	}
+	defer file.Close()
+
+	root := filepath.Dir(path)
+	switch filepath.Base(root) {
+	case ".github", ".gitlab", "docs":
+		root = filepath.Dir(root)
+	}
+
+	owners, err := ParseCodeOwners(file, root)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CODEOWNERS: %s, %w", path, err)
+	}
+
+	return owners, nil
+}
+
+// ParseCodeOwners parses CODEOWNERS with patterns relative to the root directory. Every line is a pattern followed by owners,
+// e.g. '/live/network/ @org/network-team'. Sections and optional approvals of GitLab are ignored
+func ParseCodeOwners(r io.Reader, root string) (*CodeOwners, error) {
+	c := &CodeOwners{root: root}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(text, " #"); i >= 0 {
+			text = strings.TrimSpace(text[:i])
+		}
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, "[") || strings.HasPrefix(text, "^[") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		globs, err := codeOwnersGlobs(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		c.rules = append(c.rules, codeOwnersRule{globs: globs, owners: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// codeOwnersGlobs converts the pattern of CODEOWNERS, which follows the rules of gitignore, to doublestar globs matching paths of the files
+func codeOwnersGlobs(pattern string) ([]string, error) {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	trimmed := strings.Trim(pattern, "/")
+	if trimmed == "" {
+		return nil, fmt.Errorf("invalid pattern: %q", pattern)
+	}
+
+	// pattern without slash, other than the trailing one, matches at any depth
+	glob := trimmed
+	if !strings.HasPrefix(pattern, "/") && !strings.Contains(trimmed, "/") {
+		glob = "**/" + glob
+	}
+	if !doublestar.ValidatePattern(glob) {
+		return nil, fmt.Errorf("invalid pattern: %q", pattern)
+	}
+
+	switch {
+	case dirOnly:
+		return []string{glob + "/**"}, nil
+	case strings.HasSuffix(glob, "/*"):
+		// unlike gitignore, docs/* does not match files in subdirectories of docs
+		return []string{glob}, nil
+	default:
+		return []string{glob, glob + "/**"}, nil
+	}
+}
+
+// Owners returns owners of the file, the last matching pattern wins. Relative paths are relative to the root of the repository.
+// Returns nil, when the file is not owned by anyone or it is outside of the repository
+func (c *CodeOwners) Owners(path string) []string {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return nil
+		}
+		path = rel
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == ".." || strings.HasPrefix(path, "../") {
+		return nil
+	}
+
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		for _, glob := range c.rules[i].globs {
+			if ok, _ := doublestar.Match(glob, path); ok {
+				return c.rules[i].owners
+			}
+		}
+	}
+
+	return nil
+}
+
+// codeOwnersFile is the file which owners are the owners of the deployment, like the file declaring the backend it is expected in every deployment
+const codeOwnersFile = "main.tf"
+
+// WithCodeOwners makes the [Scanner] set [Node.CodeOwners] and [Deployment.CodeOwners] to the owners of main.tf of the deployment
+func WithCodeOwners(owners *CodeOwners) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.codeOwners = owners
+	}
+}
+
+// ownersOf returns the code owners of the module, nil when the [Scanner] was created without [WithCodeOwners]
+func (s *Scanner) ownersOf(path string) []string {
+	if s.codeOwners == nil {
+		return nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+
+	return s.codeOwners.Owners(filepath.Join(abs, codeOwnersFile))
+}
+
+// Team returns [Node.Owner] declared in the [Manifest] or, when it is empty, the first of [Node.CodeOwners]
+func (n *Node) Team() string {
+	return team(n.Owner, n.CodeOwners)
+}
+
+// HasTeam checks whether the team is [Node.Owner] or any of [Node.CodeOwners]. Leading @ is ignored, so @org/team is the same as org/team
+func (n *Node) HasTeam(team string) bool {
+	return hasTeam(n.Owner, n.CodeOwners, team)
+}
+
+// Team returns [Deployment.Owner] declared in the [Manifest] or, when it is empty, the first of [Deployment.CodeOwners]
+func (d Deployment) Team() string {
+	return team(d.Owner, d.CodeOwners)
+}
+
+// HasTeam checks whether the team is [Deployment.Owner] or any of [Deployment.CodeOwners], like [Node.HasTeam]
+func (d Deployment) HasTeam(team string) bool {
+	return hasTeam(d.Owner, d.CodeOwners, team)
+}
+
+func team(owner string, codeOwners []string) string {
+	if owner != "" || len(codeOwners) == 0 {
+		return owner
+	}
+
+	return codeOwners[0]
+}
+
+func hasTeam(owner string, codeOwners []string, team string) bool {
+	team = strings.TrimPrefix(team, "@")
+	if owner != "" && strings.TrimPrefix(owner, "@") == team {
+		return true
+	}
+	for _, o := range codeOwners {
+		if strings.TrimPrefix(o, "@") == team {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,182 @@
+package terradep
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// WithIOConcurrency sets how many modules the [Scanner] reads and parses at the same time. Lower it on slow network filesystems,
+// e.g. EFS or NFS checkouts in CI, to avoid thrashing the mount. Defaults to 1, so modules are scanned one by one.
+// [Stater] and [ScanCache] used with value greater than 1 must be safe for concurrent use.
+// The graph does not depend on the concurrency, only the order of [Diagnostics] may change. [Scanner.Explain] always scans modules one by one
+func WithIOConcurrency(n int) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.concurrency = n
+	}
+}
+
+// moduleTask is the module scanned by the worker. Results are stored in its own scan and merged into the parent scan in walk order
+type moduleTask struct {
+	sc   *scan
+	path string
+	err  error
+	done chan struct{}
+}
+
+// workers run the module tasks of single scan
+type workers struct {
+	sem   chan struct{}
+	wg    sync.WaitGroup
+	tasks []*moduleTask
+	// err is the first error in walk order, returned by the scan
+	err    error
+	failed atomic.Bool
+}
+
+func newWorkers(concurrency int) *workers {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &workers{sem: make(chan struct{}, concurrency)}
+}
+
+// scanModuleAsync scans the module dir by the worker, blocking when all the workers are busy.
+// Modules are scanned synchronously when concurrency is 1 or the scan explains decisions of the [Scanner], so reports keep walk order
+func (s *Scanner) scanModuleAsync(sc *scan, path string) error {
+	if cap(sc.workers.sem) == 1 || sc.explain {
+		if err := s.scanModuleTraced(sc, path); !errors.Is(err, fs.SkipDir) {
+			return err
+		}
+		if err := s.moduleScanned(sc, path); err != nil {
+			return err
+		}
+
+		// do not scan submodules
+		return fs.SkipDir
+	}
+
+	if sc.workers.failed.Load() {
+		// the scan fails anyway, do not start new modules
+		return filepath.SkipAll
+	}
+
+	task := &moduleTask{sc: sc.child(), path: path, done: make(chan struct{})}
+	sc.workers.tasks = append(sc.workers.tasks, task)
+	sc.workers.sem <- struct{}{}
+	sc.workers.wg.Add(1)
+	go func() {
+		defer sc.workers.wg.Done()
+		defer func() { <-sc.workers.sem }()
+		defer close(task.done)
+
+		task.err = s.scanModuleTraced(task.sc, path)
+		if task.err != nil && !errors.Is(task.err, fs.SkipDir) {
+			sc.workers.failed.Store(true)
+		}
+	}()
+
+	if err := s.mergeDone(sc, false); err != nil {
+		return err
+	}
+
+	// do not scan submodules
+	return fs.SkipDir
+}
+
+// wait waits for all the module tasks and merges their results into the scan.
+// Returns error of the first failed module in walk order
+func (s *Scanner) wait(sc *scan) error {
+	sc.workers.wg.Wait()
+	return s.mergeDone(sc, true)
+}
+
+// mergeDone merges results of the finished tasks into the scan, in walk order. When block is true, waits for the tasks which did not finish.
+// After the first error results are still merged, but modules are not passed to [Scanner.moduleScanned]
+func (s *Scanner) mergeDone(sc *scan, block bool) error {
+	w := sc.workers
+	for len(w.tasks) != 0 {
+		task := w.tasks[0]
+		if block {
+			<-task.done
+		} else {
+			select {
+			case <-task.done:
+			default:
+				return w.err
+			}
+		}
+		w.tasks = w.tasks[1:]
+		sc.merge(task.sc)
+
+		if w.err != nil {
+			continue
+		}
+		if task.err != nil && !errors.Is(task.err, fs.SkipDir) {
+			w.err = task.err
+		} else {
+			w.err = s.moduleScanned(sc, task.path)
+		}
+		if w.err != nil {
+			w.failed.Store(true)
+		}
+	}
+
+	return w.err
+}
+
+// child returns empty scan sharing the files and the parser with sc, used to scan single module by the worker
+func (sc *scan) child() *scan {
+	return &scan{
+		root:        sc.root,
+		states:      map[deployment]State{},
+		deps:        map[deployment][]State{},
+		modules:     map[deployment][]string{},
+		details:     map[string]*nodeDetails{},
+		annotations: map[string][]declaredDependency{},
+		calls:       map[string][]string{},
+		noBackend:   map[string]error{},
+		fs:          sc.fs,
+		parser:      sc.parser,
+		span:        sc.span,
+		moduleSpans: sc.moduleSpans,
+		record:      sc.record,
+		stats:       sc.stats,
+	}
+}
+
+// merge adds results of the child scan to sc
+func (sc *scan) merge(child *scan) {
+	for dep, state := range child.states {
+		sc.states[dep] = state
+	}
+	for dep, deps := range child.deps {
+		sc.deps[dep] = deps
+	}
+	for dep, calls := range child.modules {
+		sc.modules[dep] = calls
+	}
+	for path, details := range child.details {
+		sc.details[path] = details
+	}
+	for path, annotations := range child.annotations {
+		sc.annotations[path] = annotations
+	}
+	for path, calls := range child.calls {
+		sc.calls[path] = calls
+	}
+	for path, err := range child.noBackend {
+		sc.noBackend[path] = err
+	}
+	sc.reports = append(sc.reports, child.reports...)
+	for path, d := range child.durations {
+		sc.recordDuration(path, d)
+	}
+
+	sc.mu.Lock()
+	sc.diags = append(sc.diags, child.diags...)
+	sc.mu.Unlock()
+}
@@ -0,0 +1,106 @@
+package terradep_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+// concurrentFiles are many deployments depending on the previous ones and calling shared module, which has its own dependency,
+// with a warning and a module which cannot be scanned
+func concurrentFiles() terradeptest.Files {
+	files := terradeptest.Files{
+		"modules/network/main.tf": `
+data "terraform_remote_state" "dns" {
+  backend = "s3"
+  config = {
+    bucket = "b"
+    key    = "dns"
+  }
+}
+`,
+		"live/dns/main.tf": `
+terraform {
+  backend "s3" {
+    bucket = "b"
+    key    = "dns"
+  }
+}
+`,
+		"live/broken/main.tf": `terraform {`,
+	}
+	for i := 0; i < 30; i++ {
+		// first deployment depends on itself
+		previous := i - 1
+		if i == 0 {
+			previous = 0
+		}
+		files[fmt.Sprintf("live/app%02d/main.tf", i)] = fmt.Sprintf(`
+terraform {
+  backend "s3" {
+    bucket = "b"
+    key    = "app%02d"
+  }
+}
+
+module "network" {
+  source = "../../modules/network"
+}
+
+data "terraform_remote_state" "previous" {
+  backend = "s3"
+  config = {
+    bucket = "b"
+    key    = "app%02d"
+  }
+}
+
+output "previous" {
+  value = data.terraform_remote_state.previous.outputs
+}
+`, i, previous)
+	}
+
+	return files
+}
+
+func TestScanDoesNotDependOnConcurrency(t *testing.T) {
+	dir := terradeptest.Dir(t, concurrentFiles())
+	scan := func(concurrency int) (string, []string) {
+		s := terradep.NewScanner(nil, terradeptest.NewStater(), terradep.WithRelativePaths(), terradep.WithContinueOnError(),
+			terradep.WithModuleEdges(), terradep.WithFollowModules(), terradep.WithIOConcurrency(concurrency))
+		graph, diags, err := s.Scan(dir)
+		if err != nil {
+			t.Fatalf("scanning directory with concurrency: %d, %v", concurrency, err)
+		}
+
+		out := make([]string, 0, len(diags))
+		for _, d := range diags {
+			out = append(out, d.String())
+		}
+		sort.Strings(out)
+
+		return terradeptest.Describe(graph), out
+	}
+
+	graph, diags := scan(1)
+	if len(diags) != 2 {
+		t.Fatalf("expected diagnostics of broken module and self-dependency, got: %v", diags)
+	}
+	for _, concurrency := range []int{2, 8, 64} {
+		t.Run(fmt.Sprint(concurrency), func(t *testing.T) {
+			for i := 0; i < 5; i++ {
+				gotGraph, gotDiags := scan(concurrency)
+				if gotGraph != graph {
+					t.Fatalf("graph differs from the graph scanned one by one\ngot:\n%s\nwant:\n%s", gotGraph, graph)
+				}
+				if !equalStrings(gotDiags, diags) {
+					t.Fatalf("diagnostics differ from the diagnostics of scan one by one\ngot: %v\nwant: %v", gotDiags, diags)
+				}
+			}
+		})
+	}
+}
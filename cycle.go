@@ -0,0 +1,114 @@
+package terradep
+
+import (
+	"strings"
+)
+
+// checkGraph returns [*DuplicateStateError] or [*CycleError] when the dependencies cannot be represented as [Graph]
+func checkGraph(states map[deployment]State, deps map[deployment][]State) error {
+	if duplicates := findDuplicateStates(states); len(duplicates) != 0 {
+		err := &DuplicateStateError{State: states[duplicates[0][0]]}
+		for _, d := range duplicates[0] {
+			err.Paths = append(err.Paths, d.path)
+		}
+		return err
+	}
+
+	if cycles := findCycles(states, deps); len(cycles) != 0 {
+		err := &CycleError{}
+		for _, d := range cycles[0] {
+			err.States = append(err.States, states[d])
+			err.Paths = append(err.Paths, d.path)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// findDuplicateStates returns groups of deployments sharing the state, in sorted order
+func findDuplicateStates(states map[deployment]State) [][]deployment {
+	byState := make(map[string][]deployment, len(states))
+	var order []string
+	for _, dep := range sortedDeployments(states) {
+		key := states[dep].String()
+		if _, ok := byState[key]; !ok {
+			order = append(order, key)
+		}
+		byState[key] = append(byState[key], dep)
+	}
+
+	var out [][]deployment
+	for _, key := range order {
+		if len(byState[key]) > 1 {
+			out = append(out, byState[key])
+		}
+	}
+
+	return out
+}
+
+// findCycles returns cycles found by depth-first search visiting deployments in sorted order, one cycle for every back edge.
+// Every cycle starts and ends with the same deployment
+func findCycles(states map[deployment]State, deps map[deployment][]State) [][]deployment {
+	byState := make(map[string]deployment, len(states))
+	for _, dep := range sortedDeployments(states) {
+		if _, ok := byState[states[dep].String()]; !ok {
+			byState[states[dep].String()] = dep
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[deployment]int, len(states))
+	var stack []deployment
+	var out [][]deployment
+
+	var visit func(dep deployment)
+	visit = func(dep deployment) {
+		marks[dep] = visiting
+		stack = append(stack, dep)
+		for _, child := range deps[dep] {
+			// external states do not have dependencies, they cannot be a part of the cycle
+			next, ok := byState[child.String()]
+			if !ok {
+				continue
+			}
+
+			switch marks[next] {
+			case visiting:
+				for i, d := range stack {
+					if d == next {
+						out = append(out, append(append([]deployment{}, stack[i:]...), next))
+						break
+					}
+				}
+			case visited:
+			default:
+				visit(next)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		marks[dep] = visited
+	}
+
+	for _, dep := range sortedDeployments(states) {
+		if marks[dep] == 0 {
+			visit(dep)
+		}
+	}
+
+	return out
+}
+
+// cycleString returns states of the cycle joined with arrows
+func cycleString(states map[deployment]State, cycle []deployment) string {
+	out := make([]string, 0, len(cycle))
+	for _, d := range cycle {
+		out = append(out, states[d].String())
+	}
+
+	return strings.Join(out, " -> ")
+}
@@ -0,0 +1,171 @@
+package terradep
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/exp/slog"
+)
+
+// DataSourceRule declares that data source other than terraform_remote_state implies dependency on another deployment.
+//
+// Example: outputs of deployments are handed off through AWS SSM parameters named /outputs/<deployment>/<output>,
+// while the state of each deployment is stored in S3 bucket tf-state under key <deployment>/terraform.tfstate:
+//
+//	DataSourceRule{
+//		Type:      "aws_ssm_parameter",
+//		Attribute: "name",
+//		Pattern:   regexp.MustCompile(`^/outputs/(?P<deployment>[^/]+)/`),
+//		Backend:   "s3",
+//		Config: map[string]cty.Value{
+//			"bucket": cty.StringVal("tf-state"),
+//			"key":    cty.StringVal("${deployment}/terraform.tfstate"),
+//		},
+//	}
+type DataSourceRule struct {
+	// Type of the data source, e.g. aws_ssm_parameter
+	Type string
+	// Attribute of the data source which value is matched with the Pattern
+	Attribute string
+	// Pattern must match value of the Attribute, otherwise data source is ignored.
+	// Submatches can be referenced in Config
+	Pattern *regexp.Regexp
+	// Backend is passed to [Stater.RemoteState]
+	Backend string
+	// Config is passed to [Stater.RemoteState] as config of terraform_remote_state.
+	// Submatches of the Pattern are expanded in string values (also nested ones) with [regexp.Regexp.Expand] syntax, e.g. $1 or ${name}
+	Config map[string]cty.Value
+}
+
+// WithDataSourceRules makes the [Scanner] find dependencies declared with data sources described by the rules
+func WithDataSourceRules(rules ...DataSourceRule) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.dataSourceRules = append(cfg.dataSourceRules, rules...)
+	}
+}
+
+// findDataSourceDependencies returns states referenced by data sources matching rules of the [Scanner]
+func (s *Scanner) findDataSourceDependencies(sc *scan, module *tfconfig.Module, deploymentPath string, ctx *hcl.EvalContext) ([]State, error) {
+	if len(s.dataSourceRules) == 0 {
+		return nil, nil
+	}
+
+	rulesByType := make(map[string][]DataSourceRule, len(s.dataSourceRules))
+	for _, rule := range s.dataSourceRules {
+		rulesByType[rule.Type] = append(rulesByType[rule.Type], rule)
+	}
+
+	matching := make([]*tfconfig.Resource, 0)
+	for _, resource := range module.DataResources {
+		if _, ok := rulesByType[resource.Type]; ok {
+			matching = append(matching, resource)
+		}
+	}
+
+	var out []State
+	for _, file := range sortedKeys(groupResByFile(matching)) {
+		states, err := s.parseDataSources(sc, module.Path, deploymentPath, file, rulesByType, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("parsing data sources in file: %s, %w", file, err)
+		}
+		out = append(out, states...)
+	}
+
+	return out, nil
+}
+
+func (s *Scanner) parseDataSources(sc *scan, modulePath, deploymentPath, file string, rulesByType map[string][]DataSourceRule, ctx *hcl.EvalContext) ([]State, error) {
+	hclFile, diags := sc.parser.ParseFile(file)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	content, _, diags := hclFile.Body.PartialContent(dataSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var out []State
+	for _, block := range content.Blocks {
+		for _, rule := range rulesByType[block.Labels[0]] {
+			state, err := s.applyDataSourceRule(sc, modulePath, deploymentPath, rule, block, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("data source: %s.%s, %w", block.Labels[0], block.Labels[1], err)
+			}
+
+			if state != nil {
+				s.log.Info("decoded data source state", slog.String("type", rule.Type), slog.String("name", block.Labels[1]), slog.String("state", state.String()))
+				sc.addEdge(deploymentPath, state, block.DefRange, nil)
+				out = append(out, state)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// applyDataSourceRule returns nil when the data source does not match the rule
+func (s *Scanner) applyDataSourceRule(sc *scan, modulePath, deploymentPath string, rule DataSourceRule, block *hcl.Block, ctx *hcl.EvalContext) (State, error) {
+	content, _, diags := block.Body.PartialContent(&hcl.BodySchema{Attributes: []hcl.AttributeSchema{{Name: rule.Attribute}}})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	attr, ok := content.Attributes[rule.Attribute]
+	if !ok {
+		return nil, nil
+	}
+
+	value, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() || !value.IsKnown() || value.IsNull() || !value.Type().Equals(cty.String) {
+		diag := Diagnostic{
+			Rule:    RuleDynamicDataSource,
+			Summary: fmt.Sprintf("skipping data source %s.%s, value of the attribute %q cannot be resolved statically", rule.Type, block.Labels[1], rule.Attribute),
+			Module:  modulePath,
+			Range:   rangePtr(attr.Range),
+		}
+		if diags.HasErrors() {
+			diag.Detail = diags.Error()
+		}
+		s.warn(sc, diag)
+		return nil, nil
+	}
+
+	src := value.AsString()
+	match := rule.Pattern.FindStringSubmatchIndex(src)
+	if match == nil {
+		return nil, nil
+	}
+
+	cfg := make(map[string]cty.Value, len(rule.Config))
+	for key, template := range rule.Config {
+		cfg[key] = expandValue(rule.Pattern, template, src, match)
+	}
+
+	return s.remoteState(sc, deploymentPath, rule.Backend, cfg)
+}
+
+// expandValue expands submatches in all strings found in the value
+func expandValue(re *regexp.Regexp, value cty.Value, src string, match []int) cty.Value {
+	switch {
+	case value.IsNull() || !value.IsKnown():
+		return value
+	case value.Type().Equals(cty.String):
+		return cty.StringVal(string(re.ExpandString(nil, value.AsString(), src, match)))
+	case value.Type().IsObjectType():
+		attrs := value.AsValueMap()
+		for key, attr := range attrs {
+			attrs[key] = expandValue(re, attr, src, match)
+		}
+		return cty.ObjectVal(attrs)
+	default:
+		return value
+	}
+}
+
+var dataSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "data", LabelNames: []string{"type", "name"}}},
+}
@@ -0,0 +1,48 @@
+package terradep_test
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+func TestDynamicDataSourceDetail(t *testing.T) {
+	dir := terradeptest.Dir(t, terradeptest.Files{
+		"app/main.tf": `
+terraform {
+  backend "s3" {
+    bucket = "b"
+    key    = "app"
+  }
+}
+
+data "aws_ssm_parameter" "a_number" {
+  name = 1
+}
+
+data "aws_ssm_parameter" "b_reference" {
+  name = aws_vpc.main.id
+}
+`,
+	})
+	rule := terradep.DataSourceRule{Type: "aws_ssm_parameter", Attribute: "name", Pattern: regexp.MustCompile(`^/(.+)$`), Backend: "s3"}
+
+	_, diags, err := terradep.NewScanner(nil, terradeptest.NewStater(), terradep.WithDataSourceRules(rule)).Scan(dir)
+	if err != nil {
+		t.Fatalf("scanning directory: %v", err)
+	}
+	if len(diags) != 2 {
+		t.Fatalf("expected diagnostics of both data sources, got: %v", diags)
+	}
+	sort.Slice(diags, func(i, j int) bool { return diags[i].Summary < diags[j].Summary })
+
+	if diags[0].Rule != terradep.RuleDynamicDataSource || diags[0].Detail != "" {
+		t.Errorf("expected diagnostic without detail of value which is not a string, got: %s, detail: %q", diags[0], diags[0].Detail)
+	}
+	if diags[1].Rule != terradep.RuleDynamicDataSource || diags[1].Detail == "" {
+		t.Errorf("expected diagnostic with detail of the error of evaluation, got: %s", diags[1])
+	}
+}
@@ -0,0 +1,134 @@
+package terradep
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"golang.org/x/exp/slog"
+)
+
+// Severity of the [Diagnostic]
+type Severity string
+
+const (
+	// SeverityWarning means that part of the configuration was skipped, but the graph is still valid
+	SeverityWarning Severity = "warning"
+	// SeverityError means that the module could not be scanned
+	SeverityError Severity = "error"
+)
+
+// Rules identify the checks of the [Scanner] producing the [Diagnostic], so tools can filter or annotate them without parsing the summary
+const (
+	RuleModuleError           = "module-error"
+	RuleSkippedFile           = "skipped-file"
+	RuleUnparsableFile        = "unparsable-file"
+	RuleDynamicRemoteState    = "dynamic-remote-state"
+	RuleDynamicDataSource     = "dynamic-data-source"
+	RuleMissingDeclaredTarget = "missing-declared-target"
+	RuleBrokenSymlink         = "broken-symlink"
+	RuleSymlinkLoop           = "symlink-loop"
+	RuleGitHistory            = "git-history"
+	RuleStateKeyMismatch      = "state-key-mismatch"
+	RuleUnusedRemoteState     = "unused-remote-state"
+	RuleSelfDependency        = "self-dependency"
+	RuleRedundantRemoteState  = "redundant-remote-state"
+	// RuleExternalDependency, RuleCycle and RuleDuplicateState are not reported by the [Scanner], see [CheckDeployments]
+	RuleExternalDependency = "external-dependency"
+	RuleCycle              = "cycle"
+	RuleDuplicateState     = "duplicate-state"
+	// RuleLayerViolation is reported by [CheckLayers]
+	RuleLayerViolation = "layer-violation"
+	// RuleCrossRegion and RuleCrossAccount are reported by [CheckRegions] and [CheckAccounts]
+	RuleCrossRegion  = "cross-region"
+	RuleCrossAccount = "cross-account"
+	// RuleThreshold is reported by [CheckThresholds]
+	RuleThreshold = "threshold"
+	// RuleRemoteStateMismatch is reported by [CheckRemoteStates]
+	RuleRemoteStateMismatch = "remote-state-mismatch"
+	// RuleMissingState and RuleMissingOutput are reported by the cli, when the state the deployment depends on cannot be read from the backend
+	// or it does not have the output consumed by the deployment
+	RuleMissingState  = "missing-state"
+	RuleMissingOutput = "missing-output"
+	// RuleMissingRunTrigger and RuleUnexpectedRunTrigger are reported by the cli, when run triggers of Terraform Cloud workspaces do not match the dependencies
+	RuleMissingRunTrigger    = "missing-run-trigger"
+	RuleUnexpectedRunTrigger = "unexpected-run-trigger"
+	// RulePolicyViolation is reported by package policy, when the graph breaks the Rego policy
+	RulePolicyViolation = "policy-violation"
+)
+
+// Diagnostic describes a problem found by the [Scanner] which did not stop the scan
+type Diagnostic struct {
+	Severity Severity
+	// Rule is one of the Rule constants, e.g. [RuleDynamicRemoteState]
+	Rule    string
+	Summary string
+	Detail  string
+	// Module is the path of the module where the problem was found, empty if the problem is not related to any module
+	Module string
+	// Range points to the code causing the problem, nil if it is not known
+	Range *hcl.Range
+}
+
+// String returns diagnostic in format similar to compilers: file:line,column: severity: summary; detail
+func (d Diagnostic) String() string {
+	sb := strings.Builder{}
+	switch {
+	case d.Range != nil:
+		sb.WriteString(d.Range.String())
+		sb.WriteString(": ")
+	case d.Module != "":
+		sb.WriteString(d.Module)
+		sb.WriteString(": ")
+	}
+
+	sb.WriteString(string(d.Severity))
+	sb.WriteString(": ")
+	sb.WriteString(d.Summary)
+	if d.Detail != "" {
+		sb.WriteString("; ")
+		sb.WriteString(d.Detail)
+	}
+
+	return sb.String()
+}
+
+// Diagnostics is a list of [Diagnostic] returned by [Scanner.Scan]
+type Diagnostics []Diagnostic
+
+// HasErrors checks whether any of the diagnostics has [SeverityError]
+func (d Diagnostics) HasErrors() bool {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// warn adds diagnostic with [SeverityWarning] to the scan and logs it
+func (s *Scanner) warn(sc *scan, diag Diagnostic) {
+	diag.Severity = SeverityWarning
+	s.log.Warn(diag.Summary, diagAttrs(diag)...)
+	s.tracer.Add(CounterDiagnostics, 1, slog.String("rule", diag.Rule))
+	sc.mu.Lock()
+	sc.diags = append(sc.diags, diag)
+	sc.mu.Unlock()
+}
+
+func diagAttrs(diag Diagnostic) []any {
+	attrs := []any{slog.String("rule", diag.Rule), slog.String("detail", diag.Detail)}
+	if diag.Module != "" {
+		attrs = append(attrs, slog.String("module", diag.Module))
+	}
+	if diag.Range != nil {
+		attrs = append(attrs, slog.String("range", diag.Range.String()))
+	}
+
+	return attrs
+}
+
+// rangePtr returns pointer to the copy of the range
+func rangePtr(r hcl.Range) *hcl.Range {
+	return &r
+}
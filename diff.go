@@ -0,0 +1,77 @@
+package terradep
+
+// Edge is a dependency of the deployment From on the state of the node To
+type Edge struct {
+	From *Node
+	To   *Node
+}
+
+// GraphDiff describes changes between two graphs, e.g. scanned before and after the change of the code.
+// Nodes are identified by their states, so the graphs can be scanned from different directories, e.g. two checkouts of the repository
+type GraphDiff struct {
+	// AddedDeployments and AddedDependencies are nodes and edges of the newer graph
+	AddedDeployments  []*Node
+	AddedDependencies []Edge
+	// RemovedDeployments and RemovedDependencies are nodes and edges of the older graph
+	RemovedDeployments  []*Node
+	RemovedDependencies []Edge
+}
+
+// IsEmpty checks whether the graphs have the same deployments and dependencies
+func (d GraphDiff) IsEmpty() bool {
+	return len(d.AddedDeployments) == 0 && len(d.AddedDependencies) == 0 && len(d.RemovedDeployments) == 0 && len(d.RemovedDependencies) == 0
+}
+
+// DiffGraphs returns deployments and dependencies added to and removed from the base graph in the head graph.
+// Edges to local modules are not compared. Nodes and edges are ordered like [Node.Children]
+func DiffGraphs(base, head *Graph) GraphDiff {
+	baseNodes, baseEdges := diffKeys(base)
+	headNodes, headEdges := diffKeys(head)
+
+	var diff GraphDiff
+	for _, n := range head.Nodes() {
+		if _, ok := baseNodes[n.State.String()]; !ok && head.IsDeployment(n) {
+			diff.AddedDeployments = append(diff.AddedDeployments, n)
+		}
+	}
+	for _, n := range base.Nodes() {
+		if _, ok := headNodes[n.State.String()]; !ok && base.IsDeployment(n) {
+			diff.RemovedDeployments = append(diff.RemovedDeployments, n)
+		}
+	}
+	diff.AddedDependencies = missingEdges(head, baseEdges)
+	diff.RemovedDependencies = missingEdges(base, headEdges)
+
+	return diff
+}
+
+// diffKeys returns states of the deployments and pairs of states of the edges of the graph
+func diffKeys(g *Graph) (map[string]struct{}, map[[2]string]struct{}) {
+	nodes := make(map[string]struct{})
+	edges := make(map[[2]string]struct{})
+	for _, n := range g.Nodes() {
+		if !g.IsDeployment(n) {
+			continue
+		}
+		nodes[n.State.String()] = struct{}{}
+		for _, child := range n.Children {
+			edges[[2]string{n.State.String(), child.State.String()}] = struct{}{}
+		}
+	}
+
+	return nodes, edges
+}
+
+// missingEdges returns edges of the graph, which are not among the other edges
+func missingEdges(g *Graph, other map[[2]string]struct{}) []Edge {
+	var out []Edge
+	for _, n := range g.Nodes() {
+		for _, child := range n.Children {
+			if _, ok := other[[2]string{n.State.String(), child.State.String()}]; !ok {
+				out = append(out, Edge{From: n, To: child})
+			}
+		}
+	}
+
+	return out
+}
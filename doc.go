@@ -4,12 +4,22 @@
 // dependencies in your deployments and plan especially when you organize your code in [Terraservices setup]
 // and you need orchestrating layer over Terraform.
 //
-// terradep can represent your dependency graph in two formats:
-//   - [Graphviz DOT] - which can be piped to [graph-easy] to generate SVG, PNG or ASCII output
-//   - JSON Lines (mostly for debugging)
+// [OpenTofu] files (.tofu and .tofu.json) are scanned too. As in OpenTofu, a .tofu file shadows the .tf file with the same name.
+//
+// Stacks synthesized by [CDKTF] (cdktf.out/stacks/*/cdk.tf.json) are modules in JSON syntax, so they are scanned like any other module,
+// when the output of cdktf synth is inside the scanned directory. Cross-stack references are terraform_remote_state data sources,
+// so stacks using local backend are linked by paths of their state files. Relative paths are resolved against the directory
+// of the stack, like Terraform does.
+//
+// terradep can represent your dependency graph in formats including:
+//   - [Graphviz DOT] - which can be rendered by Graphviz to SVG or PNG output
+//   - ASCII box-and-arrow diagram - to see the graph in the terminal, without installing [graph-easy]
+//   - NDJSON - one line per node with its edges, to filter the graph with jq or stream it to ingestion pipelines
 //
 // [terraform_remote_state]: https://developer.hashicorp.com/terraform/language/state/remote
 // [Terraservices setup]: https://www.hashicorp.com/resources/evolving-infrastructure-terraform-opencredo
 // [Graphviz DOT]: https://graphviz.org/doc/info/lang.html
 // [graph-easy]: https://metacpan.org/pod/Graph::Easy
+// [OpenTofu]: https://opentofu.org/docs/language/files/
+// [CDKTF]: https://developer.hashicorp.com/terraform/cdktf
 package terradep
@@ -0,0 +1,93 @@
+package terradep
+
+import (
+	"errors"
+	"time"
+)
+
+// DirStatus tells why the directory was or wasn't treated as a deployment by the [Scanner]
+type DirStatus string
+
+const (
+	// DirDeployment means that the directory is a deployment and is a part of the [Graph]
+	DirDeployment DirStatus = "deployment"
+	// DirSkippedName means that the name of the directory is one of the skipped dirs, see [DefaultSkipDirs]
+	DirSkippedName DirStatus = "skipped-by-name"
+	// DirTooDeep means that the directory is deeper than allowed by [WithMaxDepth]
+	DirTooDeep DirStatus = "too-deep"
+	// DirSkippedGlob means that the directory matches one of the globs set with [WithSkipPaths]
+	DirSkippedGlob DirStatus = "skipped-by-glob"
+	// DirNotModule means that the directory does not contain any Terraform files
+	DirNotModule DirStatus = "not-a-module"
+	// DirNotIncluded means that the directory does not match any of the globs set with [WithIncludePaths]
+	DirNotIncluded DirStatus = "not-included"
+	// DirSkippedSymlink means that the symlink was not followed because of the [SymlinkPolicy]
+	DirSkippedSymlink DirStatus = "skipped-symlink"
+	// DirAlreadyScanned means that the same module was already scanned through another path
+	DirAlreadyScanned DirStatus = "already-scanned"
+	// DirParseError means that Terraform files in the directory could not be parsed
+	DirParseError DirStatus = "parse-error"
+	// DirNoBackend means that the module does not have backend nor cloud block, so most likely it is a module called by deployments
+	DirNoBackend DirStatus = "no-backend"
+	// DirBackendError means that the backend block could not be turned into the [State]
+	DirBackendError DirStatus = "backend-error"
+	// DirDependencyError means that the dependencies of the module could not be found
+	DirDependencyError DirStatus = "dependency-error"
+)
+
+// DirReport explains how the directory was handled by the [Scanner]
+type DirReport struct {
+	Path   string
+	Status DirStatus
+	// Detail contains the error or other additional information, may be empty
+	Detail string
+	// Duration is the time spent scanning the module, zero for directories which were not scanned. Set only by [Scanner.ScanWithReport]
+	Duration time.Duration
+}
+
+// Explain walks the root the same way as [Scanner.Scan] does and reports for every visited directory why it was or wasn't treated as a deployment.
+// Unlike [Scanner.Scan], it does not stop on the errors in the modules, they are reported instead
+func (s *Scanner) Explain(root string) ([]DirReport, error) {
+	if err := checkDirExists(root); err != nil {
+		return nil, err
+	}
+
+	if err := s.validatePaths(); err != nil {
+		return nil, err
+	}
+
+	sc := s.newScan(root)
+	sc.explain = true
+	sc.record = true
+	err := s.walkRoot(sc)
+	if s.relativePaths {
+		sc.relativizeReports()
+	}
+
+	return sc.reports, err
+}
+
+// report stores the status of the directory, when the scan records reports
+func (sc *scan) report(path string, status DirStatus, detail string) {
+	if !sc.record {
+		return
+	}
+
+	sc.reports = append(sc.reports, DirReport{Path: path, Status: status, Detail: detail})
+}
+
+// relativizeReports makes paths of the reports relative to the root of the scan
+func (sc *scan) relativizeReports() {
+	for i := range sc.reports {
+		sc.reports[i].Path = relativePath(sc.root, sc.reports[i].Path)
+	}
+}
+
+// stateStatus classifies the error returned by [Scanner.findState]
+func stateStatus(err error) DirStatus {
+	if errors.Is(err, ErrNoBackend) {
+		return DirNoBackend
+	}
+
+	return DirBackendError
+}
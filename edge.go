@@ -0,0 +1,50 @@
+package terradep
+
+import "github.com/hashicorp/hcl/v2"
+
+// EdgeMetadata describes the dependency of the deployment on the state, see [Node.EdgeMetadata] and [Deployment.EdgeMetadata]
+type EdgeMetadata struct {
+	// Ranges point to terraform_remote_state data sources, data sources matched by [DataSourceRule] and comments with [AnnotationPrefix]
+	// referencing the state, in the deployment or in the local modules it calls. Empty for dependencies declared in the [Manifest]
+	Ranges []hcl.Range `json:"ranges"`
+	// Outputs are names of the outputs of the state referenced by the deployment, e.g. data.terraform_remote_state.net.outputs.vpc_id,
+	// ordered by name. [AllOutputs] means that the outputs are referenced as a whole. Empty when none is referenced
+	Outputs []string `json:"outputs,omitempty"`
+}
+
+// EdgeMetadata returns the metadata of the edge to the child, which is one of [Node.Children]
+func (n *Node) EdgeMetadata(child *Node) EdgeMetadata {
+	return n.Edges[child.State.Identity()]
+}
+
+// EdgeMetadata returns the metadata of the edge to the state, which is one of [Deployment.Dependencies]
+func (d Deployment) EdgeMetadata(state State) EdgeMetadata {
+	return d.Edges[state.Identity()]
+}
+
+// addEdge records the code of the deployment referencing the state and the outputs of the state it consumes
+func (sc *scan) addEdge(path string, state State, r hcl.Range, outputs []string) {
+	d := sc.detailsOf(path)
+	if d.edges == nil {
+		d.edges = make(map[string]EdgeMetadata)
+	}
+
+	key := state.Identity()
+	edge := d.edges[key]
+	edge.Outputs = mergeOutputs(edge.Outputs, outputs)
+	if !containsRange(edge.Ranges, r) {
+		// the same block is found again in every workspace and overlay
+		edge.Ranges = append(edge.Ranges, r)
+	}
+	d.edges[key] = edge
+}
+
+func containsRange(ranges []hcl.Range, r hcl.Range) bool {
+	for _, known := range ranges {
+		if known == r {
+			return true
+		}
+	}
+
+	return false
+}
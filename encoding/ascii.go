@@ -0,0 +1,453 @@
+package encoding
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.interactor.dev/terradep"
+)
+
+const (
+	// asciiGap is the number of columns between the boxes of the same row
+	asciiGap = 3
+	// asciiBoxHeight is the number of lines of the box: top border, label and bottom border
+	asciiBoxHeight = 3
+)
+
+// directions of the lines meeting in the cell of [asciiCanvas]
+const (
+	asciiUp uint8 = 1 << iota
+	asciiDown
+	asciiLeft
+	asciiRight
+)
+
+// asciiLines are box-drawing characters by the directions of the lines meeting in the cell
+var asciiLines = map[uint8]rune{
+	asciiUp:                                      '│',
+	asciiDown:                                    '│',
+	asciiUp | asciiDown:                          '│',
+	asciiLeft:                                    '─',
+	asciiRight:                                   '─',
+	asciiLeft | asciiRight:                       '─',
+	asciiDown | asciiRight:                       '┌',
+	asciiDown | asciiLeft:                        '┐',
+	asciiUp | asciiRight:                         '└',
+	asciiUp | asciiLeft:                          '┘',
+	asciiUp | asciiDown | asciiRight:             '├',
+	asciiUp | asciiDown | asciiLeft:              '┤',
+	asciiDown | asciiLeft | asciiRight:           '┬',
+	asciiUp | asciiLeft | asciiRight:             '┴',
+	asciiUp | asciiDown | asciiLeft | asciiRight: '┼',
+}
+
+// asciiRounded are rounded corners of the boxes of local modules
+var asciiRounded = map[uint8]rune{
+	asciiDown | asciiRight: '╭',
+	asciiDown | asciiLeft:  '╮',
+	asciiUp | asciiRight:   '╰',
+	asciiUp | asciiLeft:    '╯',
+}
+
+// ASCIIOpt changes the output of [BuildASCII]
+type ASCIIOpt func(cfg *asciiCfg)
+
+type asciiCfg struct {
+	direction Direction
+}
+
+// WithASCIIDirection sets the direction of the arrows
+func WithASCIIDirection(direction Direction) ASCIIOpt {
+	return func(cfg *asciiCfg) {
+		cfg.direction = direction
+	}
+}
+
+// asciiItem is the box of the node or, when node is nil, the vertical line of the edge passing through the row
+type asciiItem struct {
+	node  *terradep.Node
+	label string
+	row   int
+	x     int
+	width int
+	in    []*asciiSegment
+	out   []*asciiSegment
+}
+
+func (i *asciiItem) center() int {
+	return i.x + i.width/2
+}
+
+// asciiSegment is the part of the edge between adjacent rows, drawn down from fromX to toX with the horizontal line at track
+type asciiSegment struct {
+	from, to   *asciiItem
+	fromX, toX int
+	track      int
+	dashed     bool
+	// arrowUp and arrowDown are set on the segment touching the node the edge points to
+	arrowUp, arrowDown bool
+}
+
+// BuildASCII returns the graph as box-and-arrow diagram drawn with box-drawing characters, which can be viewed in the terminal without Graphviz.
+// Deployments are in rows above their dependencies and used modules, edges spanning several rows pass between the boxes.
+// External states have dashed borders and local modules rounded corners, edges to local modules are dashed.
+// Arrows point from deployments to their dependencies, unless changed with [WithASCIIDirection].
+// Intended for small and medium graphs, lines of large graphs are wider than the terminal
+func BuildASCII(graph *terradep.Graph, opts ...ASCIIOpt) []byte {
+	cfg := &asciiCfg{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	nodes := graph.Nodes()
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	positions := gridLayout(nodes)
+	top := 0
+	for _, pos := range positions {
+		if pos.column > top {
+			top = pos.column
+		}
+	}
+
+	rows := make([][]*asciiItem, top+1)
+	items := make(map[*terradep.Node]*asciiItem, len(nodes))
+	for _, n := range nodes {
+		item := &asciiItem{node: n, label: nodeLabel(graph, n), row: top - positions[n].column}
+		items[n] = item
+		rows[item.row] = append(rows[item.row], item)
+	}
+
+	link := func(from, to *terradep.Node, dashed bool) {
+		upper, lower := items[from], items[to]
+		if lower.row <= upper.row {
+			// closes a cycle ignored by the layout
+			return
+		}
+
+		prev := upper
+		for row := upper.row + 1; row <= lower.row; row++ {
+			next := lower
+			if row < lower.row {
+				next = &asciiItem{row: row, width: 1}
+				rows[row] = append(rows[row], next)
+			}
+			s := &asciiSegment{from: prev, to: next, dashed: dashed}
+			s.arrowDown = cfg.direction == DependencyDirection && next == lower
+			s.arrowUp = cfg.direction == DataFlowDirection && prev == upper
+			prev.out = append(prev.out, s)
+			next.in = append(next.in, s)
+			prev = next
+		}
+	}
+	for _, n := range nodes {
+		for _, child := range n.Children {
+			link(n, child, false)
+		}
+		for _, module := range n.Modules {
+			link(n, module, true)
+		}
+	}
+
+	orderASCIIRows(rows)
+	width := placeASCIIRows(rows)
+
+	// lines of the rows and the channels between them, channel has the line for the arrows and one line for every track
+	boxY := make([]int, len(rows))
+	channels := make([][]*asciiSegment, len(rows)-1)
+	height := 0
+	for r, row := range rows {
+		boxY[r] = height
+		height += asciiBoxHeight
+		if r == len(rows)-1 {
+			break
+		}
+		for _, item := range row {
+			channels[r] = append(channels[r], item.out...)
+		}
+		height += assignASCIITracks(channels[r]) + 1
+	}
+
+	canvas := newASCIICanvas(width, height)
+	for r, row := range rows {
+		y := boxY[r]
+		for _, item := range row {
+			if item.node == nil {
+				canvas.line(item.x, y, item.x, y+asciiBoxHeight-1, false)
+				continue
+			}
+			canvas.box(item, y, graph)
+		}
+	}
+
+	for r, segments := range channels {
+		above, below := boxY[r]+asciiBoxHeight-1, boxY[r+1]
+		trackOffset := above + 1
+		if cfg.direction == DataFlowDirection {
+			trackOffset++
+		}
+		for _, s := range segments {
+			if s.fromX == s.toX {
+				canvas.line(s.fromX, above, s.toX, below, s.dashed)
+			} else {
+				y := trackOffset + s.track
+				canvas.line(s.fromX, above, s.fromX, y, s.dashed)
+				canvas.line(s.fromX, y, s.toX, y, s.dashed)
+				canvas.line(s.toX, y, s.toX, below, s.dashed)
+			}
+			if s.arrowDown {
+				canvas.text(s.toX, below-1, "▼")
+			}
+			if s.arrowUp {
+				canvas.text(s.fromX, above+1, "▲")
+			}
+		}
+	}
+
+	return canvas.bytes()
+}
+
+// orderASCIIRows orders items of every row by the mean position of the items above linked to them, so edges cross less.
+// Items without links to the row above are moved to its end, keeping their order
+func orderASCIIRows(rows [][]*asciiItem) {
+	for r := 1; r < len(rows); r++ {
+		index := make(map[*asciiItem]int, len(rows[r-1]))
+		for i, item := range rows[r-1] {
+			index[item] = i
+		}
+
+		keys := make(map[*asciiItem]float64, len(rows[r]))
+		for i, item := range rows[r] {
+			if len(item.in) == 0 {
+				keys[item] = float64(len(rows[r-1]) + i)
+				continue
+			}
+			sum := 0
+			for _, s := range item.in {
+				sum += index[s.from]
+			}
+			keys[item] = float64(sum) / float64(len(item.in))
+		}
+		sort.SliceStable(rows[r], func(i, j int) bool {
+			return keys[rows[r][i]] < keys[rows[r][j]]
+		})
+	}
+}
+
+// placeASCIIRows sets widths and positions of the items, rows are centered, and returns the width of the widest row.
+// Then it sets ends of the segments: ports on the borders of the boxes, ordered like the items on the other end
+func placeASCIIRows(rows [][]*asciiItem) int {
+	width := 0
+	totals := make([]int, len(rows))
+	for r, row := range rows {
+		for i, item := range row {
+			if item.node != nil {
+				item.width = utf8.RuneCountInString(item.label) + 4
+				// ports are at least 2 columns apart, so the lines leaving them do not touch
+				if ports := 2*len(item.out) + 4; ports > item.width {
+					item.width = ports
+				}
+				if ports := 2*len(item.in) + 4; ports > item.width {
+					item.width = ports
+				}
+			}
+			if i > 0 {
+				totals[r] += asciiGap
+			}
+			totals[r] += item.width
+		}
+		if totals[r] > width {
+			width = totals[r]
+		}
+	}
+
+	for r, row := range rows {
+		x := (width - totals[r]) / 2
+		for _, item := range row {
+			item.x = x
+			x += item.width + asciiGap
+		}
+	}
+
+	for _, row := range rows {
+		for _, item := range row {
+			sort.SliceStable(item.out, func(i, j int) bool { return item.out[i].to.center() < item.out[j].to.center() })
+			sort.SliceStable(item.in, func(i, j int) bool { return item.in[i].from.center() < item.in[j].from.center() })
+			for i, s := range item.out {
+				s.fromX = item.port(i, len(item.out))
+			}
+			for i, s := range item.in {
+				s.toX = item.port(i, len(item.in))
+			}
+		}
+	}
+
+	return width
+}
+
+// port returns the column of i-th of ports spread evenly over the border of the box, or the column of the vertical line
+func (i *asciiItem) port(index, ports int) int {
+	if i.node == nil {
+		return i.x
+	}
+	inner := i.width - 2
+
+	return i.x + 1 + (index+1)*inner/(ports+1)
+}
+
+// assignASCIITracks assigns lines of the channel to the segments which are not straight and returns the number of the lines.
+// Segment leaving the box at the column where another segment enters the box below is above it, so vertical lines do not overlap
+func assignASCIITracks(segments []*asciiSegment) int {
+	var bent []*asciiSegment
+	for _, s := range segments {
+		if s.fromX != s.toX {
+			bent = append(bent, s)
+		}
+	}
+
+	placed := make(map[*asciiSegment]bool, len(bent))
+	for track := 0; track < len(bent); track++ {
+		var next *asciiSegment
+		for _, s := range bent {
+			if placed[s] {
+				continue
+			}
+			// segments leaving at the column where s enters must be above it
+			blocked := false
+			for _, other := range bent {
+				if !placed[other] && other != s && other.fromX == s.toX {
+					blocked = true
+					break
+				}
+			}
+			if !blocked {
+				next = s
+				break
+			}
+		}
+		if next == nil {
+			// cyclic constraints, lines overlap
+			for _, s := range bent {
+				if !placed[s] {
+					next = s
+					break
+				}
+			}
+		}
+		next.track = track
+		placed[next] = true
+	}
+
+	return len(bent)
+}
+
+type asciiCell struct {
+	lines  uint8
+	dashed bool
+	round  bool
+	text   rune
+}
+
+// asciiCanvas draws lines, which are joined with box-drawing characters, and text, which is drawn over the lines
+type asciiCanvas struct {
+	cells [][]asciiCell
+}
+
+func newASCIICanvas(width, height int) *asciiCanvas {
+	cells := make([][]asciiCell, height)
+	for y := range cells {
+		cells[y] = make([]asciiCell, width)
+	}
+
+	return &asciiCanvas{cells: cells}
+}
+
+// line draws horizontal or vertical line between the cells, both included
+func (c *asciiCanvas) line(x1, y1, x2, y2 int, dashed bool) {
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+
+	for y := y1; y <= y2; y++ {
+		for x := x1; x <= x2; x++ {
+			cell := &c.cells[y][x]
+			if y > y1 {
+				cell.lines |= asciiUp
+			}
+			if y < y2 {
+				cell.lines |= asciiDown
+			}
+			if x > x1 {
+				cell.lines |= asciiLeft
+			}
+			if x < x2 {
+				cell.lines |= asciiRight
+			}
+			cell.dashed = cell.dashed || dashed
+		}
+	}
+}
+
+// box draws the border of the node with the label in the middle
+func (c *asciiCanvas) box(item *asciiItem, y int, graph *terradep.Graph) {
+	_, module := item.node.State.(terradep.LocalModule)
+	dashed := !module && !graph.IsDeployment(item.node)
+	left, right, bottom := item.x, item.x+item.width-1, y+asciiBoxHeight-1
+
+	c.line(left, y, right, y, dashed)
+	c.line(left, bottom, right, bottom, dashed)
+	c.line(left, y, left, bottom, dashed)
+	c.line(right, y, right, bottom, dashed)
+	if module {
+		for _, corner := range [][2]int{{left, y}, {right, y}, {left, bottom}, {right, bottom}} {
+			c.cells[corner[1]][corner[0]].round = true
+		}
+	}
+
+	c.text(left+(item.width-utf8.RuneCountInString(item.label))/2, y+1, item.label)
+}
+
+func (c *asciiCanvas) text(x, y int, text string) {
+	for _, r := range text {
+		c.cells[y][x].text = r
+		x++
+	}
+}
+
+// bytes returns lines of the canvas without trailing spaces
+func (c *asciiCanvas) bytes() []byte {
+	sb := strings.Builder{}
+	for _, row := range c.cells {
+		line := make([]rune, len(row))
+		for x, cell := range row {
+			line[x] = cell.rune()
+		}
+		sb.WriteString(strings.TrimRight(string(line), " "))
+		sb.WriteByte('\n')
+	}
+
+	return []byte(sb.String())
+}
+
+func (c asciiCell) rune() rune {
+	switch {
+	case c.text != 0:
+		return c.text
+	case c.lines == 0:
+		return ' '
+	case c.round && asciiRounded[c.lines] != 0:
+		return asciiRounded[c.lines]
+	case c.dashed && c.lines&(asciiLeft|asciiRight) == 0:
+		return '╎'
+	case c.dashed && c.lines&(asciiUp|asciiDown) == 0:
+		return '╌'
+	default:
+		return asciiLines[c.lines]
+	}
+}
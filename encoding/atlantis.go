@@ -0,0 +1,62 @@
+package encoding
+
+import (
+	"fmt"
+
+	"go.interactor.dev/terradep"
+)
+
+// atlantisWhenModified are the files of the deployment triggering autoplan, relative to its directory
+var atlantisWhenModified = append([]string{"*.tf", "*.tfvars", ".terraform.lock.hcl"}, terradep.ManifestFiles...)
+
+type atlantisConfig struct {
+	Version       int               `yaml:"version"`
+	ParallelPlan  bool              `yaml:"parallel_plan"`
+	ParallelApply bool              `yaml:"parallel_apply"`
+	Projects      []atlantisProject `yaml:"projects"`
+}
+
+type atlantisProject struct {
+	Name                string           `yaml:"name"`
+	Dir                 string           `yaml:"dir"`
+	Workspace           string           `yaml:"workspace,omitempty"`
+	ExecutionOrderGroup int              `yaml:"execution_order_group"`
+	DependsOn           []string         `yaml:"depends_on,omitempty"`
+	Autoplan            atlantisAutoplan `yaml:"autoplan"`
+}
+
+type atlantisAutoplan struct {
+	Enabled      bool     `yaml:"enabled"`
+	WhenModified []string `yaml:"when_modified"`
+}
+
+// BuildAtlantisConfig returns atlantis.yaml with one project per deployment. Deployments are applied in execution order groups,
+// so every deployment is applied after all its dependencies. Autoplan is triggered by changes of Terraform files of the deployment
+// and of local modules it uses, which are known only when the graph was scanned with [terradep.WithModuleEdges]
+func BuildAtlantisConfig(graph *terradep.Graph, opts ...GenerateOpt) ([]byte, error) {
+	cfg := newGenerateCfg(opts)
+	config := atlantisConfig{Version: 3, ParallelPlan: true, ParallelApply: true, Projects: []atlantisProject{}}
+	for _, d := range cfg.deployments(graph) {
+		project := atlantisProject{
+			Name:                d.label(),
+			Dir:                 d.dir,
+			Workspace:           d.Workspace,
+			ExecutionOrderGroup: d.level,
+			Autoplan:            atlantisAutoplan{Enabled: true, WhenModified: append([]string{}, atlantisWhenModified...)},
+		}
+		for _, dep := range d.dependencies {
+			project.DependsOn = append(project.DependsOn, dep.label())
+		}
+		for _, module := range modulePaths(d.Node) {
+			project.Autoplan.WhenModified = append(project.Autoplan.WhenModified, module+"/*.tf")
+		}
+		config.Projects = append(config.Projects, project)
+	}
+
+	out, err := encodeYAML(config)
+	if err != nil {
+		return nil, fmt.Errorf("encoding atlantis config: %w", err)
+	}
+
+	return out, nil
+}
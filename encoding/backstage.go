@@ -0,0 +1,111 @@
+package encoding
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.interactor.dev/terradep"
+)
+
+const (
+	// backstageNameLimit is the maximum length of names and tags of Backstage entities
+	backstageNameLimit = 63
+	// BackstageType is the type of Backstage resources describing the deployments
+	BackstageType = "terraform-deployment"
+)
+
+var (
+	// backstageName matches characters not allowed in names of Backstage entities
+	backstageName = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+	// backstageTag matches characters not allowed in tags of Backstage entities
+	backstageTag = regexp.MustCompile(`[^a-z0-9:+#]+`)
+)
+
+type backstageEntity struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   backstageMetadata `yaml:"metadata"`
+	Spec       backstageSpec     `yaml:"spec"`
+}
+
+type backstageMetadata struct {
+	Name        string            `yaml:"name"`
+	Title       string            `yaml:"title"`
+	Description string            `yaml:"description"`
+	Annotations map[string]string `yaml:"annotations"`
+	Tags        []string          `yaml:"tags,omitempty"`
+}
+
+type backstageSpec struct {
+	Type      string   `yaml:"type"`
+	Owner     string   `yaml:"owner"`
+	System    string   `yaml:"system,omitempty"`
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+}
+
+// BuildBackstageCatalog returns catalog-info.yaml with Backstage Resource of type [BackstageType] for every deployment,
+// which depends on the resources of the deployments it depends on. Owner, layer and tags are read from the [terradep.Manifest],
+// layer becomes the system of the resource. Deployments without owner are owned by the owner set with [WithDefaultOwner]
+func BuildBackstageCatalog(graph *terradep.Graph, opts ...GenerateOpt) ([]byte, error) {
+	cfg := newGenerateCfg(opts)
+	deployments := cfg.deployments(graph)
+	names := uniqueIDs(deployments, func(label string) string {
+		name := strings.Trim(backstageName.ReplaceAllString(label, "-"), "-_.")
+		if len(name) > backstageNameLimit-3 {
+			// leaves space for the suffix of repeated names
+			name = strings.Trim(name[len(name)-backstageNameLimit+3:], "-_.")
+		}
+		return name
+	})
+
+	entities := make([]any, 0, len(deployments))
+	for _, d := range deployments {
+		entity := backstageEntity{
+			APIVersion: "backstage.io/v1alpha1",
+			Kind:       "Resource",
+			Metadata: backstageMetadata{
+				Name:        names[d],
+				Title:       d.label(),
+				Description: "Terraform deployment " + d.label() + " with state " + d.State.String(),
+				Annotations: map[string]string{"terradep.io/path": d.dir, "terradep.io/state": d.State.String()},
+			},
+			Spec: backstageSpec{Type: BackstageType, Owner: d.Owner, System: d.Layer},
+		}
+		if entity.Spec.Owner == "" {
+			entity.Spec.Owner = cfg.owner
+		}
+		if d.Workspace != "" {
+			entity.Metadata.Annotations["terradep.io/workspace"] = d.Workspace
+		}
+		if d.Overlay != "" {
+			entity.Metadata.Annotations["terradep.io/overlay"] = d.Overlay
+		}
+		for _, tag := range d.Tags {
+			if tag = backstageTagOf(tag); tag != "" {
+				entity.Metadata.Tags = append(entity.Metadata.Tags, tag)
+			}
+		}
+		for _, dep := range d.dependencies {
+			entity.Spec.DependsOn = append(entity.Spec.DependsOn, "resource:"+names[dep])
+		}
+		entities = append(entities, entity)
+	}
+
+	out, err := encodeYAML(entities...)
+	if err != nil {
+		return nil, fmt.Errorf("encoding backstage catalog: %w", err)
+	}
+
+	return out, nil
+}
+
+// backstageTagOf returns the tag in the format accepted by Backstage, e.g. Team A becomes team-a
+func backstageTagOf(tag string) string {
+	tag = strings.Trim(backstageTag.ReplaceAllString(strings.ToLower(tag), "-"), "-")
+	if len(tag) > backstageNameLimit {
+		tag = strings.TrimRight(tag[:backstageNameLimit], "-")
+	}
+
+	return tag
+}
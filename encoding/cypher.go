@@ -0,0 +1,161 @@
+package encoding
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.interactor.dev/terradep"
+)
+
+// labels of the nodes written by [BuildCypher]
+const (
+	CypherDeployment = "Deployment"
+	CypherExternal   = "ExternalState"
+	CypherModule     = "Module"
+)
+
+// CypherOpt changes the output of [BuildCypher]
+type CypherOpt func(cfg *cypherCfg)
+
+type cypherCfg struct {
+	direction  Direction
+	decorators decorators
+}
+
+// WithCypherDirection sets the direction of the relationships. In [DataFlowDirection] relationships REQUIRED_BY point from dependencies
+// to deployments depending on them and USED_BY from modules to deployments using them
+func WithCypherDirection(direction Direction) CypherOpt {
+	return func(cfg *cypherCfg) {
+		cfg.direction = direction
+	}
+}
+
+// WithCypherNodeDecorator sets the attributes of the decorator as properties of the nodes, e.g. n.url.
+// Can be set many times, later decorators override earlier ones
+func WithCypherNodeDecorator(decorator NodeDecorator) CypherOpt {
+	return func(cfg *cypherCfg) {
+		cfg.decorators.nodes = append(cfg.decorators.nodes, decorator)
+	}
+}
+
+// WithCypherEdgeDecorator sets the attributes of the decorator as properties of the relationships
+func WithCypherEdgeDecorator(decorator EdgeDecorator) CypherOpt {
+	return func(cfg *cypherCfg) {
+		cfg.decorators.edges = append(cfg.decorators.edges, decorator)
+	}
+}
+
+// cypherIdentifier matches names of the properties which do not have to be quoted with backticks
+var cypherIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// cypherString escapes string literal of Cypher in double quotes
+var cypherString = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+// BuildCypher returns Cypher statements loading the graph into Neo4j, e.g. with cypher-shell. Nodes are merged by the state,
+// so loading the graph again updates existing nodes, property id is [terradep.Node.ID]. Deployments are labeled [CypherDeployment], states not produced by any deployment
+// [CypherExternal] and local modules [CypherModule]. Relationships DEPENDS_ON point to dependencies and USES_MODULE to used modules,
+// unless changed with [WithCypherDirection]
+func BuildCypher(graph *terradep.Graph, opts ...CypherOpt) []byte {
+	cfg := &cypherCfg{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	dependsOn, usesModule := "DEPENDS_ON", "USES_MODULE"
+	if cfg.direction == DataFlowDirection {
+		dependsOn, usesModule = "REQUIRED_BY", "USED_BY"
+	}
+
+	sb := strings.Builder{}
+	for _, label := range []string{CypherDeployment, CypherExternal, CypherModule} {
+		fmt.Fprintf(&sb, "CREATE CONSTRAINT IF NOT EXISTS FOR (n:%s) REQUIRE n.state IS UNIQUE;\n", label)
+	}
+
+	nodes := graph.Nodes()
+	labels := make(map[*terradep.Node]string, len(nodes))
+	for _, n := range nodes {
+		labels[n] = cypherLabel(graph, n)
+		fmt.Fprintf(&sb, "MERGE (n:%s {state: %s})", labels[n], cypherQuote(n.State.String()))
+		props := []string{"n.id = " + cypherQuote(n.ID())}
+		if labels[n] != CypherExternal {
+			props = append(props, "n.path = "+cypherQuote(n.Path))
+		}
+		if labels[n] == CypherDeployment {
+			props = append(props,
+				"n.workspace = "+cypherQuote(n.Workspace),
+				"n.overlay = "+cypherQuote(n.Overlay),
+				"n.owner = "+cypherQuote(n.Owner),
+				"n.layer = "+cypherQuote(n.Layer),
+				"n.tags = "+cypherList(n.Tags),
+			)
+			if n.Metadata != nil {
+				props = append(props, "n.backend = "+cypherQuote(n.Metadata.Backend))
+			}
+		}
+		props = append(props, cypherProperties("n", cfg.decorators.node(graph, n))...)
+		fmt.Fprintf(&sb, " SET %s;\n", strings.Join(props, ", "))
+	}
+
+	for _, n := range nodes {
+		for _, child := range n.Children {
+			from, to := cfg.direction.edge(n, child)
+			writeCypherRelationship(&sb, dependsOn, labels[from], from, labels[to], to, cfg.decorators.edge(graph, n, child))
+		}
+		for _, module := range n.Modules {
+			from, to := cfg.direction.edge(n, module)
+			writeCypherRelationship(&sb, usesModule, labels[from], from, labels[to], to, cfg.decorators.edge(graph, n, module))
+		}
+	}
+
+	return []byte(sb.String())
+}
+
+func cypherLabel(graph *terradep.Graph, n *terradep.Node) string {
+	if _, ok := n.State.(terradep.LocalModule); ok {
+		return CypherModule
+	}
+	if graph.IsDeployment(n) {
+		return CypherDeployment
+	}
+
+	return CypherExternal
+}
+
+// writeCypherRelationship merges the relationship, setting the attributes as its properties
+func writeCypherRelationship(sb *strings.Builder, relationship, fromLabel string, from *terradep.Node, toLabel string, to *terradep.Node, attrs Attributes) {
+	fmt.Fprintf(sb, "MATCH (a:%s {state: %s}), (b:%s {state: %s}) ",
+		fromLabel, cypherQuote(from.State.String()), toLabel, cypherQuote(to.State.String()))
+	if len(attrs) == 0 {
+		fmt.Fprintf(sb, "MERGE (a)-[:%s]->(b);\n", relationship)
+		return
+	}
+	fmt.Fprintf(sb, "MERGE (a)-[r:%s]->(b) SET %s;\n", relationship, strings.Join(cypherProperties("r", attrs), ", "))
+}
+
+// cypherProperties returns assignments of the attributes to the properties of the variable, ordered by name
+func cypherProperties(variable string, attrs Attributes) []string {
+	out := make([]string, 0, len(attrs))
+	for _, key := range sortedKeys(attrs) {
+		name := key
+		if !cypherIdentifier.MatchString(key) {
+			name = "`" + strings.ReplaceAll(key, "`", "``") + "`"
+		}
+		out = append(out, fmt.Sprintf("%s.%s = %s", variable, name, cypherQuote(attrs[key])))
+	}
+
+	return out
+}
+
+func cypherQuote(s string) string {
+	return `"` + cypherString.Replace(s) + `"`
+}
+
+func cypherList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, cypherQuote(v))
+	}
+
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
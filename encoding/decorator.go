@@ -0,0 +1,102 @@
+package encoding
+
+import "go.interactor.dev/terradep"
+
+// Well-known attributes returned by [NodeDecorator] and [EdgeDecorator], which every encoder consulting the decorators maps to its own representation,
+// e.g. [AttrURL] to attribute URL of DOT and to click of Mermaid. Other attributes are written only by the formats with free-form properties:
+// DOT, Cypher, draw.io, Excalidraw and JSON
+const (
+	// AttrLabel replaces the label of the node or labels the edge
+	AttrLabel = "label"
+	// AttrColor is the color of the border of the node or of the edge, e.g. #d33
+	AttrColor = "color"
+	// AttrURL is the link opened by clicking the node or the edge, e.g. the dashboard of the deployment
+	AttrURL = "url"
+	// AttrTooltip is shown when the node or the edge is hovered, it replaces the tooltip of [WithNodeMetadata]
+	AttrTooltip = "tooltip"
+)
+
+// Attributes are extra attributes of the node or the edge, keyed by name, e.g. [AttrColor]
+type Attributes map[string]string
+
+// NodeDecorator provides extra attributes of the nodes, so integrations, e.g. with the service catalog or cost reports,
+// can enrich the output of the encoders without changing them. Returns nil when the node does not have extra attributes
+type NodeDecorator interface {
+	DecorateNode(graph *terradep.Graph, n *terradep.Node) Attributes
+}
+
+// EdgeDecorator provides extra attributes of the edges, like [NodeDecorator]. From is the deployment depending on to,
+// which is its child or local module it calls, regardless of the direction the edge is drawn in
+type EdgeDecorator interface {
+	DecorateEdge(graph *terradep.Graph, from, to *terradep.Node) Attributes
+}
+
+// NodeDecoratorFunc adapts the function to [NodeDecorator]
+type NodeDecoratorFunc func(graph *terradep.Graph, n *terradep.Node) Attributes
+
+// DecorateNode implements [NodeDecorator]
+func (f NodeDecoratorFunc) DecorateNode(graph *terradep.Graph, n *terradep.Node) Attributes {
+	return f(graph, n)
+}
+
+// EdgeDecoratorFunc adapts the function to [EdgeDecorator]
+type EdgeDecoratorFunc func(graph *terradep.Graph, from, to *terradep.Node) Attributes
+
+// DecorateEdge implements [EdgeDecorator]
+func (f EdgeDecoratorFunc) DecorateEdge(graph *terradep.Graph, from, to *terradep.Node) Attributes {
+	return f(graph, from, to)
+}
+
+// decorators are set with the options of the encoders, e.g. [WithDOTNodeDecorator]
+type decorators struct {
+	nodes []NodeDecorator
+	edges []EdgeDecorator
+}
+
+// node returns the attributes of the node, attributes of later decorators override the same attributes of earlier ones
+func (d decorators) node(graph *terradep.Graph, n *terradep.Node) Attributes {
+	var out Attributes
+	for _, decorator := range d.nodes {
+		out = mergeAttributes(out, decorator.DecorateNode(graph, n))
+	}
+
+	return out
+}
+
+// edge returns the attributes of the edge from the deployment to its dependency or module, like [decorators.node]
+func (d decorators) edge(graph *terradep.Graph, from, to *terradep.Node) Attributes {
+	var out Attributes
+	for _, decorator := range d.edges {
+		out = mergeAttributes(out, decorator.DecorateEdge(graph, from, to))
+	}
+
+	return out
+}
+
+func mergeAttributes(dst, src Attributes) Attributes {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(Attributes, len(src))
+	}
+	for key, value := range src {
+		dst[key] = value
+	}
+
+	return dst
+}
+
+// custom returns the attributes which are not well-known, ordered by name
+func (a Attributes) custom() []string {
+	var out []string
+	for _, key := range sortedKeys(a) {
+		switch key {
+		case AttrLabel, AttrColor, AttrURL, AttrTooltip:
+		default:
+			out = append(out, key)
+		}
+	}
+
+	return out
+}
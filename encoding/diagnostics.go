@@ -0,0 +1,131 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"go.interactor.dev/terradep"
+)
+
+// DiagnosticEncoder writes [terradep.Diagnostics] in JSON Lines format, one line per diagnostic
+type DiagnosticEncoder struct {
+	enc *json.Encoder
+}
+
+// NewDiagnosticEncoder returns [DiagnosticEncoder] writing to w
+func NewDiagnosticEncoder(w io.Writer) *DiagnosticEncoder {
+	return &DiagnosticEncoder{enc: json.NewEncoder(w)}
+}
+
+type diagnosticLine struct {
+	Severity terradep.Severity `json:"severity"`
+	Rule     string            `json:"rule,omitempty"`
+	Summary  string            `json:"summary"`
+	Detail   string            `json:"detail,omitempty"`
+	Module   string            `json:"module,omitempty"`
+	// File, Line and Column point to the start of the code causing the problem, EndLine and EndColumn to its end
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+}
+
+// Encode writes every diagnostic as single line
+func (e *DiagnosticEncoder) Encode(diags terradep.Diagnostics) error {
+	for _, d := range diags {
+		line := diagnosticLine{
+			Severity: d.Severity,
+			Rule:     d.Rule,
+			Summary:  d.Summary,
+			Detail:   d.Detail,
+			Module:   d.Module,
+		}
+		if d.Range != nil {
+			line.File = d.Range.Filename
+			line.Line, line.Column = d.Range.Start.Line, d.Range.Start.Column
+			line.EndLine, line.EndColumn = d.Range.End.Line, d.Range.End.Column
+		}
+
+		if err := e.enc.Encode(line); err != nil {
+			return fmt.Errorf("encoding diagnostic: %s, %w", d.Summary, err)
+		}
+	}
+
+	return nil
+}
+
+// GitHubAnnotationEncoder writes [terradep.Diagnostics] as GitHub Actions workflow commands, e.g. ::warning file=main.tf,line=1::summary,
+// so they are shown as annotations of the files in pull requests
+type GitHubAnnotationEncoder struct {
+	w    io.Writer
+	root string
+}
+
+// NewGitHubAnnotationEncoder returns [GitHubAnnotationEncoder] writing to w. Paths of the files are made relative to the root,
+// which should be the root of the repository, e.g. GITHUB_WORKSPACE. Empty root leaves the paths unchanged
+func NewGitHubAnnotationEncoder(w io.Writer, root string) *GitHubAnnotationEncoder {
+	return &GitHubAnnotationEncoder{w: w, root: root}
+}
+
+// gitHubData escapes the message of the workflow command
+var gitHubData = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// gitHubProperty escapes the value of the property of the workflow command
+var gitHubProperty = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// Encode writes every diagnostic as single workflow command
+func (e *GitHubAnnotationEncoder) Encode(diags terradep.Diagnostics) error {
+	for _, d := range diags {
+		command := "warning"
+		if d.Severity == terradep.SeverityError {
+			command = "error"
+		}
+
+		var props []string
+		file := d.Module
+		if d.Range != nil {
+			file = d.Range.Filename
+		}
+		if file != "" {
+			props = append(props, "file="+gitHubProperty.Replace(e.relPath(file)))
+		}
+		if r := d.Range; r != nil {
+			props = append(props, fmt.Sprintf("line=%d,col=%d,endLine=%d,endColumn=%d", r.Start.Line, r.Start.Column, r.End.Line, r.End.Column))
+		}
+		if d.Rule != "" {
+			props = append(props, "title="+gitHubProperty.Replace(d.Rule))
+		}
+
+		message := d.Summary
+		if d.Detail != "" {
+			message += "; " + d.Detail
+		}
+
+		if len(props) != 0 {
+			command += " " + strings.Join(props, ",")
+		}
+
+		if _, err := fmt.Fprintf(e.w, "::%s::%s\n", command, gitHubData.Replace(message)); err != nil {
+			return fmt.Errorf("writing annotation: %s, %w", d.Summary, err)
+		}
+	}
+
+	return nil
+}
+
+func (e *GitHubAnnotationEncoder) relPath(path string) string {
+	if e.root == "" || !filepath.IsAbs(path) {
+		return filepath.ToSlash(path)
+	}
+
+	rel, err := filepath.Rel(e.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+
+	return filepath.ToSlash(rel)
+}
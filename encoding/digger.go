@@ -0,0 +1,43 @@
+package encoding
+
+import (
+	"fmt"
+
+	"go.interactor.dev/terradep"
+)
+
+type diggerConfig struct {
+	Projects []diggerProject `yaml:"projects"`
+}
+
+type diggerProject struct {
+	Name            string   `yaml:"name"`
+	Dir             string   `yaml:"dir"`
+	Workspace       string   `yaml:"workspace,omitempty"`
+	DependsOn       []string `yaml:"depends_on,omitempty"`
+	IncludePatterns []string `yaml:"include_patterns,omitempty"`
+}
+
+// BuildDiggerConfig returns digger.yml with one project per deployment, which depends on the projects of the deployments it depends on.
+// Include patterns match local modules used by the deployment, which are known only when the graph was scanned with [terradep.WithModuleEdges]
+func BuildDiggerConfig(graph *terradep.Graph, opts ...GenerateOpt) ([]byte, error) {
+	cfg := newGenerateCfg(opts)
+	config := diggerConfig{Projects: []diggerProject{}}
+	for _, d := range cfg.deployments(graph) {
+		project := diggerProject{Name: d.label(), Dir: d.dir, Workspace: d.Workspace}
+		for _, dep := range d.dependencies {
+			project.DependsOn = append(project.DependsOn, dep.label())
+		}
+		for _, module := range d.Modules {
+			project.IncludePatterns = append(project.IncludePatterns, cfg.relPath(module.Path)+"/**")
+		}
+		config.Projects = append(config.Projects, project)
+	}
+
+	out, err := encodeYAML(config)
+	if err != nil {
+		return nil, fmt.Errorf("encoding digger config: %w", err)
+	}
+
+	return out, nil
+}
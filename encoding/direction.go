@@ -0,0 +1,22 @@
+package encoding
+
+import "go.interactor.dev/terradep"
+
+// Direction is the direction of the edges drawn by the encoders of the graph: [BuildDOTGraph], [BuildMermaidGraph] and [BuildCypher]
+type Direction int
+
+const (
+	// DependencyDirection draws edges from deployments to their dependencies and to the modules they use, it is the default
+	DependencyDirection Direction = iota
+	// DataFlowDirection draws edges from dependencies to deployments depending on them, i.e. the way outputs of the states flow
+	DataFlowDirection
+)
+
+// edge returns ends of the edge from the deployment to its dependency or module, swapped when the direction is [DataFlowDirection]
+func (d Direction) edge(from, to *terradep.Node) (*terradep.Node, *terradep.Node) {
+	if d == DataFlowDirection {
+		return to, from
+	}
+
+	return from, to
+}
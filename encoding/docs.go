@@ -0,0 +1,133 @@
+package encoding
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"go.interactor.dev/terradep"
+)
+
+// DocsFile is the name of the documentation of the dependencies of the deployment written by [BuildDeploymentDocs]
+const DocsFile = "DEPENDENCIES.md"
+
+// docsHeader marks the generated documentation, so it is not edited by hand
+const docsHeader = "<!-- Generated by terradep, do not edit. Regenerate with: terradep docs -->\n"
+
+// WithDocsDir makes [BuildDeploymentDocs] place the documentation in the directory relative to the root, e.g. docs/dependencies,
+// mirroring the directories of the deployments, instead of the directories of the deployments
+func WithDocsDir(dir string) GenerateOpt {
+	return func(cfg *generateCfg) {
+		cfg.docsDir = path.Clean(strings.Trim(dir, "/"))
+	}
+}
+
+// docsDirectory is a directory with the deployments, described by single file, because all the workspaces and overlays share the code
+type docsDirectory struct {
+	dir         string
+	deployments []*generated
+	upstream    map[*generated]struct{}
+	downstream  map[*generated]struct{}
+	external    map[string]struct{}
+}
+
+// BuildDeploymentDocs returns Markdown documentation of every deployment directory, keyed by the path of the file relative to the root directory,
+// e.g. live/app/DEPENDENCIES.md. It lists deployments the directory depends on and deployments depending on it, with links to their documentation.
+// The output depends only on the graph, so it can be regenerated and committed without changes when the dependencies did not change
+func BuildDeploymentDocs(graph *terradep.Graph, opts ...GenerateOpt) map[string][]byte {
+	cfg := newGenerateCfg(opts)
+	dirs := make(map[string]*docsDirectory)
+	deployments := cfg.deployments(graph)
+	for _, d := range deployments {
+		if _, ok := dirs[d.dir]; !ok {
+			dirs[d.dir] = &docsDirectory{dir: d.dir, upstream: map[*generated]struct{}{}, downstream: map[*generated]struct{}{}, external: map[string]struct{}{}}
+		}
+		dirs[d.dir].deployments = append(dirs[d.dir].deployments, d)
+	}
+	for _, d := range deployments {
+		for _, dep := range d.dependencies {
+			if dep.dir == d.dir {
+				continue
+			}
+			dirs[d.dir].upstream[dep] = struct{}{}
+			dirs[dep.dir].downstream[d] = struct{}{}
+		}
+		for _, child := range d.Children {
+			if !graph.IsDeployment(child) {
+				dirs[d.dir].external[child.State.String()] = struct{}{}
+			}
+		}
+	}
+
+	out := make(map[string][]byte, len(dirs))
+	for _, dir := range sortedKeys(dirs) {
+		out[cfg.docsPath(dir)] = cfg.deploymentDoc(dirs[dir])
+	}
+
+	return out
+}
+
+// docsPath returns path of the documentation of the deployment directory relative to the root directory
+func (cfg *generateCfg) docsPath(dir string) string {
+	if cfg.docsDir != "" {
+		return path.Join(cfg.docsDir, dir, DocsFile)
+	}
+
+	return path.Join(dir, DocsFile)
+}
+
+func (cfg *generateCfg) deploymentDoc(d *docsDirectory) []byte {
+	docDir := path.Dir(cfg.docsPath(d.dir))
+	sb := strings.Builder{}
+	sb.WriteString(docsHeader)
+	fmt.Fprintf(&sb, "# %s\n\n", d.dir)
+	if cfg.docsDir != "" {
+		fmt.Fprintf(&sb, "Source: [%s](%s)\n\n", d.dir, relDir(docDir, d.dir))
+	}
+
+	sb.WriteString("| Deployment | State | Owner | Layer | Tags |\n|---|---|---|---|---|\n")
+	for _, g := range d.deployments {
+		fmt.Fprintf(&sb, "| %s | `%s` | %s | %s | %s |\n", markdownCell(g.label()), markdownCell(g.State.String()), markdownCell(g.Team()), markdownCell(g.Layer), markdownCell(strings.Join(g.Tags, ", ")))
+	}
+
+	sb.WriteString("\n## Upstream\n\nDeployments this deployment depends on, which must be applied first.\n\n")
+	links := cfg.docsLinks(docDir, d.upstream)
+	for _, state := range sortedKeys(d.external) {
+		links = append(links, fmt.Sprintf("- `%s`, not produced by any scanned deployment", state))
+	}
+	writeDocsList(&sb, links)
+
+	sb.WriteString("\n## Downstream\n\nDeployments depending on this deployment, which may be affected by its changes.\n\n")
+	writeDocsList(&sb, cfg.docsLinks(docDir, d.downstream))
+
+	return []byte(sb.String())
+}
+
+// docsLinks returns list items linking to the documentation of the deployments, relative to the directory of the documentation
+func (cfg *generateCfg) docsLinks(docDir string, deployments map[*generated]struct{}) []string {
+	out := make([]string, 0, len(deployments))
+	for g := range deployments {
+		link := relDir(docDir, cfg.docsPath(g.dir))
+		out = append(out, fmt.Sprintf("- [%s](%s)", g.label(), link))
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+func writeDocsList(sb *strings.Builder, items []string) {
+	if len(items) == 0 {
+		sb.WriteString("None.\n")
+		return
+	}
+
+	for _, item := range items {
+		sb.WriteString(item + "\n")
+	}
+}
+
+// markdownCell escapes the value of the cell of Markdown table
+func markdownCell(value string) string {
+	return strings.ReplaceAll(value, "|", `\|`)
+}
@@ -0,0 +1,228 @@
+package encoding
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"go.interactor.dev/terradep"
+)
+
+// sizes of the shapes written by [BuildDrawIO], in pixels
+const (
+	drawIOWidth     = 240
+	drawIOHeight    = 40
+	drawIOColumnGap = 80
+	drawIORowGap    = 20
+)
+
+// styles of the shapes written by [BuildDrawIO]
+const (
+	drawIODeploymentStyle = "rounded=1;whiteSpace=wrap;html=1;"
+	drawIOExternalStyle   = "rounded=1;whiteSpace=wrap;html=1;dashed=1;fillColor=#f5f5f5;"
+	drawIOModuleStyle     = "rounded=1;whiteSpace=wrap;html=1;fillColor=#dae8fc;strokeColor=#6c8ebf;"
+	drawIOEdgeStyle       = "edgeStyle=orthogonalEdgeStyle;rounded=1;html=1;endArrow=block;"
+	drawIOModuleEdgeStyle = drawIOEdgeStyle + "dashed=1;"
+)
+
+// DrawIOOpt changes the output of [BuildDrawIO]
+type DrawIOOpt func(cfg *drawIOCfg)
+
+type drawIOCfg struct {
+	direction  Direction
+	decorators decorators
+}
+
+// WithDrawIODirection sets the direction of the arrows, arrows to modules are labeled used-by in [DataFlowDirection]
+func WithDrawIODirection(direction Direction) DrawIOOpt {
+	return func(cfg *drawIOCfg) {
+		cfg.direction = direction
+	}
+}
+
+// WithDrawIONodeDecorator changes the shapes with the attributes of the decorator: [AttrLabel], [AttrColor] of the border,
+// [AttrURL] set as the link and [AttrTooltip]. Other attributes are added to the properties of the shape, except properties set by terradep,
+// e.g. path, so they must be valid XML names. Can be set many times, later decorators override earlier ones
+func WithDrawIONodeDecorator(decorator NodeDecorator) DrawIOOpt {
+	return func(cfg *drawIOCfg) {
+		cfg.decorators.nodes = append(cfg.decorators.nodes, decorator)
+	}
+}
+
+// WithDrawIOEdgeDecorator changes the arrows with the attributes [AttrLabel] and [AttrColor] of the decorator, other attributes are ignored
+func WithDrawIOEdgeDecorator(decorator EdgeDecorator) DrawIOOpt {
+	return func(cfg *drawIOCfg) {
+		cfg.decorators.edges = append(cfg.decorators.edges, decorator)
+	}
+}
+
+// drawIOProperties are properties of the shapes set by terradep, which cannot be set with [WithDrawIONodeDecorator]
+var drawIOProperties = map[string]struct{}{
+	"id": {}, "label": {}, "path": {}, "workspace": {}, "overlay": {}, "state": {}, "owner": {}, "layer": {}, "link": {}, "tooltip": {}, "placeholders": {},
+}
+
+type drawIOFile struct {
+	XMLName xml.Name      `xml:"mxfile"`
+	Host    string        `xml:"host,attr"`
+	Diagram drawIODiagram `xml:"diagram"`
+}
+
+type drawIODiagram struct {
+	ID    string           `xml:"id,attr"`
+	Name  string           `xml:"name,attr"`
+	Model drawIOGraphModel `xml:"mxGraphModel"`
+}
+
+type drawIOGraphModel struct {
+	Cells []any `xml:"root>any"`
+}
+
+// drawIOCell is mxCell, the shape or the edge of the diagram
+type drawIOCell struct {
+	XMLName  xml.Name        `xml:"mxCell"`
+	ID       string          `xml:"id,attr,omitempty"`
+	Value    string          `xml:"value,attr,omitempty"`
+	Style    string          `xml:"style,attr,omitempty"`
+	Parent   string          `xml:"parent,attr,omitempty"`
+	Vertex   string          `xml:"vertex,attr,omitempty"`
+	Edge     string          `xml:"edge,attr,omitempty"`
+	Source   string          `xml:"source,attr,omitempty"`
+	Target   string          `xml:"target,attr,omitempty"`
+	Geometry *drawIOGeometry `xml:"mxGeometry"`
+}
+
+// drawIOObject is UserObject, the shape with properties shown in Edit Data dialog of draw.io
+type drawIOObject struct {
+	XMLName   xml.Name `xml:"UserObject"`
+	ID        string   `xml:"id,attr"`
+	Label     string   `xml:"label,attr"`
+	Path      string   `xml:"path,attr"`
+	Workspace string   `xml:"workspace,attr,omitempty"`
+	Overlay   string   `xml:"overlay,attr,omitempty"`
+	State     string   `xml:"state,attr"`
+	Owner     string   `xml:"owner,attr,omitempty"`
+	Layer     string   `xml:"layer,attr,omitempty"`
+	Link      string   `xml:"link,attr,omitempty"`
+	Tooltip   string   `xml:"tooltip,attr,omitempty"`
+	// Properties are set with [WithDrawIONodeDecorator]
+	Properties []xml.Attr `xml:",any,attr"`
+	Cell       drawIOCell
+}
+
+type drawIOGeometry struct {
+	X        int    `xml:"x,attr,omitempty"`
+	Y        int    `xml:"y,attr,omitempty"`
+	Width    int    `xml:"width,attr,omitempty"`
+	Height   int    `xml:"height,attr,omitempty"`
+	Relative string `xml:"relative,attr,omitempty"`
+	As       string `xml:"as,attr"`
+}
+
+// BuildDrawIO returns the graph as uncompressed draw.io (mxGraph) XML file, which can be imported into draw.io and enriched manually, e.g. for documentation.
+// Shapes are labeled like Mermaid nodes and have properties path, workspace, overlay, state, owner and layer. They are laid out in columns,
+// dependencies left of the deployments depending on them. Arrows point from deployments to their dependencies, unless changed with [WithDrawIODirection],
+// arrows to local modules are dashed. Ids of the shapes are derived from [terradep.Node.ID], so they are the same after every export
+func BuildDrawIO(graph *terradep.Graph, opts ...DrawIOOpt) ([]byte, error) {
+	cfg := &drawIOCfg{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	const root = "1"
+	cells := []any{drawIOCell{ID: "0"}, drawIOCell{ID: root, Parent: "0"}}
+
+	nodes := graph.Nodes()
+	positions := gridLayout(nodes)
+	ids := make(map[*terradep.Node]string, len(nodes))
+	for _, n := range nodes {
+		ids[n] = "n" + n.ID()
+		pos := positions[n]
+
+		style := drawIODeploymentStyle
+		if _, ok := n.State.(terradep.LocalModule); ok {
+			style = drawIOModuleStyle
+		} else if !graph.IsDeployment(n) {
+			style = drawIOExternalStyle
+		}
+
+		object := drawIOObject{
+			ID:        ids[n],
+			Label:     nodeLabel(graph, n),
+			Path:      n.Path,
+			Workspace: n.Workspace,
+			Overlay:   n.Overlay,
+			State:     n.State.String(),
+			Owner:     n.Owner,
+			Layer:     n.Layer,
+			Cell: drawIOCell{
+				Style:  style,
+				Parent: root,
+				Vertex: "1",
+				Geometry: &drawIOGeometry{
+					X:      drawIORowGap + pos.column*(drawIOWidth+drawIOColumnGap),
+					Y:      drawIORowGap + pos.row*(drawIOHeight+drawIORowGap),
+					Width:  drawIOWidth,
+					Height: drawIOHeight,
+					As:     "geometry",
+				},
+			},
+		}
+		attrs := cfg.decorators.node(graph, n)
+		if label, ok := attrs[AttrLabel]; ok {
+			object.Label = label
+		}
+		if color, ok := attrs[AttrColor]; ok {
+			object.Cell.Style += "strokeColor=" + color + ";"
+		}
+		object.Link, object.Tooltip = attrs[AttrURL], attrs[AttrTooltip]
+		for _, key := range attrs.custom() {
+			if _, ok := drawIOProperties[key]; !ok {
+				object.Properties = append(object.Properties, xml.Attr{Name: xml.Name{Local: key}, Value: attrs[key]})
+			}
+		}
+		cells = append(cells, object)
+	}
+
+	moduleLabel := "uses-module"
+	if cfg.direction == DataFlowDirection {
+		moduleLabel = "used-by"
+	}
+	edge := func(kind string, from, to *terradep.Node, style, label string) {
+		attrs := cfg.decorators.edge(graph, from, to)
+		if l, ok := attrs[AttrLabel]; ok {
+			label = l
+		}
+		if color, ok := attrs[AttrColor]; ok {
+			style += "strokeColor=" + color + ";"
+		}
+		from, to = cfg.direction.edge(from, to)
+		cells = append(cells, drawIOCell{
+			ID:       fmt.Sprintf("%s%s-%s", kind, from.ID(), to.ID()),
+			Value:    label,
+			Style:    style,
+			Parent:   root,
+			Edge:     "1",
+			Source:   ids[from],
+			Target:   ids[to],
+			Geometry: &drawIOGeometry{Relative: "1", As: "geometry"},
+		})
+	}
+	for _, n := range nodes {
+		for _, child := range n.Children {
+			edge("e", n, child, drawIOEdgeStyle, "")
+		}
+		for _, module := range n.Modules {
+			edge("m", n, module, drawIOModuleEdgeStyle, moduleLabel)
+		}
+	}
+
+	file := drawIOFile{
+		Host:    "terradep",
+		Diagram: drawIODiagram{ID: "terradep", Name: "Dependencies", Model: drawIOGraphModel{Cells: cells}},
+	}
+	out, err := xml.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling draw.io file: %w", err)
+	}
+
+	return append(out, '\n'), nil
+}
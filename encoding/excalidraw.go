@@ -0,0 +1,295 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+
+	"go.interactor.dev/terradep"
+)
+
+// sizes of the elements written by [BuildExcalidraw], in pixels
+const (
+	excalidrawWidth      = 260
+	excalidrawHeight     = 60
+	excalidrawColumnGap  = 120
+	excalidrawRowGap     = 40
+	excalidrawFontSize   = 16
+	excalidrawLineHeight = 1.25
+	// excalidrawFontFamily is Helvetica, the first hand-drawn font Virgil is hard to read with long paths
+	excalidrawFontFamily = 2
+)
+
+// ExcalidrawOpt changes the output of [BuildExcalidraw]
+type ExcalidrawOpt func(cfg *excalidrawCfg)
+
+type excalidrawCfg struct {
+	direction  Direction
+	decorators decorators
+}
+
+// WithExcalidrawDirection sets the direction of the arrows
+func WithExcalidrawDirection(direction Direction) ExcalidrawOpt {
+	return func(cfg *excalidrawCfg) {
+		cfg.direction = direction
+	}
+}
+
+// WithExcalidrawNodeDecorator changes the rectangles with the attributes of the decorator: [AttrLabel], [AttrColor] of the stroke and [AttrURL] set as the link.
+// Other attributes, including [AttrTooltip], are added to customData of the rectangle, except state and path set by terradep.
+// Can be set many times, later decorators override earlier ones
+func WithExcalidrawNodeDecorator(decorator NodeDecorator) ExcalidrawOpt {
+	return func(cfg *excalidrawCfg) {
+		cfg.decorators.nodes = append(cfg.decorators.nodes, decorator)
+	}
+}
+
+// WithExcalidrawEdgeDecorator changes the arrows with the attributes of the decorator, like [WithExcalidrawNodeDecorator].
+// Label is the text bound to the arrow
+func WithExcalidrawEdgeDecorator(decorator EdgeDecorator) ExcalidrawOpt {
+	return func(cfg *excalidrawCfg) {
+		cfg.decorators.edges = append(cfg.decorators.edges, decorator)
+	}
+}
+
+type excalidrawFile struct {
+	Type     string              `json:"type"`
+	Version  int                 `json:"version"`
+	Source   string              `json:"source"`
+	Elements []excalidrawElement `json:"elements"`
+	AppState excalidrawAppState  `json:"appState"`
+	Files    struct{}            `json:"files"`
+}
+
+type excalidrawAppState struct {
+	ViewBackgroundColor string `json:"viewBackgroundColor"`
+	GridSize            *int   `json:"gridSize"`
+}
+
+// excalidrawElement is rectangle, text or arrow, properties of other types are omitted
+type excalidrawElement struct {
+	ID              string              `json:"id"`
+	Type            string              `json:"type"`
+	X               float64             `json:"x"`
+	Y               float64             `json:"y"`
+	Width           float64             `json:"width"`
+	Height          float64             `json:"height"`
+	Angle           float64             `json:"angle"`
+	StrokeColor     string              `json:"strokeColor"`
+	BackgroundColor string              `json:"backgroundColor"`
+	FillStyle       string              `json:"fillStyle"`
+	StrokeWidth     int                 `json:"strokeWidth"`
+	StrokeStyle     string              `json:"strokeStyle"`
+	Roughness       int                 `json:"roughness"`
+	Opacity         int                 `json:"opacity"`
+	GroupIDs        []string            `json:"groupIds"`
+	FrameID         *string             `json:"frameId"`
+	Roundness       *excalidrawRound    `json:"roundness"`
+	Seed            uint32              `json:"seed"`
+	Version         int                 `json:"version"`
+	VersionNonce    uint32              `json:"versionNonce"`
+	IsDeleted       bool                `json:"isDeleted"`
+	BoundElements   []excalidrawBinding `json:"boundElements"`
+	Updated         int                 `json:"updated"`
+	Link            *string             `json:"link"`
+	Locked          bool                `json:"locked"`
+	CustomData      map[string]string   `json:"customData,omitempty"`
+
+	// text
+	Text          string  `json:"text,omitempty"`
+	OriginalText  string  `json:"originalText,omitempty"`
+	FontSize      int     `json:"fontSize,omitempty"`
+	FontFamily    int     `json:"fontFamily,omitempty"`
+	TextAlign     string  `json:"textAlign,omitempty"`
+	VerticalAlign string  `json:"verticalAlign,omitempty"`
+	ContainerID   string  `json:"containerId,omitempty"`
+	LineHeight    float64 `json:"lineHeight,omitempty"`
+
+	// arrow
+	Points       [][2]float64          `json:"points,omitempty"`
+	StartBinding *excalidrawEdgeTarget `json:"startBinding,omitempty"`
+	EndBinding   *excalidrawEdgeTarget `json:"endBinding,omitempty"`
+	EndArrowhead string                `json:"endArrowhead,omitempty"`
+}
+
+type excalidrawRound struct {
+	Type int `json:"type"`
+}
+
+type excalidrawBinding struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type excalidrawEdgeTarget struct {
+	ElementID string  `json:"elementId"`
+	Focus     float64 `json:"focus"`
+	Gap       float64 `json:"gap"`
+}
+
+// BuildExcalidraw returns the graph as Excalidraw scene, which can be opened in Excalidraw as a starting point of the architecture sketch.
+// Nodes are rectangles labeled like Mermaid nodes, laid out in columns, dependencies left of the deployments depending on them.
+// External states are dashed and local modules filled. Arrows point from deployments to their dependencies, unless changed with [WithExcalidrawDirection],
+// arrows to local modules are dashed. Ids of the elements are derived from [terradep.Node.ID], so they are the same after every export
+func BuildExcalidraw(graph *terradep.Graph, opts ...ExcalidrawOpt) ([]byte, error) {
+	cfg := &excalidrawCfg{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	nodes := graph.Nodes()
+	positions := gridLayout(nodes)
+	shapes := make(map[*terradep.Node]*excalidrawElement, len(nodes))
+	var texts, arrows []*excalidrawElement
+	for _, n := range nodes {
+		pos := positions[n]
+		shape := newExcalidrawElement("n"+n.ID(), "rectangle")
+		shape.X = float64(excalidrawRowGap + pos.column*(excalidrawWidth+excalidrawColumnGap))
+		shape.Y = float64(excalidrawRowGap + pos.row*(excalidrawHeight+excalidrawRowGap))
+		shape.Width, shape.Height = excalidrawWidth, excalidrawHeight
+		shape.Roundness = &excalidrawRound{Type: 3}
+		shape.CustomData = map[string]string{"state": n.State.String()}
+		if _, ok := n.State.(terradep.LocalModule); ok {
+			shape.BackgroundColor, shape.FillStyle = "#a5d8ff", "solid"
+		} else if !graph.IsDeployment(n) {
+			shape.StrokeStyle = "dashed"
+		} else {
+			shape.CustomData["path"] = n.Path
+		}
+		shapes[n] = shape
+
+		label := nodeLabel(graph, n)
+		if l, ok := decorateExcalidraw(shape, cfg.decorators.node(graph, n)); ok {
+			label = l
+		}
+		text := newExcalidrawText("t"+n.ID(), shape, label)
+		text.Width = excalidrawWidth - 2*excalidrawFontSize
+		text.X = shape.X + (shape.Width-text.Width)/2
+		text.Y = shape.Y + (shape.Height-text.Height)/2
+		texts = append(texts, text)
+	}
+
+	arrow := func(kind string, from, to *terradep.Node, style string) {
+		attrs := cfg.decorators.edge(graph, from, to)
+		from, to = cfg.direction.edge(from, to)
+		start, end := shapes[from], shapes[to]
+
+		a := newExcalidrawElement(fmt.Sprintf("%s%s-%s", kind, from.ID(), to.ID()), "arrow")
+		a.StrokeStyle = style
+		a.Roundness = &excalidrawRound{Type: 2}
+		a.X, a.Y = start.X+start.Width, start.Y+start.Height/2
+		endX, endY := end.X, end.Y+end.Height/2
+		if start.X > end.X {
+			a.X, endX = start.X, end.X+end.Width
+		}
+		a.Points = [][2]float64{{0, 0}, {endX - a.X, endY - a.Y}}
+		a.Width, a.Height = math.Abs(endX-a.X), math.Abs(endY-a.Y)
+		a.StartBinding = &excalidrawEdgeTarget{ElementID: start.ID, Gap: 1}
+		a.EndBinding = &excalidrawEdgeTarget{ElementID: end.ID, Gap: 1}
+		a.EndArrowhead = "arrow"
+
+		start.BoundElements = append(start.BoundElements, excalidrawBinding{ID: a.ID, Type: "arrow"})
+		end.BoundElements = append(end.BoundElements, excalidrawBinding{ID: a.ID, Type: "arrow"})
+		arrows = append(arrows, a)
+
+		if label, ok := decorateExcalidraw(a, attrs); ok {
+			text := newExcalidrawText("l"+a.ID, a, label)
+			text.Width = excalidrawColumnGap
+			text.X = a.X + a.Points[1][0]/2 - text.Width/2
+			text.Y = a.Y + a.Points[1][1]/2 - text.Height/2
+			arrows = append(arrows, text)
+		}
+	}
+	for _, n := range nodes {
+		for _, child := range n.Children {
+			arrow("e", n, child, "solid")
+		}
+		for _, module := range n.Modules {
+			arrow("m", n, module, "dashed")
+		}
+	}
+
+	file := excalidrawFile{
+		Type:     "excalidraw",
+		Version:  2,
+		Source:   "terradep",
+		AppState: excalidrawAppState{ViewBackgroundColor: "#ffffff"},
+	}
+	// text must follow its container, otherwise Excalidraw draws the rectangle over it
+	for _, n := range nodes {
+		file.Elements = append(file.Elements, *shapes[n])
+	}
+	for _, e := range append(texts, arrows...) {
+		file.Elements = append(file.Elements, *e)
+	}
+
+	out, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling Excalidraw scene: %w", err)
+	}
+
+	return append(out, '\n'), nil
+}
+
+// decorateExcalidraw sets the attributes of the decorators to the element, returns the label when it is set
+func decorateExcalidraw(e *excalidrawElement, attrs Attributes) (string, bool) {
+	if color, ok := attrs[AttrColor]; ok {
+		e.StrokeColor = color
+	}
+	if url, ok := attrs[AttrURL]; ok {
+		e.Link = &url
+	}
+	for _, key := range append(attrs.custom(), AttrTooltip) {
+		if _, set := e.CustomData[key]; set {
+			continue
+		}
+		if value, ok := attrs[key]; ok {
+			if e.CustomData == nil {
+				e.CustomData = make(map[string]string)
+			}
+			e.CustomData[key] = value
+		}
+	}
+
+	label, ok := attrs[AttrLabel]
+	return label, ok
+}
+
+// newExcalidrawText returns the text bound to the container, centered in one line. Caller sets its width and position
+func newExcalidrawText(id string, container *excalidrawElement, label string) *excalidrawElement {
+	text := newExcalidrawElement(id, "text")
+	text.Height = excalidrawFontSize * excalidrawLineHeight
+	text.Text, text.OriginalText = label, label
+	text.FontSize, text.FontFamily, text.LineHeight = excalidrawFontSize, excalidrawFontFamily, excalidrawLineHeight
+	text.TextAlign, text.VerticalAlign = "center", "middle"
+	text.ContainerID = container.ID
+	container.BoundElements = append(container.BoundElements, excalidrawBinding{ID: text.ID, Type: "text"})
+
+	return text
+}
+
+// newExcalidrawElement returns the element with default style. Seed, which randomizes the hand-drawn strokes, is derived from the id,
+// so the scene is the same after every export
+func newExcalidrawElement(id, typ string) *excalidrawElement {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	seed := h.Sum32()
+
+	return &excalidrawElement{
+		ID:              id,
+		Type:            typ,
+		StrokeColor:     "#1e1e1e",
+		BackgroundColor: "transparent",
+		FillStyle:       "hachure",
+		StrokeWidth:     1,
+		StrokeStyle:     "solid",
+		Roughness:       1,
+		Opacity:         100,
+		GroupIDs:        []string{},
+		Seed:            seed,
+		Version:         1,
+		VersionNonce:    seed,
+		BoundElements:   []excalidrawBinding{},
+	}
+}
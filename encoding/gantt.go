@@ -0,0 +1,86 @@
+package encoding
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.interactor.dev/terradep"
+)
+
+// estimated duration of applying the deployment, see [EstimateApplyDuration]
+const (
+	// DefaultApplyDuration is the time of initializing and refreshing the deployment without resources
+	DefaultApplyDuration = time.Minute
+	// DefaultResourceDuration is the time added for every managed resource of the deployment
+	DefaultResourceDuration = 10 * time.Second
+)
+
+// GanttOpt changes the output of [BuildMermaidGantt]
+type GanttOpt func(cfg *ganttCfg)
+
+type ganttCfg struct {
+	duration func(*terradep.Node) time.Duration
+}
+
+// WithGanttDurations sets the duration of applying every deployment, e.g. measured by previous runs, instead of [EstimateApplyDuration]
+func WithGanttDurations(duration func(*terradep.Node) time.Duration) GanttOpt {
+	return func(cfg *ganttCfg) {
+		cfg.duration = duration
+	}
+}
+
+// EstimateApplyDuration estimates the time of applying the deployment from the number of its managed resources:
+// [DefaultApplyDuration] and [DefaultResourceDuration] for every resource
+func EstimateApplyDuration(n *terradep.Node) time.Duration {
+	if n.Metadata == nil {
+		return DefaultApplyDuration
+	}
+
+	return DefaultApplyDuration + time.Duration(n.Metadata.Resources)*DefaultResourceDuration
+}
+
+// ganttTask escapes the name of the task, which cannot contain colon separating it from the properties nor semicolon ending the statement
+var ganttTask = strings.NewReplacer(":", " ", ";", " ")
+
+// BuildMermaidGantt returns the timeline of applying the deployments as Mermaid gantt chart, see [terradep.ScheduleDeployments].
+// Deployments are in sections by batches returned by [terradep.Levels] and start as soon as all their dependencies finish.
+// Deployments on the critical path, which determines the time of applying all of them, are marked crit.
+// Durations are estimated with [EstimateApplyDuration], unless set with [WithGanttDurations]
+func BuildMermaidGantt(graph *terradep.Graph, opts ...GanttOpt) []byte {
+	cfg := &ganttCfg{duration: EstimateApplyDuration}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	schedule := terradep.ScheduleDeployments(graph, func(n *terradep.Node) time.Duration {
+		// the chart has resolution of seconds
+		return cfg.duration(n).Round(time.Second)
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString("gantt\n")
+	fmt.Fprintf(&sb, "  title Apply of %d deployments, total %s\n", len(schedule.Start), schedule.Total)
+	sb.WriteString("  dateFormat HH:mm:ss\n")
+	sb.WriteString("  axisFormat %H:%M:%S\n")
+	for i, batch := range schedule.Batches {
+		fmt.Fprintf(&sb, "  section Batch %d\n", i+1)
+		for _, n := range batch {
+			tags := ""
+			if schedule.IsCritical(n) {
+				tags = "crit, "
+			}
+			start, length := schedule.Start[n], schedule.Finish[n]-schedule.Start[n]
+			fmt.Fprintf(&sb, "  %s :%sn%s, %s, %ds\n", ganttTask.Replace(nodeLabel(graph, n)), tags, n.ID(), ganttTime(start), int(length.Seconds()))
+		}
+	}
+
+	return []byte(sb.String())
+}
+
+// ganttTime formats the offset from the start like dateFormat of the chart
+func ganttTime(d time.Duration) string {
+	seconds := int(d.Seconds())
+
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+}
@@ -0,0 +1,243 @@
+package encoding
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"go.interactor.dev/terradep"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCommands are run in every deployment by the generated CI jobs, unless changed with [WithCommands]
+var DefaultCommands = []string{"terraform init -input=false", "terraform plan -input=false"}
+
+// DefaultOwner owns the deployments which do not declare the owner in the [terradep.Manifest], unless changed with [WithDefaultOwner]
+const DefaultOwner = "unknown"
+
+// GenerateOpt changes the output of generators of CI configuration, e.g. [BuildAtlantisConfig]
+type GenerateOpt func(cfg *generateCfg)
+
+type generateCfg struct {
+	root     string
+	commands []string
+	// owner is used when the deployment does not declare the owner in the manifest
+	owner string
+	// docsDir is the directory of the documentation relative to the root, empty when it is written to the directories of the deployments
+	docsDir string
+	// only is nil, when all the deployments are generated
+	only map[*terradep.Node]struct{}
+}
+
+// WithRootDir makes absolute paths of the deployments relative to the directory, usually the root of the repository
+func WithRootDir(dir string) GenerateOpt {
+	return func(cfg *generateCfg) {
+		cfg.root = dir
+	}
+}
+
+// WithOnly limits the output to the deployments among the nodes, e.g. returned by [terradep.Graph.Affected].
+// Dependencies on other deployments are omitted
+func WithOnly(nodes ...*terradep.Node) GenerateOpt {
+	return func(cfg *generateCfg) {
+		cfg.only = make(map[*terradep.Node]struct{}, len(nodes))
+		for _, n := range nodes {
+			cfg.only[n] = struct{}{}
+		}
+	}
+}
+
+// WithCommands sets shell commands run in the directory of every deployment by the generated CI jobs
+func WithCommands(commands ...string) GenerateOpt {
+	return func(cfg *generateCfg) {
+		cfg.commands = commands
+	}
+}
+
+// WithDefaultOwner sets owner of the deployments which do not declare the owner in the [terradep.Manifest]
+func WithDefaultOwner(owner string) GenerateOpt {
+	return func(cfg *generateCfg) {
+		cfg.owner = owner
+	}
+}
+
+func newGenerateCfg(opts []GenerateOpt) *generateCfg {
+	cfg := &generateCfg{commands: DefaultCommands, owner: DefaultOwner}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// generated is a deployment written by the generator with its dependencies, which are also generated
+type generated struct {
+	*terradep.Node
+	// dir is the path relative to the root directory with forward slashes
+	dir          string
+	dependencies []*generated
+	// level is the depth returned by [terradep.Levels]
+	level int
+}
+
+// label identifies the deployment, workspace and overlay, e.g. live/app:prod#eu
+func (g *generated) label() string {
+	label := g.dir
+	if g.Workspace != "" {
+		label += ":" + g.Workspace
+	}
+	if g.Overlay != "" {
+		label += "#" + g.Overlay
+	}
+
+	return label
+}
+
+// uniqueIDs returns ids of the deployments derived from their labels by the function, ids repeated after the derivation get numeric suffix
+func uniqueIDs(deployments []*generated, derive func(label string) string) map[*generated]string {
+	out := make(map[*generated]string, len(deployments))
+	used := make(map[string]struct{}, len(deployments))
+	for _, d := range deployments {
+		base := derive(d.label())
+		id := base
+		for i := 2; ; i++ {
+			if _, ok := used[id]; !ok {
+				break
+			}
+			id = base + "-" + strconv.Itoa(i)
+		}
+		used[id] = struct{}{}
+		out[d] = id
+	}
+
+	return out
+}
+
+// deploymentEnv returns environment variables describing the deployment to the commands, like set by terradep run
+func deploymentEnv(g *generated) map[string]string {
+	env := map[string]string{"TERRADEP_PATH": g.dir}
+	if g.Overlay != "" {
+		env["TERRADEP_OVERLAY"] = g.Overlay
+	}
+	if g.Workspace != "" {
+		env["TF_WORKSPACE"] = g.Workspace
+	}
+
+	return env
+}
+
+// deployments returns deployments of the graph to generate, ordered like [terradep.Node.Children]
+func (cfg *generateCfg) deployments(graph *terradep.Graph) []*generated {
+	var out []*generated
+	byNode := make(map[*terradep.Node]*generated)
+	for _, n := range graph.Nodes() {
+		if !graph.IsDeployment(n) {
+			continue
+		}
+		if _, ok := cfg.only[n]; cfg.only != nil && !ok {
+			continue
+		}
+		g := &generated{Node: n, dir: cfg.relPath(n.Path)}
+		byNode[n] = g
+		out = append(out, g)
+	}
+
+	for _, g := range out {
+		for _, child := range g.Children {
+			if dep, ok := byNode[child]; ok {
+				g.dependencies = append(g.dependencies, dep)
+			}
+		}
+	}
+
+	_, depth := terradep.Levels(graph, cfg.levelsOpts()...)
+	for _, g := range out {
+		g.level = depth[g.Node]
+	}
+
+	return out
+}
+
+// levelsOpts limits [terradep.Levels] to the generated deployments
+func (cfg *generateCfg) levelsOpts() []terradep.LevelsOpt {
+	if cfg.only == nil {
+		return nil
+	}
+
+	nodes := make([]*terradep.Node, 0, len(cfg.only))
+	for n := range cfg.only {
+		nodes = append(nodes, n)
+	}
+
+	return []terradep.LevelsOpt{terradep.WithLevelsOnly(nodes...)}
+}
+
+// relPath returns the path relative to the root directory with forward slashes. Relative paths are returned unchanged
+func (cfg *generateCfg) relPath(p string) string {
+	if cfg.root != "" && filepath.IsAbs(p) {
+		if rel, err := filepath.Rel(cfg.root, p); err == nil {
+			p = rel
+		}
+	}
+
+	return path.Clean(filepath.ToSlash(p))
+}
+
+// modulePaths returns directories of local modules used by the deployment relative to the deployment directory,
+// set only when the graph was scanned with [terradep.WithModuleEdges]
+func modulePaths(n *terradep.Node) []string {
+	var out []string
+	for _, m := range n.Modules {
+		rel, err := filepath.Rel(n.Path, m.Path)
+		if err != nil {
+			continue
+		}
+		out = append(out, filepath.ToSlash(rel))
+	}
+
+	return out
+}
+
+// yamlMapping is a YAML mapping keeping the order of the keys, unlike Go maps
+type yamlMapping struct {
+	node yaml.Node
+}
+
+func newYAMLMapping() *yamlMapping {
+	return &yamlMapping{node: yaml.Node{Kind: yaml.MappingNode}}
+}
+
+// set appends the key with encoded value to the mapping
+func (m *yamlMapping) set(key string, value any) error {
+	v := &yaml.Node{}
+	if err := v.Encode(value); err != nil {
+		return fmt.Errorf("encoding yaml key: %s, %w", key, err)
+	}
+	m.node.Content = append(m.node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, v)
+
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler
+func (m *yamlMapping) MarshalYAML() (any, error) {
+	return &m.node, nil
+}
+
+// encodeYAML returns the values encoded as YAML documents indented with 2 spaces
+func encodeYAML(docs ...any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
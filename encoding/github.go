@@ -0,0 +1,141 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"go.interactor.dev/terradep"
+)
+
+// gitHubJobID matches characters not allowed in ids of GitHub Actions jobs
+var gitHubJobID = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+type gitHubMatrix struct {
+	Batches []gitHubBatch `json:"batches"`
+}
+
+// gitHubBatch is a matrix of the deployments which can run at the same time
+type gitHubBatch struct {
+	Include []gitHubMatrixEntry `json:"include"`
+}
+
+type gitHubMatrixEntry struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Workspace string `json:"workspace"`
+	Overlay   string `json:"overlay"`
+}
+
+// BuildGitHubMatrix returns JSON with GitHub Actions job matrices, one per batch of deployments, e.g. {"batches":[{"include":[{"name":"live/network","path":"live/network","workspace":"","overlay":""}]}]}.
+// Deployments of the batch depend only on deployments of the previous batches, so batch can be run after all the previous ones, e.g. with strategy.matrix
+// set to ${{ fromJSON(needs.terradep.outputs.matrix).batches[0] }}
+func BuildGitHubMatrix(graph *terradep.Graph, opts ...GenerateOpt) ([]byte, error) {
+	cfg := newGenerateCfg(opts)
+	matrix := gitHubMatrix{Batches: []gitHubBatch{}}
+	for _, d := range cfg.deployments(graph) {
+		for len(matrix.Batches) <= d.level {
+			matrix.Batches = append(matrix.Batches, gitHubBatch{Include: []gitHubMatrixEntry{}})
+		}
+		batch := &matrix.Batches[d.level]
+		batch.Include = append(batch.Include, gitHubMatrixEntry{Name: d.label(), Path: d.dir, Workspace: d.Workspace, Overlay: d.Overlay})
+	}
+
+	out, err := json.Marshal(matrix)
+	if err != nil {
+		return nil, fmt.Errorf("encoding github matrix: %w", err)
+	}
+
+	return append(out, '\n'), nil
+}
+
+type gitHubJob struct {
+	Name        string            `yaml:"name"`
+	RunsOn      string            `yaml:"runs-on"`
+	Needs       []string          `yaml:"needs,omitempty"`
+	Concurrency string            `yaml:"concurrency"`
+	Env         map[string]string `yaml:"env"`
+	Defaults    gitHubDefaults    `yaml:"defaults"`
+	Steps       []gitHubStep      `yaml:"steps"`
+}
+
+type gitHubDefaults struct {
+	Run gitHubRun `yaml:"run"`
+}
+
+type gitHubRun struct {
+	WorkingDirectory string `yaml:"working-directory"`
+}
+
+type gitHubStep struct {
+	Uses string `yaml:"uses,omitempty"`
+	Run  string `yaml:"run,omitempty"`
+}
+
+// gitHubSetupSteps are run by every job before the commands
+var gitHubSetupSteps = []gitHubStep{{Uses: "actions/checkout@v4"}, {Uses: "hashicorp/setup-terraform@v3"}}
+
+// BuildGitHubWorkflow returns GitHub Actions workflow with one job per deployment, which can be run manually or called by other workflows.
+// Job needs the jobs of all the deployments it depends on and runs the commands set with [WithCommands] in the directory of the deployment
+// with variables TERRADEP_PATH, TERRADEP_OVERLAY and TF_WORKSPACE set. Jobs of the same deployment never run at the same time
+func BuildGitHubWorkflow(graph *terradep.Graph, opts ...GenerateOpt) ([]byte, error) {
+	cfg := newGenerateCfg(opts)
+	workflow := newYAMLMapping()
+	if err := workflow.set("name", "terradep"); err != nil {
+		return nil, err
+	}
+	if err := workflow.set("on", map[string]any{"workflow_call": map[string]any{}, "workflow_dispatch": map[string]any{}}); err != nil {
+		return nil, err
+	}
+
+	deployments := cfg.deployments(graph)
+	ids := gitHubJobIDs(deployments)
+	jobs := newYAMLMapping()
+	if len(deployments) == 0 {
+		// workflow without jobs is invalid
+		job := map[string]any{"runs-on": "ubuntu-latest", "steps": []gitHubStep{{Run: `echo "No deployments to run"`}}}
+		if err := jobs.set("no-deployments", job); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range deployments {
+		job := gitHubJob{
+			Name:        d.label(),
+			RunsOn:      "ubuntu-latest",
+			Concurrency: d.label(),
+			Env:         deploymentEnv(d),
+			Defaults:    gitHubDefaults{Run: gitHubRun{WorkingDirectory: d.dir}},
+			Steps:       append([]gitHubStep{}, gitHubSetupSteps...),
+		}
+		for _, dep := range d.dependencies {
+			job.Needs = append(job.Needs, ids[dep])
+		}
+		for _, command := range cfg.commands {
+			job.Steps = append(job.Steps, gitHubStep{Run: command})
+		}
+		if err := jobs.set(ids[d], job); err != nil {
+			return nil, err
+		}
+	}
+	if err := workflow.set("jobs", jobs); err != nil {
+		return nil, err
+	}
+
+	out, err := encodeYAML(workflow)
+	if err != nil {
+		return nil, fmt.Errorf("encoding github workflow: %w", err)
+	}
+
+	return out, nil
+}
+
+// gitHubJobIDs returns unique ids of the jobs, which may contain only alphanumeric characters, '-' or '_' and must start with a letter or '_'
+func gitHubJobIDs(deployments []*generated) map[*generated]string {
+	return uniqueIDs(deployments, func(label string) string {
+		id := gitHubJobID.ReplaceAllString(label, "-")
+		if id == "" || id[0] == '-' || (id[0] >= '0' && id[0] <= '9') {
+			id = "_" + id
+		}
+		return id
+	})
+}
@@ -0,0 +1,64 @@
+package encoding
+
+import (
+	"fmt"
+
+	"go.interactor.dev/terradep"
+)
+
+const (
+	gitLabStage = "terradep"
+	// gitLabEmptyJob is generated when there are no deployments, because pipeline without jobs is invalid
+	gitLabEmptyJob = "terradep:no-deployments"
+)
+
+type gitLabJob struct {
+	Stage         string            `yaml:"stage"`
+	Variables     map[string]string `yaml:"variables,omitempty"`
+	Needs         []string          `yaml:"needs"`
+	ResourceGroup string            `yaml:"resource_group,omitempty"`
+	Script        []string          `yaml:"script"`
+}
+
+// BuildGitLabPipeline returns GitLab CI pipeline, usually run as dynamic child pipeline, with one job per deployment.
+// Job needs the jobs of all the deployments it depends on, so the deployments run in the order of the dependencies.
+// Jobs run the commands set with [WithCommands] in the directory of the deployment with variables TERRADEP_PATH,
+// TERRADEP_OVERLAY and TF_WORKSPACE set. Jobs of the same deployment never run at the same time, thanks to the resource group
+func BuildGitLabPipeline(graph *terradep.Graph, opts ...GenerateOpt) ([]byte, error) {
+	cfg := newGenerateCfg(opts)
+	pipeline := newYAMLMapping()
+	if err := pipeline.set("stages", []string{gitLabStage}); err != nil {
+		return nil, err
+	}
+
+	deployments := cfg.deployments(graph)
+	if len(deployments) == 0 {
+		job := gitLabJob{Stage: gitLabStage, Needs: []string{}, Script: []string{`echo "No deployments to run"`}}
+		if err := pipeline.set(gitLabEmptyJob, job); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, d := range deployments {
+		job := gitLabJob{
+			Stage:         gitLabStage,
+			Variables:     deploymentEnv(d),
+			Needs:         make([]string, 0, len(d.dependencies)),
+			ResourceGroup: d.label(),
+			Script:        append([]string{`cd "$TERRADEP_PATH"`}, cfg.commands...),
+		}
+		for _, dep := range d.dependencies {
+			job.Needs = append(job.Needs, dep.label())
+		}
+		if err := pipeline.set(d.label(), job); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := encodeYAML(pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("encoding gitlab pipeline: %w", err)
+	}
+
+	return out, nil
+}
@@ -2,26 +2,155 @@ package encoding
 
 import (
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
 
 	"go.interactor.dev/terradep"
+	"gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/encoding"
 	multi2 "gonum.org/v1/gonum/graph/multi"
 )
 import "gonum.org/v1/gonum/graph/encoding/dot"
 
+// DOTOpt changes the output of [BuildDOTGraph]
+type DOTOpt func(cfg *dotCfg)
+
+type dotCfg struct {
+	metadata bool
+	// cluster returns the key of the cluster of the node, see [WithClusters]
+	cluster   func(*terradep.Node) string
+	direction Direction
+	outputs   bool
+	// weight returns the weight of the node named weightName, see [WithNodeWeight]
+	weight     func(*terradep.Node) int
+	weightName string
+	legend     bool
+	decorators decorators
+}
+
+// WithNodeMetadata adds to every node a tooltip describing [terradep.ModuleMetadata], [terradep.GitInfo] and metadata read from [terradep.Manifest]
+func WithNodeMetadata() DOTOpt {
+	return func(cfg *dotCfg) {
+		cfg.metadata = true
+	}
+}
+
+// WithClusters groups nodes with the same key into clusters labeled with the key, e.g. by [terradep.Node.Team].
+// Nodes with empty key are not grouped
+func WithClusters(key func(*terradep.Node) string) DOTOpt {
+	return func(cfg *dotCfg) {
+		cfg.cluster = key
+	}
+}
+
+// WithDOTDirection sets the direction of the edges, edges to modules are labeled used-by in [DataFlowDirection]
+func WithDOTDirection(direction Direction) DOTOpt {
+	return func(cfg *dotCfg) {
+		cfg.direction = direction
+	}
+}
+
+// WithEdgeOutputs labels the edges with the outputs of the states consumed by the deployments, see [terradep.EdgeMetadata]
+func WithEdgeOutputs() DOTOpt {
+	return func(cfg *dotCfg) {
+		cfg.outputs = true
+	}
+}
+
+// WithNodeWeight scales and colors the nodes by the weight, e.g. number of resources or dependents, so the heaviest deployments stand out.
+// The node with the highest weight is the biggest and the darkest, nodes with zero weight are drawn like without the option.
+// Name of the weight is shown in the legend, see [WithLegend]
+func WithNodeWeight(name string, weight func(*terradep.Node) int) DOTOpt {
+	return func(cfg *dotCfg) {
+		cfg.weight = weight
+		cfg.weightName = name
+	}
+}
+
+// WithLegend adds the box explaining the edges, clusters and colors of the nodes, so the rendered graph is understandable without the documentation
+func WithLegend() DOTOpt {
+	return func(cfg *dotCfg) {
+		cfg.legend = true
+	}
+}
+
+// WithDOTNodeDecorator adds the attributes of the decorator to the nodes, [AttrURL] is written as attribute URL and other attributes as they are,
+// so they must be attributes of Graphviz, e.g. fillcolor. Can be set many times, later decorators override earlier ones
+func WithDOTNodeDecorator(decorator NodeDecorator) DOTOpt {
+	return func(cfg *dotCfg) {
+		cfg.decorators.nodes = append(cfg.decorators.nodes, decorator)
+	}
+}
+
+// WithDOTEdgeDecorator adds the attributes of the decorator to the edges, like [WithDOTNodeDecorator]
+func WithDOTEdgeDecorator(decorator EdgeDecorator) DOTOpt {
+	return func(cfg *dotCfg) {
+		cfg.decorators.edges = append(cfg.decorators.edges, decorator)
+	}
+}
+
 // BuildDOTGraph returns graph represented in Graphviz DOT format
-func BuildDOTGraph(dep *terradep.Graph) ([]byte, error) {
+func BuildDOTGraph(dep *terradep.Graph, opts ...DOTOpt) ([]byte, error) {
+	cfg := &dotCfg{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	multi := multi2.NewDirectedGraph()
 
-	nodeByPath := mapNodes(dep)
+	nodeByState := mapNodes(dep)
+	for state, node := range nodeByState {
+		node.metadata = cfg.metadata
+		node.decorated = cfg.decorators.node(dep, node.Node)
+		nodeByState[state] = node
+	}
+	heaviest := 0
+	if cfg.weight != nil {
+		heaviest = scaleNodes(nodeByState, cfg.weight)
+	}
 
-	for _, node := range nodeByPath {
+	moduleAttrs := usesModuleAttrs
+	if cfg.direction == DataFlowDirection {
+		moduleAttrs = usedByAttrs
+	}
+	for _, node := range sortedByID(nodeByState) {
 		for _, child := range node.Children {
-			line := multi.NewLine(node, nodeByPath[child.Path])
-			multi.SetLine(line)
+			from, to := cfg.direction.edge(node.Node, child)
+			line := multi.NewLine(nodeByState[from.State.String()], nodeByState[to.State.String()])
+			var attrs []encoding.Attribute
+			if outputs := node.EdgeMetadata(child).Outputs; cfg.outputs && len(outputs) != 0 {
+				attrs = []encoding.Attribute{{Key: "label", Value: strings.Join(outputs, ", ")}}
+			}
+			attrs = dotAttributes(attrs, cfg.decorators.edge(dep, node.Node, child))
+			if len(attrs) == 0 {
+				multi.SetLine(line)
+				continue
+			}
+			multi.SetLine(styledLine{Line: line, attrs: attrs})
 		}
+
+		for _, module := range node.Modules {
+			from, to := cfg.direction.edge(node.Node, module)
+			line := multi.NewLine(nodeByState[from.State.String()], nodeByState[to.State.String()])
+			multi.SetLine(styledLine{Line: line, attrs: dotAttributes(moduleAttrs, cfg.decorators.edge(dep, node.Node, module))})
+		}
+	}
+
+	var g graph.Multigraph = multi
+	if cfg.cluster != nil || cfg.legend {
+		clustered := clusteredGraph{DirectedGraph: multi}
+		if cfg.cluster != nil {
+			clustered.clusters = buildClusters(nodeByState, cfg.cluster)
+		}
+		if cfg.legend {
+			clustered.clusters = append(clustered.clusters, buildLegend(cfg, dep, heaviest))
+		}
+		g = clustered
 	}
 
-	bytes, err := dot.MarshalMulti(multi, "name", "", "")
+	bytes, err := dot.MarshalMulti(g, "name", "", "")
 	if err != nil {
 		return nil, fmt.Errorf("marshaling multigraph: %w", err)
 	}
@@ -29,44 +158,44 @@ func BuildDOTGraph(dep *terradep.Graph) ([]byte, error) {
 	return bytes, nil
 }
 
-// mapNodes returns map where key is path to the module of terradep.Node
+// mapNodes returns map where key is string representation of state of terradep.Node. Unlike path, state is unique for every node
 func mapNodes(dep *terradep.Graph) map[string]graphNode {
 	depNodes := make([]*terradep.Node, 0)
-	for _, head := range dep.Heads {
-		depNodes = append(depNodes, head)
-		depNodes = append(depNodes, getAllChildren(head)...)
-	}
+	// visit does not return errors
+	_ = dep.Walk(func(n *terradep.Node) error {
+		depNodes = append(depNodes, n)
+		return nil
+	})
 
 	uniqueDepNodes := toGraphNodes(depNodes)
 
 	out := make(map[string]graphNode, len(uniqueDepNodes))
 	for _, depNode := range uniqueDepNodes {
-		out[depNode.Path] = depNode
+		out[depNode.State.String()] = depNode
 	}
 
 	return out
 }
 
-func getAllChildren(n *terradep.Node) []*terradep.Node {
-	if len(n.Children) == 0 {
-		return nil
+// sortedByID returns nodes ordered by ID, so lines are always added in the same order
+func sortedByID(nodes map[string]graphNode) []graphNode {
+	out := make([]graphNode, 0, len(nodes))
+	for _, node := range nodes {
+		out = append(out, node)
 	}
 
-	var out []*terradep.Node
-	out = append(out, n.Children...)
-
-	for _, child := range n.Children {
-		out = append(out, getAllChildren(child)...)
-	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].id < out[j].id
+	})
 
 	return out
 }
 
 func toGraphNodes(nodes []*terradep.Node) []graphNode {
 	out := make([]graphNode, 0)
-	for i, node := range nodes {
+	for _, node := range nodes {
 		out = append(out, graphNode{
-			id:   int64(i),
+			id:   node.NumericID(),
 			Node: node,
 		})
 	}
@@ -75,8 +204,15 @@ func toGraphNodes(nodes []*terradep.Node) []graphNode {
 }
 
 type graphNode struct {
+	// id is [terradep.Node.NumericID], so gonum orders the nodes the same way after every scan
 	id int64
 	*terradep.Node
+	// metadata enables the tooltip, see [WithNodeMetadata]
+	metadata bool
+	// scale is the weight of the node relative to the heaviest node, from 0 to 1, see [WithNodeWeight]
+	scale float64
+	// decorated are the attributes of [WithDOTNodeDecorator]
+	decorated Attributes
 }
 
 // ID implements graph.Node
@@ -88,3 +224,227 @@ func (n graphNode) ID() int64 {
 func (n graphNode) DOTID() string {
 	return n.State.String()
 }
+
+// Attributes implements encoding.Attributer. Attribute id is [terradep.Node.ID], which is the id of the element in SVG rendered by Graphviz
+func (n graphNode) Attributes() []encoding.Attribute {
+	attrs := []encoding.Attribute{{Key: "id", Value: n.Node.ID()}}
+	if n.scale > 0 {
+		attrs = append(attrs, weightAttrs(n.scale)...)
+	}
+	if n.metadata {
+		if tooltip := nodeTooltip(n.Node); tooltip != "" {
+			attrs = append(attrs, encoding.Attribute{Key: "tooltip", Value: tooltip})
+		}
+	}
+
+	return dotAttributes(attrs, n.decorated)
+}
+
+// dotAttributes returns the copy of the attributes with the decorated ones, which override the attributes with the same key.
+// [AttrURL] is renamed to URL, other attributes are added ordered by key
+func dotAttributes(attrs []encoding.Attribute, decorated Attributes) []encoding.Attribute {
+	if len(decorated) == 0 {
+		return attrs
+	}
+
+	out := make([]encoding.Attribute, 0, len(attrs)+len(decorated))
+	set := make(map[string]string, len(decorated))
+	for _, key := range sortedKeys(decorated) {
+		name := key
+		if key == AttrURL {
+			name = "URL"
+		}
+		set[name] = decorated[key]
+	}
+	for _, attr := range attrs {
+		if value, ok := set[attr.Key]; ok {
+			attr.Value = value
+			delete(set, attr.Key)
+		}
+		out = append(out, attr)
+	}
+	for _, key := range sortedKeys(set) {
+		out = append(out, encoding.Attribute{Key: key, Value: set[key]})
+	}
+
+	return out
+}
+
+// weightColors is the number of colors of Graphviz color scheme blues9, lighter colors are used for lighter nodes
+const weightColors = 9
+
+// scaleNodes sets the scale of every node to its weight divided by the highest weight, which is returned
+func scaleNodes(nodes map[string]graphNode, weight func(*terradep.Node) int) int {
+	weights := make(map[string]int, len(nodes))
+	heaviest := 0
+	for state, node := range nodes {
+		weights[state] = weight(node.Node)
+		if weights[state] > heaviest {
+			heaviest = weights[state]
+		}
+	}
+	if heaviest == 0 {
+		return 0
+	}
+
+	for state, node := range nodes {
+		node.scale = float64(weights[state]) / float64(heaviest)
+		nodes[state] = node
+	}
+
+	return heaviest
+}
+
+// weightAttrs fill the node with darker color and make it bigger, the higher the scale is
+func weightAttrs(scale float64) []encoding.Attribute {
+	color := weightColor(scale)
+	attrs := []encoding.Attribute{
+		{Key: "style", Value: "filled"},
+		{Key: "colorscheme", Value: fmt.Sprintf("blues%d", weightColors)},
+		{Key: "fillcolor", Value: fmt.Sprint(color)},
+		{Key: "width", Value: fmt.Sprintf("%.2f", 0.75+scale*2.25)},
+		{Key: "height", Value: fmt.Sprintf("%.2f", 0.5+scale*0.75)},
+		{Key: "fontsize", Value: fmt.Sprintf("%.0f", 14+scale*10)},
+	}
+	if color > weightColors/2+1 {
+		attrs = append(attrs, encoding.Attribute{Key: "fontcolor", Value: "white"})
+	}
+
+	return attrs
+}
+
+// weightColor returns the color of the scale in color scheme blues9
+func weightColor(scale float64) int {
+	return 1 + int(math.Round(scale*(weightColors-1)))
+}
+
+// nodeTooltip describes metadata of the node, one property per line
+func nodeTooltip(n *terradep.Node) string {
+	var lines []string
+	if n.Owner != "" {
+		lines = append(lines, "owner: "+n.Owner)
+	}
+	if len(n.CodeOwners) != 0 {
+		lines = append(lines, "code owners: "+strings.Join(n.CodeOwners, ", "))
+	}
+	if n.Layer != "" {
+		lines = append(lines, "layer: "+n.Layer)
+	}
+	if len(n.Tags) != 0 {
+		lines = append(lines, "tags: "+strings.Join(n.Tags, ", "))
+	}
+
+	if m := n.Metadata; m != nil {
+		lines = append(lines, "backend: "+m.Backend)
+		if len(m.RequiredVersion) != 0 {
+			lines = append(lines, "terraform: "+strings.Join(m.RequiredVersion, ", "))
+		}
+		for _, name := range sortedKeys(m.Providers) {
+			p := m.Providers[name]
+			lines = append(lines, strings.TrimSpace(fmt.Sprintf("provider: %s %s %s", name, p.Source, strings.Join(p.VersionConstraints, ", "))))
+		}
+		lines = append(lines, fmt.Sprintf("resources: %d, data sources: %d", m.Resources, m.DataSources))
+	}
+
+	if g := n.Git; g != nil {
+		lines = append(lines, fmt.Sprintf("last commit: %.8s %s", g.LastCommit, g.LastCommitDate.Format(time.DateOnly)))
+		names := make([]string, 0, len(g.Contributors))
+		for _, c := range g.Contributors {
+			names = append(names, c.Name)
+		}
+		if len(names) != 0 {
+			lines = append(lines, "contributors: "+strings.Join(names, ", "))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for key := range m {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// clusteredGraph is a graph with the nodes grouped into subgraphs, which Graphviz draws as boxes when their names start with cluster
+type clusteredGraph struct {
+	*multi2.DirectedGraph
+	clusters []dot.Multigraph
+}
+
+// Structure implements dot.MultiStructurer
+func (g clusteredGraph) Structure() []dot.Multigraph {
+	return g.clusters
+}
+
+// cluster is a subgraph containing only the nodes, edges are defined by the whole graph
+type cluster struct {
+	*multi2.DirectedGraph
+	id    string
+	label string
+}
+
+// DOTID implements dot.Multigraph
+func (c cluster) DOTID() string {
+	return c.id
+}
+
+// DOTAttributers implements dot.Attributers
+func (c cluster) DOTAttributers() (graph, node, edge encoding.Attributer) {
+	return attributes{{Key: "label", Value: c.label}}, attributes(nil), attributes(nil)
+}
+
+type attributes []encoding.Attribute
+
+// Attributes implements encoding.Attributer
+func (a attributes) Attributes() []encoding.Attribute {
+	return a
+}
+
+// buildClusters returns clusters ordered by the key, so the output does not change between runs
+func buildClusters(nodes map[string]graphNode, key func(*terradep.Node) string) []dot.Multigraph {
+	byKey := make(map[string][]graphNode)
+	for _, node := range sortedByID(nodes) {
+		if k := key(node.Node); k != "" {
+			byKey[k] = append(byKey[k], node)
+		}
+	}
+
+	out := make([]dot.Multigraph, 0, len(byKey))
+	for i, k := range sortedKeys(byKey) {
+		c := cluster{DirectedGraph: multi2.NewDirectedGraph(), id: fmt.Sprintf("cluster_%d", i), label: k}
+		for _, node := range byKey[k] {
+			c.AddNode(node)
+		}
+		out = append(out, c)
+	}
+
+	return out
+}
+
+// usesModuleAttrs distinguish edges to called modules from dependencies through the state
+var usesModuleAttrs = []encoding.Attribute{
+	{Key: "style", Value: "dashed"},
+	{Key: "label", Value: "uses-module"},
+}
+
+// usedByAttrs are usesModuleAttrs of the edges drawn in [DataFlowDirection]
+var usedByAttrs = []encoding.Attribute{
+	{Key: "style", Value: "dashed"},
+	{Key: "label", Value: "used-by"},
+}
+
+// styledLine is a graph.Line with DOT attributes
+type styledLine struct {
+	graph.Line
+	attrs []encoding.Attribute
+}
+
+// Attributes implements encoding.Attributer
+func (l styledLine) Attributes() []encoding.Attribute {
+	return l.attrs
+}
@@ -0,0 +1,104 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"go.interactor.dev/terradep"
+)
+
+// JSONOpt changes the output of [BuildGraphJSON]
+type JSONOpt func(cfg *jsonCfg)
+
+type jsonCfg struct {
+	decorators decorators
+}
+
+// WithJSONNodeDecorator adds the attributes of the decorator to the deployments as field attributes.
+// Can be set many times, later decorators override earlier ones
+func WithJSONNodeDecorator(decorator NodeDecorator) JSONOpt {
+	return func(cfg *jsonCfg) {
+		cfg.decorators.nodes = append(cfg.decorators.nodes, decorator)
+	}
+}
+
+// WithJSONEdgeDecorator adds the attributes of the decorator to the deployments as field edgeAttributes,
+// keyed by the states of the dependencies and the local modules
+func WithJSONEdgeDecorator(decorator EdgeDecorator) JSONOpt {
+	return func(cfg *jsonCfg) {
+		cfg.decorators.edges = append(cfg.decorators.edges, decorator)
+	}
+}
+
+// graphJSON is the document written by [terradep.Graph.MarshalJSON], deployments are kept encoded, so their fields stay in the same order
+type graphJSON struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	Deployments   []json.RawMessage `json:"deployments"`
+}
+
+// BuildGraphJSON returns the graph encoded with [terradep.Graph.MarshalJSON] and indented, with attributes of the decorators added to the deployments.
+// Added fields are ignored when the graph is read back with [terradep.Graph.UnmarshalJSON], external states and local modules are not decorated
+func BuildGraphJSON(graph *terradep.Graph, opts ...JSONOpt) ([]byte, error) {
+	cfg := &jsonCfg{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	encoded, err := graph.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var doc graphJSON
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil, fmt.Errorf("decoding graph: %w", err)
+	}
+
+	nodes := make(map[string]*terradep.Node)
+	for _, n := range graph.Nodes() {
+		if graph.IsDeployment(n) {
+			nodes[n.ID()] = n
+		}
+	}
+
+	for i, raw := range doc.Deployments {
+		var d struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &d); err != nil {
+			return nil, fmt.Errorf("decoding deployment: %w", err)
+		}
+		n, ok := nodes[d.ID]
+		if !ok {
+			continue
+		}
+
+		edges := make(map[string]Attributes)
+		for _, to := range append(append([]*terradep.Node{}, n.Children...), n.Modules...) {
+			if attrs := cfg.decorators.edge(graph, n, to); len(attrs) != 0 {
+				edges[to.State.String()] = attrs
+			}
+		}
+		fields := struct {
+			Attributes     Attributes            `json:"attributes,omitempty"`
+			EdgeAttributes map[string]Attributes `json:"edgeAttributes,omitempty"`
+		}{Attributes: cfg.decorators.node(graph, n), EdgeAttributes: edges}
+		added, err := json.Marshal(fields)
+		if err != nil {
+			return nil, fmt.Errorf("encoding attributes of deployment: %s, %w", n.Path, err)
+		}
+		if bytes.Equal(added, []byte("{}")) {
+			continue
+		}
+		// both are objects, the fields are appended to the fields of the deployment
+		doc.Deployments[i] = append(append(raw[:len(raw)-1:len(raw)-1], ','), added[1:]...)
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding graph: %w", err)
+	}
+
+	return append(out, '\n'), nil
+}
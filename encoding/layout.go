@@ -0,0 +1,48 @@
+package encoding
+
+import "go.interactor.dev/terradep"
+
+// gridPosition is the column and the row of the node laid out by [gridLayout]
+type gridPosition struct {
+	column, row int
+}
+
+// gridLayout lays the nodes out in columns, so dependencies and used modules are left of the nodes depending on them.
+// Column is 0 for nodes without dependencies and used modules, otherwise greater by 1 than the highest column of them,
+// edges closing a cycle are ignored. Nodes of every column are in rows ordered like the nodes
+func gridLayout(nodes []*terradep.Node) map[*terradep.Node]gridPosition {
+	columns := make(map[*terradep.Node]int, len(nodes))
+	visiting := make(map[*terradep.Node]bool, len(nodes))
+
+	var column func(n *terradep.Node) int
+	column = func(n *terradep.Node) int {
+		if c, ok := columns[n]; ok {
+			return c
+		}
+		visiting[n] = true
+
+		c := 0
+		for _, dep := range append(append([]*terradep.Node(nil), n.Children...), n.Modules...) {
+			if visiting[dep] {
+				continue
+			}
+			if l := column(dep) + 1; l > c {
+				c = l
+			}
+		}
+
+		visiting[n] = false
+		columns[n] = c
+		return c
+	}
+
+	positions := make(map[*terradep.Node]gridPosition, len(nodes))
+	rows := make(map[int]int)
+	for _, n := range nodes {
+		c := column(n)
+		positions[n] = gridPosition{column: c, row: rows[c]}
+		rows[c]++
+	}
+
+	return positions
+}
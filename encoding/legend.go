@@ -0,0 +1,81 @@
+package encoding
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"go.interactor.dev/terradep"
+	"gonum.org/v1/gonum/graph/encoding"
+	"gonum.org/v1/gonum/graph/encoding/dot"
+	multi2 "gonum.org/v1/gonum/graph/multi"
+)
+
+// legendID is the id of the cluster and the node of the legend, it cannot collide with the states, which are URLs
+const legendID = "legend"
+
+// legendNode is the only node of the legend, the table in its label describes the graph
+type legendNode struct {
+	label string
+}
+
+// ID implements graph.Node
+func (n legendNode) ID() int64 {
+	return 0
+}
+
+// DOTID implements dot.Node
+func (n legendNode) DOTID() string {
+	return legendID
+}
+
+// Attributes implements encoding.Attributer
+func (n legendNode) Attributes() []encoding.Attribute {
+	return []encoding.Attribute{{Key: "shape", Value: "plaintext"}, {Key: "label", Value: n.label}}
+}
+
+// buildLegend returns the cluster with the table describing only the styles used in the graph, heaviest is the highest weight of the nodes
+func buildLegend(cfg *dotCfg, dep *terradep.Graph, heaviest int) dot.Multigraph {
+	var rows [][2]string
+	if cfg.direction == DataFlowDirection {
+		rows = append(rows, [2]string{"arrow", "from the state to the deployment reading it"})
+	} else {
+		rows = append(rows, [2]string{"arrow", "from the deployment to the state it depends on"})
+	}
+	if hasModuleEdges(dep) {
+		rows = append(rows, [2]string{"dashed arrow", "deployment uses code of the local module"})
+	}
+	if cfg.outputs {
+		rows = append(rows, [2]string{"arrow label", "outputs of the state consumed by the deployment, * when they are used as a whole"})
+	}
+	if cfg.cluster != nil {
+		rows = append(rows, [2]string{"box", "deployments sharing the property in the label of the box"})
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(`<<table border="0" cellborder="1" cellspacing="0" cellpadding="4">`)
+	for _, row := range rows {
+		fmt.Fprintf(&sb, `<tr><td>%s</td><td align="left">%s</td></tr>`, html.EscapeString(row[0]), html.EscapeString(row[1]))
+	}
+	if cfg.weight != nil && heaviest > 0 {
+		name := html.EscapeString(cfg.weightName)
+		fmt.Fprintf(&sb, `<tr><td bgcolor="/blues%d/%d">%s: %d</td><td align="left">the lightest node</td></tr>`, weightColors, weightColor(0), name, 0)
+		fmt.Fprintf(&sb, `<tr><td bgcolor="/blues%d/%d"><font color="white">%s: %d</font></td><td align="left">the darkest and the biggest node</td></tr>`, weightColors, weightColor(1), name, heaviest)
+	}
+	sb.WriteString(`</table>>`)
+
+	c := cluster{DirectedGraph: multi2.NewDirectedGraph(), id: "cluster_" + legendID, label: "Legend"}
+	c.AddNode(legendNode{label: sb.String()})
+
+	return c
+}
+
+func hasModuleEdges(dep *terradep.Graph) bool {
+	for _, n := range dep.Nodes() {
+		if len(n.Modules) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
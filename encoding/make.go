@@ -0,0 +1,88 @@
+package encoding
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"go.interactor.dev/terradep"
+)
+
+// makeTarget matches characters which cannot be used in names of Makefile targets
+var makeTarget = regexp.MustCompile(`[^A-Za-z0-9_./-]+`)
+
+// MakeCommandData is passed to the templates of the commands run by the targets generated by [BuildMakefile]
+type MakeCommandData struct {
+	// Path is relative to the root directory, see [WithRootDir]
+	Path      string
+	Workspace string
+	Overlay   string
+	State     string
+	// Target is the name of the target of the deployment
+	Target string
+}
+
+// BuildMakefile returns Makefile with one target per deployment, which depends on the targets of the deployments it depends on,
+// so make -j runs the deployments in parallel in the order of the dependencies. Target all runs every deployment.
+// Commands set with [WithCommands] are Go templates executed with [MakeCommandData], e.g. 'terraform -chdir={{ .Path }} plan'.
+// They are run in the directory of the deployment with variables TERRADEP_PATH, TERRADEP_OVERLAY and TF_WORKSPACE exported
+func BuildMakefile(graph *terradep.Graph, opts ...GenerateOpt) ([]byte, error) {
+	cfg := newGenerateCfg(opts)
+	templates := make([]*template.Template, 0, len(cfg.commands))
+	for i, command := range cfg.commands {
+		tmpl, err := template.New(fmt.Sprintf("command %d", i)).Option("missingkey=error").Parse(command)
+		if err != nil {
+			return nil, fmt.Errorf("parsing command: %s, %w", command, err)
+		}
+		templates = append(templates, tmpl)
+	}
+
+	deployments := cfg.deployments(graph)
+	targets := uniqueIDs(deployments, func(label string) string {
+		return makeTarget.ReplaceAllString(label, "_")
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString("# Generated by terradep, run all the deployments in the order of the dependencies with: make -j 4 all\n")
+	names := make([]string, 0, len(deployments))
+	for _, d := range deployments {
+		names = append(names, targets[d])
+	}
+	fmt.Fprintf(&sb, ".PHONY: all %s\n", strings.Join(names, " "))
+	fmt.Fprintf(&sb, "all: %s\n", strings.Join(names, " "))
+
+	for _, d := range deployments {
+		target := targets[d]
+		sb.WriteString("\n")
+		env := deploymentEnv(d)
+		for _, key := range sortedKeys(env) {
+			fmt.Fprintf(&sb, "%s: export %s := %s\n", target, key, makeEscape(env[key]))
+		}
+
+		deps := make([]string, 0, len(d.dependencies))
+		for _, dep := range d.dependencies {
+			deps = append(deps, targets[dep])
+		}
+		sort.Strings(deps)
+		sb.WriteString(strings.TrimSpace(target + ": " + strings.Join(deps, " ")))
+		sb.WriteString("\n")
+
+		data := MakeCommandData{Path: d.dir, Workspace: d.Workspace, Overlay: d.Overlay, State: d.State.String(), Target: target}
+		for _, tmpl := range templates {
+			command := strings.Builder{}
+			if err := tmpl.Execute(&command, data); err != nil {
+				return nil, fmt.Errorf("executing command template: %s, deployment: %s, %w", tmpl.Name(), d.label(), err)
+			}
+			fmt.Fprintf(&sb, "\tcd %s && %s\n", makeEscape(d.dir), makeEscape(command.String()))
+		}
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// makeEscape escapes references of make variables, so $ is passed to the shell
+func makeEscape(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
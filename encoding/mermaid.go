@@ -0,0 +1,144 @@
+package encoding
+
+import (
+	"fmt"
+	"strings"
+
+	"go.interactor.dev/terradep"
+)
+
+// MermaidOpt changes the output of [BuildMermaidGraph]
+type MermaidOpt func(cfg *mermaidCfg)
+
+type mermaidCfg struct {
+	highlighted map[*terradep.Node]struct{}
+	direction   Direction
+	decorators  decorators
+}
+
+// WithHighlighted highlights the nodes, e.g. returned by [terradep.Graph.Affected]
+func WithHighlighted(nodes ...*terradep.Node) MermaidOpt {
+	return func(cfg *mermaidCfg) {
+		cfg.highlighted = make(map[*terradep.Node]struct{}, len(nodes))
+		for _, n := range nodes {
+			cfg.highlighted[n] = struct{}{}
+		}
+	}
+}
+
+// WithMermaidDirection sets the direction of the arrows
+func WithMermaidDirection(direction Direction) MermaidOpt {
+	return func(cfg *mermaidCfg) {
+		cfg.direction = direction
+	}
+}
+
+// WithMermaidNodeDecorator changes the nodes with the attributes of the decorator: [AttrLabel], [AttrColor] of the border,
+// [AttrURL] opened by click and [AttrTooltip] of the link. Other attributes are ignored. Can be set many times, later decorators override earlier ones
+func WithMermaidNodeDecorator(decorator NodeDecorator) MermaidOpt {
+	return func(cfg *mermaidCfg) {
+		cfg.decorators.nodes = append(cfg.decorators.nodes, decorator)
+	}
+}
+
+// WithMermaidEdgeDecorator changes the arrows with the attributes [AttrLabel] and [AttrColor] of the decorator, other attributes are ignored
+func WithMermaidEdgeDecorator(decorator EdgeDecorator) MermaidOpt {
+	return func(cfg *mermaidCfg) {
+		cfg.decorators.edges = append(cfg.decorators.edges, decorator)
+	}
+}
+
+// mermaidLabel escapes the label of the node, which cannot contain double quotes
+var mermaidLabel = strings.NewReplacer(`"`, "#quot;")
+
+// BuildMermaidGraph returns the graph as Mermaid flowchart, which is rendered e.g. by GitHub and GitLab in markdown.
+// Arrows point from deployments to their dependencies, unless changed with [WithMermaidDirection], edges to local modules are dotted
+func BuildMermaidGraph(graph *terradep.Graph, opts ...MermaidOpt) []byte {
+	cfg := &mermaidCfg{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	nodes := graph.Nodes()
+	ids := make(map[*terradep.Node]string, len(nodes))
+	decorated := make(map[*terradep.Node]Attributes, len(nodes))
+	sb := strings.Builder{}
+	sb.WriteString("flowchart LR\n")
+	for _, n := range nodes {
+		// prefixed, so the id of the node never starts with a digit
+		ids[n] = "n" + n.ID()
+		decorated[n] = cfg.decorators.node(graph, n)
+		label := nodeLabel(graph, n)
+		if l, ok := decorated[n][AttrLabel]; ok {
+			label = l
+		}
+		fmt.Fprintf(&sb, "  %s[\"%s\"]\n", ids[n], mermaidLabel.Replace(label))
+	}
+
+	// links are numbered in order they are defined, linkStyle refers to them by the number
+	links := 0
+	var linkStyles []string
+	link := func(from, to *terradep.Node, arrow string) {
+		attrs := cfg.decorators.edge(graph, from, to)
+		from, to = cfg.direction.edge(from, to)
+		if label, ok := attrs[AttrLabel]; ok {
+			arrow += fmt.Sprintf("|\"%s\"|", mermaidLabel.Replace(label))
+		}
+		fmt.Fprintf(&sb, "  %s %s %s\n", ids[from], arrow, ids[to])
+		if color, ok := attrs[AttrColor]; ok {
+			linkStyles = append(linkStyles, fmt.Sprintf("  linkStyle %d stroke:%s\n", links, color))
+		}
+		links++
+	}
+	for _, n := range nodes {
+		for _, child := range n.Children {
+			link(n, child, "-->")
+		}
+		for _, module := range n.Modules {
+			link(n, module, "-.->")
+		}
+	}
+	sb.WriteString(strings.Join(linkStyles, ""))
+
+	for _, n := range nodes {
+		if color, ok := decorated[n][AttrColor]; ok {
+			fmt.Fprintf(&sb, "  style %s stroke:%s\n", ids[n], color)
+		}
+		if url, ok := decorated[n][AttrURL]; ok {
+			fmt.Fprintf(&sb, "  click %s href \"%s\"", ids[n], mermaidLabel.Replace(url))
+			if tooltip, ok := decorated[n][AttrTooltip]; ok {
+				fmt.Fprintf(&sb, " \"%s\"", mermaidLabel.Replace(tooltip))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	if len(cfg.highlighted) != 0 {
+		sb.WriteString("  classDef highlighted fill:#ffe8cc,stroke:#d33\n")
+		for _, n := range nodes {
+			if _, ok := cfg.highlighted[n]; ok {
+				fmt.Fprintf(&sb, "  class %s highlighted\n", ids[n])
+			}
+		}
+	}
+
+	return []byte(sb.String())
+}
+
+// nodeLabel returns path of the deployment with workspace after colon and overlay after hash, e.g. live/app:prod#blue.
+// External states and local modules are identified by the state
+func nodeLabel(graph *terradep.Graph, n *terradep.Node) string {
+	if !graph.IsDeployment(n) {
+		return n.State.String()
+	}
+
+	label := n.Path
+	if n.Workspace != "" {
+		label += ":" + n.Workspace
+	}
+	if n.Overlay != "" {
+		label += "#" + n.Overlay
+	}
+
+	return label
+}
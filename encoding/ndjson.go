@@ -0,0 +1,88 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/hcl/v2"
+	"go.interactor.dev/terradep"
+)
+
+// kinds of the nodes written by [NodeEncoder]
+const (
+	NodeDeployment = "deployment"
+	NodeExternal   = "external"
+	NodeModule     = "module"
+)
+
+// NodeEncoder writes nodes of the graph in JSON Lines format, one line per node with its edges, so the graph can be filtered with jq
+// or loaded by ingestion pipelines line by line. Lines of deployments have the same fields as lines of [DeploymentEncoder]
+type NodeEncoder struct {
+	enc *json.Encoder
+}
+
+// NewNodeEncoder returns [NodeEncoder] writing to w
+func NewNodeEncoder(w io.Writer) *NodeEncoder {
+	return &NodeEncoder{enc: json.NewEncoder(w)}
+}
+
+type nodeLine struct {
+	// ID is [terradep.Node.ID]
+	ID string `json:"id"`
+	// Kind is one of [NodeDeployment], [NodeExternal] or [NodeModule]
+	Kind      string `json:"kind"`
+	Path      string `json:"path,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
+	Overlay   string `json:"overlay,omitempty"`
+	State     string `json:"state"`
+	// Dependencies are states of the children, empty for external states and local modules
+	Dependencies []string                 `json:"dependencies"`
+	Modules      []string                 `json:"modules,omitempty"`
+	Owner        string                   `json:"owner,omitempty"`
+	CodeOwners   []string                 `json:"codeOwners,omitempty"`
+	Layer        string                   `json:"layer,omitempty"`
+	Tags         []string                 `json:"tags,omitempty"`
+	Metadata     *terradep.ModuleMetadata `json:"metadata,omitempty"`
+	Git          *terradep.GitInfo        `json:"git,omitempty"`
+	BackendRange *hcl.Range               `json:"backendRange,omitempty"`
+	// Edges are keyed by the dependencies
+	Edges map[string]terradep.EdgeMetadata `json:"edges,omitempty"`
+}
+
+// Encode writes the node of the graph as single line
+func (e *NodeEncoder) Encode(graph *terradep.Graph, n *terradep.Node) error {
+	line := nodeLine{
+		ID:           n.ID(),
+		Kind:         NodeExternal,
+		State:        n.State.String(),
+		Dependencies: make([]string, 0, len(n.Children)),
+	}
+	if _, ok := n.State.(terradep.LocalModule); ok {
+		line.Kind, line.Path = NodeModule, n.Path
+	} else if graph.IsDeployment(n) {
+		line.Kind = NodeDeployment
+		line.Path, line.Workspace, line.Overlay = n.Path, n.Workspace, n.Overlay
+		line.Owner, line.CodeOwners, line.Layer, line.Tags = n.Owner, n.CodeOwners, n.Layer, n.Tags
+		line.Metadata, line.Git, line.BackendRange, line.Edges = n.Metadata, n.Git, n.BackendRange, n.Edges
+	}
+	for _, child := range n.Children {
+		line.Dependencies = append(line.Dependencies, child.State.String())
+	}
+	for _, module := range n.Modules {
+		line.Modules = append(line.Modules, module.Path)
+	}
+
+	if err := e.enc.Encode(line); err != nil {
+		return fmt.Errorf("encoding node: %s, %w", n.State, err)
+	}
+
+	return nil
+}
+
+// EncodeGraph writes every node of the graph in order of [terradep.Graph.Walk], each line as soon as the node is visited
+func (e *NodeEncoder) EncodeGraph(graph *terradep.Graph) error {
+	return graph.Walk(func(n *terradep.Node) error {
+		return e.Encode(graph, n)
+	})
+}
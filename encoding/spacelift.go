@@ -0,0 +1,81 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.interactor.dev/terradep"
+)
+
+// spaceliftSlug matches characters replaced with '-' in ids of Spacelift stacks derived from their names
+var spaceliftSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// SpaceliftStackID returns id of the stack Spacelift derives from the name of the stack, e.g. live-app-prod for live/app:prod.
+// Generated dependencies expect the stacks to be named like the deployments: path, optionally followed by :workspace and #overlay
+func SpaceliftStackID(name string) string {
+	return strings.Trim(spaceliftSlug.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+type spaceliftStack struct {
+	ID          string   `yaml:"id"`
+	Name        string   `yaml:"name"`
+	ProjectRoot string   `yaml:"project_root"`
+	DependsOn   []string `yaml:"depends_on"`
+}
+
+// BuildSpaceliftStacks returns YAML with stacks of all the deployments and ids of the stacks they depend on,
+// which can be read with yamldecode by Terraform code managing the stacks
+func BuildSpaceliftStacks(graph *terradep.Graph, opts ...GenerateOpt) ([]byte, error) {
+	cfg := newGenerateCfg(opts)
+	stacks := []spaceliftStack{}
+	for _, d := range cfg.deployments(graph) {
+		stack := spaceliftStack{ID: SpaceliftStackID(d.label()), Name: d.label(), ProjectRoot: d.dir, DependsOn: []string{}}
+		for _, dep := range d.dependencies {
+			stack.DependsOn = append(stack.DependsOn, SpaceliftStackID(dep.label()))
+		}
+		stacks = append(stacks, stack)
+	}
+
+	out, err := encodeYAML(map[string]any{"stacks": stacks})
+	if err != nil {
+		return nil, fmt.Errorf("encoding spacelift stacks: %w", err)
+	}
+
+	return out, nil
+}
+
+type spaceliftDependency struct {
+	StackID          string `json:"stack_id"`
+	DependsOnStackID string `json:"depends_on_stack_id"`
+}
+
+// BuildSpaceliftDependencies returns Terraform JSON configuration, e.g. stack-dependencies.tf.json, with spacelift_stack_dependency resource
+// of every dependency between the deployments. Ids of the stacks are returned by [SpaceliftStackID]
+func BuildSpaceliftDependencies(graph *terradep.Graph, opts ...GenerateOpt) ([]byte, error) {
+	cfg := newGenerateCfg(opts)
+	resources := make(map[string]spaceliftDependency)
+	for _, d := range cfg.deployments(graph) {
+		for _, dep := range d.dependencies {
+			dependency := spaceliftDependency{StackID: SpaceliftStackID(d.label()), DependsOnStackID: SpaceliftStackID(dep.label())}
+			name := strings.ReplaceAll(dependency.StackID+"--"+dependency.DependsOnStackID, "-", "_")
+			if name[0] >= '0' && name[0] <= '9' {
+				// names of the resources must start with a letter or underscore
+				name = "_" + name
+			}
+			resources[name] = dependency
+		}
+	}
+
+	config := map[string]any{}
+	if len(resources) != 0 {
+		config["resource"] = map[string]any{"spacelift_stack_dependency": resources}
+	}
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding spacelift dependencies: %w", err)
+	}
+
+	return append(out, '\n'), nil
+}
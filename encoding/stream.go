@@ -0,0 +1,70 @@
+package encoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/hcl/v2"
+	"go.interactor.dev/terradep"
+)
+
+// DeploymentEncoder writes deployments streamed by [terradep.Scanner.Stream] in JSON Lines format, one line per deployment
+type DeploymentEncoder struct {
+	enc *json.Encoder
+}
+
+// NewDeploymentEncoder returns [DeploymentEncoder] writing to w
+func NewDeploymentEncoder(w io.Writer) *DeploymentEncoder {
+	return &DeploymentEncoder{enc: json.NewEncoder(w)}
+}
+
+type deploymentLine struct {
+	// ID is [terradep.Deployment.ID]
+	ID           string                   `json:"id"`
+	Path         string                   `json:"path"`
+	Workspace    string                   `json:"workspace,omitempty"`
+	Overlay      string                   `json:"overlay,omitempty"`
+	State        string                   `json:"state"`
+	Dependencies []string                 `json:"dependencies"`
+	Modules      []string                 `json:"modules,omitempty"`
+	Owner        string                   `json:"owner,omitempty"`
+	CodeOwners   []string                 `json:"codeOwners,omitempty"`
+	Layer        string                   `json:"layer,omitempty"`
+	Tags         []string                 `json:"tags,omitempty"`
+	Metadata     *terradep.ModuleMetadata `json:"metadata,omitempty"`
+	Git          *terradep.GitInfo        `json:"git,omitempty"`
+	BackendRange *hcl.Range               `json:"backendRange,omitempty"`
+	// Edges are keyed by the dependencies
+	Edges map[string]terradep.EdgeMetadata `json:"edges,omitempty"`
+}
+
+// Encode writes the deployment as single line
+func (e *DeploymentEncoder) Encode(d terradep.Deployment) error {
+	line := deploymentLine{
+		ID:           d.ID(),
+		Path:         d.Path,
+		Workspace:    d.Workspace,
+		Overlay:      d.Overlay,
+		State:        d.State.String(),
+		Dependencies: make([]string, 0, len(d.Dependencies)),
+		Modules:      d.Modules,
+		Owner:        d.Owner,
+		CodeOwners:   d.CodeOwners,
+		Layer:        d.Layer,
+		Tags:         d.Tags,
+		Metadata:     d.Metadata,
+		Git:          d.Git,
+		BackendRange: d.BackendRange,
+		Edges:        d.Edges,
+	}
+	for _, dep := range d.Dependencies {
+		line.Dependencies = append(line.Dependencies, dep.String())
+	}
+
+	if err := e.enc.Encode(line); err != nil {
+		return fmt.Errorf("encoding deployment: %s, %w", d.Path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package encoding
+
+import (
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+)
+
+// TerragruntFile is the name of the Terragrunt configuration of the deployment
+const TerragruntFile = "terragrunt.hcl"
+
+// terragruntName matches characters not allowed in labels of dependency blocks
+var terragruntName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// BuildTerragruntConfigs returns terragrunt.hcl of every deployment directory, keyed by the path of the file relative to the root directory.
+// Every deployment the directory depends on, in any workspace or overlay, becomes a dependency block, so Terragrunt runs it first.
+// States not produced by any deployment are listed in comments. Deployments read outputs of the dependencies with terraform_remote_state,
+// which should be replaced by inputs from dependency.<name>.outputs, when migrating to Terragrunt
+func BuildTerragruntConfigs(graph *terradep.Graph, opts ...GenerateOpt) map[string][]byte {
+	cfg := newGenerateCfg(opts)
+	dependencies := make(map[string]map[string]struct{})
+	external := make(map[string]map[string]struct{})
+	for _, d := range cfg.deployments(graph) {
+		if _, ok := dependencies[d.dir]; !ok {
+			dependencies[d.dir] = make(map[string]struct{})
+			external[d.dir] = make(map[string]struct{})
+		}
+		for _, dep := range d.dependencies {
+			if dep.dir != d.dir {
+				dependencies[d.dir][dep.dir] = struct{}{}
+			}
+		}
+		for _, child := range d.Children {
+			if !graph.IsDeployment(child) {
+				external[d.dir][child.State.String()] = struct{}{}
+			}
+		}
+	}
+
+	out := make(map[string][]byte, len(dependencies))
+	for _, dir := range sortedKeys(dependencies) {
+		f := hclwrite.NewEmptyFile()
+		body := f.Body()
+		body.AppendUnstructuredTokens(commentTokens("Generated by terradep from terraform_remote_state of " + dir))
+		for _, state := range sortedKeys(external[dir]) {
+			body.AppendUnstructuredTokens(commentTokens("Depends on " + state + ", which is not produced by any scanned deployment"))
+		}
+
+		names := make(map[string]struct{})
+		for _, dep := range sortedKeys(dependencies[dir]) {
+			body.AppendNewline()
+			block := body.AppendNewBlock("dependency", []string{uniqueName(names, dep)})
+			block.Body().SetAttributeValue("config_path", cty.StringVal(relDir(dir, dep)))
+		}
+
+		out[path.Join(dir, TerragruntFile)] = f.Bytes()
+	}
+
+	return out
+}
+
+func commentTokens(comment string) hclwrite.Tokens {
+	return hclwrite.Tokens{{Type: hclsyntax.TokenComment, Bytes: []byte("# " + comment + "\n")}}
+}
+
+// uniqueName returns label of the dependency block derived from the last element of the path, e.g. network for live/network
+func uniqueName(used map[string]struct{}, dir string) string {
+	base := terragruntName.ReplaceAllString(path.Base(dir), "_")
+	name := base
+	for i := 2; ; i++ {
+		if _, ok := used[name]; !ok {
+			break
+		}
+		name = base + "_" + strconv.Itoa(i)
+	}
+	used[name] = struct{}{}
+
+	return name
+}
+
+// relDir returns path of the directory relative to the other one, both using forward slashes
+func relDir(from, to string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(from), filepath.FromSlash(to))
+	if err != nil {
+		return to
+	}
+
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,50 @@
+package terradep
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// environmentGroup is the name of the submatch of the pattern which is the environment, see [StateEnvironment]
+const environmentGroup = "env"
+
+// CompileEnvironmentPatterns compiles regular expressions extracting the environment from [State.Identity], see [StateEnvironment].
+// Every pattern must have a submatch, e.g. env:/(\w+)/ or ^s3://[^/]+/(?P<env>[^/]+)/
+func CompileEnvironmentPatterns(patterns ...string) ([]*regexp.Regexp, error) {
+	out := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compiling environment pattern: %q, %w", pattern, err)
+		}
+		if re.NumSubexp() == 0 {
+			return nil, fmt.Errorf("environment pattern: %q has no submatch, e.g. (\\w+), matching the environment", pattern)
+		}
+		out = append(out, re)
+	}
+
+	return out, nil
+}
+
+// StateEnvironment returns the environment of the state, i.e. the submatch env, or the first submatch, of the first pattern matching [State.Identity],
+// e.g. prod for pattern env:/(\w+)/ and state s3://tf-state/env:/prod/app.tfstate. When no pattern matches, it is the workspace of [WorkspaceState].
+// Returns empty string when the environment is not known
+func StateEnvironment(state State, patterns ...*regexp.Regexp) string {
+	identity := state.Identity()
+	for _, re := range patterns {
+		match := re.FindStringSubmatch(identity)
+		if match == nil {
+			continue
+		}
+		if i := re.SubexpIndex(environmentGroup); i > 0 {
+			return match[i]
+		}
+		return match[1]
+	}
+
+	if ws, ok := state.(WorkspaceState); ok {
+		return ws.Workspace
+	}
+
+	return ""
+}
@@ -0,0 +1,140 @@
+package terradep
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrCycle is returned when deployments depend on each other, so the dependencies cannot be represented as [Graph].
+	// The error is [*CycleError]
+	ErrCycle = errors.New("dependency cycle")
+	// ErrDuplicateState is returned when more than one deployment stores its state in the same place. The error is [*DuplicateStateError]
+	ErrDuplicateState = errors.New("duplicate state")
+	// ErrNoBackend is returned when the module does not define where its state is stored, so it cannot be a deployment.
+	// The error is [*NoBackendError]
+	ErrNoBackend = errors.New("no backend")
+	// ErrNotADirectory is returned when the path passed to the [Scanner] is not a directory. The error is [*NotADirectoryError]
+	ErrNotADirectory = errors.New("not a directory")
+	// ErrInvalidModule is returned when the module cannot be scanned, e.g. because of invalid HCL or unsupported backend.
+	// The error is [*ModuleError]
+	ErrInvalidModule = errors.New("module cannot be scanned")
+	// ErrUnsupportedBackend is returned by [Stater] which cannot read the state from the backend. The error is [*UnsupportedBackendError]
+	ErrUnsupportedBackend = errors.New("unsupported backend")
+	// ErrReadOnlyGraph is returned when the [Graph] which may be read concurrently is changed, e.g. the graph returned by [Scanner.Scan].
+	// Change the copy returned by [Graph.Clone] instead
+	ErrReadOnlyGraph = errors.New("graph is read-only, change its clone")
+)
+
+// CycleError is returned when deployments depend on each other, it matches [ErrCycle] with [errors.Is]
+type CycleError struct {
+	// States of the deployments forming the cycle, the first state is repeated at the end
+	States []State
+	// Paths of the deployments forming the cycle, in the order of States
+	Paths []string
+}
+
+func (e *CycleError) Error() string {
+	states := make([]string, 0, len(e.States))
+	for _, s := range e.States {
+		states = append(states, s.String())
+	}
+
+	return fmt.Sprintf("deployments depend on each other: %s, %s", strings.Join(states, " -> "), ErrCycle)
+}
+
+// Is implements interface used by [errors.Is]
+func (e *CycleError) Is(target error) bool {
+	return target == ErrCycle
+}
+
+// DuplicateStateError is returned when deployments share the state, it matches [ErrDuplicateState] with [errors.Is]
+type DuplicateStateError struct {
+	State State
+	// Paths of the deployments sharing the state, sorted
+	Paths []string
+}
+
+func (e *DuplicateStateError) Error() string {
+	return fmt.Sprintf("deployments: %s have the same state: %s, %s", strings.Join(e.Paths, " and "), e.State, ErrDuplicateState)
+}
+
+// Is implements interface used by [errors.Is]
+func (e *DuplicateStateError) Is(target error) bool {
+	return target == ErrDuplicateState
+}
+
+// NoBackendError is returned when the module does not declare backend nor cloud block, it matches [ErrNoBackend] with [errors.Is]
+type NoBackendError struct {
+	// Path of the module
+	Path string
+	// TerraformBlock is false when the module does not have terraform block at all
+	TerraformBlock bool
+}
+
+func (e *NoBackendError) Error() string {
+	if !e.TerraformBlock {
+		return fmt.Sprintf("module does not have terraform block: %s, %s", e.Path, ErrNoBackend)
+	}
+
+	return fmt.Sprintf("terraform block does not have backend nor cloud block, module: %s, %s", e.Path, ErrNoBackend)
+}
+
+// Is implements interface used by [errors.Is]
+func (e *NoBackendError) Is(target error) bool {
+	return target == ErrNoBackend
+}
+
+// ModuleError is returned when the module cannot be scanned, it matches [ErrInvalidModule] and the cause with [errors.Is]
+type ModuleError struct {
+	// Path of the module
+	Path string
+	// Status of the module, e.g. [DirParseError]
+	Status DirStatus
+	Err    error
+}
+
+func (e *ModuleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ModuleError) Unwrap() error {
+	return e.Err
+}
+
+// Is implements interface used by [errors.Is]
+func (e *ModuleError) Is(target error) bool {
+	return target == ErrInvalidModule
+}
+
+// NotADirectoryError is returned when the path passed to the [Scanner] is a file, it matches [ErrNotADirectory] with [errors.Is]
+type NotADirectoryError struct {
+	Path string
+}
+
+func (e *NotADirectoryError) Error() string {
+	return fmt.Sprintf("it is not directory: %s", e.Path)
+}
+
+// Is implements interface used by [errors.Is]
+func (e *NotADirectoryError) Is(target error) bool {
+	return target == ErrNotADirectory
+}
+
+// UnsupportedBackendError is returned by [Stater] which cannot read the state from the backend, it matches [ErrUnsupportedBackend] with [errors.Is]
+type UnsupportedBackendError struct {
+	// Backend is the type of the backend, e.g. gcs
+	Backend string
+	// Supported are types of the backends supported by the [Stater], sorted
+	Supported []string
+}
+
+func (e *UnsupportedBackendError) Error() string {
+	return fmt.Sprintf("supported backends: %v, got: %q, %s", e.Supported, e.Backend, ErrUnsupportedBackend)
+}
+
+// Is implements interface used by [errors.Is]
+func (e *UnsupportedBackendError) Is(target error) bool {
+	return target == ErrUnsupportedBackend
+}
@@ -0,0 +1,197 @@
+package terradep
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	"go.interactor.dev/terradep/inspect"
+	"golang.org/x/exp/slog"
+)
+
+// moduleEvalContext returns context allowing to statically evaluate expressions in the module.
+// It contains terraform.workspace, subset of Terraform functions, variables of the [Overlay] and locals which can be resolved without resources etc.
+func (s *Scanner) moduleEvalContext(sc *scan, module *tfconfig.Module, inst instance) *hcl.EvalContext {
+	ctx := workspaceEvalContext(inst.workspace)
+	ctx.Functions = evalFunctions
+	if vars := inst.overlay.variables(); vars != cty.NilVal {
+		ctx.Variables["var"] = vars
+	}
+
+	locals := s.resolveLocals(sc, module.Path, ctx)
+	ctx.Variables["local"] = cty.ObjectVal(locals)
+
+	return ctx
+}
+
+// resolveLocals returns values of locals which could be evaluated. Locals referencing unknown values are skipped
+func (s *Scanner) resolveLocals(sc *scan, dir string, ctx *hcl.EvalContext) map[string]cty.Value {
+	attrs := s.findLocals(sc, dir)
+	resolved := make(map[string]cty.Value, len(attrs))
+
+	// locals can reference each other, each pass resolves at least one of them or stops
+	for progress := true; progress; {
+		progress = false
+		localCtx := ctx.NewChild()
+		localCtx.Variables = map[string]cty.Value{"local": cty.ObjectVal(resolved)}
+
+		for name, attr := range attrs {
+			if _, ok := resolved[name]; ok {
+				continue
+			}
+
+			value, diags := attr.Expr.Value(localCtx)
+			if diags.HasErrors() || !value.IsWhollyKnown() {
+				continue
+			}
+
+			resolved[name] = value
+			progress = true
+		}
+	}
+
+	if len(resolved) != len(attrs) {
+		s.log.Debug("some locals cannot be resolved statically", slog.String("module", dir), slog.Int("resolved", len(resolved)), slog.Int("all", len(attrs)))
+	}
+
+	return resolved
+}
+
+// findLocals returns attributes of all locals blocks of the module
+func (s *Scanner) findLocals(sc *scan, dir string) hcl.Attributes {
+	files, _ := inspect.DirFiles(sc.fs, dir)
+
+	out := make(hcl.Attributes)
+	for _, filename := range files {
+		file, diags := sc.parser.ParseFile(filename)
+		if diags.HasErrors() {
+			s.warn(sc, Diagnostic{
+				Rule:    RuleUnparsableFile,
+				Summary: "skipping unparsable file when looking for locals",
+				Detail:  diags.Error(),
+				Module:  dir,
+				Range:   diags[0].Subject,
+			})
+			continue
+		}
+
+		content, _, _ := file.Body.PartialContent(localsSchema)
+		for _, block := range content.Blocks {
+			attrs, _ := block.Body.JustAttributes()
+			for name, attr := range attrs {
+				out[name] = attr
+			}
+		}
+	}
+
+	return out
+}
+
+var localsSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "locals"}},
+}
+
+// expandInstances returns one context for every instance of the block declared with meta-argument for_each or count.
+// When block does not use any of them, ctx is returned as the only instance
+func expandInstances(attrs hcl.Attributes, ctx *hcl.EvalContext) ([]*hcl.EvalContext, error) {
+	if attr, ok := attrs["for_each"]; ok {
+		return expandForEach(attr, ctx)
+	}
+
+	if attr, ok := attrs["count"]; ok {
+		return expandCount(attr, ctx)
+	}
+
+	return []*hcl.EvalContext{ctx}, nil
+}
+
+func expandForEach(attr *hcl.Attribute, ctx *hcl.EvalContext) ([]*hcl.EvalContext, error) {
+	value, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("evaluating for_each: %w", diags)
+	}
+
+	if !value.IsWhollyKnown() || value.IsNull() {
+		return nil, fmt.Errorf("value of for_each is not known")
+	}
+
+	ty := value.Type()
+	if !ty.IsMapType() && !ty.IsObjectType() && !ty.IsSetType() {
+		return nil, fmt.Errorf("for_each must be a map or set of strings, got: %s", ty.FriendlyName())
+	}
+
+	out := make([]*hcl.EvalContext, 0, value.LengthInt())
+	for it := value.ElementIterator(); it.Next(); {
+		key, val := it.Element()
+		if ty.IsSetType() {
+			if !key.Type().Equals(cty.String) {
+				return nil, fmt.Errorf("for_each set must contain strings, got: %s", key.Type().FriendlyName())
+			}
+			key = val
+		}
+
+		instance := ctx.NewChild()
+		instance.Variables = map[string]cty.Value{
+			"each": cty.ObjectVal(map[string]cty.Value{"key": key, "value": val}),
+		}
+		out = append(out, instance)
+	}
+
+	return out, nil
+}
+
+func expandCount(attr *hcl.Attribute, ctx *hcl.EvalContext) ([]*hcl.EvalContext, error) {
+	value, diags := attr.Expr.Value(ctx)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("evaluating count: %w", diags)
+	}
+
+	if !value.IsKnown() || value.IsNull() || !value.Type().Equals(cty.Number) {
+		return nil, fmt.Errorf("value of count is not known number")
+	}
+
+	count, accuracy := value.AsBigFloat().Int64()
+	if accuracy != 0 || count < 0 {
+		return nil, fmt.Errorf("count must be non-negative whole number, got: %s", value.AsBigFloat().String())
+	}
+
+	out := make([]*hcl.EvalContext, 0, count)
+	for i := int64(0); i < count; i++ {
+		instance := ctx.NewChild()
+		instance.Variables = map[string]cty.Value{
+			"count": cty.ObjectVal(map[string]cty.Value{"index": cty.NumberIntVal(i)}),
+		}
+		out = append(out, instance)
+	}
+
+	return out, nil
+}
+
+// evalFunctions are functions of Terraform which can be evaluated statically, usually used to build collections for for_each
+var evalFunctions = map[string]function.Function{
+	"concat":    stdlib.ConcatFunc,
+	"distinct":  stdlib.DistinctFunc,
+	"element":   stdlib.ElementFunc,
+	"flatten":   stdlib.FlattenFunc,
+	"format":    stdlib.FormatFunc,
+	"join":      stdlib.JoinFunc,
+	"keys":      stdlib.KeysFunc,
+	"length":    stdlib.LengthFunc,
+	"lookup":    stdlib.LookupFunc,
+	"lower":     stdlib.LowerFunc,
+	"merge":     stdlib.MergeFunc,
+	"range":     stdlib.RangeFunc,
+	"replace":   stdlib.ReplaceFunc,
+	"split":     stdlib.SplitFunc,
+	"tolist":    stdlib.MakeToFunc(cty.List(cty.DynamicPseudoType)),
+	"tomap":     stdlib.MakeToFunc(cty.Map(cty.DynamicPseudoType)),
+	"toset":     stdlib.MakeToFunc(cty.Set(cty.DynamicPseudoType)),
+	"tostring":  stdlib.MakeToFunc(cty.String),
+	"trimspace": stdlib.TrimSpaceFunc,
+	"upper":     stdlib.UpperFunc,
+	"values":    stdlib.ValuesFunc,
+	"zipmap":    stdlib.ZipmapFunc,
+}
@@ -0,0 +1,183 @@
+package terradep_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+func TestScanExpandsRemoteStateInstancesWithLocals(t *testing.T) {
+	const backend = `
+terraform {
+  backend "s3" {
+    bucket = "b"
+    key    = "app"
+  }
+}
+`
+	tests := []struct {
+		name        string
+		src         string
+		want        []string
+		wantDynamic bool
+	}{
+		{
+			name: "locals referencing each other",
+			src: `
+locals {
+  key    = "${local.prefix}-network"
+  prefix = "shared"
+}
+
+data "terraform_remote_state" "network" {
+  backend = "s3"
+  config = {
+    bucket = "b"
+    key    = local.key
+  }
+}
+`,
+			want: []string{"s3://?bucket=b&key=shared-network"},
+		},
+		{
+			name: "for_each over set of locals",
+			src: `
+locals {
+  envs = ["dev", "prod"]
+}
+
+data "terraform_remote_state" "db" {
+  for_each = toset(local.envs)
+  backend  = "s3"
+  config = {
+    bucket = "b"
+    key    = "db-${each.key}"
+  }
+}
+`,
+			want: []string{"s3://?bucket=b&key=db-dev", "s3://?bucket=b&key=db-prod"},
+		},
+		{
+			name: "for_each over map",
+			src: `
+data "terraform_remote_state" "db" {
+  for_each = { eu = "db-eu", us = "db-us" }
+  backend  = "s3"
+  config = {
+    bucket = "b"
+    key    = "${each.key}-${each.value}"
+  }
+}
+`,
+			want: []string{"s3://?bucket=b&key=eu-db-eu", "s3://?bucket=b&key=us-db-us"},
+		},
+		{
+			name: "count",
+			src: `
+data "terraform_remote_state" "shard" {
+  count   = 2
+  backend = "s3"
+  config = {
+    bucket = "b"
+    key    = "shard-${count.index}"
+  }
+}
+`,
+			want: []string{"s3://?bucket=b&key=shard-0", "s3://?bucket=b&key=shard-1"},
+		},
+		{
+			name: "zero count",
+			src: `
+data "terraform_remote_state" "shard" {
+  count   = 0
+  backend = "s3"
+  config = {
+    bucket = "b"
+    key    = "shard-${count.index}"
+  }
+}
+`,
+		},
+		{
+			name: "for_each over unknown value",
+			src: `
+data "terraform_remote_state" "db" {
+  for_each = toset(var.envs)
+  backend  = "s3"
+  config = {
+    bucket = "b"
+    key    = "db-${each.key}"
+  }
+}
+`,
+			wantDynamic: true,
+		},
+		{
+			name: "negative count",
+			src: `
+data "terraform_remote_state" "shard" {
+  count   = -1
+  backend = "s3"
+  config = {
+    bucket = "b"
+    key    = "shard"
+  }
+}
+`,
+			wantDynamic: true,
+		},
+		{
+			name: "for_each over list",
+			src: `
+data "terraform_remote_state" "db" {
+  for_each = ["dev"]
+  backend  = "s3"
+  config = {
+    bucket = "b"
+    key    = "db-${each.key}"
+  }
+}
+`,
+			wantDynamic: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := terradeptest.Dir(t, terradeptest.Files{"app/main.tf": backend + tt.src})
+
+			graph, diags, err := terradep.NewScanner(nil, terradeptest.NewStater(), terradep.WithRelativePaths()).Scan(dir)
+			if err != nil {
+				t.Fatalf("scanning directory: %v", err)
+			}
+
+			// outputs of the states are not consumed, so only diagnostics of the expansion are checked
+			dynamic := 0
+			for _, d := range diags {
+				if d.Rule == terradep.RuleDynamicRemoteState {
+					dynamic++
+				}
+			}
+			if (dynamic == 1) != tt.wantDynamic || dynamic > 1 {
+				t.Errorf("unexpected diagnostics: %v, want %s: %t", diags, terradep.RuleDynamicRemoteState, tt.wantDynamic)
+			}
+
+			var got []string
+			for _, n := range graph.Nodes() {
+				if n.Path != "app" {
+					continue
+				}
+				for _, child := range n.Children {
+					got = append(got, child.State.String())
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dependencies: %v, want: %v", got, tt.want)
+			}
+		})
+	}
+}
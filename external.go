@@ -0,0 +1,41 @@
+package terradep
+
+import "sort"
+
+// ExternalDependency is a dependency on the state which is not produced by any deployment of the [Graph],
+// e.g. deployment outside of scanned directories or a typo in the bucket or key of terraform_remote_state
+type ExternalDependency struct {
+	// Path, Workspace and Overlay identify the deployment depending on the state
+	Path      string
+	Workspace string
+	Overlay   string
+	State     State
+}
+
+// ExternalDependencies returns dependencies on the states not produced by any deployment of the graph, ordered by the deployment and the state
+func (g *Graph) ExternalDependencies() []ExternalDependency {
+	return externalDependencies(g.states, g.deps)
+}
+
+func externalDependencies(states map[deployment]State, deps map[deployment][]State) []ExternalDependency {
+	known := make(map[string]struct{}, len(states))
+	for _, state := range states {
+		known[state.String()] = struct{}{}
+	}
+
+	var out []ExternalDependency
+	for _, dep := range sortedDeployments(deps) {
+		depStates := append([]State{}, deps[dep]...)
+		sort.Slice(depStates, func(i, j int) bool {
+			return depStates[i].String() < depStates[j].String()
+		})
+		for _, state := range depStates {
+			if _, ok := known[state.String()]; ok {
+				continue
+			}
+			out = append(out, ExternalDependency{Path: dep.path, Workspace: dep.workspace, Overlay: dep.overlay, State: state})
+		}
+	}
+
+	return out
+}
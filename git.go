@@ -0,0 +1,147 @@
+package terradep
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitTopContributors limits the number of [GitContributor] in [GitInfo]
+const gitTopContributors = 3
+
+// GitInfo describes history of the deployment directory in git repository
+type GitInfo struct {
+	LastCommit     string    `json:"lastCommit"`
+	LastCommitDate time.Time `json:"lastCommitDate"`
+	// Contributors are authors of the most commits touching the directory, the most active first
+	Contributors []GitContributor `json:"contributors,omitempty"`
+}
+
+// GitContributor is an author of the commits touching the deployment directory
+type GitContributor struct {
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Commits int    `json:"commits"`
+}
+
+// WithGitMetadata makes the [Scanner] set [Node.Git] for every deployment tracked in git repository.
+// It requires git executable in PATH
+func WithGitMetadata() ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.gitMetadata = true
+	}
+}
+
+// readGitInfo reads git history of all scanned deployments. It is not cached, because history changes without changes in the files
+func (s *Scanner) readGitInfo(sc *scan) {
+	visited := map[string]struct{}{}
+	for _, dep := range sortedDeployments(sc.states) {
+		if _, ok := visited[dep.path]; ok {
+			continue
+		}
+		visited[dep.path] = struct{}{}
+		if !s.gitAvailable(sc) {
+			return
+		}
+
+		info, err := gitInfo(dep.path)
+		if err != nil {
+			s.warn(sc, Diagnostic{Rule: RuleGitHistory, Summary: "cannot read git history of the deployment", Detail: err.Error(), Module: dep.path})
+			continue
+		}
+
+		sc.detailsOf(dep.path).git = info
+	}
+}
+
+// gitAvailable checks once per scan whether git executable is in PATH and the root is in git repository.
+// When it is not, the problem is reported once, instead of for every deployment
+func (s *Scanner) gitAvailable(sc *scan) bool {
+	sc.gitOnce.Do(func() {
+		if _, err := exec.LookPath("git"); err != nil {
+			sc.gitErr = fmt.Errorf("git executable is not found: %w", err)
+		} else if _, err := git(sc.root, "rev-parse", "--git-dir"); err != nil {
+			sc.gitErr = fmt.Errorf("scanned directory is not in git repository: %w", err)
+		}
+		if sc.gitErr != nil {
+			s.warn(sc, Diagnostic{Rule: RuleGitHistory, Summary: "cannot read git history of the deployments", Detail: sc.gitErr.Error(), Module: sc.root})
+		}
+	})
+
+	return sc.gitErr == nil
+}
+
+// gitInfo returns history of the dir. Returns nil, when the dir does not have any commits
+func gitInfo(dir string) (*GitInfo, error) {
+	out, err := git(dir, "log", "-1", "--format=%H%x00%cI", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	last := strings.TrimSpace(string(out))
+	if last == "" {
+		return nil, nil
+	}
+
+	sha, date, ok := strings.Cut(last, "\x00")
+	if !ok {
+		return nil, fmt.Errorf("unexpected output of git log: %q", last)
+	}
+
+	info := &GitInfo{LastCommit: sha}
+	info.LastCommitDate, err = time.Parse(time.RFC3339, date)
+	if err != nil {
+		return nil, fmt.Errorf("parsing date of commit: %s, %w", sha, err)
+	}
+
+	out, err = git(dir, "shortlog", "--summary", "--numbered", "--email", "HEAD", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	info.Contributors, err = parseShortlog(out)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// parseShortlog parses lines in format: "    12\tName <email>"
+func parseShortlog(out []byte) ([]GitContributor, error) {
+	var contributors []GitContributor
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() && len(contributors) < gitTopContributors {
+		count, author, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "\t")
+		if !ok {
+			continue
+		}
+
+		commits, err := strconv.Atoi(count)
+		if err != nil {
+			return nil, fmt.Errorf("parsing number of commits: %q, %w", count, err)
+		}
+
+		name, email, _ := strings.Cut(author, " <")
+		contributors = append(contributors, GitContributor{Name: name, Email: strings.TrimSuffix(email, ">"), Commits: commits})
+	}
+
+	return contributors, scanner.Err()
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running git %s: %w, %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package terradep_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+func TestScanWarnsOnceWhenGitHistoryCannotBeRead(t *testing.T) {
+	dir := terradeptest.Dir(t, sharedModuleFiles)
+	// git must not find the repository of the directories above the fixture
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "not in git repository", path: os.Getenv("PATH")},
+		{name: "git not found", path: t.TempDir()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PATH", tt.path)
+
+			s := terradep.NewScanner(nil, terradeptest.NewStater(), terradep.WithGitMetadata())
+			_, diags, err := s.Scan(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(diags) != 1 || diags[0].Rule != terradep.RuleGitHistory {
+				t.Errorf("expected single diagnostic: %s, got: %v", terradep.RuleGitHistory, diags)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package terradep
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// GraphSchemaVersion is the version of JSON representation of the [Graph] written by [Graph.MarshalJSON].
+// It changes only when the representation changes in the way older versions cannot read
+const GraphSchemaVersion = 1
+
+// graphJSON is the JSON representation of the [Graph]. Only deployments are stored, external states and local modules
+// are restored from dependencies and modules of the deployments
+type graphJSON struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	Deployments   []deploymentJSON `json:"deployments"`
+}
+
+type deploymentJSON struct {
+	// ID is [Node.ID], it is derived from the state, so it is not read
+	ID        string `json:"id"`
+	Path      string `json:"path"`
+	Workspace string `json:"workspace,omitempty"`
+	Overlay   string `json:"overlay,omitempty"`
+	State     string `json:"state"`
+	// Dependencies are states of the deployments, in order they were found
+	Dependencies []string `json:"dependencies"`
+	// Modules are paths of local modules called by the deployment
+	Modules    []string        `json:"modules,omitempty"`
+	Owner      string          `json:"owner,omitempty"`
+	Layer      string          `json:"layer,omitempty"`
+	Tags       []string        `json:"tags,omitempty"`
+	CodeOwners []string        `json:"codeOwners,omitempty"`
+	Metadata   *ModuleMetadata `json:"metadata,omitempty"`
+	Git        *GitInfo        `json:"git,omitempty"`
+	// BackendRange and Edges are shared by the workspaces and overlays of the deployment, like the other details
+	BackendRange *hcl.Range              `json:"backendRange,omitempty"`
+	Edges        map[string]EdgeMetadata `json:"edges,omitempty"`
+}
+
+// MarshalJSON implements [json.Marshaler]. Deployments are ordered by path, workspace and overlay,
+// so the same graph is always encoded the same way and can be compared with text diff
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	out := graphJSON{SchemaVersion: GraphSchemaVersion, Deployments: make([]deploymentJSON, 0, len(g.states))}
+	for _, dep := range sortedDeployments(g.states) {
+		d := deploymentJSON{
+			ID:           StateID(g.states[dep]),
+			Path:         dep.path,
+			Workspace:    dep.workspace,
+			Overlay:      dep.overlay,
+			State:        g.states[dep].String(),
+			Dependencies: make([]string, 0, len(g.deps[dep])),
+			Modules:      g.modules[dep],
+		}
+		for _, state := range g.deps[dep] {
+			d.Dependencies = append(d.Dependencies, state.String())
+		}
+		if details, ok := g.details[dep.path]; ok {
+			if m := details.manifest; m != nil {
+				d.Owner, d.Layer, d.Tags = m.Owner, m.Layer, m.Tags
+			}
+			d.CodeOwners, d.Metadata, d.Git = details.codeOwners, details.metadata, details.git
+			d.BackendRange, d.Edges = details.backendRange, details.edges
+		}
+		out.Deployments = append(out.Deployments, d)
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements [json.Unmarshaler]. Restored graph has the same nodes and edges as the encoded one,
+// but states are compared only by their String representation, like states restored from [ScanCache].
+// Returns error when the schema version is newer than [GraphSchemaVersion], or [*CycleError] and [*DuplicateStateError] like [Scanner.Scan]
+func (g *Graph) UnmarshalJSON(b []byte) error {
+	var in graphJSON
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch {
+	case in.SchemaVersion == 0:
+		return errors.New("graph schema version is not set")
+	case in.SchemaVersion > GraphSchemaVersion:
+		return fmt.Errorf("unsupported graph schema version: %d, supported up to: %d", in.SchemaVersion, GraphSchemaVersion)
+	}
+
+	states := make(map[deployment]State, len(in.Deployments))
+	deps := make(map[deployment][]State, len(in.Deployments))
+	modules := make(map[deployment][]string)
+	details := make(map[string]*nodeDetails)
+	for _, d := range in.Deployments {
+		dep := deployment{path: d.Path, workspace: d.Workspace, overlay: d.Overlay}
+		if _, ok := states[dep]; ok {
+			return fmt.Errorf("deployment is encoded twice: %s, workspace: %q, overlay: %q", d.Path, d.Workspace, d.Overlay)
+		}
+		states[dep] = cachedState(d.State)
+
+		deps[dep] = make([]State, 0, len(d.Dependencies))
+		for _, state := range d.Dependencies {
+			deps[dep] = append(deps[dep], cachedState(state))
+		}
+		if len(d.Modules) != 0 {
+			modules[dep] = d.Modules
+		}
+
+		// details are shared by the workspaces and overlays of the deployment
+		if _, ok := details[d.Path]; !ok {
+			details[d.Path] = &nodeDetails{codeOwners: d.CodeOwners, metadata: d.Metadata, git: d.Git, backendRange: d.BackendRange, edges: d.Edges}
+			if d.Owner != "" || d.Layer != "" || len(d.Tags) != 0 {
+				details[d.Path].manifest = &Manifest{Owner: d.Owner, Layer: d.Layer, Tags: d.Tags}
+			}
+		}
+	}
+
+	if err := checkGraph(states, deps); err != nil {
+		return err
+	}
+
+	*g = *buildTree(discardLogger, states, deps, modules, details)
+	return nil
+}
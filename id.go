@@ -0,0 +1,34 @@
+package terradep
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// ID returns stable identifier of the node derived from its state, so it is the same after every scan, as long as the state does not change.
+// The state is unique in the [Graph], so ID is unique too. It is 16 hexadecimal digits, e.g. 3f2a9c0d1b7e4a58
+func (n *Node) ID() string {
+	return StateID(n.State)
+}
+
+// ID returns the identifier of the deployment equal to [Node.ID] of its node
+func (d Deployment) ID() string {
+	return StateID(d.State)
+}
+
+// StateID returns the identifier of the node with the state, see [Node.ID]. Dependencies of [Deployment] are identified with it
+func StateID(s State) string {
+	sum := stateHash(s)
+	return hex.EncodeToString(sum[:8])
+}
+
+// NumericID returns [Node.ID] as number, for libraries identifying nodes with integers, like gonum
+func (n *Node) NumericID() int64 {
+	sum := stateHash(n.State)
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+func stateHash(s State) [sha256.Size]byte {
+	return sha256.Sum256([]byte(s.String()))
+}
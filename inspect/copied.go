@@ -73,10 +73,155 @@ func FindTerraformBlock(log *slog.Logger, dir string) (*hcl.Block, error) {
 	return terraformBlock, nil
 }
 
+// IsModuleDir checks if the given path in the fs contains Terraform or OpenTofu configuration files.
+// It is a replacement of [tfconfig.IsModuleDirOnFilesystem] which recognizes only Terraform files
+func IsModuleDir(fs tfconfig.FS, dir string) bool {
+	paths, _ := DirFiles(fs, dir)
+	return len(paths) != 0
+}
+
+// LoadModule reads the directory at the given path and attempts to interpret it as a Terraform module, parsing the files with the parser.
+// It is a replacement of [tfconfig.LoadModuleFromFilesystem] which reads OpenTofu files too, using logic from function loadModule from [terraform-config-inspect]/tfconfig/load_hcl.go.
+// Modules without OpenTofu files which cannot be loaded are loaded again by [tfconfig.LoadModuleFromFilesystem] to keep its fallback to the legacy HCL parser
+//
+// [terraform-config-inspect]: https://github.com/hashicorp/terraform-config-inspect/
+func LoadModule(parser *Parser, dir string) (*tfconfig.Module, tfconfig.Diagnostics) {
+	paths, diags := DirFiles(parser.FS(), dir)
+
+	mod := tfconfig.NewModule(dir)
+	for _, filename := range paths {
+		file, fileDiags := parser.ParseFile(filename)
+		diags = append(diags, fileDiags...)
+		if file == nil {
+			continue
+		}
+
+		diags = append(diags, tfconfig.LoadModuleFromFile(file, mod)...)
+	}
+	if diags.HasErrors() && !hasTofuFile(paths) {
+		return tfconfig.LoadModuleFromFilesystem(parser.FS(), dir)
+	}
+
+	// copy of method init of tfconfig.Module, filling implied provider requirements
+	for _, r := range mod.ManagedResources {
+		if _, exists := mod.RequiredProviders[r.Provider.Name]; !exists {
+			mod.RequiredProviders[r.Provider.Name] = &tfconfig.ProviderRequirement{}
+		}
+	}
+	for _, r := range mod.DataResources {
+		if _, exists := mod.RequiredProviders[r.Provider.Name]; !exists {
+			mod.RequiredProviders[r.Provider.Name] = &tfconfig.ProviderRequirement{}
+		}
+	}
+	mod.Diagnostics = diagnosticsHCL(diags)
+
+	return mod, mod.Diagnostics
+}
+
+func hasTofuFile(paths []string) bool {
+	for _, path := range paths {
+		if IsTofuFile(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diagnosticsHCL is a copy of function diagnosticsHCL from [terraform-config-inspect]/tfconfig/diagnostic.go
+//
+// [terraform-config-inspect]: https://github.com/hashicorp/terraform-config-inspect/
+func diagnosticsHCL(diags hcl.Diagnostics) tfconfig.Diagnostics { //nolint:all
+	if len(diags) == 0 {
+		return nil
+	}
+	ret := make(tfconfig.Diagnostics, len(diags))
+	for i, diag := range diags {
+		ret[i] = tfconfig.Diagnostic{
+			Summary: diag.Summary,
+			Detail:  diag.Detail,
+		}
+		switch diag.Severity {
+		case hcl.DiagError:
+			ret[i].Severity = tfconfig.DiagError
+		case hcl.DiagWarning:
+			ret[i].Severity = tfconfig.DiagWarning
+		}
+		if diag.Subject != nil {
+			ret[i].Pos = &tfconfig.SourcePos{Filename: diag.Subject.Filename, Line: diag.Subject.Start.Line}
+		}
+	}
+	return ret
+}
+
+// TerraformBlocks contains all blocks "terraform" of the module, split by the kind of the file they were found in
+type TerraformBlocks struct {
+	// Primary are blocks found in regular files, in alphabetical order of the files
+	Primary []*hcl.Block
+	// Override are blocks found in override files, in order in which Terraform applies them
+	Override []*hcl.Block
+}
+
+// FindTerraformBlocks finds all blocks "terraform" in terraform files in dir, parsing them with the parser.
+// Unlike [FindTerraformBlock] it does not drop any of them, so the caller can apply the [override rules] used by Terraform
+//
+// [override rules]: https://developer.hashicorp.com/terraform/language/files/override
+func FindTerraformBlocks(log *slog.Logger, parser *Parser, dir string) (TerraformBlocks, error) {
+	paths, diags := DirFiles(parser.FS(), dir)
+	if diags.HasErrors() {
+		return TerraformBlocks{}, fmt.Errorf("listing files in dir: %s, %w", dir, diags)
+	}
+
+	log.Debug("looking for blocks 'terraform'", slog.Any("paths", paths))
+
+	out := TerraformBlocks{}
+	for _, filename := range paths {
+		file, diags := parser.ParseFile(filename)
+		if diags.HasErrors() {
+			return TerraformBlocks{}, fmt.Errorf("parsing file: %s, %w", filename, diags)
+		}
+
+		content, _, diags := file.Body.PartialContent(rootSchema)
+		if diags.HasErrors() {
+			return TerraformBlocks{}, fmt.Errorf("reading content of file: %s, %w", filename, diags)
+		}
+
+		for _, block := range content.Blocks {
+			if block.Type != "terraform" {
+				continue
+			}
+
+			if IsOverrideFile(filename) {
+				out.Override = append(out.Override, block)
+			} else {
+				out.Primary = append(out.Primary, block)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// IsOverrideFile checks whether the file is an [override file]
+//
+// [override file]: https://developer.hashicorp.com/terraform/language/files/override
+func IsOverrideFile(path string) bool {
+	name := filepath.Base(path)
+	ext := fileExt(name)
+	if ext == "" {
+		return false
+	}
+
+	baseName := name[:len(name)-len(ext)]
+	return baseName == "override" || strings.HasSuffix(baseName, "_override")
+}
+
 // DirFiles lists all the files which are a part of Terraform project within the fs.
-// Code is a copy of unexported function dirFiles from [terraform-config-inspect]/tfconfig/load.go
+// Code is a copy of unexported function dirFiles from [terraform-config-inspect]/tfconfig/load.go,
+// extended with [OpenTofu files]: a file with extension .tofu or .tofu.json takes precedence over the file with the same name and extension .tf or .tf.json
 //
 // [terraform-config-inspect]: https://github.com/hashicorp/terraform-config-inspect/
+// [OpenTofu files]: https://opentofu.org/docs/language/files/#file-extension
 func DirFiles(fs tfconfig.FS, dir string) (primary []string, diags hcl.Diagnostics) { //nolint:all
 	infos, err := fs.ReadDir(dir)
 	if err != nil {
@@ -88,6 +233,11 @@ func DirFiles(fs tfconfig.FS, dir string) (primary []string, diags hcl.Diagnosti
 		return
 	}
 
+	names := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		names[info.Name()] = struct{}{}
+	}
+
 	var override []string
 	for _, info := range infos {
 		if info.IsDir() {
@@ -102,6 +252,10 @@ func DirFiles(fs tfconfig.FS, dir string) (primary []string, diags hcl.Diagnosti
 		}
 
 		baseName := name[:len(name)-len(ext)] // strip extension
+		if _, ok := names[baseName+tofuExt(ext)]; ok && !IsTofuFile(name) {
+			// OpenTofu ignores the Terraform file when there is a tofu file with the same name
+			continue
+		}
 		isOverride := baseName == "override" || strings.HasSuffix(baseName, "_override")
 
 		fullPath := filepath.Join(dir, name)
@@ -119,18 +273,37 @@ func DirFiles(fs tfconfig.FS, dir string) (primary []string, diags hcl.Diagnosti
 	return
 }
 
-// fileExt returns the Terraform configuration extension of the given
+// fileExt returns the Terraform or OpenTofu configuration extension of the given
 // path, or a blank string if it is not a recognized extension.
 func fileExt(path string) string { //nolint:all
 	if strings.HasSuffix(path, ".tf") {
 		return ".tf"
 	} else if strings.HasSuffix(path, ".tf.json") {
 		return ".tf.json"
+	} else if strings.HasSuffix(path, ".tofu") {
+		return ".tofu"
+	} else if strings.HasSuffix(path, ".tofu.json") {
+		return ".tofu.json"
 	} else {
 		return ""
 	}
 }
 
+// IsConfigFile checks whether the file has extension of Terraform or OpenTofu configuration file
+func IsConfigFile(path string) bool {
+	return fileExt(path) != ""
+}
+
+// tofuExt returns OpenTofu counterpart of the Terraform extension
+func tofuExt(ext string) string {
+	return strings.Replace(ext, ".tf", ".tofu", 1)
+}
+
+// IsTofuFile checks whether the file uses the OpenTofu-specific extension .tofu or .tofu.json
+func IsTofuFile(path string) bool {
+	return strings.HasSuffix(path, ".tofu") || strings.HasSuffix(path, ".tofu.json")
+}
+
 // isIgnoredFile returns true if the given filename (which must not have a
 // directory path ahead of it) should be ignored as e.g. an editor swap file.
 func isIgnoredFile(name string) bool { //nolint:all
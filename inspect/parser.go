@@ -0,0 +1,135 @@
+package inspect
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/json"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+// Parser parses Terraform files read from the fs. Every file is read and parsed only once,
+// next calls return the same file and diagnostics. It is safe for concurrent use
+type Parser struct {
+	fs tfconfig.FS
+	// tokens required to parse the file, nil when every file must be parsed
+	tokens [][]byte
+
+	// hook is called around parsing of every file, nil when not set
+	hook ParseHook
+
+	mu sync.Mutex
+	// files are parsed once, concurrent calls for the same file wait for the first one
+	files map[string]*parsedFile
+}
+
+// ParseHook is called before the file is read and parsed. Returned function is called after parsing with the result,
+// file is nil when it could not be read. Hook is not called for the files returned again, but it can be called concurrently
+type ParseHook func(filename string) func(file *hcl.File, diags hcl.Diagnostics)
+
+type parsedFile struct {
+	once  sync.Once
+	file  *hcl.File
+	diags hcl.Diagnostics
+}
+
+// NewParser returns [Parser] reading the files from the fs.
+// When tokens are not empty, files which do not contain any of them are not parsed, see [Parser.ParseFile]
+func NewParser(fs tfconfig.FS, tokens ...string) *Parser {
+	p := &Parser{fs: fs, files: map[string]*parsedFile{}}
+	for _, token := range tokens {
+		p.tokens = append(p.tokens, []byte(token))
+	}
+
+	return p
+}
+
+// SetHook sets the hook called for every parsed file, e.g. to measure how long the parsing takes.
+// It must be set before the first file is parsed
+func (p *Parser) SetHook(hook ParseHook) {
+	p.hook = hook
+}
+
+// FS returns the filesystem the files are read from
+func (p *Parser) FS() tfconfig.FS {
+	return p.fs
+}
+
+// ParseFile parses the file using native or JSON syntax, depending on the extension of the file.
+// When the file does not contain any of the tokens passed to [NewParser], it is not parsed and returned file has empty body,
+// but [hcl.File.Bytes] still contains its source
+func (p *Parser) ParseFile(filename string) (*hcl.File, hcl.Diagnostics) {
+	p.mu.Lock()
+	parsed, ok := p.files[filename]
+	if !ok {
+		parsed = &parsedFile{}
+		p.files[filename] = parsed
+	}
+	p.mu.Unlock()
+
+	parsed.once.Do(func() {
+		var done func(*hcl.File, hcl.Diagnostics)
+		if p.hook != nil {
+			done = p.hook(filename)
+		}
+		parsed.file, parsed.diags = p.parse(filename)
+		if done != nil {
+			done(parsed.file, parsed.diags)
+		}
+	})
+
+	return parsed.file, parsed.diags
+}
+
+func (p *Parser) parse(filename string) (*hcl.File, hcl.Diagnostics) {
+	src, err := p.fs.ReadFile(filename)
+	if err != nil {
+		return nil, hcl.Diagnostics{{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to read file",
+			Detail:   fmt.Sprintf("The configuration file %q could not be read: %s", filename, err),
+		}}
+	}
+
+	if !p.containsToken(src) {
+		return &hcl.File{Body: hcl.EmptyBody(), Bytes: src}, nil
+	}
+
+	if strings.HasSuffix(filename, ".json") {
+		return json.Parse(src, filename)
+	}
+
+	return hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+}
+
+// Forget drops the files from the dir, so memory used by them can be reclaimed. Files will be parsed again when needed
+func (p *Parser) Forget(dir string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for filename := range p.files {
+		if filepath.Dir(filename) == dir {
+			delete(p.files, filename)
+		}
+	}
+}
+
+// containsToken is a cheap check whether the source must be parsed, it may find tokens inside comments or strings
+func (p *Parser) containsToken(src []byte) bool {
+	if len(p.tokens) == 0 {
+		return true
+	}
+
+	for _, token := range p.tokens {
+		if bytes.Contains(src, token) {
+			return true
+		}
+	}
+
+	return false
+}
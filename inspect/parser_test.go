@@ -0,0 +1,57 @@
+package inspect_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"go.interactor.dev/terradep/inspect"
+)
+
+func TestParseFileParsesFileOnceWhenCalledConcurrently(t *testing.T) {
+	fs := tfconfig.WrapFS(fstest.MapFS{
+		"main.tf": &fstest.MapFile{Data: []byte(`terraform {
+  backend "s3" {}
+}
+`)},
+	})
+
+	var parsed atomic.Int32
+	p := inspect.NewParser(fs)
+	p.SetHook(func(string) func(*hcl.File, hcl.Diagnostics) {
+		parsed.Add(1)
+		return nil
+	})
+
+	files := make([]*hcl.File, 16)
+	wg := sync.WaitGroup{}
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var diags hcl.Diagnostics
+			files[i], diags = p.ParseFile("main.tf")
+			if diags.HasErrors() {
+				t.Errorf("unexpected diagnostics: %v", diags)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := parsed.Load(); n != 1 {
+		t.Errorf("file parsed %d times, want once", n)
+	}
+	for _, f := range files {
+		if f != files[0] {
+			t.Fatalf("concurrent calls returned different files")
+		}
+	}
+
+	p.Forget(".")
+	if _, diags := p.ParseFile("main.tf"); diags.HasErrors() || parsed.Load() != 2 {
+		t.Errorf("forgotten file is not parsed again, parsed: %d times, diagnostics: %v", parsed.Load(), diags)
+	}
+}
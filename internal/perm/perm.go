@@ -0,0 +1,9 @@
+// Package perm defines permissions of the files and the directories written by terradep
+package perm
+
+const (
+	// UserRW allows only the owner to read and write the file
+	UserRW = 0o600
+	// UserRWX allows only the owner to read, write and list the directory
+	UserRWX = 0o700
+)
@@ -0,0 +1,163 @@
+package terradep
+
+import (
+	"fmt"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// LayerRule assigns deployments to the named layer and declares on which layers they may depend, so architecture like
+// "network ← platform ← apps" can be enforced with [CheckLayers] without writing policies.
+//
+// Example: applications may depend on the platform and the network, the platform only on the network:
+//
+//	[]LayerRule{
+//		{Name: "network", Paths: []string{"network/**"}},
+//		{Name: "platform", Paths: []string{"platform/**"}, DependsOn: []string{"network"}},
+//		{Name: "apps", Paths: []string{"apps/**"}, States: []string{"s3://tf-state/apps/**"}, DependsOn: []string{"platform", "network"}},
+//	}
+type LayerRule struct {
+	// Name of the layer, it is set as the layer of the matching deployments, e.g. [Node.Layer]
+	Name string `json:"name"`
+	// Paths are glob patterns matched with paths of the deployments relative to the scanned root, see [WithIncludePaths]
+	Paths []string `json:"paths,omitempty"`
+	// States are glob patterns matched with [State.Identity] of the deployments and of the states not produced by any of them
+	States []string `json:"states,omitempty"`
+	// DependsOn are names of the other layers the deployments of the layer may depend on. Dependencies within the layer are always allowed
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// WithLayerRules makes the [Scanner] assign the layer of the first matching rule to deployments which do not declare the layer in the [Manifest]
+func WithLayerRules(rules ...LayerRule) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.layerRules = append(cfg.layerRules, rules...)
+	}
+}
+
+// ValidateLayerRules checks whether the rules have unique names, valid glob patterns and depend only on declared layers
+func ValidateLayerRules(rules []LayerRule) error {
+	names := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("layer rule without name")
+		}
+		if _, ok := names[rule.Name]; ok {
+			return fmt.Errorf("layer declared more than once: %s", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+
+		for _, glob := range append(append([]string(nil), rule.Paths...), rule.States...) {
+			if !doublestar.ValidatePattern(glob) {
+				return fmt.Errorf("invalid glob pattern: %q in layer: %s", glob, rule.Name)
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		for _, dep := range rule.DependsOn {
+			if _, ok := names[dep]; !ok {
+				return fmt.Errorf("layer: %s depends on undeclared layer: %s", rule.Name, dep)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckLayers finds dependencies breaking the rules ([RuleLayerViolation]): deployment of the layer depends on the deployment
+// or the state of the other layer not listed in [LayerRule.DependsOn]. Layers of the deployments are taken from [Deployment.Layer],
+// e.g. set by [WithLayerRules], layers of states not produced by any deployment are matched with [LayerRule.States].
+// Deployments and states without the layer are not checked. All the diagnostics have [SeverityError]
+func CheckLayers(deployments []Deployment, rules []LayerRule) Diagnostics {
+	byName := make(map[string]LayerRule, len(rules))
+	for _, rule := range rules {
+		byName[rule.Name] = rule
+	}
+
+	layers := make(map[string]string, len(deployments))
+	for _, d := range deployments {
+		if d.Layer != "" {
+			layers[d.State.Identity()] = d.Layer
+		}
+	}
+
+	var diags Diagnostics
+	for _, d := range deployments {
+		rule, ok := byName[d.Layer]
+		if !ok {
+			continue
+		}
+
+		for _, dep := range d.Dependencies {
+			layer, ok := layers[dep.Identity()]
+			if !ok {
+				layer = stateLayer(rules, dep)
+			}
+			if layer == "" || layer == rule.Name || contains(rule.DependsOn, layer) {
+				continue
+			}
+
+			diag := Diagnostic{
+				Severity: SeverityError,
+				Rule:     RuleLayerViolation,
+				Summary:  fmt.Sprintf("layer: %s must not depend on layer: %s", rule.Name, layer),
+				Detail:   fmt.Sprintf("state: %s", dep),
+				Module:   d.Path,
+				Range:    edgeRange(d, dep),
+			}
+			if d.Workspace != "" || d.Overlay != "" {
+				diag.Detail += fmt.Sprintf(", workspace: %q, overlay: %q", d.Workspace, d.Overlay)
+			}
+			diags = append(diags, diag)
+		}
+	}
+
+	return diags
+}
+
+// assignLayer sets the layer of the first rule matching the deployment at the path, unless its manifest declares the layer
+func (s *Scanner) assignLayer(sc *scan, path string) {
+	if len(s.layerRules) == 0 {
+		return
+	}
+
+	details := sc.detailsOf(path)
+	if details.manifest != nil && details.manifest.Layer != "" {
+		return
+	}
+
+	rel := relativePath(sc.root, path)
+	state := sc.states[deployment{path: path, workspace: s.moduleWorkspaces()[0], overlay: overlayName(sc.overlaysOf(path)[0])}]
+	for _, rule := range s.layerRules {
+		if !matchAny(rule.Paths, rel) && (state == nil || !matchAny(rule.States, state.Identity())) {
+			continue
+		}
+
+		if details.manifest == nil {
+			details.manifest = &Manifest{}
+		}
+		details.manifest.Layer = rule.Name
+		return
+	}
+}
+
+// stateLayer returns name of the first rule matching the state, empty string when none does
+func stateLayer(rules []LayerRule, state State) string {
+	for _, rule := range rules {
+		if matchAny(rule.States, state.Identity()) {
+			return rule.Name
+		}
+	}
+
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
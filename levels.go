@@ -0,0 +1,75 @@
+package terradep
+
+// LevelsOpt changes the deployments grouped by [Levels]
+type LevelsOpt func(cfg *levelsCfg)
+
+type levelsCfg struct {
+	// only is nil, when all the deployments are grouped
+	only map[*Node]struct{}
+}
+
+// WithLevelsOnly limits [Levels] to the deployments among the nodes, e.g. returned by [Graph.Affected].
+// Dependencies on other deployments are ignored, like dependencies on external states
+func WithLevelsOnly(nodes ...*Node) LevelsOpt {
+	return func(cfg *levelsCfg) {
+		cfg.only = make(map[*Node]struct{}, len(nodes))
+		for _, n := range nodes {
+			cfg.only[n] = struct{}{}
+		}
+	}
+}
+
+// Levels groups deployments of the graph into batches, which can be planned or applied one after another, e.g. by own scheduler.
+// Deployments of the batch depend only on deployments of the previous batches, so all of them can run at the same time.
+// Depth is the index of the batch of every deployment: 0 for deployments without dependencies on other deployments,
+// otherwise greater by 1 than the highest depth of its dependencies. Deployments of every batch are ordered like [Graph.Nodes].
+// External states and local modules are not grouped
+func Levels(g *Graph, opts ...LevelsOpt) (batches [][]*Node, depth map[*Node]int) {
+	cfg := &levelsCfg{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var deployments []*Node
+	depth = make(map[*Node]int)
+	for _, n := range g.Nodes() {
+		if !g.IsDeployment(n) {
+			continue
+		}
+		if _, ok := cfg.only[n]; cfg.only != nil && !ok {
+			continue
+		}
+		deployments = append(deployments, n)
+		depth[n] = -1
+	}
+
+	// graph is acyclic, which is checked by the scanner and [Graph.AddEdge]
+	var level func(n *Node) int
+	level = func(n *Node) int {
+		if depth[n] >= 0 {
+			return depth[n]
+		}
+		d := 0
+		for _, child := range n.Children {
+			if _, ok := depth[child]; !ok {
+				continue
+			}
+			if l := level(child) + 1; l > d {
+				d = l
+			}
+		}
+		depth[n] = d
+
+		return d
+	}
+
+	for _, n := range deployments {
+		d := level(n)
+		for len(batches) <= d {
+			batches = append(batches, nil)
+		}
+		batches[d] = append(batches[d], n)
+	}
+
+	return batches, depth
+}
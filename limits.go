@@ -0,0 +1,118 @@
+package terradep
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"go.interactor.dev/terradep/inspect"
+)
+
+// DefaultMaxFileSize is the size in bytes of the largest Terraform file parsed by the [Scanner], unless changed with [WithMaxFileSize]
+const DefaultMaxFileSize int64 = 10 << 20
+
+// binarySniffLen is the number of bytes read from the beginning of the file to check whether it is binary, the same as git uses
+const binarySniffLen = 8000
+
+// WithMaxFileSize makes the [Scanner] skip Terraform files larger than size in bytes instead of parsing them,
+// so a stray generated file does not exhaust the memory. Skipped files are reported as [Diagnostics] with [SeverityWarning].
+// Non-positive size disables the limit. Defaults to [DefaultMaxFileSize]
+func WithMaxFileSize(size int64) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.maxFileSize = size
+	}
+}
+
+// limitedFs hides Terraform files exceeding the size limit and binary files from the listings of the directories,
+// so every part of the [Scanner] skips them consistently. Every hidden file is reported once with skipped
+type limitedFs struct {
+	tfconfig.FS
+	maxSize int64
+	skipped func(path, reason string)
+
+	mu sync.Mutex
+	// allowed stores results of the checks by path of the file
+	allowed map[string]bool
+}
+
+func newLimitedFs(maxSize int64, skipped func(path, reason string)) *limitedFs {
+	return &limitedFs{
+		FS:      tfconfig.NewOsFs(),
+		maxSize: maxSize,
+		skipped: skipped,
+		allowed: map[string]bool{},
+	}
+}
+
+// ReadDir lists the dir without the files which must not be parsed
+func (f *limitedFs) ReadDir(dir string) ([]os.FileInfo, error) {
+	infos, err := f.FS.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]os.FileInfo, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || !inspect.IsConfigFile(info.Name()) || f.isAllowed(filepath.Join(dir, info.Name()), info) {
+			out = append(out, info)
+		}
+	}
+
+	return out, nil
+}
+
+func (f *limitedFs) isAllowed(path string, info os.FileInfo) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if allowed, ok := f.allowed[path]; ok {
+		return allowed
+	}
+
+	reason := f.check(path, info)
+	f.allowed[path] = reason == ""
+	if reason != "" {
+		f.skipped(path, reason)
+	}
+
+	return reason == ""
+}
+
+// check returns the reason why the file must be skipped or empty string
+func (f *limitedFs) check(path string, info os.FileInfo) string {
+	if f.maxSize > 0 && info.Size() > f.maxSize {
+		return fmt.Sprintf("file has: %d bytes, limit is: %d bytes", info.Size(), f.maxSize)
+	}
+
+	binary, err := isBinaryFile(path)
+	if err != nil {
+		// let the parser report the problem with reading the file
+		return ""
+	}
+	if binary {
+		return "file has binary content"
+	}
+
+	return ""
+}
+
+// isBinaryFile checks whether the beginning of the file contains NUL byte, which never appears in the text files
+func isBinaryFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+
+	return bytes.IndexByte(buf[:n], 0) != -1, nil
+}
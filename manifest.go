@@ -0,0 +1,199 @@
+package terradep
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFiles are names of the files read as [Manifest] from the deployment directory. When there is more than one, the first is used
+var ManifestFiles = []string{"terradep.hcl", "terradep.yaml", "terradep.yml"}
+
+// Manifest is an optional file in the deployment directory, which declares dependencies and metadata that cannot be found in Terraform files.
+// Example of terradep.hcl:
+//
+//	owner      = "team-network"
+//	layer      = "foundation"
+//	tags       = ["dns"]
+//	depends_on = ["s3://bucket/iam.tfstate", "../dns"]
+//
+// The same in terradep.yaml:
+//
+//	owner: team-network
+//	layer: foundation
+//	tags: [dns]
+//	depends_on: [s3://bucket/iam.tfstate, ../dns]
+type Manifest struct {
+	Owner string   `hcl:"owner,optional" yaml:"owner"`
+	Layer string   `hcl:"layer,optional" yaml:"layer"`
+	Tags  []string `hcl:"tags,optional" yaml:"tags"`
+	// DependsOn contains states as they are printed in the graph, e.g. s3://bucket/key,
+	// or paths of other deployments relative to the deployment directory, e.g. ../network
+	DependsOn []string `hcl:"depends_on,optional" yaml:"depends_on"`
+	// Overlays deploy the module directory more than once with different backend configuration
+	Overlays []Overlay `hcl:"overlay,block" yaml:"overlays"`
+}
+
+// declaredState is a [State] declared in the [Manifest]. It is equal to other states with the same String representation
+type declaredState string
+
+// String implements State
+func (s declaredState) String() string {
+	return string(s)
+}
+
+// Backend implements State. Manifest declares only the identity of the state, so the backend is not known
+func (s declaredState) Backend() string {
+	return ""
+}
+
+// Identity implements State
+func (s declaredState) Identity() string {
+	return string(s)
+}
+
+// MarshalJSON implements [json.Marshaler]
+func (s declaredState) MarshalJSON() ([]byte, error) {
+	return MarshalState(s, nil)
+}
+
+// readManifest reads the [Manifest] from the dir. Returns nil, when there is no manifest
+func readManifest(dir string) (*Manifest, error) {
+	for _, name := range ManifestFiles {
+		path := filepath.Join(dir, name)
+		b, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading manifest: %s, %w", path, err)
+		}
+
+		m := &Manifest{}
+		if filepath.Ext(name) == ".hcl" {
+			err = decodeHCLManifest(path, b, m)
+		} else {
+			err = decodeYAMLManifest(b, m)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding manifest: %s, %w", path, err)
+		}
+
+		if err := validateOverlays(m.Overlays); err != nil {
+			return nil, fmt.Errorf("validating manifest: %s, %w", path, err)
+		}
+
+		return m, nil
+	}
+
+	return nil, nil
+}
+
+func decodeHCLManifest(path string, b []byte, m *Manifest) error {
+	file, diags := hclparse.NewParser().ParseHCL(b, path)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	if diags := gohcl.DecodeBody(file.Body, nil, m); diags.HasErrors() {
+		return diags
+	}
+
+	return nil
+}
+
+func decodeYAMLManifest(b []byte, m *Manifest) error {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(m); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+// declaredDependency is a dependency declared in the [Manifest] or in the comment, see [AnnotationPrefix]
+type declaredDependency struct {
+	// Target is a state, e.g. s3://bucket/key, or a path of another deployment relative to the deployment directory
+	Target string
+	// Range points to the comment, it is nil for dependencies declared in the manifest
+	Range *hcl.Range
+}
+
+// declaredDependencies returns dependencies of the deployment declared in its manifest and comments
+func (sc *scan) declaredDependencies(path string) []declaredDependency {
+	var out []declaredDependency
+	if m := sc.detailsOf(path).manifest; m != nil {
+		for _, target := range m.DependsOn {
+			out = append(out, declaredDependency{Target: target})
+		}
+	}
+
+	return append(out, sc.annotations[path]...)
+}
+
+// applyDeclaredDependencies adds dependencies declared in manifests and comments to the scanned deployments
+func (s *Scanner) applyDeclaredDependencies(sc *scan) {
+	for _, dep := range sortedDeployments(sc.states) {
+		s.applyDeclared(sc, dep)
+	}
+}
+
+// applyDeclared adds dependencies declared by the deployment. Deployments declared by path must be already in the scan
+func (s *Scanner) applyDeclared(sc *scan, dep deployment) {
+	for _, declared := range sc.declaredDependencies(dep.path) {
+		if isDeclaredState(declared.Target) {
+			s.addDeclaredDependency(sc, dep, declaredState(declared.Target), declared.Range)
+			continue
+		}
+
+		target := deployment{path: filepath.Join(dep.path, filepath.FromSlash(declared.Target)), workspace: dep.workspace, overlay: dep.overlay}
+		state, ok := sc.states[target]
+		if !ok {
+			// deployment without overlays can depend on the deployment with overlays and the other way around
+			target.overlay = ""
+			state, ok = sc.states[target]
+		}
+		if !ok {
+			s.warn(sc, Diagnostic{
+				Rule:    RuleMissingDeclaredTarget,
+				Summary: fmt.Sprintf("skipping declared dependency, deployment not found: %s", declared.Target),
+				Detail:  fmt.Sprintf("path: %s, workspace: %q", target.path, target.workspace),
+				Module:  dep.path,
+				Range:   declared.Range,
+			})
+			continue
+		}
+		s.addDeclaredDependency(sc, dep, state, declared.Range)
+	}
+}
+
+// isDeclaredState checks whether the declared dependency is a state, otherwise it is a path of the deployment
+func isDeclaredState(target string) bool {
+	return strings.Contains(target, "://")
+}
+
+// addDeclaredDependency adds the dependency, unless it was already found. Range points to the comment declaring it, nil for the [Manifest]
+func (s *Scanner) addDeclaredDependency(sc *scan, dep deployment, state State, r *hcl.Range) {
+	if r != nil {
+		sc.addEdge(dep.path, state, *r, nil)
+	}
+	for _, known := range sc.deps[dep] {
+		if known.String() == state.String() {
+			s.log.Debug("declared dependency already found", slog.String("module", dep.path), slog.String("state", state.String()))
+			return
+		}
+	}
+
+	sc.deps[dep] = append(sc.deps[dep], state)
+}
@@ -0,0 +1,79 @@
+package terradep
+
+import (
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+// ModuleMetadata describes the deployment, it is extracted from Terraform files during the scan
+type ModuleMetadata struct {
+	// RequiredVersion contains constraints of Terraform version from attributes required_version
+	RequiredVersion []string `json:"requiredVersion,omitempty"`
+	// Providers are keyed by the local name of the provider
+	Providers map[string]ProviderRequirement `json:"providers,omitempty"`
+	// Resources is the number of managed resources, not counting the instances created with count or for_each
+	Resources int `json:"resources"`
+	// DataSources is the number of data sources, including terraform_remote_state
+	DataSources int `json:"dataSources"`
+	// Backend is the type of the backend or [CloudBackend]
+	Backend string `json:"backend"`
+}
+
+// ProviderRequirement is a provider declared in block required_providers
+type ProviderRequirement struct {
+	Source             string   `json:"source,omitempty"`
+	VersionConstraints []string `json:"versionConstraints,omitempty"`
+}
+
+// nodeDetails are properties of the deployment which do not depend on the workspace
+type nodeDetails struct {
+	manifest   *Manifest
+	metadata   *ModuleMetadata
+	git        *GitInfo
+	codeOwners []string
+	// backendRange points to the backend or cloud block
+	backendRange *hcl.Range
+	// edges are keyed by [State.Identity] of the dependencies of all the workspaces and overlays
+	edges map[string]EdgeMetadata
+}
+
+// apply copies the details to the node
+func (d *nodeDetails) apply(node *Node) {
+	if m := d.manifest; m != nil {
+		node.Owner, node.Layer, node.Tags = m.Owner, m.Layer, m.Tags
+	}
+	node.CodeOwners = d.codeOwners
+	node.Metadata = d.metadata
+	node.Git = d.git
+	node.BackendRange = d.backendRange
+	node.Edges = d.edges
+}
+
+// detailsOf returns details of the deployment, creating them when needed
+func (sc *scan) detailsOf(path string) *nodeDetails {
+	d, ok := sc.details[path]
+	if !ok {
+		d = &nodeDetails{}
+		sc.details[path] = d
+	}
+
+	return d
+}
+
+func moduleMetadata(module *tfconfig.Module, backend string) *ModuleMetadata {
+	out := &ModuleMetadata{
+		RequiredVersion: module.RequiredCore,
+		Resources:       len(module.ManagedResources),
+		DataSources:     len(module.DataResources),
+		Backend:         backend,
+	}
+
+	if len(module.RequiredProviders) != 0 {
+		out.Providers = make(map[string]ProviderRequirement, len(module.RequiredProviders))
+		for name, req := range module.RequiredProviders {
+			out.Providers[name] = ProviderRequirement{Source: req.Source, VersionConstraints: req.VersionConstraints}
+		}
+	}
+
+	return out
+}
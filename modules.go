@@ -0,0 +1,195 @@
+package terradep
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"go.interactor.dev/terradep/inspect"
+	"golang.org/x/exp/slog"
+)
+
+// LocalModule is used as a [State] of the [Node] representing local module called by the deployments.
+// Such nodes are created only when [Scanner] was created with [WithModuleEdges]
+type LocalModule string
+
+// String implements State
+func (m LocalModule) String() string {
+	return "module:" + string(m)
+}
+
+// Backend implements State. Local modules do not have state, so it is always empty
+func (m LocalModule) Backend() string {
+	return ""
+}
+
+// Identity implements State
+func (m LocalModule) Identity() string {
+	return m.String()
+}
+
+// MarshalJSON implements [json.Marshaler]
+func (m LocalModule) MarshalJSON() ([]byte, error) {
+	return MarshalState(m, map[string]any{"path": string(m)})
+}
+
+// WithModuleEdges makes the [Scanner] find local modules called by deployments, see [Node.Modules].
+// It reveals which deployments are affected by the change in a shared module
+func WithModuleEdges() ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.moduleEdges = true
+	}
+}
+
+// WithFollowModules makes the [Scanner] look for terraform_remote_state also in local modules called by the deployment.
+// Modules are followed recursively and found states are attributed to the calling deployment
+func WithFollowModules() ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.followModules = true
+	}
+}
+
+// findModulesDependencies returns remote states declared in local modules called by the module and their local modules.
+// Visited contains paths of already checked modules, it protects from scanning the same module twice
+func (s *Scanner) findModulesDependencies(sc *scan, module *tfconfig.Module, inst instance, visited map[string]struct{}) ([]State, error) {
+	var out []State
+	for _, call := range localModuleCalls(module) {
+		if _, ok := visited[call]; ok {
+			continue
+		}
+		visited[call] = struct{}{}
+
+		s.log.Debug("following module call", slog.String("caller", module.Path), slog.String("module", call))
+		child, diags := inspect.LoadModule(sc.parser, call)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("loading module: %q called by: %q, %w", call, module.Path, diags.Err())
+		}
+
+		states, err := s.findRemoteStates(sc, child, inst)
+		if err != nil {
+			return nil, fmt.Errorf("finding dependencies in module: %q called by: %q, %w", call, module.Path, err)
+		}
+		out = append(out, states...)
+
+		nested, err := s.findModulesDependencies(sc, child, inst, visited)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, nested...)
+	}
+
+	return out, nil
+}
+
+// checkNoBackend fails the scan with the error of the first module without backend, which is not called by any deployment, directly or through other modules.
+// Called modules are shared by the deployments, so they are not a part of the graph, unless it has [WithModuleEdges]
+func (s *Scanner) checkNoBackend(sc *scan) error {
+	called := make(map[string]struct{})
+	var visit func(path string)
+	visit = func(path string) {
+		for _, call := range sc.calls[path] {
+			if _, ok := called[call]; ok {
+				continue
+			}
+			called[call] = struct{}{}
+			visit(call)
+		}
+	}
+	for _, dep := range sortedDeployments(sc.states) {
+		visit(dep.path)
+	}
+
+	for _, path := range sortedKeys(sc.noBackend) {
+		if _, ok := called[path]; ok {
+			s.log.Debug("module without backend is called by deployments", slog.String("path", path))
+			continue
+		}
+		if err := s.skipFailed(sc, path, DirNoBackend, sc.noBackend[path]); !errors.Is(err, fs.SkipDir) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// localModuleCalls returns absolute paths of local modules (source starting with ./ or ../) called by the module
+func localModuleCalls(module *tfconfig.Module) []string {
+	out := make([]string, 0, len(module.ModuleCalls))
+	for _, name := range sortedKeys(module.ModuleCalls) {
+		call := module.ModuleCalls[name]
+		if !isLocalSource(call.Source) {
+			continue
+		}
+
+		out = append(out, filepath.Join(module.Path, filepath.FromSlash(call.Source)))
+	}
+
+	return out
+}
+
+func isLocalSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// linkModules adds [Node.Modules] to deployments calling local modules.
+// When called module is inside the directory of another deployment, edge points to that deployment,
+// otherwise to the [Node] with [LocalModule] state shared by all the callers
+func linkModules(log *slog.Logger, nodes map[deployment]*Node, modules map[deployment][]string) {
+	moduleNodes := make(map[string]*Node)
+	for _, caller := range sortedDeployments(modules) {
+		calls := modules[caller]
+		callerNode := nodes[caller]
+		for _, call := range calls {
+			target := findOwningNode(nodes, call, caller)
+			if target == callerNode {
+				// module inside the deployment's own tree
+				continue
+			}
+
+			if target == nil {
+				target = moduleNodes[call]
+			}
+
+			if target == nil {
+				log.Debug("found local module", slog.String("path", call))
+				target = &Node{
+					Path:  call,
+					State: LocalModule(call),
+				}
+				moduleNodes[call] = target
+			}
+
+			callerNode.Modules = append(callerNode.Modules, target)
+		}
+	}
+}
+
+// findOwningNode returns the deployment in the same workspace and overlay as the caller with the longest path containing the module
+// or nil, if there is no such deployment
+func findOwningNode(nodes map[deployment]*Node, module string, caller deployment) *Node {
+	var owner *Node
+	for dep, node := range nodes {
+		if dep.workspace != caller.workspace || dep.overlay != caller.overlay || !isSubPath(dep.path, module) {
+			continue
+		}
+
+		if owner == nil || len(owner.Path) < len(node.Path) {
+			owner = node
+		}
+	}
+
+	return owner
+}
+
+// isSubPath checks whether path is the same as parent or is inside of it
+func isSubPath(parent, path string) bool {
+	rel, err := filepath.Rel(parent, path)
+	if err != nil {
+		return false
+	}
+
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,92 @@
+package terradep_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+var sharedModuleFiles = terradeptest.Files{
+	"live/network/main.tf": `
+terraform {
+  backend "s3" {
+    bucket = "b"
+    key    = "network"
+  }
+}
+
+module "vpc" {
+  source = "../../modules/vpc"
+}
+`,
+	"live/app/main.tf": `
+terraform {
+  backend "s3" {
+    bucket = "b"
+    key    = "app"
+  }
+}
+
+module "service" {
+  source = "../../modules/service"
+}
+`,
+	"modules/vpc/main.tf": `
+terraform {
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+`,
+	// service does not have terraform block and calls other module without backend
+	"modules/service/main.tf": `
+module "sg" {
+  source = "../sg"
+}
+`,
+	"modules/sg/main.tf": `
+resource "null_resource" "sg" {}
+`,
+}
+
+func TestScanTreatsCalledDirsWithoutBackendAsModules(t *testing.T) {
+	for _, opts := range []struct {
+		name string
+		opts []terradep.ScannerOpt
+	}{
+		{name: "module edges", opts: []terradep.ScannerOpt{terradep.WithModuleEdges()}},
+		{name: "module edges concurrently", opts: []terradep.ScannerOpt{terradep.WithModuleEdges(), terradep.WithIOConcurrency(8)}},
+		{name: "follow modules", opts: []terradep.ScannerOpt{terradep.WithModuleEdges(), terradep.WithFollowModules()}},
+	} {
+		t.Run(opts.name, func(t *testing.T) {
+			dir := terradeptest.Dir(t, sharedModuleFiles)
+			graph := terradeptest.Scan(t, dir, opts.opts...)
+			terradeptest.AssertGolden(t, graph, "testdata/shared-modules.golden")
+		})
+	}
+}
+
+func TestScanFailsOnDirWithoutBackendNotCalledByDeployments(t *testing.T) {
+	files := terradeptest.Files{"orphan/main.tf": `resource "null_resource" "x" {}`}
+	for name, file := range sharedModuleFiles {
+		files[name] = file
+	}
+	dir := terradeptest.Dir(t, files)
+
+	_, _, err := terradep.NewScanner(nil, terradeptest.NewStater(), terradep.WithRelativePaths()).Scan(dir)
+	if !errors.Is(err, terradep.ErrNoBackend) {
+		t.Fatalf("expected error: %v, got: %v", terradep.ErrNoBackend, err)
+	}
+
+	_, diags, err := terradep.NewScanner(nil, terradeptest.NewStater(), terradep.WithContinueOnError()).Scan(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Rule != terradep.RuleModuleError {
+		t.Errorf("expected single diagnostic of the orphan module, got: %v", diags)
+	}
+}
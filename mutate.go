@@ -0,0 +1,373 @@
+package terradep
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// AddNode adds the deployment to the graph, the zero [Graph] is empty graph ready to use. The node must have Path and State and no edges,
+// dependencies are added with [Graph.AddEdge]. Owner, Layer, Tags, CodeOwners, Metadata and Git of the node are kept and encoded with the graph.
+// When the graph has an external state equal to the state of the node, the node replaces it, so the dependents depend on the added deployment.
+// Returns error when the deployment is already in the graph or [*DuplicateStateError] when other deployment has the same state
+func (g *Graph) AddNode(n *Node) error {
+	switch {
+	case g.readOnly:
+		return ErrReadOnlyGraph
+	case n == nil:
+		return errors.New("node is nil")
+	case n.Path == "":
+		return errors.New("node does not have path")
+	case n.State == nil:
+		return fmt.Errorf("node does not have state: %s", n.Path)
+	case len(n.Children) != 0 || len(n.Modules) != 0:
+		return fmt.Errorf("node must not have children nor modules, they are added with AddEdge: %s", n.Path)
+	}
+	if _, ok := n.State.(LocalModule); ok {
+		return fmt.Errorf("local module cannot be added as deployment: %s", n.Path)
+	}
+
+	g.init()
+	dep := deploymentOf(n)
+	if _, ok := g.states[dep]; ok {
+		return fmt.Errorf("deployment is already in the graph: %s, workspace: %q, overlay: %q", n.Path, n.Workspace, n.Overlay)
+	}
+
+	nodes := g.Nodes()
+	var external *Node
+	for _, existing := range nodes {
+		if existing.State.String() != n.State.String() {
+			continue
+		}
+		if g.IsDeployment(existing) {
+			paths := []string{existing.Path, n.Path}
+			sort.Strings(paths)
+			return &DuplicateStateError{State: n.State, Paths: paths}
+		}
+		external = existing
+	}
+
+	g.states[dep] = n.State
+	if _, ok := g.details[n.Path]; !ok {
+		g.details[n.Path] = detailsOf(n)
+	}
+
+	if external != nil {
+		for _, dependent := range g.dependents()[external] {
+			replaceNode(dependent.Children, external, n)
+			sortNodes(dependent.Children)
+		}
+	}
+
+	g.relink(append(g.deployments(nodes), n))
+	return nil
+}
+
+// AddEdge adds dependency of the deployment from on the state of the node to. When to is not in the graph, it is added as an external state
+// and its Path defaults to the string representation of the state. Adding existing edge does nothing.
+// Returns error when from is not a deployment of the graph, when other node of the graph has the state of to,
+// or [*CycleError] when to depends on from, directly or transitively
+func (g *Graph) AddEdge(from, to *Node) error {
+	switch {
+	case g.readOnly:
+		return ErrReadOnlyGraph
+	case from == nil || to == nil:
+		return errors.New("node is nil")
+	case to.State == nil:
+		return fmt.Errorf("node does not have state: %s", to.Path)
+	case !g.contains(from) || !g.IsDeployment(from):
+		return fmt.Errorf("node is not a deployment of the graph: %s, workspace: %q, overlay: %q", from.Path, from.Workspace, from.Overlay)
+	}
+	if _, ok := to.State.(LocalModule); ok {
+		return fmt.Errorf("deployment cannot depend on the state of local module: %s", to.Path)
+	}
+
+	for _, child := range from.Children {
+		if child == to {
+			return nil
+		}
+	}
+
+	nodes := g.Nodes()
+	if !g.contains(to) {
+		if len(to.Children) != 0 || len(to.Modules) != 0 {
+			return fmt.Errorf("node not in the graph must not have children nor modules: %s", to.Path)
+		}
+		if g.IsDeployment(to) {
+			return fmt.Errorf("node is a copy of the deployment of the graph: %s, workspace: %q, overlay: %q", to.Path, to.Workspace, to.Overlay)
+		}
+		for _, existing := range nodes {
+			if existing.State.String() == to.State.String() {
+				return fmt.Errorf("other node of the graph has the same state: %s, path: %s", to.State, existing.Path)
+			}
+		}
+		if to.Path == "" {
+			to.Path = to.State.String()
+		}
+	}
+
+	if cycle := dependencyPath(to, from); cycle != nil {
+		err := &CycleError{}
+		for _, n := range append([]*Node{from}, cycle...) {
+			err.States = append(err.States, n.State)
+			err.Paths = append(err.Paths, n.Path)
+		}
+		return err
+	}
+
+	dep := deploymentOf(from)
+	g.deps[dep] = append(g.deps[dep], to.State)
+	from.Children = append(from.Children, to)
+	sortNodes(from.Children)
+
+	g.relink(g.deployments(nodes))
+	return nil
+}
+
+// RemoveNode removes the node and all the edges from and to it. External states and local modules left without dependents are removed too,
+// deployments left without dependents become [Graph.Heads]. Returns error when the node is not in the graph
+func (g *Graph) RemoveNode(n *Node) error {
+	switch {
+	case g.readOnly:
+		return ErrReadOnlyGraph
+	case n == nil:
+		return errors.New("node is nil")
+	}
+	if !g.contains(n) {
+		return fmt.Errorf("node is not in the graph: %s, workspace: %q, overlay: %q", n.Path, n.Workspace, n.Overlay)
+	}
+
+	nodes := g.Nodes()
+	for _, dependent := range g.dependents()[n] {
+		dep := deploymentOf(dependent)
+		if removed := removeNode(&dependent.Children, n); removed {
+			g.deps[dep] = removeState(g.deps[dep], n.State)
+		}
+		if removed := removeNode(&dependent.Modules, n); removed {
+			// calls resolved to the node are the calls inside its directory
+			var calls []string
+			for _, call := range g.modules[dep] {
+				if !isSubPath(n.Path, call) {
+					calls = append(calls, call)
+				}
+			}
+			g.modules[dep] = calls
+		}
+	}
+
+	deployments := g.deployments(nodes)
+	if g.IsDeployment(n) {
+		dep := deploymentOf(n)
+		delete(g.states, dep)
+		delete(g.deps, dep)
+		delete(g.modules, dep)
+		if !g.hasPath(n.Path) {
+			delete(g.details, n.Path)
+		}
+		removeNode(&deployments, n)
+	}
+
+	g.relink(deployments)
+	return nil
+}
+
+// Clone returns deep copy of the graph which can be changed, e.g. with [Graph.AddNode], without affecting the graph and its readers.
+// Nodes are copied, Tags, CodeOwners, Metadata, Git, BackendRange and Edges of the nodes are shared and must not be changed
+func (g *Graph) Clone() *Graph {
+	clone := &Graph{}
+	clone.init()
+	for dep, state := range g.states {
+		clone.states[dep] = state
+	}
+	for dep, states := range g.deps {
+		clone.deps[dep] = append([]State(nil), states...)
+	}
+	for dep, calls := range g.modules {
+		clone.modules[dep] = append([]string(nil), calls...)
+	}
+	for path, d := range g.details {
+		clone.details[path] = d
+	}
+
+	nodes := g.Nodes()
+	copies := make(map[*Node]*Node, len(nodes))
+	for _, n := range nodes {
+		c := *n
+		copies[n] = &c
+	}
+	for _, c := range copies {
+		c.Parent = copies[c.Parent]
+		c.Children = copyNodes(c.Children, copies)
+		c.Modules = copyNodes(c.Modules, copies)
+	}
+	clone.Heads = copyNodes(g.Heads, copies)
+	if clone.Heads == nil {
+		clone.Heads = make([]*Node, 0)
+	}
+
+	return clone
+}
+
+// copyNodes returns the copies of the nodes, nil for empty slice
+func copyNodes(nodes []*Node, copies map[*Node]*Node) []*Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	out := make([]*Node, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, copies[n])
+	}
+
+	return out
+}
+
+// init makes the zero graph ready for adding nodes
+func (g *Graph) init() {
+	if g.states == nil {
+		g.states = make(map[deployment]State)
+	}
+	if g.deps == nil {
+		g.deps = make(map[deployment][]State)
+	}
+	if g.modules == nil {
+		g.modules = make(map[deployment][]string)
+	}
+	if g.details == nil {
+		g.details = make(map[string]*nodeDetails)
+	}
+}
+
+// contains checks whether the node is reachable from [Graph.Heads]
+func (g *Graph) contains(n *Node) bool {
+	found := false
+	_ = g.Walk(func(visited *Node) error {
+		if visited == n {
+			found = true
+			return errors.New("found")
+		}
+		return nil
+	})
+
+	return found
+}
+
+// hasPath checks whether any deployment of the graph, in any workspace or overlay, has the path
+func (g *Graph) hasPath(path string) bool {
+	for dep := range g.states {
+		if dep.path == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deployments returns the nodes which are deployments of the graph
+func (g *Graph) deployments(nodes []*Node) []*Node {
+	var out []*Node
+	for _, n := range nodes {
+		if g.IsDeployment(n) {
+			out = append(out, n)
+		}
+	}
+
+	return out
+}
+
+// relink sets [Node.Parent] of the nodes reachable from the deployments and [Graph.Heads] to the deployments without dependents, like buildTree
+func (g *Graph) relink(deployments []*Node) {
+	sortNodes(deployments)
+	for _, n := range deployments {
+		n.Parent = nil
+		for _, child := range n.Children {
+			child.Parent = nil
+		}
+	}
+
+	for _, n := range deployments {
+		for _, child := range n.Children {
+			child.Parent = n
+		}
+	}
+
+	g.Heads = make([]*Node, 0)
+	for _, n := range deployments {
+		if n.Parent == nil {
+			g.Heads = append(g.Heads, n)
+		}
+	}
+}
+
+// dependencyPath returns the nodes on the path from the node to the target through [Node.Children], excluding the node,
+// or nil when the node does not depend on the target
+func dependencyPath(from, target *Node) []*Node {
+	prev := map[*Node]*Node{from: nil}
+	queue := []*Node{from}
+	for len(queue) != 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n == target {
+			var path []*Node
+			for ; n != nil; n = prev[n] {
+				path = append([]*Node{n}, path...)
+			}
+			return path
+		}
+		for _, child := range n.Children {
+			if _, ok := prev[child]; !ok {
+				prev[child] = n
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	return nil
+}
+
+func deploymentOf(n *Node) deployment {
+	return deployment{path: n.Path, workspace: n.Workspace, overlay: n.Overlay}
+}
+
+// detailsOf returns details of the node, so they are restored when the graph is encoded and decoded
+func detailsOf(n *Node) *nodeDetails {
+	d := &nodeDetails{codeOwners: n.CodeOwners, metadata: n.Metadata, git: n.Git, backendRange: n.BackendRange, edges: n.Edges}
+	if n.Owner != "" || n.Layer != "" || len(n.Tags) != 0 {
+		d.manifest = &Manifest{Owner: n.Owner, Layer: n.Layer, Tags: n.Tags}
+	}
+
+	return d
+}
+
+func replaceNode(nodes []*Node, old, replacement *Node) {
+	for i, n := range nodes {
+		if n == old {
+			nodes[i] = replacement
+		}
+	}
+}
+
+// removeNode removes the node from the slice, reports whether it was there
+func removeNode(nodes *[]*Node, node *Node) bool {
+	out := (*nodes)[:0]
+	for _, n := range *nodes {
+		if n != node {
+			out = append(out, n)
+		}
+	}
+	removed := len(out) != len(*nodes)
+	*nodes = out
+
+	return removed
+}
+
+func removeState(states []State, state State) []State {
+	var out []State
+	for _, s := range states {
+		if s.String() != state.String() {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,61 @@
+package terradep
+
+import "sort"
+
+// less orders deployments by path, overlay and then by workspace
+func (d deployment) less(other deployment) bool {
+	if d.path != other.path {
+		return d.path < other.path
+	}
+
+	if d.overlay != other.overlay {
+		return d.overlay < other.overlay
+	}
+
+	return d.workspace < other.workspace
+}
+
+// sortedDeployments returns keys of the map in order defined by [deployment.less], so the iteration does not depend on the map
+func sortedDeployments[V any](m map[deployment]V) []deployment {
+	out := make([]deployment, 0, len(m))
+	for dep := range m {
+		out = append(out, dep)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].less(out[j])
+	})
+
+	return out
+}
+
+// sortNodes orders nodes by path, overlay, workspace and state
+func sortNodes(nodes []*Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+
+		if a.Overlay != b.Overlay {
+			return a.Overlay < b.Overlay
+		}
+
+		if a.Workspace != b.Workspace {
+			return a.Workspace < b.Workspace
+		}
+
+		return a.State.String() < b.State.String()
+	})
+}
+
+// sortedKeys returns keys of the map in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for key := range m {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+
+	return out
+}
@@ -0,0 +1,178 @@
+package terradep
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep/inspect"
+)
+
+// AllOutputs is the output consumed from the state, see [EdgeMetadata], when the outputs are referenced as a whole,
+// e.g. assigned to the local value, so it is not known which of them are used
+const AllOutputs = "*"
+
+// remoteStateUse describes references to terraform_remote_state data source in the module
+type remoteStateUse struct {
+	// outputs are names of the referenced outputs, [AllOutputs] when the data source is referenced as a whole
+	outputs map[string]struct{}
+}
+
+// sortedOutputs returns names of the referenced outputs
+func (u *remoteStateUse) sortedOutputs() []string {
+	if u == nil {
+		return nil
+	}
+
+	return sortedKeys(u.outputs)
+}
+
+// findConsumedOutputs returns references to terraform_remote_state data sources in the module dir by the name of the data source.
+// Data sources which are not referenced are not returned. Returns nil map when the references cannot be found, e.g. in files with JSON syntax
+func findConsumedOutputs(parser *inspect.Parser, dir string) (map[string]*remoteStateUse, error) {
+	files, diags := inspect.DirFiles(parser.FS(), dir)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("listing files in dir: %s, %w", dir, diags)
+	}
+
+	uses := make(map[string]*remoteStateUse)
+	for _, filename := range files {
+		file, diags := parser.ParseFile(filename)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			if bytes.Contains(file.Bytes, []byte(terraformRemoteState)) {
+				return nil, nil
+			}
+			// file was not parsed, because it does not reference any state
+			continue
+		}
+
+		_ = hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+			if attr, ok := node.(*hclsyntax.Attribute); ok {
+				for _, traversal := range attr.Expr.Variables() {
+					addOutputReference(uses, traversal)
+				}
+			}
+			return nil
+		})
+	}
+
+	return uses, nil
+}
+
+// addOutputReference records the traversal, when it references terraform_remote_state, e.g. data.terraform_remote_state.net.outputs.vpc_id.
+// Index of the data source with count or for_each and index of the output, e.g. outputs["vpc_id"], are supported
+func addOutputReference(uses map[string]*remoteStateUse, traversal hcl.Traversal) {
+	if len(traversal) < 3 || traversal.RootName() != "data" || attrName(traversal[1]) != terraformRemoteState {
+		return
+	}
+	name := attrName(traversal[2])
+	if name == "" {
+		return
+	}
+
+	use, ok := uses[name]
+	if !ok {
+		use = &remoteStateUse{outputs: make(map[string]struct{})}
+		uses[name] = use
+	}
+
+	rest := traversal[3:]
+	if len(rest) != 0 {
+		if _, ok := rest[0].(hcl.TraverseIndex); ok {
+			rest = rest[1:]
+		}
+	}
+	if len(rest) < 2 || attrName(rest[0]) != "outputs" {
+		use.outputs[AllOutputs] = struct{}{}
+		return
+	}
+
+	output := attrName(rest[1])
+	if index, ok := rest[1].(hcl.TraverseIndex); ok && index.Key.Type() == cty.String && index.Key.IsKnown() && !index.Key.IsNull() {
+		output = index.Key.AsString()
+	}
+	if output == "" {
+		output = AllOutputs
+	}
+	use.outputs[output] = struct{}{}
+}
+
+// attrName returns name of the attribute traversed by the step, empty string when the step is not an attribute
+func attrName(step hcl.Traverser) string {
+	switch s := step.(type) {
+	case hcl.TraverseRoot:
+		return s.Name
+	case hcl.TraverseAttr:
+		return s.Name
+	default:
+		return ""
+	}
+}
+
+// mergeOutputs returns sorted names of outputs from both lists, every name once
+func mergeOutputs(known, added []string) []string {
+	if len(added) == 0 {
+		return known
+	}
+
+	set := make(map[string]struct{}, len(known)+len(added))
+	for _, o := range known {
+		set[o] = struct{}{}
+	}
+	for _, o := range added {
+		set[o] = struct{}{}
+	}
+
+	return sortedKeys(set)
+}
+
+// checkUnusedRemoteStates reports terraform_remote_state data sources of the module which are never referenced ([RuleUnusedRemoteState]),
+// so the dependencies can be removed. Data sources of the local modules called by the module are not checked
+func (s *Scanner) checkUnusedRemoteStates(sc *scan, module *tfconfig.Module) error {
+	if !hasRemoteStates(module) {
+		return nil
+	}
+
+	uses, err := findConsumedOutputs(sc.parser, module.Path)
+	if err != nil || uses == nil {
+		return err
+	}
+
+	blocks, err := findRemoteStateBlocks(sc.parser, module.Path)
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		if _, ok := uses[block.Name]; ok {
+			continue
+		}
+		s.warn(sc, Diagnostic{
+			Rule:    RuleUnusedRemoteState,
+			Summary: fmt.Sprintf("terraform_remote_state %q is never referenced", block.Name),
+			Detail:  "outputs of the state are not used, the dependency can be removed",
+			Module:  module.Path,
+			Range:   rangePtr(block.Range),
+		})
+	}
+
+	return nil
+}
+
+func hasRemoteStates(module *tfconfig.Module) bool {
+	for _, resource := range module.DataResources {
+		if resource.Type == terraformRemoteState {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,258 @@
+package terradep
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Overlay deploys the module directory with different backend configuration, e.g. one per environment.
+// Overlays are declared in the [Manifest], every overlay becomes separate [Node] with [Node.Overlay] set. Example of terradep.hcl:
+//
+//	overlay "prod" {
+//	  backend_config_files = ["env/prod.s3.tfbackend"]
+//	  backend_config       = { key = "app/prod.tfstate" }
+//	  variables            = { env = "prod" }
+//	}
+type Overlay struct {
+	Name string `hcl:"name,label" yaml:"name"`
+	// BackendConfigFiles are paths relative to the deployment directory, like -backend-config=path passed to terraform init
+	BackendConfigFiles []string `hcl:"backend_config_files,optional" yaml:"backend_config_files"`
+	// BackendConfig overrides attributes of the backend block and BackendConfigFiles, like -backend-config=key=value passed to terraform init
+	BackendConfig map[string]string `hcl:"backend_config,optional" yaml:"backend_config"`
+	// Variables are available in expressions as var.name, so terraform_remote_state can be resolved differently for every overlay
+	Variables map[string]string `hcl:"variables,optional" yaml:"variables"`
+}
+
+// instance is a module deployed with the overlay to the workspace, it is used to evaluate expressions in the module
+type instance struct {
+	// path of the deployment, code referencing the states in the modules it calls is recorded in its [EdgeMetadata]
+	path      string
+	workspace string
+	// overlay is nil, when module does not declare any overlays
+	overlay *Overlay
+}
+
+// overlayName returns name of the overlay or empty string for nil overlay
+func overlayName(o *Overlay) string {
+	if o == nil {
+		return ""
+	}
+
+	return o.Name
+}
+
+// overlaysOf returns overlays declared in the manifest of the module or single nil overlay, when there are none
+func (sc *scan) overlaysOf(path string) []*Overlay {
+	d, ok := sc.details[path]
+	if !ok || d.manifest == nil || len(d.manifest.Overlays) == 0 {
+		return []*Overlay{nil}
+	}
+
+	out := make([]*Overlay, 0, len(d.manifest.Overlays))
+	for i := range d.manifest.Overlays {
+		out = append(out, &d.manifest.Overlays[i])
+	}
+
+	return out
+}
+
+// validateOverlays checks that names of the overlays are not empty and unique
+func validateOverlays(overlays []Overlay) error {
+	names := make(map[string]struct{}, len(overlays))
+	for _, o := range overlays {
+		if o.Name == "" {
+			return fmt.Errorf("overlay must have a name")
+		}
+
+		if _, ok := names[o.Name]; ok {
+			return fmt.Errorf("duplicate overlay: %q", o.Name)
+		}
+		names[o.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+// variables returns value of var used in expressions, it is null when the overlay does not declare any variables
+func (o *Overlay) variables() cty.Value {
+	if o == nil || len(o.Variables) == 0 {
+		return cty.NilVal
+	}
+
+	vars := make(map[string]cty.Value, len(o.Variables))
+	for name, value := range o.Variables {
+		vars[name] = cty.StringVal(value)
+	}
+
+	return cty.ObjectVal(vars)
+}
+
+// backendBody returns body of the backend block of the module in dir with attributes replaced by the overlay
+func (o *Overlay) backendBody(dir string, body hcl.Body) (hcl.Body, error) {
+	if o == nil {
+		return body, nil
+	}
+
+	attrs := map[string]cty.Value{}
+	for _, file := range o.BackendConfigFiles {
+		values, err := readBackendConfigFile(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, fmt.Errorf("overlay: %q, %w", o.Name, err)
+		}
+
+		for name, value := range values {
+			attrs[name] = value
+		}
+	}
+
+	for name, value := range o.BackendConfig {
+		// values are strings, they are converted to the types of backend attributes when decoded
+		attrs[name] = cty.StringVal(value)
+	}
+
+	return &overrideBody{base: body, attrs: attrs, rng: body.MissingItemRange()}, nil
+}
+
+// readBackendConfigFile reads values of the attributes from the file with [backend configuration]
+//
+// [backend configuration]: https://developer.hashicorp.com/terraform/language/settings/backends/configuration#file
+func readBackendConfigFile(path string) (map[string]cty.Value, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading backend config file: %s, %w", path, err)
+	}
+
+	file, diags := hclparse.NewParser().ParseHCL(b, path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	out := make(map[string]cty.Value, len(attrs))
+	for name, attr := range attrs {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		out[name] = value
+	}
+
+	return out, nil
+}
+
+// overrideBody is a hcl.Body with attributes replaced with static values, the same way as partial backend configuration does
+type overrideBody struct {
+	base  hcl.Body
+	attrs map[string]cty.Value
+	// rng is used for attributes of the overlay, they are not defined in the body
+	rng hcl.Range
+}
+
+// Content implements hcl.Body
+func (b *overrideBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	content, diags := b.base.Content(optionalAttributes(schema))
+	content, diags = b.override(schema, content, diags)
+
+	known := make(map[string]struct{}, len(schema.Attributes))
+	for _, attr := range schema.Attributes {
+		known[attr.Name] = struct{}{}
+	}
+
+	for _, name := range sortedKeys(b.attrs) {
+		if _, ok := known[name]; !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unsupported argument",
+				Detail:   fmt.Sprintf("An argument named %q is not expected here.", name),
+				Subject:  b.rng.Ptr(),
+			})
+		}
+	}
+
+	return content, diags
+}
+
+// PartialContent implements hcl.Body
+func (b *overrideBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	content, remain, diags := b.base.PartialContent(optionalAttributes(schema))
+	content, diags = b.override(schema, content, diags)
+
+	remainAttrs := make(map[string]cty.Value)
+	for name, value := range b.attrs {
+		if _, ok := content.Attributes[name]; !ok {
+			remainAttrs[name] = value
+		}
+	}
+
+	return content, &overrideBody{base: remain, attrs: remainAttrs, rng: b.rng}, diags
+}
+
+// JustAttributes implements hcl.Body
+func (b *overrideBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	attrs, diags := b.base.JustAttributes()
+	if attrs == nil {
+		attrs = hcl.Attributes{}
+	}
+
+	for name, value := range b.attrs {
+		attrs[name] = b.attribute(name, value)
+	}
+
+	return attrs, diags
+}
+
+// MissingItemRange implements hcl.Body
+func (b *overrideBody) MissingItemRange() hcl.Range {
+	return b.base.MissingItemRange()
+}
+
+// override replaces attributes of the content with attributes of the body and checks required attributes
+func (b *overrideBody) override(schema *hcl.BodySchema, content *hcl.BodyContent, diags hcl.Diagnostics) (*hcl.BodyContent, hcl.Diagnostics) {
+	if content == nil {
+		content = &hcl.BodyContent{}
+	}
+	if content.Attributes == nil {
+		content.Attributes = hcl.Attributes{}
+	}
+
+	for _, attr := range schema.Attributes {
+		if value, ok := b.attrs[attr.Name]; ok {
+			content.Attributes[attr.Name] = b.attribute(attr.Name, value)
+		}
+
+		if _, ok := content.Attributes[attr.Name]; attr.Required && !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Missing required argument",
+				Detail:   fmt.Sprintf("The argument %q is required, but no definition was found.", attr.Name),
+				Subject:  b.rng.Ptr(),
+			})
+		}
+	}
+
+	return content, diags
+}
+
+func (b *overrideBody) attribute(name string, value cty.Value) *hcl.Attribute {
+	return &hcl.Attribute{Name: name, Expr: hcl.StaticExpr(value, b.rng), Range: b.rng, NameRange: b.rng}
+}
+
+// optionalAttributes returns copy of the schema with all attributes optional, so missing attributes can be provided by the [overrideBody]
+func optionalAttributes(schema *hcl.BodySchema) *hcl.BodySchema {
+	out := &hcl.BodySchema{Blocks: schema.Blocks}
+	for _, attr := range schema.Attributes {
+		attr.Required = false
+		out.Attributes = append(out.Attributes, attr)
+	}
+
+	return out
+}
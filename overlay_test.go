@@ -0,0 +1,201 @@
+package terradep
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// overlayBackendSchema is the schema of the backend block with required bucket and key
+var overlayBackendSchema = &hcl.BodySchema{Attributes: []hcl.AttributeSchema{
+	{Name: "bucket", Required: true},
+	{Name: "key", Required: true},
+	{Name: "region"},
+}}
+
+func parseBody(t *testing.T, src string) hcl.Body {
+	t.Helper()
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(src), "backend.tf")
+	if diags.HasErrors() {
+		t.Fatalf("parsing body: %v", diags)
+	}
+
+	return file.Body
+}
+
+// attributeValues evaluates the attributes, they must not reference any variables
+func attributeValues(t *testing.T, attrs hcl.Attributes) map[string]string {
+	t.Helper()
+
+	out := make(map[string]string, len(attrs))
+	for name, attr := range attrs {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			t.Fatalf("evaluating attribute: %s, %v", name, diags)
+		}
+		out[name] = value.AsString()
+	}
+
+	return out
+}
+
+func TestOverlayBackendBody(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"env/prod.tfbackend":   "key = \"prod\"\nregion = \"eu-west-1\"\n",
+		"env/region.tfbackend": "region = \"us-east-1\"\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("creating directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("writing file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		overlay *Overlay
+		body    string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "nil overlay keeps the body",
+			body: `bucket = "b"
+key = "app"`,
+			want: map[string]string{"bucket": "b", "key": "app"},
+		},
+		{
+			name:    "files override the body, later files override earlier",
+			overlay: &Overlay{Name: "prod", BackendConfigFiles: []string{"env/prod.tfbackend", "env/region.tfbackend"}},
+			body: `bucket = "b"
+key = "app"
+region = "eu-central-1"`,
+			want: map[string]string{"bucket": "b", "key": "prod", "region": "us-east-1"},
+		},
+		{
+			name:    "backend config overrides the files",
+			overlay: &Overlay{Name: "prod", BackendConfigFiles: []string{"env/prod.tfbackend"}, BackendConfig: map[string]string{"key": "override"}},
+			body:    `bucket = "b"`,
+			want:    map[string]string{"bucket": "b", "key": "override", "region": "eu-west-1"},
+		},
+		{
+			name:    "required attribute provided only by the overlay",
+			overlay: &Overlay{Name: "prod", BackendConfig: map[string]string{"bucket": "b", "key": "app"}},
+			want:    map[string]string{"bucket": "b", "key": "app"},
+		},
+		{
+			name:    "required attribute missing",
+			overlay: &Overlay{Name: "prod", BackendConfig: map[string]string{"bucket": "b"}},
+			wantErr: `The argument "key" is required`,
+		},
+		{
+			name:    "unsupported attribute of the overlay",
+			overlay: &Overlay{Name: "prod", BackendConfig: map[string]string{"bucket": "b", "key": "app", "table": "locks"}},
+			wantErr: `An argument named "table" is not expected here`,
+		},
+		{
+			name:    "missing file",
+			overlay: &Overlay{Name: "prod", BackendConfigFiles: []string{"env/dev.tfbackend"}},
+			wantErr: `overlay: "prod", reading backend config file`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := tt.overlay.backendBody(dir, parseBody(t, tt.body))
+			if err == nil {
+				var content *hcl.BodyContent
+				var diags hcl.Diagnostics
+				content, diags = body.Content(overlayBackendSchema)
+				if diags.HasErrors() {
+					err = diags
+				} else if got := attributeValues(t, content.Attributes); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("attributes: %v, want: %v", got, tt.want)
+				}
+			}
+
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("expected error containing: %s, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestOverrideBodyPartialContentAndJustAttributes(t *testing.T) {
+	body := &overrideBody{
+		base:  parseBody(t, "bucket = \"b\"\nkey = \"app\"\ndynamodb_table = \"locks\"\n"),
+		attrs: map[string]cty.Value{"key": cty.StringVal("prod"), "profile": cty.StringVal("deploy")},
+	}
+
+	content, remain, diags := body.PartialContent(overlayBackendSchema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if got, want := attributeValues(t, content.Attributes), map[string]string{"bucket": "b", "key": "prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("content: %v, want: %v", got, want)
+	}
+
+	attrs, diags := remain.JustAttributes()
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if got, want := attributeValues(t, attrs), map[string]string{"dynamodb_table": "locks", "profile": "deploy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("remaining attributes: %v, want: %v", got, want)
+	}
+}
+
+func TestReadBackendConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "attributes", content: "bucket = \"b\"\nkey = \"app/${\"prod\"}\"\n", want: map[string]string{"bucket": "b", "key": "app/prod"}},
+		{name: "empty file", content: "", want: map[string]string{}},
+		{name: "invalid syntax", content: "bucket = \n", wantErr: "Invalid expression"},
+		{name: "block", content: "assume_role {\n}\n", wantErr: "Unexpected \"assume_role\" block"},
+		{name: "reference", content: "key = var.key\n", wantErr: "Variables not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "backend.tfbackend")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("writing file: %v", err)
+			}
+
+			values, err := readBackendConfigFile(path)
+			switch {
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing: %s, got: %v", tt.wantErr, err)
+				}
+			case err != nil:
+				t.Errorf("unexpected error: %v", err)
+			default:
+				got := make(map[string]string, len(values))
+				for name, value := range values {
+					got[name] = value.AsString()
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("values: %v, want: %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
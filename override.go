@@ -0,0 +1,82 @@
+package terradep
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"go.interactor.dev/terradep/inspect"
+)
+
+const terraformRemoteState = "terraform_remote_state"
+
+// remoteStateBlock is block terraform_remote_state with the overrides already applied
+type remoteStateBlock struct {
+	Name string
+	// Range points to the definition of the primary block
+	Range hcl.Range
+	Attrs hcl.Attributes
+}
+
+// findRemoteStateBlocks returns blocks terraform_remote_state defined in the module dir, parsing the files with the parser.
+// Blocks from the override files are merged into the primary blocks following the [override rules] of Terraform:
+// every attribute of the override block replaces the attribute with the same name of the primary block
+//
+// [override rules]: https://developer.hashicorp.com/terraform/language/files/override#merging-resource-and-data-blocks
+func findRemoteStateBlocks(parser *inspect.Parser, dir string) ([]*remoteStateBlock, error) {
+	// files are returned in the order in which Terraform applies them: primary files first, then overrides
+	files, diags := inspect.DirFiles(parser.FS(), dir)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("listing files in dir: %s, %w", dir, diags)
+	}
+
+	var out []*remoteStateBlock
+	byName := map[string]*remoteStateBlock{}
+	for _, filename := range files {
+		file, diags := parser.ParseFile(filename)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		content, _, diags := file.Body.PartialContent(backendSchema)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		for _, block := range content.Blocks {
+			if block.Labels[0] != terraformRemoteState {
+				continue
+			}
+
+			name := block.Labels[1]
+			if len(name) == 0 {
+				return nil, fmt.Errorf("block %q does not have the name: %s", terraformRemoteState, block.DefRange)
+			}
+
+			attrs, diags := block.Body.JustAttributes()
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("reading attributes of %s %q, %w", terraformRemoteState, name, diags)
+			}
+
+			primary, ok := byName[name]
+			if !inspect.IsOverrideFile(filename) {
+				if ok {
+					return nil, fmt.Errorf("duplicate %s %q: %s and %s", terraformRemoteState, name, primary.Range, block.DefRange)
+				}
+
+				rs := &remoteStateBlock{Name: name, Range: block.DefRange, Attrs: attrs}
+				byName[name] = rs
+				out = append(out, rs)
+				continue
+			}
+
+			if !ok {
+				return nil, fmt.Errorf("override of %s %q does not have the primary block: %s", terraformRemoteState, name, block.DefRange)
+			}
+			for attrName, attr := range attrs {
+				primary.Attrs[attrName] = attr
+			}
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package terradep
+
+// WithRelativePaths makes the [Scanner] set [Node.Path] and paths of [LocalModule] relative to the scanned root, using forward slashes.
+// It makes the graph the same, no matter where the repository was checked out and on which operating system it was scanned.
+// When not set, paths start with the root passed to [Scanner.Scan] and use separator of the operating system
+func WithRelativePaths() ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.relativePaths = true
+	}
+}
+
+// relativize replaces paths in results of the scan with paths relative to the scanned root
+func (sc *scan) relativize() {
+	rel := func(path string) string {
+		return relativePath(sc.root, path)
+	}
+	relDep := func(dep deployment) deployment {
+		return deployment{path: rel(dep.path), workspace: dep.workspace, overlay: dep.overlay}
+	}
+
+	states := make(map[deployment]State, len(sc.states))
+	for dep, state := range sc.states {
+		states[relDep(dep)] = state
+	}
+
+	deps := make(map[deployment][]State, len(sc.deps))
+	for dep, states := range sc.deps {
+		deps[relDep(dep)] = states
+	}
+
+	modules := make(map[deployment][]string, len(sc.modules))
+	for dep, calls := range sc.modules {
+		relCalls := make([]string, 0, len(calls))
+		for _, call := range calls {
+			relCalls = append(relCalls, rel(call))
+		}
+		modules[relDep(dep)] = relCalls
+	}
+
+	details := make(map[string]*nodeDetails, len(sc.details))
+	for path, d := range sc.details {
+		details[rel(path)] = d
+	}
+
+	sc.states, sc.deps, sc.modules, sc.details = states, deps, modules, details
+}
@@ -0,0 +1,234 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/encoding"
+	"go.interactor.dev/terradep/plugin/pluginpb"
+)
+
+// handshakeTimeout limits how long [Start] waits for the plugin, so executable which is not a plugin does not block the cli
+const handshakeTimeout = 10 * time.Second
+
+// Client is the plugin started by [Start]. It implements [terradep.Stater] of the backends of the plugin and is safe for concurrent use
+type Client struct {
+	path      string
+	client    *goplugin.Client
+	rpc       pluginpb.PluginClient
+	backends  []string
+	formats   []string
+	decorates bool
+}
+
+// ClientOpt changes how the plugin is started by [Start]
+type ClientOpt func(cfg *clientCfg)
+
+type clientCfg struct {
+	stderr io.Writer
+	args   []string
+}
+
+// WithStderr sets where standard error of the plugin is written, defaults to standard error of the process
+func WithStderr(w io.Writer) ClientOpt {
+	return func(cfg *clientCfg) {
+		cfg.stderr = w
+	}
+}
+
+// WithArgs sets the arguments the plugin is started with
+func WithArgs(args ...string) ClientOpt {
+	return func(cfg *clientCfg) {
+		cfg.args = args
+	}
+}
+
+// Start starts the executable at the path as the plugin with HashiCorp go-plugin and checks whether it serves the same [ProtocolVersion].
+// The plugin must be stopped with [Client.Close]
+func Start(path string, opts ...ClientOpt) (*Client, error) {
+	cfg := &clientCfg{stderr: os.Stderr}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	client := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  handshake,
+		Plugins:          goplugin.PluginSet{pluginName: &grpcPlugin{}},
+		Cmd:              exec.Command(path, cfg.args...),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC},
+		StartTimeout:     handshakeTimeout,
+		// standard error of the plugin is redirected by go-plugin, writes of the plugin to it are synced over gRPC
+		Stderr:     cfg.stderr,
+		SyncStderr: cfg.stderr,
+		Logger:     hclog.NewNullLogger(),
+	})
+
+	c := &Client{path: path, client: client}
+	if err := c.handshake(); err != nil {
+		client.Kill()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Client) handshake() error {
+	protocol, err := c.client.Client()
+	if err != nil {
+		return fmt.Errorf("starting plugin: %s, %w", c.path, err)
+	}
+	dispensed, err := protocol.Dispense(pluginName)
+	if err != nil {
+		return fmt.Errorf("connecting to plugin: %s, %w", c.path, err)
+	}
+	c.rpc = dispensed.(pluginpb.PluginClient)
+
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	reply, err := c.rpc.Describe(ctx, &pluginpb.DescribeRequest{})
+	if err != nil {
+		return fmt.Errorf("describing plugin: %s, %w", c.path, err)
+	}
+
+	c.backends, c.formats, c.decorates = reply.Backends, reply.Formats, reply.Decorates
+	return nil
+}
+
+// Path returns the path of the plugin
+func (c *Client) Path() string {
+	return c.path
+}
+
+// Backends returns types of the backends read by the plugin
+func (c *Client) Backends() []string {
+	return c.backends
+}
+
+// Formats returns formats of the graph written by the plugin
+func (c *Client) Formats() []string {
+	return c.formats
+}
+
+// Decorates checks whether the plugin provides attributes of the nodes and the edges, see [Client.Decorate]
+func (c *Client) Decorates() bool {
+	return c.decorates
+}
+
+// BackendState implements [terradep.Stater]. Attributes of the block are passed to the plugin, blocks nested in the backend block are not supported
+func (c *Client) BackendState(backend string, body hcl.Body) (terradep.State, error) {
+	attrs, diags := body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("reading backend %s for plugin: %s, %w", backend, c.path, diags)
+	}
+
+	config := make(map[string]cty.Value, len(attrs))
+	for name, attr := range attrs {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("evaluating attribute: %s of backend %s for plugin: %s, %w", name, backend, c.path, diags)
+		}
+		config[name] = value
+	}
+
+	return c.RemoteState(backend, config)
+}
+
+// RemoteState implements [terradep.Stater]
+func (c *Client) RemoteState(backend string, config map[string]cty.Value) (terradep.State, error) {
+	encoded, err := marshalConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.rpc.State(context.Background(), &pluginpb.StateRequest{Backend: backend, Config: encoded})
+	if err != nil {
+		return nil, fmt.Errorf("reading state of backend: %s with plugin: %s, %w", backend, c.path, err)
+	}
+	if reply.Identity == "" {
+		return nil, fmt.Errorf("plugin returned state without identity: %s, backend: %s", c.path, backend)
+	}
+
+	var fields map[string]any
+	if len(reply.Fields.GetFields()) != 0 {
+		fields = reply.Fields.AsMap()
+	}
+
+	return State{backend: reply.Backend, identity: reply.Identity, fields: fields}, nil
+}
+
+// DecodeState implements [terradep.StateDecoder]. The plugin is not called, the fields are kept as they were encoded by the plugin
+func (c *Client) DecodeState(fields map[string]any) (terradep.State, error) {
+	s := State{fields: make(map[string]any, len(fields))}
+	for key, value := range fields {
+		switch key {
+		case "backend":
+			s.backend, _ = value.(string)
+		case "identity":
+			s.identity, _ = value.(string)
+		default:
+			s.fields[key] = value
+		}
+	}
+	if len(s.fields) == 0 {
+		s.fields = nil
+	}
+
+	return s, nil
+}
+
+// Encode writes the graph in the format with the plugin
+func (c *Client) Encode(format string, graph *terradep.Graph) ([]byte, error) {
+	encoded, err := graph.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("encoding graph for plugin: %s, %w", c.path, err)
+	}
+
+	reply, err := c.rpc.Encode(context.Background(), &pluginpb.EncodeRequest{Format: format, Graph: encoded})
+	if err != nil {
+		return nil, fmt.Errorf("encoding graph as: %s with plugin: %s, %w", format, c.path, err)
+	}
+
+	return reply.Output, nil
+}
+
+// Decorate returns the attributes of the nodes and the edges of the graph provided by the plugin
+func (c *Client) Decorate(graph *terradep.Graph) (*Decorations, error) {
+	encoded, err := graph.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("encoding graph for plugin: %s, %w", c.path, err)
+	}
+
+	reply, err := c.rpc.Decorate(context.Background(), &pluginpb.DecorateRequest{Graph: encoded})
+	if err != nil {
+		return nil, fmt.Errorf("decorating graph with plugin: %s, %w", c.path, err)
+	}
+
+	d := &Decorations{nodes: make(map[string]encoding.Attributes, len(reply.Nodes)), edges: make(map[[2]string]encoding.Attributes, len(reply.Edges))}
+	for id, attrs := range reply.Nodes {
+		d.nodes[id] = attrs.GetValues()
+	}
+	for _, e := range reply.Edges {
+		d.edges[[2]string{e.From, e.To}] = e.Attributes.GetValues()
+	}
+
+	return d, nil
+}
+
+// Close stops the plugin, the plugin is killed when it does not exit in time
+func (c *Client) Close() error {
+	c.client.Kill()
+	if !c.client.Exited() {
+		return fmt.Errorf("plugin did not exit: %s", c.path)
+	}
+
+	return nil
+}
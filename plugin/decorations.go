@@ -0,0 +1,24 @@
+package plugin
+
+import (
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/encoding"
+)
+
+// Decorations are the attributes of the nodes and the edges of the graph returned by [Client.Decorate].
+// Nodes are identified by [terradep.Node.ID], so decorations of the graph can be used by the encoders only for the same graph
+type Decorations struct {
+	nodes map[string]encoding.Attributes
+	// edges are keyed by ids of the deployment and its dependency or module
+	edges map[[2]string]encoding.Attributes
+}
+
+// DecorateNode implements [encoding.NodeDecorator]
+func (d *Decorations) DecorateNode(_ *terradep.Graph, n *terradep.Node) encoding.Attributes {
+	return d.nodes[n.ID()]
+}
+
+// DecorateEdge implements [encoding.EdgeDecorator]
+func (d *Decorations) DecorateEdge(_ *terradep.Graph, from, to *terradep.Node) encoding.Attributes {
+	return d.edges[[2]string{from.ID(), to.ID()}]
+}
@@ -0,0 +1,12 @@
+// Package plugin lets organizations ship staters of private backends, encoders of own graph formats and decorators adding attributes
+// of the nodes and the edges to built-in formats, e.g. links to the service catalog, as executables,
+// which are started by terradep cli, so it does not have to be recompiled. Plugins are configured with flag --plugin or key plugin of the config file.
+//
+// Plugin is a program calling [Serve] from its main function, with the backends and formats it supports. The cli starts the plugin
+// with HashiCorp go-plugin as a subprocess with environment variable [CookieEnv], checks the version of the protocol and calls the plugin
+// over gRPC, with service Plugin of package [go.interactor.dev/terradep/plugin/pluginpb]. Plugins written in other languages implement the service and the handshake of go-plugin.
+// Standard output and error of the plugin are redirected by go-plugin, its standard error is passed to the cli, so it can be used for logs.
+//
+// Configuration of backend blocks and terraform_remote_state is passed to the plugin as cty values encoded with package cty/json,
+// backend blocks with nested blocks are not supported. Graphs are passed to encoders and decorators encoded with [terradep.Graph.MarshalJSON]
+package plugin
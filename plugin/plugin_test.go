@@ -0,0 +1,172 @@
+package plugin_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/encoding"
+	"go.interactor.dev/terradep/plugin"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+// TestMain serves the plugin when the test binary is started by [plugin.Start], so the tests run the real protocol
+func TestMain(m *testing.M) {
+	if os.Getenv(plugin.CookieEnv) == plugin.CookieValue {
+		fmt.Fprintln(os.Stderr, "serving test plugin")
+		if err := plugin.Serve(plugin.WithStater(gcsStater{}, "gcs"), plugin.WithEncoder(countEncoder{}, "COUNT"), plugin.WithDecorator(linkDecorator{})); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestServeWithoutCookie(t *testing.T) {
+	t.Setenv(plugin.CookieEnv, "")
+	if err := plugin.Serve(); err != plugin.ErrNotStarted {
+		t.Errorf("expected error: %v, got: %v", plugin.ErrNotStarted, err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	stderr := &syncBuffer{}
+	client, err := plugin.Start(os.Args[0], plugin.WithStderr(stderr))
+	if err != nil {
+		t.Fatalf("starting plugin: %v", err)
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			t.Errorf("closing plugin: %v", err)
+		}
+		if !strings.Contains(stderr.String(), "serving test plugin") {
+			t.Errorf("standard error of plugin was not passed: %q", stderr.String())
+		}
+	}()
+
+	if fmt.Sprint(client.Backends(), client.Formats(), client.Decorates()) != "[gcs] [COUNT] true" {
+		t.Errorf("unexpected description of plugin: %v %v %v", client.Backends(), client.Formats(), client.Decorates())
+	}
+
+	t.Run("state", func(t *testing.T) {
+		state, err := client.RemoteState("gcs", map[string]cty.Value{"bucket": cty.StringVal("tf-state"), "prefix": cty.StringVal("network")})
+		if err != nil {
+			t.Fatalf("reading state: %v", err)
+		}
+		if state.Backend() != "gcs" || state.Identity() != "gcs://tf-state/network" {
+			t.Errorf("unexpected state: %s, backend: %s", state.Identity(), state.Backend())
+		}
+		if fields := state.(plugin.State).Fields(); fmt.Sprint(fields) != "map[bucket:tf-state]" {
+			t.Errorf("unexpected fields of state: %v", fields)
+		}
+
+		if _, err := client.RemoteState("gcs", map[string]cty.Value{"prefix": cty.StringVal("network")}); err == nil || !strings.Contains(err.Error(), "bucket is required") {
+			t.Errorf("expected error of the stater, got: %v", err)
+		}
+	})
+
+	graph := terradeptest.NewGraph().
+		Deployment("network", "gcs://?bucket=b&prefix=network").
+		Deployment("app", "gcs://?bucket=b&prefix=app", "gcs://?bucket=b&prefix=network").
+		MustBuild(t)
+
+	t.Run("encode", func(t *testing.T) {
+		out, err := client.Encode("COUNT", graph)
+		if err != nil {
+			t.Fatalf("encoding graph: %v", err)
+		}
+		if string(out) != "2 deployments" {
+			t.Errorf("unexpected output: %q", out)
+		}
+	})
+
+	t.Run("decorate", func(t *testing.T) {
+		decorations, err := client.Decorate(graph)
+		if err != nil {
+			t.Fatalf("decorating graph: %v", err)
+		}
+		for _, n := range graph.Nodes() {
+			if got := decorations.DecorateNode(graph, n)["URL"]; got != "https://catalog.example.com/"+n.Path {
+				t.Errorf("unexpected URL of node: %s, %q", n.Path, got)
+			}
+			for _, to := range n.Children {
+				if got := decorations.DecorateEdge(graph, n, to)["label"]; got != n.Path+" -> "+to.Path {
+					t.Errorf("unexpected label of edge: %s -> %s, %q", n.Path, to.Path, got)
+				}
+			}
+		}
+	})
+}
+
+type gcsState struct {
+	bucket string
+	prefix string
+}
+
+func (s gcsState) String() string {
+	return s.Identity()
+}
+
+func (s gcsState) Backend() string {
+	return "gcs"
+}
+
+func (s gcsState) Identity() string {
+	return "gcs://" + s.bucket + "/" + s.prefix
+}
+
+func (s gcsState) MarshalJSON() ([]byte, error) {
+	return terradep.MarshalState(s, map[string]any{"bucket": s.bucket})
+}
+
+type gcsStater struct{}
+
+func (gcsStater) State(_ string, config map[string]cty.Value) (terradep.State, error) {
+	bucket, ok := config["bucket"]
+	if !ok {
+		return nil, fmt.Errorf("bucket is required")
+	}
+
+	return gcsState{bucket: bucket.AsString(), prefix: config["prefix"].AsString()}, nil
+}
+
+type countEncoder struct{}
+
+func (countEncoder) Encode(_ string, graph *terradep.Graph) ([]byte, error) {
+	return []byte(fmt.Sprintf("%d deployments", len(graph.Nodes()))), nil
+}
+
+type linkDecorator struct{}
+
+func (linkDecorator) DecorateNode(_ *terradep.Graph, n *terradep.Node) encoding.Attributes {
+	return encoding.Attributes{"URL": "https://catalog.example.com/" + n.Path}
+}
+
+func (linkDecorator) DecorateEdge(_ *terradep.Graph, from, to *terradep.Node) encoding.Attributes {
+	return encoding.Attributes{"label": from.Path + " -> " + to.Path}
+}
+
+// syncBuffer is written by goroutines of go-plugin copying standard error of the plugin
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
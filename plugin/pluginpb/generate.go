@@ -0,0 +1,7 @@
+// Package pluginpb contains messages and gRPC service of the protocol of terradep plugins, generated from plugin.proto.
+// Plugins written in Go call [plugin.Serve], plugins written in other languages implement service Plugin and the handshake of HashiCorp go-plugin
+//
+// [plugin.Serve]: https://pkg.go.dev/go.interactor.dev/terradep/plugin#Serve
+package pluginpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative plugin.proto
@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"go.interactor.dev/terradep/plugin/pluginpb"
+	"google.golang.org/grpc"
+)
+
+const (
+	// CookieEnv is set to [CookieValue] by [Start], so [Serve] can tell that the plugin was started by terradep and not by the user
+	CookieEnv   = "TERRADEP_PLUGIN_COOKIE"
+	CookieValue = "7c1f3e5a9b2d4c68a0e1f2d3c4b5a697"
+	// ProtocolVersion is increased when the messages change in a way which is not compatible, plugins serving other version are not started
+	ProtocolVersion = 1
+	// pluginName is the name of the plugin dispensed by go-plugin, every executable serves single plugin
+	pluginName = "terradep"
+)
+
+// handshake is checked by go-plugin before the plugin is dispensed
+var handshake = goplugin.HandshakeConfig{ProtocolVersion: ProtocolVersion, MagicCookieKey: CookieEnv, MagicCookieValue: CookieValue}
+
+// grpcPlugin serves the service of the plugin and connects the cli with it over gRPC, server is nil in the cli
+type grpcPlugin struct {
+	goplugin.NetRPCUnsupportedPlugin
+	server pluginpb.PluginServer
+}
+
+// GRPCServer implements [goplugin.GRPCPlugin]
+func (p *grpcPlugin) GRPCServer(_ *goplugin.GRPCBroker, s *grpc.Server) error {
+	pluginpb.RegisterPluginServer(s, p.server)
+	return nil
+}
+
+// GRPCClient implements [goplugin.GRPCPlugin]
+func (p *grpcPlugin) GRPCClient(_ context.Context, _ *goplugin.GRPCBroker, conn *grpc.ClientConn) (any, error) {
+	return pluginpb.NewPluginClient(conn), nil
+}
+
+// marshalConfig encodes the configuration with the types of the values, so they are decoded to the same values
+func marshalConfig(config map[string]cty.Value) ([]byte, error) {
+	obj := cty.EmptyObjectVal
+	if len(config) != 0 {
+		obj = cty.ObjectVal(config)
+	}
+
+	encoded, err := ctyjson.Marshal(obj, cty.DynamicPseudoType)
+	if err != nil {
+		return nil, fmt.Errorf("encoding configuration of state: %w", err)
+	}
+
+	return encoded, nil
+}
+
+func unmarshalConfig(encoded []byte) (map[string]cty.Value, error) {
+	obj, err := ctyjson.Unmarshal(encoded, cty.DynamicPseudoType)
+	if err != nil {
+		return nil, fmt.Errorf("decoding configuration of state: %w", err)
+	}
+	if !obj.Type().IsObjectType() {
+		return nil, fmt.Errorf("configuration of state must be an object, got: %s", obj.Type().FriendlyName())
+	}
+
+	config := obj.AsValueMap()
+	if config == nil {
+		config = make(map[string]cty.Value)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,159 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/encoding"
+	"go.interactor.dev/terradep/plugin/pluginpb"
+)
+
+// ErrNotStarted is returned by [Serve] when the plugin was not started by terradep, e.g. by the user running it in the terminal
+var ErrNotStarted = errors.New("plugin must be started by terradep, configure it with flag --plugin")
+
+// Stater reads states of the backends not supported by terradep, see [WithStater]
+type Stater interface {
+	// State returns the state of the backend with the configuration of backend block or terraform_remote_state
+	State(backend string, config map[string]cty.Value) (terradep.State, error)
+}
+
+// Encoder writes the graph in the formats not supported by terradep, see [WithEncoder]
+type Encoder interface {
+	Encode(format string, graph *terradep.Graph) ([]byte, error)
+}
+
+// Decorator provides attributes of the nodes and the edges of the graph, e.g. links to the dashboards of the deployments,
+// which are added to the output of graph --format, see [WithDecorator]
+type Decorator interface {
+	encoding.NodeDecorator
+	encoding.EdgeDecorator
+}
+
+// ServeOpt sets what the plugin served by [Serve] supports
+type ServeOpt func(s *service)
+
+// WithStater makes the plugin read the states of the backends with the stater
+func WithStater(stater Stater, backends ...string) ServeOpt {
+	return func(s *service) {
+		s.stater = stater
+		s.backends = append(s.backends, backends...)
+	}
+}
+
+// WithEncoder makes the plugin write the graph in the formats with the encoder. Formats are case-insensitive, e.g. graph --format ARCHIMATE
+func WithEncoder(encoder Encoder, formats ...string) ServeOpt {
+	return func(s *service) {
+		s.encoder = encoder
+		s.formats = append(s.formats, formats...)
+	}
+}
+
+// WithDecorator makes the plugin provide attributes of the nodes and the edges with the decorator
+func WithDecorator(decorator Decorator) ServeOpt {
+	return func(s *service) {
+		s.decorator = decorator
+	}
+}
+
+// Serve serves the plugin with HashiCorp go-plugin over gRPC until terradep stops it, it is called from the main function of the plugin.
+// Returns [ErrNotStarted] when the plugin was not started by terradep
+func Serve(opts ...ServeOpt) error {
+	if os.Getenv(CookieEnv) != CookieValue {
+		return ErrNotStarted
+	}
+
+	s := &service{}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: handshake,
+		Plugins:         goplugin.PluginSet{pluginName: &grpcPlugin{server: s}},
+		GRPCServer:      goplugin.DefaultGRPCServer,
+		Logger:          hclog.NewNullLogger(),
+	})
+
+	return nil
+}
+
+// service is the gRPC service of the plugin, its methods are called by [Client]
+type service struct {
+	pluginpb.UnimplementedPluginServer
+	stater    Stater
+	backends  []string
+	encoder   Encoder
+	formats   []string
+	decorator Decorator
+}
+
+func (s *service) Describe(context.Context, *pluginpb.DescribeRequest) (*pluginpb.DescribeResponse, error) {
+	return &pluginpb.DescribeResponse{Backends: s.backends, Formats: s.formats, Decorates: s.decorator != nil}, nil
+}
+
+func (s *service) State(_ context.Context, req *pluginpb.StateRequest) (*pluginpb.StateResponse, error) {
+	if s.stater == nil {
+		return nil, fmt.Errorf("plugin does not read states, backend: %s", req.Backend)
+	}
+
+	config, err := unmarshalConfig(req.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := s.stater.State(req.Backend, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return stateResponse(state)
+}
+
+func (s *service) Encode(_ context.Context, req *pluginpb.EncodeRequest) (*pluginpb.EncodeResponse, error) {
+	if s.encoder == nil {
+		return nil, fmt.Errorf("plugin does not encode graphs, format: %s", req.Format)
+	}
+
+	graph := &terradep.Graph{}
+	if err := graph.UnmarshalJSON(req.Graph); err != nil {
+		return nil, fmt.Errorf("decoding graph: %w", err)
+	}
+
+	output, err := s.encoder.Encode(req.Format, graph)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pluginpb.EncodeResponse{Output: output}, nil
+}
+
+func (s *service) Decorate(_ context.Context, req *pluginpb.DecorateRequest) (*pluginpb.DecorateResponse, error) {
+	if s.decorator == nil {
+		return nil, errors.New("plugin does not decorate graphs")
+	}
+
+	graph := &terradep.Graph{}
+	if err := graph.UnmarshalJSON(req.Graph); err != nil {
+		return nil, fmt.Errorf("decoding graph: %w", err)
+	}
+
+	reply := &pluginpb.DecorateResponse{Nodes: make(map[string]*pluginpb.Attributes)}
+	for _, n := range graph.Nodes() {
+		if attrs := s.decorator.DecorateNode(graph, n); len(attrs) != 0 {
+			reply.Nodes[n.ID()] = &pluginpb.Attributes{Values: attrs}
+		}
+		for _, to := range append(append([]*terradep.Node{}, n.Children...), n.Modules...) {
+			if attrs := s.decorator.DecorateEdge(graph, n, to); len(attrs) != 0 {
+				reply.Edges = append(reply.Edges, &pluginpb.EdgeAttributes{From: n.ID(), To: to.ID(), Attributes: &pluginpb.Attributes{Values: attrs}})
+			}
+		}
+	}
+
+	return reply, nil
+}
@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/plugin/pluginpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// State is the [terradep.State] read by the plugin. It keeps the backend, the identity and the fields of the state encoded by the plugin,
+// e.g. bucket of the backend, so the state is encoded the same way by the cli
+type State struct {
+	backend  string
+	identity string
+	fields   map[string]any
+}
+
+// String implements [terradep.State]
+func (s State) String() string {
+	return s.identity
+}
+
+// Backend implements [terradep.State]
+func (s State) Backend() string {
+	return s.backend
+}
+
+// Identity implements [terradep.State]
+func (s State) Identity() string {
+	return s.identity
+}
+
+// Fields returns the fields of the state encoded by the plugin, without backend and identity
+func (s State) Fields() map[string]any {
+	return s.fields
+}
+
+// MarshalJSON implements [json.Marshaler]
+func (s State) MarshalJSON() ([]byte, error) {
+	return terradep.MarshalState(s, s.fields)
+}
+
+// stateResponse returns the state sent to the cli, fields are read from JSON of the state implementing [json.Marshaler]
+func stateResponse(state terradep.State) (*pluginpb.StateResponse, error) {
+	reply := &pluginpb.StateResponse{Backend: state.Backend(), Identity: state.Identity()}
+	if _, ok := state.(json.Marshaler); !ok {
+		return reply, nil
+	}
+
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("encoding state: %s, %w", state, err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, fmt.Errorf("state must be encoded as JSON object: %s, %w", state, err)
+	}
+	delete(fields, "backend")
+	delete(fields, "identity")
+	if len(fields) == 0 {
+		return reply, nil
+	}
+
+	if reply.Fields, err = structpb.NewStruct(fields); err != nil {
+		return nil, fmt.Errorf("encoding fields of state: %s, %w", state, err)
+	}
+
+	return reply, nil
+}
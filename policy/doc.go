@@ -0,0 +1,22 @@
+// Package policy evaluates Rego policies against the [terradep.Graph] with Open Policy Agent, e.g. to enforce that deployments of the app layer
+// do not depend on states of other deployments of the app layer. Policies are evaluated by the opa executable, the input is the graph encoded
+// with [terradep.Graph.MarshalJSON] and the result of the query, by default [DefaultQuery], is the set of violations, e.g.
+//
+//	package terradep
+//
+//	import future.keywords.contains
+//	import future.keywords.if
+//	import future.keywords.in
+//
+//	layers := {d.state: d.layer | some d in input.deployments}
+//
+//	deny contains {"msg": sprintf("app layer depends on other app layer state: %s", [state]), "path": d.path} if {
+//		some d in input.deployments
+//		d.layer == "app"
+//		some state in d.dependencies
+//		layers[state] == "app"
+//	}
+//
+// Violation is a message or an object with fields msg and optional path of the deployment breaking the policy, like in conftest.
+// The opa executable must be [MinOPAVersion] or newer
+package policy
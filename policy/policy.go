@@ -0,0 +1,187 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"go.interactor.dev/terradep"
+)
+
+const (
+	// DefaultQuery returns violations of rules deny in package terradep
+	DefaultQuery = "data.terradep.deny"
+	// MinOPAVersion is the oldest version of opa supporting the flags of opa eval used by [Evaluate]
+	MinOPAVersion = "v0.20.0"
+)
+
+// ErrOPANotFound is returned when the opa executable does not exist or is not in PATH
+var ErrOPANotFound = errors.New("opa executable not found")
+
+// Opt changes how the policies are evaluated by [Evaluate]
+type Opt func(cfg *evalCfg)
+
+type evalCfg struct {
+	opa   string
+	query string
+}
+
+// WithOPA sets the path of the opa executable, by default opa is looked up in PATH
+func WithOPA(path string) Opt {
+	return func(cfg *evalCfg) {
+		cfg.opa = path
+	}
+}
+
+// WithQuery sets the query returning the violations, defaults to [DefaultQuery]
+func WithQuery(query string) Opt {
+	return func(cfg *evalCfg) {
+		cfg.query = query
+	}
+}
+
+// Evaluate evaluates the policies read from the files or directories against the graph and returns every violation
+// as [terradep.Diagnostic] with [terradep.RulePolicyViolation] and [terradep.SeverityError]. Violations pointing to the deployment
+// get its path and the range of its backend block. Returns error when the policies cannot be evaluated, e.g. opa is not installed
+func Evaluate(graph *terradep.Graph, policies []string, opts ...Opt) (terradep.Diagnostics, error) {
+	cfg := &evalCfg{opa: "opa", query: DefaultQuery}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if len(policies) == 0 {
+		return nil, errors.New("no policies to evaluate")
+	}
+
+	opa, err := LookupOPA(cfg.opa)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := graph.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("encoding graph: %w", err)
+	}
+
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, p := range policies {
+		args = append(args, "--data", p)
+	}
+	args = append(args, cfg.query)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(opa, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		// opa writes errors of the policies to standard output when the format is json
+		if out := strings.TrimSpace(stderr.String() + stdout.String()); out != "" {
+			return nil, fmt.Errorf("evaluating policies: %v, %s, %w", policies, out, err)
+		}
+		return nil, fmt.Errorf("evaluating policies: %v, %w", policies, err)
+	}
+
+	violations, err := parseResult(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("reading result of query: %s, %w", cfg.query, err)
+	}
+
+	return diagnostics(graph, violations), nil
+}
+
+// LookupOPA returns the path of the opa executable, the path or the name looked up in PATH.
+// Returns [ErrOPANotFound] with instructions how to install opa when it is not found
+func LookupOPA(path string) (string, error) {
+	found, err := exec.LookPath(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s, install opa %s or newer, see https://www.openpolicyagent.org/docs/latest/#running-opa, %s", ErrOPANotFound, path, MinOPAVersion, err)
+	}
+
+	return found, nil
+}
+
+// violation is the element of the set returned by the query
+type violation struct {
+	Msg  string `json:"msg"`
+	Path string `json:"path"`
+}
+
+// evalResult is the output of opa eval --format json, it is empty when the query is undefined, e.g. there are no deny rules
+type evalResult struct {
+	Result []struct {
+		Expressions []struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"expressions"`
+	} `json:"result"`
+}
+
+func parseResult(out []byte) ([]violation, error) {
+	var result evalResult
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("decoding output of opa: %w", err)
+	}
+
+	var violations []violation
+	for _, r := range result.Result {
+		for _, expr := range r.Expressions {
+			var values []json.RawMessage
+			if err := json.Unmarshal(expr.Value, &values); err != nil {
+				return nil, fmt.Errorf("query must return set or array of violations, got: %s", expr.Value)
+			}
+			for _, value := range values {
+				v, err := parseViolation(value)
+				if err != nil {
+					return nil, err
+				}
+				violations = append(violations, v)
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// parseViolation reads the message or the object with the message and the path
+func parseViolation(value json.RawMessage) (violation, error) {
+	var msg string
+	if err := json.Unmarshal(value, &msg); err == nil {
+		return violation{Msg: msg}, nil
+	}
+
+	var v violation
+	if err := json.Unmarshal(value, &v); err != nil || v.Msg == "" {
+		return violation{}, fmt.Errorf("violation must be a string or an object with field msg, got: %s", value)
+	}
+
+	return v, nil
+}
+
+func diagnostics(graph *terradep.Graph, violations []violation) terradep.Diagnostics {
+	byPath := make(map[string]*terradep.Node)
+	for _, n := range graph.Nodes() {
+		if n.BackendRange != nil {
+			byPath[n.Path] = n
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Path != violations[j].Path {
+			return violations[i].Path < violations[j].Path
+		}
+		return violations[i].Msg < violations[j].Msg
+	})
+
+	out := make(terradep.Diagnostics, 0, len(violations))
+	for _, v := range violations {
+		d := terradep.Diagnostic{Severity: terradep.SeverityError, Rule: terradep.RulePolicyViolation, Summary: v.Msg, Module: v.Path}
+		if n, ok := byPath[v.Path]; ok {
+			d.Range = n.BackendRange
+		}
+		out = append(out, d)
+	}
+
+	return out
+}
@@ -0,0 +1,124 @@
+package policy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/policy"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+// fakeOPA writes the executable which records its arguments and input in the dir and replies like opa eval
+func fakeOPA(t *testing.T, stdout, stderr string, exitCode int) (opa, dir string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake opa is a shell script")
+	}
+
+	dir = t.TempDir()
+	opa = filepath.Join(dir, "opa")
+	script := "#!/bin/sh\n" +
+		"cat > \"" + filepath.Join(dir, "input.json") + "\"\n" +
+		"echo \"$@\" > \"" + filepath.Join(dir, "args") + "\"\n" +
+		"printf '%s' '" + stdout + "'\n" +
+		"printf '%s' '" + stderr + "' >&2\n" +
+		"exit " + strconv.Itoa(exitCode) + "\n"
+	if err := os.WriteFile(opa, []byte(script), 0o700); err != nil {
+		t.Fatalf("writing fake opa: %v", err)
+	}
+
+	return opa, dir
+}
+
+func TestEvaluate(t *testing.T) {
+	graph := terradeptest.NewGraph().
+		Deployment("network", "s3://?bucket=b&key=network").
+		Deployment("app", "s3://?bucket=b&key=app", "s3://?bucket=b&key=network").
+		MustBuild(t)
+
+	tests := []struct {
+		name     string
+		stdout   string
+		stderr   string
+		exitCode int
+		want     []terradep.Diagnostic
+		wantErr  string
+	}{
+		{
+			name:   "violations",
+			stdout: `{"result": [{"expressions": [{"value": ["graph is too big", {"msg": "app depends on app", "path": "app"}]}]}]}`,
+			want: []terradep.Diagnostic{
+				{Severity: terradep.SeverityError, Rule: terradep.RulePolicyViolation, Summary: "graph is too big"},
+				{Severity: terradep.SeverityError, Rule: terradep.RulePolicyViolation, Summary: "app depends on app", Module: "app"},
+			},
+		},
+		{
+			name:   "undefined query",
+			stdout: `{}`,
+		},
+		{
+			name:    "invalid violation",
+			stdout:  `{"result": [{"expressions": [{"value": [{"path": "app"}]}]}]}`,
+			wantErr: "violation must be a string or an object with field msg",
+		},
+		{
+			name:     "invalid policy",
+			stderr:   "rego_parse_error: unexpected eof token",
+			exitCode: 1,
+			wantErr:  "rego_parse_error: unexpected eof token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opa, dir := fakeOPA(t, tt.stdout, tt.stderr, tt.exitCode)
+
+			diags, err := policy.Evaluate(graph, []string{"policies/"}, policy.WithOPA(opa))
+			switch {
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing: %s, got: %v", tt.wantErr, err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(diags) != len(tt.want) {
+				t.Fatalf("diagnostics: %v, want: %v", diags, tt.want)
+			}
+			for i := range diags {
+				if diags[i] != tt.want[i] {
+					t.Errorf("diagnostic: %#v, want: %#v", diags[i], tt.want[i])
+				}
+			}
+
+			args, err := os.ReadFile(filepath.Join(dir, "args"))
+			if err != nil {
+				t.Fatalf("reading arguments of opa: %v", err)
+			}
+			if got, want := strings.TrimSpace(string(args)), "eval --format json --stdin-input --data policies/ "+policy.DefaultQuery; got != want {
+				t.Errorf("arguments of opa: %s, want: %s", got, want)
+			}
+			input, err := os.ReadFile(filepath.Join(dir, "input.json"))
+			if err != nil || !strings.Contains(string(input), `"network"`) {
+				t.Errorf("input of opa is not the graph: %s, %v", input, err)
+			}
+		})
+	}
+}
+
+func TestEvaluateFailsWhenOPAIsNotFound(t *testing.T) {
+	graph := terradeptest.NewGraph().Deployment("network", "s3://?bucket=b&key=network").MustBuild(t)
+
+	_, err := policy.Evaluate(graph, []string{"policies/"}, policy.WithOPA(filepath.Join(t.TempDir(), "opa")))
+	if !errors.Is(err, policy.ErrOPANotFound) || !strings.Contains(err.Error(), policy.MinOPAVersion) {
+		t.Errorf("expected error: %v with required version, got: %v", policy.ErrOPANotFound, err)
+	}
+}
@@ -0,0 +1,28 @@
+package terradep
+
+// WithPreFilter makes the [Scanner] parse only Terraform files which contain any of the tokens the scan depends on,
+// e.g. terraform_remote_state, backend or locals. Other files are checked with a fast byte search and never parsed,
+// which reduces work in modules with dozens of files defining only resources.
+// [Node.Metadata] then describes only the parsed files, e.g. resources defined in skipped files are not counted
+func WithPreFilter() ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.preFilter = true
+	}
+}
+
+// preFilterTokens returns tokens which must be present in the file to parse it, nil when every file must be parsed
+func (s *Scanner) preFilterTokens() []string {
+	if !s.preFilter {
+		return nil
+	}
+
+	tokens := []string{terraformRemoteState, "backend", CloudBackend, "locals"}
+	if s.moduleEdges || s.followModules {
+		tokens = append(tokens, "module")
+	}
+	for _, rule := range s.dataSourceRules {
+		tokens = append(tokens, rule.Type)
+	}
+
+	return tokens
+}
@@ -0,0 +1,79 @@
+package terradep
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+// pruneDependencies removes repeated states and the state of the deployment from its dependencies, reporting the code reading the state
+// the deployment writes ([RuleSelfDependency]), which is usually terraform_remote_state copied from other deployment.
+// Such dependency does not order the deployments, but without removing it the deployment would be a cycle.
+// States are repeated when read by more than one data source, see [Scanner.checkRedundantRemoteStates]
+func (s *Scanner) pruneDependencies(sc *scan, dep deployment, state State, dependencies []State) []State {
+	identity := state.Identity()
+	var out []State
+	seen := make(map[string]struct{}, len(dependencies))
+	for _, d := range dependencies {
+		if _, ok := seen[d.Identity()]; ok {
+			continue
+		}
+		seen[d.Identity()] = struct{}{}
+		if d.Identity() != identity {
+			out = append(out, d)
+			continue
+		}
+		diag := Diagnostic{
+			Rule:    RuleSelfDependency,
+			Summary: "deployment depends on its own state",
+			Detail:  fmt.Sprintf("state: %s, the dependency is ignored", state),
+			Module:  dep.path,
+		}
+		if ranges := sc.detailsOf(dep.path).edges[identity].Ranges; len(ranges) != 0 {
+			diag.Range = rangePtr(ranges[0])
+		}
+		if dep.workspace != "" || dep.overlay != "" {
+			diag.Detail += fmt.Sprintf(", workspace: %q, overlay: %q", dep.workspace, dep.overlay)
+		}
+		s.warn(sc, diag)
+	}
+
+	return out
+}
+
+// checkRedundantRemoteStates reports terraform_remote_state data sources of the module reading the same state as other
+// data source of the module ([RuleRedundantRemoteState]), in any of the workspaces and overlays. Data sources of the local modules
+// called by the module are not checked
+func (s *Scanner) checkRedundantRemoteStates(sc *scan, module *tfconfig.Module) error {
+	if !hasRemoteStates(module) {
+		return nil
+	}
+
+	blocks, err := findRemoteStateBlocks(sc.parser, module.Path)
+	if err != nil {
+		return err
+	}
+
+	edges := sc.detailsOf(module.Path).edges
+	for _, key := range sortedKeys(edges) {
+		var first *remoteStateBlock
+		for _, block := range blocks {
+			if !containsRange(edges[key].Ranges, block.Range) {
+				continue
+			}
+			if first == nil {
+				first = block
+				continue
+			}
+			s.warn(sc, Diagnostic{
+				Rule:    RuleRedundantRemoteState,
+				Summary: fmt.Sprintf("terraform_remote_state %q reads the same state as %q", block.Name, first.Name),
+				Detail:  fmt.Sprintf("state: %s, one data source is enough", key),
+				Module:  module.Path,
+				Range:   rangePtr(block.Range),
+			})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package terradep
+
+import (
+	"fmt"
+	"strings"
+)
+
+// remoteStateSettings are compared by [CheckRemoteStates], they are fields of the states encoded as JSON
+var remoteStateSettings = []string{"key", "region", "encrypt"}
+
+// stateObject is the object storing the state in the bucket, e.g. of S3 backend
+type stateObject struct {
+	backend string
+	bucket  string
+	key     string
+}
+
+// CheckRemoteStates finds terraform_remote_state configured differently than the backend of the deployment producing the state ([RuleRemoteStateMismatch]),
+// e.g. with other region or with the key hard-coding the prefix of the workspace instead of setting the workspace. Such data sources read the state today,
+// but break when the backend is migrated. Producer is the deployment which state is stored in the same object, settings not set by terraform_remote_state,
+// i.e. empty or false, are not compared. Only states with fields bucket and key, e.g. of S3 backend, are checked. All the diagnostics have [SeverityWarning]
+func CheckRemoteStates(deployments []Deployment) Diagnostics {
+	producers := make(map[stateObject]Deployment, len(deployments))
+	for _, d := range deployments {
+		if object, _, ok := locateState(d.State); ok {
+			producers[object] = d
+		}
+	}
+
+	var diags Diagnostics
+	for _, d := range deployments {
+		for _, dep := range d.Dependencies {
+			object, fields, ok := locateState(dep)
+			if !ok {
+				continue
+			}
+			producer, ok := producers[object]
+			if !ok {
+				continue
+			}
+			_, produced, _ := locateState(producer.State)
+
+			var mismatches []string
+			for _, name := range remoteStateSettings {
+				value, expected := fields[name], produced[name]
+				if value == nil || value == "" || value == false || fmt.Sprint(value) == fmt.Sprint(expected) {
+					continue
+				}
+				mismatches = append(mismatches, fmt.Sprintf("%s: %v, backend has %v", name, value, expected))
+			}
+			if len(mismatches) == 0 {
+				continue
+			}
+
+			diag := Diagnostic{
+				Severity: SeverityWarning,
+				Rule:     RuleRemoteStateMismatch,
+				Summary:  fmt.Sprintf("terraform_remote_state differs from the backend of deployment: %s, %s", producer.Path, strings.Join(mismatches, ", ")),
+				Detail:   fmt.Sprintf("state: %s", dep),
+				Module:   d.Path,
+				Range:    edgeRange(d, dep),
+			}
+			if d.Workspace != "" || d.Overlay != "" {
+				diag.Detail += fmt.Sprintf(", workspace: %q, overlay: %q", d.Workspace, d.Overlay)
+			}
+			if fields["key"] != produced["key"] && producer.Workspace != "" && producer.Workspace != DefaultWorkspace {
+				diag.Detail += fmt.Sprintf(", set workspace = %q and key of the backend instead of the key of the workspace", producer.Workspace)
+			}
+			diags = append(diags, diag)
+		}
+	}
+
+	return diags
+}
+
+// locateState returns the object storing the state, the key of non-default workspace has the prefix of the workspace,
+// field workspace_key_prefix or [S3WorkspaceKeyPrefix], and the fields of the state. Returns false when the state does not have fields bucket and key
+func locateState(state State) (stateObject, map[string]any, bool) {
+	workspace := ""
+	if ws, ok := state.(WorkspaceState); ok {
+		workspace = ws.Workspace
+	}
+
+	fields, err := stateFields(state)
+	if err != nil {
+		return stateObject{}, nil, false
+	}
+	bucket, _ := fields["bucket"].(string)
+	key, _ := fields["key"].(string)
+	if bucket == "" || key == "" {
+		return stateObject{}, nil, false
+	}
+	if workspace != "" && workspace != DefaultWorkspace {
+		prefix, _ := fields["workspace_key_prefix"].(string)
+		if prefix == "" {
+			prefix = S3WorkspaceKeyPrefix
+		}
+		key = prefix + "/" + workspace + "/" + key
+	}
+
+	backend, _ := fields["backend"].(string)
+	return stateObject{backend: backend, bucket: bucket, key: key}, fields, true
+}
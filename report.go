@@ -0,0 +1,92 @@
+package terradep
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ScanReport describes what the scan covered, see [Scanner.ScanWithReport]. It tells which directories were visited and why they were skipped,
+// so it helps to find out why the deployment is missing from the [Graph], and how long the scan took
+type ScanReport struct {
+	// Root is the scanned directory
+	Root string
+	// Dirs are the visited directories ordered by path, with the same statuses as reported by [Scanner.Explain]
+	Dirs []DirReport
+	// Modules is the number of visited directories with Terraform files, Deployments is the number of those which are deployments of the graph
+	Modules     int
+	Deployments int
+	// Files and Bytes count parsed Terraform files, modules loaded from [ScanCache] are not parsed
+	Files int
+	Bytes int64
+	// Duration is the time of the whole scan, including building the graph
+	Duration time.Duration
+}
+
+// ScanWithReport scans the root like [Scanner.Scan] and returns also the report of what the scan covered.
+// The report is returned also when the scan fails, then it ends at the module which failed. It is nil only when the root cannot be scanned at all,
+// e.g. because it is not a directory
+func (s *Scanner) ScanWithReport(root string) (*Graph, *ScanReport, Diagnostics, error) {
+	return s.scanRoot(root, true)
+}
+
+// scanStats counts the work done by the scan and its children, which can run concurrently, see [WithIOConcurrency]
+type scanStats struct {
+	files atomic.Int64
+	bytes atomic.Int64
+}
+
+// moduleDir tells whether the directory with the status has Terraform files, directories reached again through symlinks are not counted
+func (s DirStatus) moduleDir() bool {
+	switch s {
+	case DirSkippedName, DirTooDeep, DirSkippedGlob, DirNotModule, DirSkippedSymlink, DirAlreadyScanned:
+		return false
+	default:
+		return true
+	}
+}
+
+// scanReport returns the report of the scan which started at the time, nil when the scan does not record reports
+func (s *Scanner) scanReport(sc *scan, start time.Time) *ScanReport {
+	if !sc.record {
+		return nil
+	}
+
+	report := &ScanReport{
+		Root:     sc.root,
+		Dirs:     sc.reports,
+		Files:    int(sc.stats.files.Load()),
+		Bytes:    sc.stats.bytes.Load(),
+		Duration: time.Since(start),
+	}
+	for i, d := range report.Dirs {
+		report.Dirs[i].Duration = sc.durations[d.Path]
+		if d.Status.moduleDir() {
+			report.Modules++
+		}
+		if d.Status == DirDeployment {
+			report.Deployments++
+		}
+	}
+	if s.relativePaths {
+		sc.relativizeReports()
+	}
+	// modules scanned by the workers are reported after the directories visited in the meantime
+	sort.SliceStable(report.Dirs, func(i, j int) bool {
+		return report.Dirs[i].Path < report.Dirs[j].Path
+	})
+
+	return report
+}
+
+// recordDuration stores the time spent scanning the module, when the scan records reports
+func (sc *scan) recordDuration(path string, d time.Duration) {
+	if !sc.record {
+		return
+	}
+	if sc.durations == nil {
+		sc.durations = make(map[string]time.Duration)
+	}
+
+	sc.durations[path] = d
+}
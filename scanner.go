@@ -1,13 +1,18 @@
 package terradep
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"golang.org/x/exp/slog"
 
 	"github.com/zclconf/go-cty/cty"
@@ -16,20 +21,47 @@ import (
 	"go.interactor.dev/terradep/inspect"
 
 	"github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/hclparse"
 	"github.com/hashicorp/terraform-config-inspect/tfconfig"
 )
 
-// State is used as unique identifier of Terraform state referenced by [terraform_remote_state] or in attribute [backend] in terraform block
+// State is used as unique identifier of Terraform state referenced by [terraform_remote_state] or in attribute [backend] in terraform block.
+// States are equal when their identities are equal, regardless of their types. Backend-specific states, e.g. of package state,
+// expose structured configuration of the backend, like bucket and key of S3, and implement [json.Marshaler]
 //
 // [terraform_remote_state]: https://developer.hashicorp.com/terraform/language/state/remote
 // [backend]: https://developer.hashicorp.com/terraform/language/settings/backends/configuration#using-a-backend-block
-type State fmt.Stringer
+type State interface {
+	// String returns the identity of the state, so the states can be printed
+	String() string
+	// Backend returns the type of the backend storing the state, e.g. s3, or empty string when it is not known
+	Backend() string
+	// Identity returns the URL-like representation of the state, e.g. s3://bucket/key, which is the same for all references to the same state
+	Identity() string
+}
 
 // Scanner can scan the directories looking for a Terraform projects
 type Scanner struct {
-	skipDirs map[string]struct{}
-	stater   Stater
+	skipDirs        map[string]struct{}
+	skipPaths       []string
+	includePaths    []string
+	maxDepth        int
+	symlinks        SymlinkPolicy
+	workspaces      []string
+	moduleEdges     bool
+	followModules   bool
+	dataSourceRules []DataSourceRule
+	layerRules      []LayerRule
+	stateKeyRules   []StateKeyRule
+	cache           ScanCache
+	continueOnError bool
+	gitMetadata     bool
+	relativePaths   bool
+	maxFileSize     int64
+	preFilter       bool
+	concurrency     int
+	codeOwners      *CodeOwners
+	stater          Stater
+	tracer          Tracer
 
 	log *slog.Logger
 }
@@ -43,21 +75,91 @@ type Stater interface {
 	RemoteState(backend string, config map[string]cty.Value) (State, error)
 }
 
-// NewScanner returns initialized instance of Scanner
+// StateDecoder is implemented by the [Stater] which can restore its states from the fields encoded by [MarshalState],
+// so the states loaded from [ScanCache] keep the structured configuration of the backend, e.g. region of the bucket.
+// It returns [*UnsupportedBackendError] for the states of other backends
+type StateDecoder interface {
+	DecodeState(fields map[string]any) (State, error)
+}
+
+// RelativeState is implemented by the [State] stored relative to the working directory of Terraform, e.g. in the file of the local backend.
+// The [Scanner] resolves it against the directory of the deployment declaring the backend block or terraform_remote_state,
+// because Terraform runs in that directory
+type RelativeState interface {
+	State
+	// Resolve returns the state resolved against the directory, the same state when it is not relative
+	Resolve(dir string) State
+}
+
+// resolveState resolves the [RelativeState] against the directory
+func resolveState(state State, dir string) State {
+	if relative, ok := state.(RelativeState); ok {
+		return relative.Resolve(dir)
+	}
+
+	return state
+}
+
+// NewScanner returns initialized instance of Scanner.
+// Logger can be nil, then the [Scanner] does not log anything, unless the logger is set with [WithLogger]
 func NewScanner(log *slog.Logger, stater Stater, opts ...ScannerOpt) *Scanner {
+	if log == nil {
+		log = discardLogger
+	}
+
 	cfg := &scannerCfg{
-		globs:      DefaultSkipDirs,
-		extraGlobs: nil,
+		globs:       DefaultSkipDirs,
+		extraGlobs:  nil,
+		maxDepth:    -1,
+		maxFileSize: DefaultMaxFileSize,
+		tracer:      noopTracer{},
+		log:         log,
 	}
 
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	if cfg.tracer == nil {
+		cfg.tracer = noopTracer{}
+	}
 
 	return &Scanner{
-		stater:   stater,
-		skipDirs: cfg.mergeGlobs(),
-		log:      log,
+		stater:          stater,
+		skipDirs:        cfg.mergeGlobs(),
+		skipPaths:       cfg.skipPaths,
+		includePaths:    cfg.includePaths,
+		maxDepth:        cfg.maxDepth,
+		symlinks:        cfg.symlinks,
+		workspaces:      cfg.workspaces,
+		moduleEdges:     cfg.moduleEdges,
+		followModules:   cfg.followModules,
+		dataSourceRules: cfg.dataSourceRules,
+		layerRules:      cfg.layerRules,
+		stateKeyRules:   cfg.stateKeyRules,
+		cache:           cfg.cache,
+		continueOnError: cfg.continueOnError,
+		gitMetadata:     cfg.gitMetadata,
+		relativePaths:   cfg.relativePaths,
+		maxFileSize:     cfg.maxFileSize,
+		preFilter:       cfg.preFilter,
+		concurrency:     cfg.concurrency,
+		codeOwners:      cfg.codeOwners,
+		tracer:          cfg.tracer,
+		log:             cfg.log,
+	}
+}
+
+// discardLogger is used when [Scanner] was created without the logger
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// WithLogger sets the logger used by the [Scanner] and functions of package inspect called by it.
+// Overrides the logger passed to [NewScanner]. Nil logger disables logging
+func WithLogger(log *slog.Logger) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		if log == nil {
+			log = discardLogger
+		}
+		cfg.log = log
 	}
 }
 
@@ -79,9 +181,71 @@ func AddSkipDirs(dirs []string) ScannerOpt {
 	}
 }
 
+// WithSkipPaths makes the [Scanner] skip directories which path relative to the scanned root matches any of the glob patterns.
+// Patterns use forward slashes and support ** matching any number of directories, e.g. **/examples/**
+func WithSkipPaths(globs ...string) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.skipPaths = append(cfg.skipPaths, globs...)
+	}
+}
+
+// WithIncludePaths makes the [Scanner] treat as deployments only directories which path relative to the scanned root
+// matches any of the glob patterns, e.g. live/prod/**. Other directories are still traversed.
+// Patterns use the same syntax as in [WithSkipPaths]
+func WithIncludePaths(globs ...string) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.includePaths = append(cfg.includePaths, globs...)
+	}
+}
+
+// WithMaxDepth limits how deep the [Scanner] descends into the scanned root. Root itself has depth 0, its subdirectories 1 etc.
+// Negative value means no limit, which is the default
+func WithMaxDepth(depth int) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.maxDepth = depth
+	}
+}
+
+// WithWorkspaces makes the [Scanner] expand every module into one [Node] per workspace.
+// Workspace becomes a part of the [State] of the module, see [WorkspaceState].
+// When not set, every module is represented by a single [Node] with workspace ignored
+func WithWorkspaces(workspaces ...string) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.workspaces = append(cfg.workspaces, workspaces...)
+	}
+}
+
+// WithContinueOnError makes the [Scanner] skip modules which cannot be scanned, e.g. because of invalid HCL or unsupported backend,
+// instead of failing the whole scan. Skipped modules are reported as [Diagnostics] with [SeverityError]
+func WithContinueOnError() ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.continueOnError = true
+	}
+}
+
 type scannerCfg struct {
-	globs      []string
-	extraGlobs []string
+	globs           []string
+	extraGlobs      []string
+	skipPaths       []string
+	includePaths    []string
+	maxDepth        int
+	symlinks        SymlinkPolicy
+	workspaces      []string
+	moduleEdges     bool
+	followModules   bool
+	dataSourceRules []DataSourceRule
+	layerRules      []LayerRule
+	stateKeyRules   []StateKeyRule
+	cache           ScanCache
+	continueOnError bool
+	gitMetadata     bool
+	relativePaths   bool
+	maxFileSize     int64
+	preFilter       bool
+	concurrency     int
+	codeOwners      *CodeOwners
+	tracer          Tracer
+	log             *slog.Logger
 }
 
 func (c scannerCfg) mergeGlobs() map[string]struct{} {
@@ -100,84 +264,360 @@ func (c scannerCfg) mergeGlobs() map[string]struct{} {
 // It can be overridden with [SetSkipDirs] or extended with [AddSkipDirs]
 var DefaultSkipDirs = []string{".terraform", ".idea", ".vscode", ".external_modules"}
 
-// Scan recursively scans the root directory and tries to find Terraform modules
-func (s *Scanner) Scan(root string) (*Graph, error) {
+// Scan recursively scans the root directory and tries to find Terraform modules.
+// Returns [*CycleError] when deployments depend on each other, [*DuplicateStateError] when they share the state and [*NotADirectoryError] when the root is a file.
+// Problems which did not stop the scan, e.g. remote states which could not be resolved statically, are returned as [Diagnostics]
+func (s *Scanner) Scan(root string) (*Graph, Diagnostics, error) {
+	graph, _, diags, err := s.scanRoot(root, false)
+	return graph, diags, err
+}
+
+// scanRoot scans the root, the report is returned only when record is true, see [Scanner.ScanWithReport]
+func (s *Scanner) scanRoot(root string, record bool) (*Graph, *ScanReport, Diagnostics, error) {
+	start := time.Now()
 	if err := checkDirExists(root); err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	modDeps := map[string][]State{}
-	modStates := map[string]State{}
-	err := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
-		if info != nil && !info.IsDir() {
-			// skip files, we only care about directories
-			return nil
+	if err := s.validatePaths(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	sc := s.newScan(root)
+	sc.record = record
+	if err := s.walkRoot(sc); err != nil {
+		return nil, s.scanReport(sc, start), sc.diags, err
+	}
+	s.applyDeclaredDependencies(sc)
+	if s.gitMetadata {
+		s.readGitInfo(sc)
+	}
+	if s.relativePaths {
+		sc.relativize()
+	}
+	if err := checkGraph(sc.states, sc.deps); err != nil {
+		return nil, s.scanReport(sc, start), sc.diags, err
+	}
+
+	graph := buildTree(s.log, sc.states, sc.deps, sc.modules, sc.details)
+	return graph, s.scanReport(sc, start), sc.diags, nil
+}
+
+// scan stores results of a single call to [Scanner.Scan]
+type scan struct {
+	root    string
+	states  map[deployment]State
+	deps    map[deployment][]State
+	modules map[deployment][]string
+	// details and annotations are keyed by the path of the deployment
+	details     map[string]*nodeDetails
+	annotations map[string][]declaredDependency
+	// calls are local modules called by the scanned modules, keyed by their paths, see [Scanner.checkNoBackend]
+	calls map[string][]string
+	// noBackend are errors of the modules without backend, which fail the scan only when they are not called by any deployment
+	noBackend map[string]error
+
+	// walked stores resolved paths of directories walked through symlinks
+	walked map[string]struct{}
+	// scanned stores resolved paths of modules, so the module reachable through symlink is not scanned twice
+	scanned map[string]struct{}
+
+	// mu guards diags, which can be reported by the workers, see [WithIOConcurrency]
+	mu    sync.Mutex
+	diags Diagnostics
+
+	// gitOnce checks whether git history can be read, gitErr is the reason it cannot, see [Scanner.gitAvailable]
+	gitOnce sync.Once
+	gitErr  error
+
+	// workers scan the modules found by the walk
+	workers *workers
+	// stream is set only by [Scanner.Stream]
+	stream *stream
+
+	// fs is used to read Terraform files of the modules, it hides files which must not be parsed
+	fs tfconfig.FS
+	// parser parses every file read from fs only once
+	parser *inspect.Parser
+
+	// span is the span of the scanned module or the whole scan, the parent of spans started by the scan, see [WithTracer]
+	span Span
+	// moduleSpans stores spans of the modules being scanned by their paths, shared with the children of the scan
+	moduleSpans *sync.Map
+
+	// explain makes the scan report errors of the modules instead of failing, see [Scanner.Explain]
+	explain bool
+	// record makes the scan store reports for every visited directory and durations of scanned modules,
+	// see [Scanner.Explain] and [Scanner.ScanWithReport]
+	record    bool
+	reports   []DirReport
+	durations map[string]time.Duration
+	// stats are shared with the children of the scan
+	stats *scanStats
+}
+
+func (s *Scanner) newScan(root string) *scan {
+	sc := &scan{
+		root:        root,
+		states:      map[deployment]State{},
+		deps:        map[deployment][]State{},
+		modules:     map[deployment][]string{},
+		details:     map[string]*nodeDetails{},
+		annotations: map[string][]declaredDependency{},
+		calls:       map[string][]string{},
+		noBackend:   map[string]error{},
+		walked:      map[string]struct{}{},
+		scanned:     map[string]struct{}{},
+		span:        noopSpan{},
+		moduleSpans: &sync.Map{},
+		stats:       &scanStats{},
+	}
+	sc.fs = newLimitedFs(s.maxFileSize, func(path, reason string) {
+		s.warn(sc, Diagnostic{Rule: RuleSkippedFile, Summary: "skipping file which must not be parsed", Detail: filepath.Base(path) + ": " + reason, Module: filepath.Dir(path)})
+	})
+	sc.parser = inspect.NewParser(sc.fs, s.preFilterTokens()...)
+	sc.parser.SetHook(s.parseHook(sc))
+	sc.workers = newWorkers(s.concurrency)
+
+	return sc
+}
+
+// walkRoot walks the root of the scan and waits for all the modules found by the walk
+func (s *Scanner) walkRoot(sc *scan) error {
+	sc.span = s.tracer.Start(nil, SpanScan, slog.String("dir", sc.root))
+	err := s.walk(sc, sc.root, sc.root)
+	if waitErr := s.wait(sc); err == nil {
+		err = waitErr
+	}
+	if err == nil {
+		err = s.checkNoBackend(sc)
+	}
+	sc.span.End(err)
+
+	return err
+}
+
+// walk walks the dir, which is visible in the results as displayDir. They differ only when dir was reached through the symlink
+func (s *Scanner) walk(sc *scan, dir, displayDir string) error {
+	return filepath.Walk(dir, func(walkedPath string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
 		}
 
-		if _, ok := s.skipDirs[info.Name()]; ok {
-			return fs.SkipDir
+		path := displayPath(dir, displayDir, walkedPath)
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return s.followSymlink(sc, path)
 		}
 
-		if !tfconfig.IsModuleDir(path) {
-			s.log.Debug("not a module dir", slog.String("path", path))
+		if !info.IsDir() {
+			// skip files, we only care about directories
 			return nil
 		}
 
-		s.log.Info("loading module", slog.String("path", path))
+		return s.visitDir(sc, path, info)
+	})
+}
 
-		module, diag := tfconfig.LoadModule(path)
-		if diag.HasErrors() {
-			return fmt.Errorf("loading module: %q, %w", path, err)
-		}
+func (s *Scanner) visitDir(sc *scan, path string, info fs.FileInfo) error {
+	if _, ok := s.skipDirs[info.Name()]; ok {
+		sc.report(path, DirSkippedName, "")
+		return fs.SkipDir
+	}
 
-		dependencies, err := s.findDependencies(module)
-		if err != nil {
-			return fmt.Errorf("finding dependencies in module: %s, %w", path, err)
-		}
-		modDeps[module.Path] = dependencies
+	rel := relativePath(sc.root, path)
+	if s.maxDepth >= 0 && pathDepth(rel) > s.maxDepth {
+		s.log.Debug("skipping dir exceeding max depth", slog.String("path", path), slog.Int("maxDepth", s.maxDepth))
+		sc.report(path, DirTooDeep, fmt.Sprintf("max depth: %d", s.maxDepth))
+		return fs.SkipDir
+	}
+
+	if matchAny(s.skipPaths, rel) {
+		s.log.Debug("skipping dir matching the glob", slog.String("path", path))
+		sc.report(path, DirSkippedGlob, "")
+		return fs.SkipDir
+	}
+
+	if !inspect.IsModuleDir(sc.fs, path) {
+		s.log.Debug("not a module dir", slog.String("path", path))
+		sc.report(path, DirNotModule, "")
+		return nil
+	}
 
-		tfState, err := s.findState(module)
+	if len(s.includePaths) != 0 && !matchAny(s.includePaths, rel) {
+		s.log.Debug("module dir not included", slog.String("path", path))
+		sc.report(path, DirNotIncluded, "")
+		return nil
+	}
+
+	if s.alreadyScanned(sc, path) {
+		s.log.Info("module already scanned through another path", slog.String("path", path))
+		sc.report(path, DirAlreadyScanned, "")
+		return fs.SkipDir
+	}
+
+	return s.scanModuleAsync(sc, path)
+}
+
+// scanModule scans the module dir and stores the results in the scan
+func (s *Scanner) scanModule(sc *scan, path string) error {
+	manifest, err := readManifest(path)
+	if err != nil {
+		return s.fail(sc, path, DirParseError, err)
+	}
+	if manifest != nil {
+		sc.detailsOf(path).manifest = manifest
+	}
+	if owners := s.ownersOf(path); owners != nil {
+		sc.detailsOf(path).codeOwners = owners
+	}
+
+	if s.loadCached(sc, path) {
+		s.assignLayer(sc, path)
+		sc.report(path, DirDeployment, "cached")
+		return fs.SkipDir
+	}
+
+	s.log.Info("loading module", slog.String("path", path))
+	// modules are scanned one by one or each in its own scan, so diagnostics reported from now on belong to the module, see [Scanner.storeCached]
+	sc.mu.Lock()
+	first := len(sc.diags)
+	sc.mu.Unlock()
+
+	module, diag := inspect.LoadModule(sc.parser, path)
+	if diag.HasErrors() {
+		return s.fail(sc, path, DirParseError, fmt.Errorf("loading module: %q, %w", path, diag.Err()))
+	}
+
+	overlays := sc.overlaysOf(path)
+	tfStates := make([]State, len(overlays))
+	var backend *backendBlock
+	for i, overlay := range overlays {
+		tfStates[i], backend, err = s.findState(sc, module, overlay)
+		if errors.Is(err, ErrNoBackend) {
+			// most likely a module shared by the deployments, which can be scanned later
+			sc.report(path, DirNoBackend, err.Error())
+			sc.calls[path] = localModuleCalls(module)
+			sc.noBackend[path] = fmt.Errorf("find state in module: %s, overlay: %q, %w", path, overlayName(overlay), err)
+			return fs.SkipDir
+		}
 		if err != nil {
-			return fmt.Errorf("find state in module: %s, %w", path, err)
+			return s.fail(sc, path, stateStatus(err), fmt.Errorf("find state in module: %s, overlay: %q, %w", path, overlayName(overlay), err))
 		}
-		modStates[path] = tfState
+		s.checkStateKeys(sc, path, tfStates[i], backend)
+	}
+	sc.detailsOf(path).backendRange = rangePtr(backend.Range)
+	sc.calls[path] = localModuleCalls(module)
 
-		// do not scan submodules
-		return fs.SkipDir
-	})
+	annotations, err := findAnnotations(sc.parser, path)
 	if err != nil {
-		return nil, err
+		return s.fail(sc, path, DirParseError, fmt.Errorf("finding annotations in module: %s, %w", path, err))
+	}
+	if len(annotations) != 0 {
+		sc.annotations[path] = annotations
+	}
+	sc.detailsOf(path).metadata = moduleMetadata(module, backend.Type)
+	if err := s.checkUnusedRemoteStates(sc, module); err != nil {
+		return s.fail(sc, path, DirParseError, fmt.Errorf("finding unused remote states in module: %s, %w", path, err))
 	}
 
-	return buildTree(s.log, modStates, modDeps), nil
+	for i, overlay := range overlays {
+		for _, workspace := range s.moduleWorkspaces() {
+			dep := deployment{path: path, workspace: workspace, overlay: overlayName(overlay)}
+			dependencies, err := s.findDependencies(sc, module, instance{path: path, workspace: workspace, overlay: overlay})
+			if err != nil {
+				return s.fail(sc, path, DirDependencyError, fmt.Errorf("finding dependencies in module: %s, workspace: %q, overlay: %q, %w", path, workspace, dep.overlay, err))
+			}
+			sc.states[dep] = withWorkspace(tfStates[i], workspace)
+			sc.deps[dep] = s.pruneDependencies(sc, dep, sc.states[dep], dependencies)
+			if s.moduleEdges {
+				sc.modules[dep] = localModuleCalls(module)
+			}
+		}
+	}
+	if err := s.checkRedundantRemoteStates(sc, module); err != nil {
+		return s.fail(sc, path, DirParseError, fmt.Errorf("finding redundant remote states in module: %s, %w", path, err))
+	}
+	s.storeCached(sc, module, first)
+	s.assignLayer(sc, path)
+	sc.report(path, DirDeployment, tfStates[0].String())
+
+	// do not scan submodules
+	return fs.SkipDir
+}
+
+// fail returns the error which stops the scan, unless the scan explains decisions of the [Scanner] or errors are allowed with [WithContinueOnError].
+// Then the error is reported and the module is skipped
+func (s *Scanner) fail(sc *scan, path string, status DirStatus, err error) error {
+	sc.report(path, status, err.Error())
+	return s.skipFailed(sc, path, status, err)
 }
 
-func buildTree(log *slog.Logger, states map[string]State, deps map[string][]State) *Graph {
+// skipFailed is [Scanner.fail] for the module which was already reported
+func (s *Scanner) skipFailed(sc *scan, path string, status DirStatus, err error) error {
+	switch {
+	case sc.explain:
+	case s.continueOnError:
+		s.log.Error("skipping module which cannot be scanned", slog.String("path", path), slog.String("error", err.Error()))
+		s.tracer.Add(CounterDiagnostics, 1, slog.String("rule", RuleModuleError))
+		sc.mu.Lock()
+		sc.diags = append(sc.diags, Diagnostic{
+			Severity: SeverityError,
+			Rule:     RuleModuleError,
+			Summary:  fmt.Sprintf("skipping module, %s", status),
+			Detail:   err.Error(),
+			Module:   path,
+		})
+		sc.mu.Unlock()
+	default:
+		return &ModuleError{Path: path, Status: status, Err: err}
+	}
+
+	// clean up results of the module which could be stored for some of the workspaces
+	for dep := range sc.states {
+		if dep.path == path {
+			delete(sc.states, dep)
+			delete(sc.deps, dep)
+			delete(sc.modules, dep)
+		}
+	}
+
+	return fs.SkipDir
+}
+
+func buildTree(log *slog.Logger, states map[deployment]State, deps map[deployment][]State, modules map[deployment][]string, details map[string]*nodeDetails) *Graph {
 	log.Info("building dependency tree")
 
-	for path, state := range states {
-		log.Debug("", slog.String("module", path), slog.String("state", state.String()))
+	// iterating over sorted keys makes the graph the same every time
+	deployments := sortedDeployments(states)
+	for _, dep := range deployments {
+		log.Debug("", slog.String("module", dep.path), slog.String("workspace", dep.workspace), slog.String("state", states[dep].String()))
 	}
 
-	for path, dep := range deps {
-		log.Debug("", slog.String("module", path), slog.Any("deps", dep))
+	for _, dep := range sortedDeployments(deps) {
+		log.Debug("", slog.String("module", dep.path), slog.String("workspace", dep.workspace), slog.Any("deps", deps[dep]))
 	}
 
 	nodes := make([]*Node, 0, len(states))
-	for path, state := range states {
-		nodes = append(nodes, &Node{
-			Path:  path,
-			State: state,
-		})
+	for _, dep := range deployments {
+		node := &Node{
+			Path:      dep.path,
+			Workspace: dep.workspace,
+			Overlay:   dep.overlay,
+			State:     states[dep],
+		}
+		if d, ok := details[dep.path]; ok {
+			d.apply(node)
+		}
+		nodes = append(nodes, node)
 	}
 
-	nodesByPath := groupByPath(nodes)
+	nodesByDeployment := groupByDeployment(nodes)
 	nodesByState := groupByState(nodes)
 
-	for parentPath, modDeps := range deps {
-		parentNode := nodesByPath[parentPath]
-		for _, childState := range modDeps {
-			childNode, ok := nodesByState[childState]
+	for _, parent := range sortedDeployments(deps) {
+		parentNode := nodesByDeployment[parent]
+		for _, childState := range deps[parent] {
+			childNode, ok := nodesByState[childState.String()]
 			if !ok {
 				// this is external module - not known to the scanner - it will never have children
 				log.Warn("found external module", slog.String("state", childState.String()))
@@ -192,6 +632,14 @@ func buildTree(log *slog.Logger, states map[string]State, deps map[string][]Stat
 		}
 	}
 
+	linkModules(log, nodesByDeployment, modules)
+
+	for _, node := range nodes {
+		sortNodes(node.Children)
+		sortNodes(node.Modules)
+	}
+
+	// nodes are sorted, so roots are sorted too
 	roots := make([]*Node, 0)
 	for _, node := range nodes {
 		// roots are nodes without dependencies
@@ -200,58 +648,83 @@ func buildTree(log *slog.Logger, states map[string]State, deps map[string][]Stat
 		}
 	}
 
-	if len(roots) == 0 {
+	if len(roots) == 0 && len(nodes) != 0 {
 		panic("none of the modules is independent")
 	}
 
-	return &Graph{Heads: roots, states: states, deps: deps}
+	return &Graph{Heads: roots, states: states, deps: deps, modules: modules, details: details, readOnly: true}
 }
 
-func groupByPath(nodes []*Node) map[string]*Node {
-	out := make(map[string]*Node, len(nodes))
+func groupByDeployment(nodes []*Node) map[deployment]*Node {
+	out := make(map[deployment]*Node, len(nodes))
 	for _, node := range nodes {
-		if ex, duplicate := out[node.Path]; duplicate {
-			panic(fmt.Errorf("more than one node has the same path: %q, first node: %v, second node: %v", node.Path, *ex, *node))
+		key := deployment{path: node.Path, workspace: node.Workspace, overlay: node.Overlay}
+		if ex, duplicate := out[key]; duplicate {
+			panic(fmt.Errorf("more than one node has the same path: %q, workspace: %q and overlay: %q, first node: %v, second node: %v", node.Path, node.Workspace, node.Overlay, *ex, *node))
 		}
 
-		out[node.Path] = node
+		out[key] = node
 	}
 
 	return out
 }
 
-func groupByState(nodes []*Node) map[State]*Node {
-	out := make(map[State]*Node, len(nodes))
+// groupByState groups nodes by String representation of the state, so states of different types (e.g. [cachedState]) can be equal
+func groupByState(nodes []*Node) map[string]*Node {
+	out := make(map[string]*Node, len(nodes))
 	for _, node := range nodes {
-		if ex, duplicate := out[node.State]; duplicate {
+		key := node.State.String()
+		if ex, duplicate := out[key]; duplicate {
 			panic(fmt.Errorf("more than one node has the same state: %v, first node: %v, second node: %v", node.State, *ex, *node))
 		}
 
-		out[node.State] = node
+		out[key] = node
 	}
 
 	return out
 }
 
-func (s *Scanner) findDependencies(module *tfconfig.Module) (out []State, err error) {
-	remoteStates := make([]*tfconfig.Resource, 0)
+func (s *Scanner) findDependencies(sc *scan, module *tfconfig.Module, inst instance) ([]State, error) {
+	out, err := s.findRemoteStates(sc, module, inst)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.followModules {
+		return out, nil
+	}
+
+	modDeps, err := s.findModulesDependencies(sc, module, inst, map[string]struct{}{module.Path: {}})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, modDeps...), nil
+}
+
+func (s *Scanner) findRemoteStates(sc *scan, module *tfconfig.Module, inst instance) (out []State, err error) {
+	ctx := s.moduleEvalContext(sc, module, inst)
+
+	expected := 0
 	for _, resource := range module.DataResources {
-		if resource.Type == "terraform_remote_state" {
-			remoteStates = append(remoteStates, resource)
+		if resource.Type == terraformRemoteState {
+			expected++
 		}
 	}
 
-	for file, resources := range groupResByFile(remoteStates) {
-		// grouping allows to parse file only once
-		states, err := s.parseTerraformRemoteStates(file, resources)
+	if expected != 0 {
+		out, err = s.parseTerraformRemoteStates(sc, module.Path, inst.path, expected, ctx)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		out = append(out, states...)
+	dsStates, err := s.findDataSourceDependencies(sc, module, inst.path, ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return
+	return append(out, dsStates...), nil
 }
 
 /*
@@ -259,6 +732,7 @@ example:
 
 	//data "terraform_remote_state" "domain_data" {
 	  backend = "someBackendType"
+	  workspace = terraform.workspace
 
 	  config = {
 		some = "data"
@@ -266,72 +740,113 @@ example:
 	}
 */
 type remoteState struct {
-	Backend string         `hcl:"backend"`
-	Config  hcl.Attributes `hcl:",remain"`
+	Backend   string
+	Workspace hcl.Expression
+	// Config contains all the other attributes, e.g. config, for_each or count
+	Config hcl.Attributes
 }
 
-func (s *Scanner) parseTerraformRemoteStates(file string, resources []*tfconfig.Resource) ([]State, error) {
-	parser := hclparse.NewParser()
-	hclFile, diags := parser.ParseHCLFile(file)
-	if diags.HasErrors() {
-		return nil, diags
+func decodeRemoteState(attrs hcl.Attributes, ctx *hcl.EvalContext) (*remoteState, error) {
+	rs := &remoteState{Config: hcl.Attributes{}}
+	for name, attr := range attrs {
+		switch name {
+		case "backend":
+			diags := gohcl.DecodeExpression(attr.Expr, ctx, &rs.Backend)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("decoding backend: %w", diags)
+			}
+		case "workspace":
+			rs.Workspace = attr.Expr
+		default:
+			rs.Config[name] = attr
+		}
 	}
 
-	content, _, diags := hclFile.Body.PartialContent(backendSchema)
-	if diags.HasErrors() {
-		return nil, diags
+	if _, ok := attrs["backend"]; !ok {
+		return nil, fmt.Errorf("missing required argument: backend")
 	}
 
-	remoteStates := make([]State, 0, len(resources))
-	for _, block := range content.Blocks {
-		const trs = "terraform_remote_state"
-		if resType := block.Labels[0]; resType != trs {
-			s.log.Warn("skipping block because first label is wrong", slog.String("expected", trs), slog.String("actual", resType))
-			continue
-		}
+	return rs, nil
+}
 
-		stateName := block.Labels[1]
-		if len(stateName) == 0 {
-			return nil, fmt.Errorf("block %q does not have the name", trs)
-		}
+// parseTerraformRemoteStates returns the states referenced by terraform_remote_state data sources of the module,
+// recording them as edges of the deployment, which is the module itself or the deployment calling it
+func (s *Scanner) parseTerraformRemoteStates(sc *scan, modulePath, deploymentPath string, expected int, ctx *hcl.EvalContext) ([]State, error) {
+	blocks, err := findRemoteStateBlocks(sc.parser, modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(blocks) != expected {
+		return nil, fmt.Errorf("expected to parse: %d remote states, but found: %d", expected, len(blocks))
+	}
 
-		backend, backendCfg, err := parseRemoteState(block)
+	uses, err := findConsumedOutputs(sc.parser, modulePath)
+	if err != nil {
+		return nil, fmt.Errorf("finding outputs of remote states: %w", err)
+	}
+
+	remoteStates := make([]State, 0, len(blocks))
+	for _, block := range blocks {
+		rs, err := decodeRemoteState(block.Attrs, ctx)
 		if err != nil {
-			return nil, fmt.Errorf("parsing terraform remote state, %w", err)
+			return nil, fmt.Errorf("decoding block %s to remoteState: %w", block.Range, err)
 		}
 
-		state, err := s.stater.RemoteState(backend, backendCfg)
+		instances, err := expandInstances(rs.Config, ctx)
 		if err != nil {
-			return nil, fmt.Errorf("reading state from terraform_remote_state: %q, %w", stateName, err)
+			// it is not an error in the configuration, it just can not be analyzed statically
+			s.warn(sc, Diagnostic{
+				Rule:    RuleDynamicRemoteState,
+				Summary: fmt.Sprintf("skipping terraform_remote_state %q, it cannot be resolved statically", block.Name),
+				Detail:  err.Error(),
+				Module:  modulePath,
+				Range:   rangePtr(block.Range),
+			})
+			continue
 		}
 
-		s.log.Info("decoded remote state", slog.String("state", state.String()))
-		remoteStates = append(remoteStates, state)
-	}
+		for _, instanceCtx := range instances {
+			backend, backendCfg, stateWorkspace, err := parseRemoteState(rs, instanceCtx)
+			if err != nil {
+				return nil, fmt.Errorf("parsing terraform remote state: %q, %w", block.Name, err)
+			}
+
+			state, err := s.remoteState(sc, deploymentPath, backend, backendCfg)
+			if err != nil {
+				return nil, fmt.Errorf("reading state from terraform_remote_state: %q, %w", block.Name, err)
+			}
+			state = withWorkspace(state, stateWorkspace)
 
-	if len(remoteStates) != len(resources) {
-		return nil, fmt.Errorf("expected to parse: %d remote states, but found: %d", len(resources), len(remoteStates))
+			s.log.Info("decoded remote state", slog.String("state", state.String()))
+			sc.addEdge(deploymentPath, state, block.Range, uses[block.Name].sortedOutputs())
+			remoteStates = append(remoteStates, state)
+		}
 	}
 
 	return remoteStates, nil
 }
 
-func parseRemoteState(block *hcl.Block) (backend string, cfg map[string]cty.Value, err error) {
-	rs := &remoteState{}
-	diags := gohcl.DecodeBody(block.Body, nil, rs)
-	if diags.HasErrors() {
-		return "", nil, fmt.Errorf("decoding block body to remoteState: %w", diags)
+func parseRemoteState(rs *remoteState, ctx *hcl.EvalContext) (backend string, cfg map[string]cty.Value, workspace string, err error) {
+	workspace, err = parseRemoteStateWorkspace(rs.Workspace, ctx)
+	if err != nil {
+		return "", nil, "", err
 	}
 
-	value, diags := rs.Config["config"].Expr.Value(nil)
+	cfgAttr, ok := rs.Config["config"]
+	if !ok {
+		return "", nil, "", fmt.Errorf("terraform remote state does not have config")
+	}
+
+	value, diags := cfgAttr.Expr.Value(ctx)
 	if diags.HasErrors() {
-		return "", nil, fmt.Errorf("reading value of remote state config, %w", diags)
+		return "", nil, "", fmt.Errorf("reading value of remote state config, %w", diags)
 	}
 	if !value.Type().IsObjectType() {
-		return "", nil, fmt.Errorf("terraform remote state config must be an object")
+		return "", nil, "", fmt.Errorf("terraform remote state config must be an object")
 	}
 
-	return rs.Backend, value.AsValueMap(), nil
+	return rs.Backend, value.AsValueMap(), workspace, nil
 }
 
 // groupResByFiles accepts map of resources, ignores the key and returns map where key is file containing the resources
@@ -346,94 +861,158 @@ func groupResByFile(res []*tfconfig.Resource) map[string][]*tfconfig.Resource {
 	return out
 }
 
-/*
-example:
+// CloudBackend is passed as backend type to [Stater.BackendState] when deployment uses [cloud block] instead of backend
+//
+// [cloud block]: https://developer.hashicorp.com/terraform/cli/cloud/settings#the-cloud-block
+const CloudBackend = "cloud"
 
-	terraform {
-	  required_version = "1.2.7"
+// findState returns the state of the module deployed with the overlay, which can be nil, and its backend block
+func (s *Scanner) findState(sc *scan, mod *tfconfig.Module, overlay *Overlay) (State, *backendBlock, error) {
+	blocks, err := inspect.FindTerraformBlocks(s.log, sc.parser, mod.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("finding terraform block for in module: %s, %w", mod.Path, err)
+	}
+	if len(blocks.Primary) == 0 && len(blocks.Override) == 0 {
+		return nil, nil, &NoBackendError{Path: mod.Path}
+	}
 
-	  backend "someBackend" {
-		some = "data"
-		other = ["list"]
-	  }
+	backend, err := s.resolveBackend(blocks)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving backend of module: %s, %w", mod.Path, err)
+	}
+	if backend == nil {
+		return nil, nil, &NoBackendError{Path: mod.Path, TerraformBlock: true}
+	}
+
+	body, err := overlay.backendBody(mod.Path, backend.Body)
+	if err != nil {
+		return nil, nil, err
 	}
-*/
-type terraformBlock struct {
-	Version string `hcl:"required_version,attr" cty:"required_version,attr"`
-	Backend struct {
-		Type string   `hcl:"type,label" cty:"type,label"`
-		Body hcl.Body `hcl:",remain"`
-	} `hcl:"backend,block"`
 
-	// Remain stores unused part of the body, e.g. required_providers
-	Remain hcl.Body `hcl:",remain"`
+	state, err := s.backendState(sc, mod.Path, backend.Type, body)
+	return state, backend, err
 }
 
-func (s *Scanner) findState(mod *tfconfig.Module) (State, error) {
-	block, err := inspect.FindTerraformBlock(s.log, mod.Path)
+func (s *Scanner) validatePaths() error {
+	for _, glob := range append(append([]string(nil), s.skipPaths...), s.includePaths...) {
+		if !doublestar.ValidatePattern(glob) {
+			return fmt.Errorf("invalid glob pattern: %q", glob)
+		}
+	}
+
+	return ValidateLayerRules(s.layerRules)
+}
+
+// relativePath returns path relative to root with forward slashes, so it can be matched with the globs
+func relativePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
 	if err != nil {
-		return nil, fmt.Errorf("finding terraform block for in module: %s, %w", mod.Path, err)
+		return filepath.ToSlash(path)
 	}
 
-	tb := &terraformBlock{}
-	diags := gohcl.DecodeBody(block.Body, nil, tb)
-	if diags.HasErrors() {
-		return nil, fmt.Errorf("decoding terraform block to object: %w", diags)
+	return filepath.ToSlash(rel)
+}
+
+// pathDepth returns number of directories in the relative path returned by relativePath
+func pathDepth(rel string) int {
+	if rel == "." {
+		return 0
+	}
+
+	return strings.Count(rel, "/") + 1
+}
+
+func matchAny(globs []string, path string) bool {
+	for _, glob := range globs {
+		// patterns were validated by validatePaths
+		if ok, _ := doublestar.Match(glob, path); ok {
+			return true
+		}
 	}
 
-	return s.stater.BackendState(tb.Backend.Type, tb.Backend.Body)
+	return false
 }
 
 func checkDirExists(path string) error {
 	stat, err := os.Stat(path)
 	switch {
 	case errors.Is(err, os.ErrNotExist):
-		return fmt.Errorf("path does not exist: %s", path)
+		return fmt.Errorf("path does not exist: %s, %w", path, fs.ErrNotExist)
 	case err != nil:
 		return err
 	}
 
 	if !stat.IsDir() {
-		return fmt.Errorf("it is not directory: %s", path)
+		return &NotADirectoryError{Path: path}
 	}
 	return nil
 }
 
-// Graph is acyclic directed graph showing dependencies between Terraform states
+// Graph is acyclic directed graph showing dependencies between Terraform states.
+//
+// Graphs returned by [Scanner.Scan], [MergeGraphs] and [Graph.UnmarshalJSON] are read-only snapshots: none of the methods changes them,
+// so they are safe for concurrent use, e.g. by many encoders or HTTP handlers, as long as the nodes are not changed by the caller.
+// Mutating methods, like [Graph.AddNode], return [ErrReadOnlyGraph] for them, change the copy returned by [Graph.Clone] instead.
+// Graph built from the zero value is not safe for concurrent use while it is changed
 type Graph struct {
 	// Heads are Nodes which represent Terraform deployments without dependencies to other states
 	Heads []*Node
 
-	states map[string]State
-	deps   map[string][]State
+	states  map[deployment]State
+	deps    map[deployment][]State
+	modules map[deployment][]string
+	// details are keyed by the path of the deployment
+	details map[string]*nodeDetails
+	// readOnly is set for the graphs which may be shared, see [ErrReadOnlyGraph]
+	readOnly bool
 }
 
-// MergeGraphs merges graph into one
+// MergeGraphs merges graph into one. Logger can be nil. Returns [*CycleError] or [*DuplicateStateError] like [Scanner.Scan]
 func MergeGraphs(log *slog.Logger, graphs ...*Graph) (*Graph, error) {
-	states := make(map[string]State)
-	deps := make(map[string][]State)
+	if log == nil {
+		log = discardLogger
+	}
+
+	states := make(map[deployment]State)
+	deps := make(map[deployment][]State)
+	modules := make(map[deployment][]string)
+	details := make(map[string]*nodeDetails)
 
 	for _, g := range graphs {
-		for path, state := range g.states {
-			if old, ok := states[path]; ok {
+		for dep, state := range g.states {
+			if old, ok := states[dep]; ok {
 				log.Warn("merging state path collision", slog.String("old", old.String()), slog.String("new", state.String()))
 			}
-			states[path] = state
+			states[dep] = state
 		}
 
-		for parentPath, modDeps := range g.deps {
-			if old, ok := deps[parentPath]; ok {
+		for parent, modDeps := range g.deps {
+			if old, ok := deps[parent]; ok {
 				log.Warn("merging dep path collision, appending", slog.Any("old", old), slog.Any("new", deps))
 			}
-			deps[parentPath] = append(deps[parentPath], modDeps...)
+			deps[parent] = append(deps[parent], modDeps...)
+		}
+
+		for caller, calls := range g.modules {
+			modules[caller] = append(modules[caller], calls...)
+		}
+
+		for path, d := range g.details {
+			details[path] = d
 		}
 	}
 
-	return buildTree(log, states, deps), nil
+	if err := checkGraph(states, deps); err != nil {
+		return nil, err
+	}
+
+	return buildTree(log, states, deps, modules, details), nil
 }
 
-// String is insanely poor implementation of representing the Graph in JSON lines format.
-// Assumes Node.String returns a JSON
+// String represents the Graph as trees of the heads, one line per head. Assumes Node.String returns a JSON
+//
+// Deprecated: states are not escaped and the graph is held in memory twice, use NodeEncoder of package encoding,
+// which writes every node with its edges as a line of JSON Lines
 func (g *Graph) String() string {
 	sb := strings.Builder{}
 	sb.WriteRune('\n')
@@ -447,10 +1026,32 @@ func (g *Graph) String() string {
 
 // Node represents Terraform deployment
 type Node struct {
-	Path     string
+	Path string
+	// Workspace is set only when [Scanner] was created with [WithWorkspaces]
+	Workspace string
+	// Overlay is set only when the deployment declares overlays in the [Manifest]
+	Overlay  string
 	State    State
 	Parent   *Node
 	Children []*Node
+	// Modules are deployments or local modules (see [LocalModule]) whose code is used by this deployment.
+	// Set only when [Scanner] was created with [WithModuleEdges]
+	Modules []*Node
+
+	// Owner, Layer and Tags are read from the [Manifest] of the deployment
+	Owner string
+	Layer string
+	Tags  []string
+	// CodeOwners are owners of the deployment read from CODEOWNERS, set only when [Scanner] was created with [WithCodeOwners]
+	CodeOwners []string
+	// Metadata is nil for the states not known to the [Scanner]
+	Metadata *ModuleMetadata
+	// Git is set only when [Scanner] was created with [WithGitMetadata] and the deployment is tracked in git repository
+	Git *GitInfo
+	// BackendRange points to the backend or cloud block of the deployment, nil for external states, local modules and states declared in the [Manifest]
+	BackendRange *hcl.Range
+	// Edges describe the dependencies on the states of Children, keyed by [State.Identity], see [Node.EdgeMetadata]
+	Edges map[string]EdgeMetadata
 }
 
 // Represents [Node] in JSON format
@@ -469,6 +1070,17 @@ func (n *Node) String() string {
 		}
 		sb.WriteString("]")
 	}
+	if n.Metadata != nil {
+		// marshaling struct without custom marshalers cannot fail
+		b, _ := json.Marshal(n.Metadata)
+		sb.WriteString(",\"metadata\":")
+		sb.Write(b)
+	}
+	if n.Git != nil {
+		b, _ := json.Marshal(n.Git)
+		sb.WriteString(",\"git\":")
+		sb.Write(b)
+	}
 	sb.WriteString("}")
 	return sb.String()
 }
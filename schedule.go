@@ -0,0 +1,74 @@
+package terradep
+
+import "time"
+
+// Schedule is the timeline of applying the deployments, when every deployment starts as soon as all its dependencies finish,
+// like in terradep run with unlimited parallelism
+type Schedule struct {
+	// Batches are returned by [Levels]
+	Batches [][]*Node
+	// Start and Finish are relative to the start of the first deployment
+	Start  map[*Node]time.Duration
+	Finish map[*Node]time.Duration
+	// Critical is the longest chain of dependencies, which determines Total, ordered from the first deployment to start.
+	// Every deployment of the chain starts when the previous one finishes
+	Critical []*Node
+	// Total is the time of applying all the deployments
+	Total time.Duration
+}
+
+// IsCritical checks whether the deployment is on [Schedule.Critical]
+func (s Schedule) IsCritical(n *Node) bool {
+	for _, c := range s.Critical {
+		if c == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ScheduleDeployments returns the [Schedule] of the deployments grouped by [Levels], which take the duration to apply.
+// Dependencies on external states and on deployments not grouped with [WithLevelsOnly] are ignored
+func ScheduleDeployments(g *Graph, duration func(*Node) time.Duration, opts ...LevelsOpt) Schedule {
+	batches, depth := Levels(g, opts...)
+	s := Schedule{
+		Batches: batches,
+		Start:   make(map[*Node]time.Duration, len(depth)),
+		Finish:  make(map[*Node]time.Duration, len(depth)),
+	}
+
+	// previous is the dependency finishing last, nil when the deployment starts first
+	previous := make(map[*Node]*Node, len(depth))
+	var last *Node
+	for _, batch := range batches {
+		for _, n := range batch {
+			for _, child := range n.Children {
+				if _, ok := depth[child]; !ok {
+					continue
+				}
+				if previous[n] == nil || s.Finish[child] > s.Finish[previous[n]] {
+					previous[n] = child
+				}
+			}
+			s.Start[n] = 0
+			if p := previous[n]; p != nil {
+				s.Start[n] = s.Finish[p]
+			}
+			s.Finish[n] = s.Start[n] + duration(n)
+
+			if last == nil || s.Finish[n] > s.Finish[last] {
+				last = n
+			}
+		}
+	}
+
+	for n := last; n != nil; n = previous[n] {
+		s.Critical = append([]*Node{n}, s.Critical...)
+	}
+	if last != nil {
+		s.Total = s.Finish[last]
+	}
+
+	return s
+}
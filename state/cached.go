@@ -0,0 +1,81 @@
+package state
+
+import (
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"go.interactor.dev/terradep"
+)
+
+// CachedStater is a [terradep.Stater] memoizing states read by other stater, see [Cached]
+type CachedStater struct {
+	next terradep.Stater
+
+	mu     sync.Mutex
+	states map[string]terradep.State
+}
+
+// Cached returns [CachedStater] which reads every state from the configuration of terraform_remote_state only once with the stater,
+// so thousands of identical blocks, common in generated code, do not compute the state again, and staters calling APIs of the backends
+// do not call them again. States are memoized by the type of the backend and the configuration, errors are not memoized.
+// Backend blocks are passed to the stater, because every deployment has its own. It is safe for concurrent use when the stater is
+func Cached(stater terradep.Stater) *CachedStater {
+	return &CachedStater{next: stater, states: make(map[string]terradep.State)}
+}
+
+// BackendState implements [terradep.Stater]
+func (s *CachedStater) BackendState(backend string, body hcl.Body) (terradep.State, error) {
+	return s.next.BackendState(backend, body)
+}
+
+// RemoteState implements [terradep.Stater]
+func (s *CachedStater) RemoteState(backend string, stateCfg map[string]cty.Value) (terradep.State, error) {
+	key, ok := configKey(backend, stateCfg)
+	if !ok {
+		return s.next.RemoteState(backend, stateCfg)
+	}
+
+	s.mu.Lock()
+	state, ok := s.states[key]
+	s.mu.Unlock()
+	if ok {
+		return state, nil
+	}
+
+	state, err := s.next.RemoteState(backend, stateCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.states[key] = state
+	s.mu.Unlock()
+
+	return state, nil
+}
+
+// DecodeState implements [terradep.StateDecoder]
+func (s *CachedStater) DecodeState(fields map[string]any) (terradep.State, error) {
+	return decodeState(s.next, fields)
+}
+
+// configKey returns canonical representation of the configuration, which includes types of the values, so e.g. "1" and 1 differ.
+// Returns false when the configuration cannot be represented, e.g. because it has unknown values
+func configKey(backend string, stateCfg map[string]cty.Value) (string, bool) {
+	obj := cty.EmptyObjectVal
+	if len(stateCfg) != 0 {
+		obj = cty.ObjectVal(stateCfg)
+	}
+	if !obj.IsWhollyKnown() {
+		return "", false
+	}
+
+	encoded, err := ctyjson.Marshal(obj, cty.DynamicPseudoType)
+	if err != nil {
+		return "", false
+	}
+
+	return backend + "\x00" + string(encoded), true
+}
@@ -0,0 +1,123 @@
+package state
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+)
+
+// LocalBackend is key of Terraform backend type storing the state in the local file. It is the default backend of CDKTF stacks
+const LocalBackend = "local"
+
+// localDefaultPath is used by Terraform when path of [LocalBackend] is not set
+const localDefaultPath = "terraform.tfstate"
+
+const localScheme = "file"
+
+// LocalStater is a [terradep.Stater] supporting backend type [LocalBackend].
+// States are identified by the path to the file. Relative paths are resolved by the [terradep.Scanner] against the directory
+// of the deployment, like Terraform does, see [LocalState.Resolve]
+type LocalStater struct{}
+
+// NewLocalStater returns new instance of [LocalStater]
+func NewLocalStater() *LocalStater {
+	return &LocalStater{}
+}
+
+// RemoteState implements [terradep.Stater]
+func (s *LocalStater) RemoteState(backend string, stateCfg map[string]cty.Value) (terradep.State, error) {
+	if backend != LocalBackend {
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: []string{LocalBackend}}
+	}
+
+	path := localDefaultPath
+	if value, ok := stateCfg["path"]; ok && !value.IsNull() {
+		if !value.Type().Equals(cty.String) || !value.IsKnown() {
+			return nil, fmt.Errorf("path of local state must be a string")
+		}
+		path = value.AsString()
+	}
+
+	return localStateURL("", path), nil
+}
+
+// BackendState implements [terradep.Stater]
+func (s *LocalStater) BackendState(backend string, body hcl.Body) (terradep.State, error) {
+	if backend != LocalBackend {
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: []string{LocalBackend}}
+	}
+
+	cfg := &localBackendConfig{}
+	diags := gohcl.DecodeBody(body, nil, cfg)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("reading LocalBackend state: %w", diags)
+	}
+
+	path := localDefaultPath
+	if cfg.Path != nil {
+		path = *cfg.Path
+	}
+
+	return localStateURL("", path), nil
+}
+
+// DecodeState implements [terradep.StateDecoder]
+func (s *LocalStater) DecodeState(fields map[string]any) (terradep.State, error) {
+	if backend := stringField(fields, "backend"); backend != LocalBackend {
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: []string{LocalBackend}}
+	}
+
+	return LocalState{Path: stringField(fields, "path")}, nil
+}
+
+type localBackendConfig struct {
+	Path   *string  `hcl:"path,optional"`
+	Remain hcl.Body `hcl:",remain"`
+}
+
+// localStateURL returns state identified by URL with scheme file. Relative path is joined with the directory, unless it is empty
+func localStateURL(dir, path string) LocalState {
+	path = filepath.FromSlash(path)
+	if dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	return LocalState{Path: filepath.ToSlash(filepath.Clean(path))}
+}
+
+// LocalState represents Terraform state stored in the local file
+type LocalState struct {
+	// Path of the file with slashes as separators, relative to the working directory of Terraform, unless it is absolute
+	Path string
+}
+
+// String implements [terradep.State]
+func (s LocalState) String() string {
+	return s.Identity()
+}
+
+// Backend implements [terradep.State]
+func (s LocalState) Backend() string {
+	return LocalBackend
+}
+
+// Identity implements [terradep.State], it is URL with scheme file, e.g. file:///project/terraform.tfstate
+func (s LocalState) Identity() string {
+	u := url.URL{Scheme: localScheme, Path: s.Path}
+	return u.String()
+}
+
+// Resolve implements [terradep.RelativeState], relative path is joined with the directory
+func (s LocalState) Resolve(dir string) terradep.State {
+	return localStateURL(dir, s.Path)
+}
+
+// MarshalJSON implements [json.Marshaler]
+func (s LocalState) MarshalJSON() ([]byte, error) {
+	return terradep.MarshalState(s, map[string]any{"path": s.Path})
+}
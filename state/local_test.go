@@ -0,0 +1,42 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/state"
+)
+
+func TestLocalStateResolve(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  map[string]cty.Value
+		dir  string
+		want string
+	}{
+		{name: "default path", cfg: map[string]cty.Value{}, dir: "/live/app", want: "/live/app/terraform.tfstate"},
+		{name: "null path", cfg: map[string]cty.Value{"path": cty.NullVal(cty.String)}, dir: "/live/app", want: "/live/app/terraform.tfstate"},
+		{name: "relative path", cfg: map[string]cty.Value{"path": cty.StringVal("../network/./terraform.tfstate")}, dir: "/live/app", want: "/live/network/terraform.tfstate"},
+		{name: "absolute path", cfg: map[string]cty.Value{"path": cty.StringVal("/states/network.tfstate")}, dir: "/live/app", want: "/states/network.tfstate"},
+		{name: "without dir", cfg: map[string]cty.Value{"path": cty.StringVal("network.tfstate")}, want: "network.tfstate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := state.NewLocalStater().RemoteState(state.LocalBackend, tt.cfg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			relative, ok := s.(terradep.RelativeState)
+			if !ok {
+				t.Fatalf("state: %T does not implement %T", s, (*terradep.RelativeState)(nil))
+			}
+
+			got, ok := relative.Resolve(tt.dir).(state.LocalState)
+			if !ok || got.Path != tt.want {
+				t.Errorf("unexpected resolved state: %#v, want path: %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package state
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go.interactor.dev/terradep"
+)
+
+// ParseState returns the state with the identity, as returned by [terradep.State.Identity], so the structured configuration
+// of the backend can be read from the states restored from the cache or JSON, which keep only the identity. States of workspaces
+// are returned as [terradep.WorkspaceState] and local modules as [terradep.LocalModule]. Returns error when the scheme is not known
+func ParseState(identity string) (terradep.State, error) {
+	if module, ok := strings.CutPrefix(identity, "module:"); ok {
+		return terradep.LocalModule(module), nil
+	}
+
+	u, err := url.Parse(identity)
+	if err != nil {
+		return nil, fmt.Errorf("parsing state: %s, %w", identity, err)
+	}
+
+	if u.Fragment != "" {
+		workspace := u.Fragment
+		u.Fragment = ""
+		state, err := ParseState(u.String())
+		if err != nil {
+			return nil, err
+		}
+		return terradep.WorkspaceState{State: state, Workspace: workspace}, nil
+	}
+
+	switch u.Scheme {
+	case S3Backend:
+		s := S3State{Bucket: u.Host, Key: strings.TrimPrefix(u.Path, "/"), Region: u.Query().Get("region"), identity: identity}
+		if encrypt := u.Query().Get("encrypt"); encrypt != "" {
+			if s.Encrypt, err = strconv.ParseBool(encrypt); err != nil {
+				return nil, fmt.Errorf("parsing encrypt of state: %s, %w", identity, err)
+			}
+		}
+		return s, nil
+	case localScheme:
+		return LocalState{Path: u.Path}, nil
+	case tfcScheme:
+		return parseTFCState(u, identity)
+	default:
+		return nil, fmt.Errorf("unknown scheme of state: %s", identity)
+	}
+}
+
+func parseTFCState(u *url.URL, identity string) (TFCState, error) {
+	s := TFCState{Hostname: u.Host, identity: identity}
+	org, workspace, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	s.Organization = org
+	switch {
+	case org == "":
+		return TFCState{}, fmt.Errorf("organization is required, state: %s", identity)
+	case strings.HasSuffix(workspace, "*"):
+		s.Prefix = strings.TrimSuffix(workspace, "*")
+	case workspace != "":
+		s.Workspace = workspace
+	case u.Query().Get("tags") != "":
+		s.Tags = strings.Split(u.Query().Get("tags"), ",")
+	default:
+		return TFCState{}, fmt.Errorf("workspace name, prefix or tags are required, state: %s", identity)
+	}
+
+	return s, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
@@ -59,20 +60,32 @@ const S3Backend = "s3"
 // RemoteState implements [terradep.Stater]
 func (s *S3Stater) RemoteState(backend string, stateCfg map[string]cty.Value) (terradep.State, error) {
 	if backend != S3Backend {
-		return nil, fmt.Errorf("supported backend type: %q, got: %q", S3Backend, backend)
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: []string{S3Backend}}
 	}
 
 	cfg := s3Config{}
 	for key, value := range stateCfg {
+		var err error
 		switch key {
 		case "bucket":
-			cfg.Bucket = value.AsString()
+			cfg.Bucket, err = stringAttribute(key, value)
 		case "key":
-			cfg.Key = value.AsString()
+			cfg.Key, err = stringAttribute(key, value)
 		case "region":
-			cfg.Region = value.AsString()
+			cfg.Region, err = stringAttribute(key, value)
 		case "encrypt":
 			cfg.Encrypt = value.RawEquals(cty.True)
+		case "role_arn":
+			cfg.RoleARN, err = stringAttribute(key, value)
+		case "profile":
+			cfg.Profile, err = stringAttribute(key, value)
+		case "workspace_key_prefix":
+			cfg.WorkspaceKeyPrefix, err = stringAttribute(key, value)
+		case "assume_role":
+			cfg.AssumeRole = value
+		}
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -82,7 +95,7 @@ func (s *S3Stater) RemoteState(backend string, stateCfg map[string]cty.Value) (t
 // BackendState implements [terradep.Stater]
 func (s *S3Stater) BackendState(backend string, body hcl.Body) (terradep.State, error) {
 	if backend != S3Backend {
-		return nil, fmt.Errorf("supported backend type: %q, got: %q", S3Backend, backend)
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: []string{S3Backend}}
 	}
 
 	cfg := &s3BackendConfig{}
@@ -94,7 +107,27 @@ func (s *S3Stater) BackendState(backend string, body hcl.Body) (terradep.State,
 	return s.urlFromConfig(s3Config(*cfg))
 }
 
-func (s *S3Stater) urlFromConfig(cfg s3Config) (s3StateURL, error) { //nolint:unparam
+// DecodeState implements [terradep.StateDecoder]
+func (s *S3Stater) DecodeState(fields map[string]any) (terradep.State, error) {
+	if backend := stringField(fields, "backend"); backend != S3Backend {
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: []string{S3Backend}}
+	}
+
+	encrypt, _ := fields["encrypt"].(bool)
+	return S3State{
+		Bucket:             stringField(fields, "bucket"),
+		Key:                stringField(fields, "key"),
+		Region:             stringField(fields, "region"),
+		Encrypt:            encrypt,
+		WorkspaceKeyPrefix: stringField(fields, "workspace_key_prefix"),
+		RoleARN:            stringField(fields, "role_arn"),
+		Profile:            stringField(fields, "profile"),
+		Account:            stringField(fields, "account"),
+		identity:           stringField(fields, "identity"),
+	}, nil
+}
+
+func (s *S3Stater) urlFromConfig(cfg s3Config) (S3State, error) { //nolint:unparam
 	u := url.URL{}
 	u.Scheme = S3Backend
 	u.Host = cfg.Bucket
@@ -107,27 +140,72 @@ func (s *S3Stater) urlFromConfig(cfg s3Config) (s3StateURL, error) { //nolint:un
 		q.Set("encrypt", strconv.FormatBool(cfg.Encrypt))
 	}
 
-	return s3StateURL(u.String()), nil
+	roleARN := s3RoleARN(cfg)
+	return S3State{
+		Bucket:             cfg.Bucket,
+		Key:                cfg.Key,
+		Region:             cfg.Region,
+		Encrypt:            cfg.Encrypt,
+		WorkspaceKeyPrefix: cfg.WorkspaceKeyPrefix,
+		RoleARN:            roleARN,
+		Profile:            cfg.Profile,
+		Account:            s3Account(roleARN, cfg.Profile),
+		identity:           u.String(),
+	}, nil
+}
+
+// s3RoleARN returns the role assumed by the backend, attribute role_arn of block assume_role takes precedence over deprecated attribute role_arn
+func s3RoleARN(cfg s3Config) string {
+	if v := cfg.AssumeRole; !v.IsNull() && v.IsKnown() && v.Type().IsObjectType() && v.Type().HasAttribute("role_arn") {
+		if arn := v.GetAttr("role_arn"); arn.Type() == cty.String && arn.IsKnown() && !arn.IsNull() {
+			return arn.AsString()
+		}
+	}
+
+	return cfg.RoleARN
+}
+
+// s3Account returns the account of the role assumed by the backend or the profile when no role is assumed
+func s3Account(roleARN, profile string) string {
+	// arn:partition:iam::account:role/name
+	if parts := strings.SplitN(roleARN, ":", 6); len(parts) == 6 && parts[4] != "" {
+		return parts[4]
+	}
+	if profile != "" {
+		return "profile:" + profile
+	}
+
+	return ""
 }
 
 type s3Config struct {
-	Bucket  string
-	Key     string
-	Region  string
-	Encrypt bool
-	Remain  *hcl.Body
+	Bucket             string
+	Key                string
+	Region             string
+	Encrypt            bool
+	RoleARN            string
+	Profile            string
+	WorkspaceKeyPrefix string
+	AssumeRole         cty.Value
+	Remain             hcl.Body
 }
 
 type s3BackendConfig struct {
-	Bucket  string `hcl:"bucket,attr"`
-	Key     string `hcl:"key,attr"`
-	Region  string `hcl:"region,attr"`
-	Encrypt bool   `hcl:"encrypt,attr"`
+	Bucket             string    `hcl:"bucket,attr"`
+	Key                string    `hcl:"key,attr"`
+	Region             string    `hcl:"region,attr"`
+	Encrypt            bool      `hcl:"encrypt,attr"`
+	RoleARN            string    `hcl:"role_arn,optional"`
+	Profile            string    `hcl:"profile,optional"`
+	WorkspaceKeyPrefix string    `hcl:"workspace_key_prefix,optional"`
+	AssumeRole         cty.Value `hcl:"assume_role,optional"`
 
-	Remain *hcl.Body `hcl:"remain,optional"`
+	// Remain are other arguments of the backend, e.g. dynamodb_table, which do not change the state
+	Remain hcl.Body `hcl:",remain"`
 }
 
-// S3State represents Terraform state stored in S3 bucket
+// S3State represents Terraform state stored in S3 bucket. Region and Encrypt are always set from the configuration,
+// but they are part of the identity only when [S3Stater] was created with [WithS3Region] and [WithS3Encryption]
 type S3State struct {
 	// Bucket is name of S3 bucket
 	Bucket string
@@ -137,11 +215,57 @@ type S3State struct {
 	Region string
 	// Encrypt indicates whether state is encrypted
 	Encrypt bool
+	// WorkspaceKeyPrefix is the prefix of keys of non-default workspaces, empty when the backend uses [terradep.S3WorkspaceKeyPrefix]
+	WorkspaceKeyPrefix string
+	// RoleARN is the role assumed to read the state, set with attribute role_arn or block assume_role
+	RoleARN string
+	// Profile is the profile of AWS shared configuration used to read the state
+	Profile string
+	// Account is AWS account id of the role assumed by the backend or terraform_remote_state, or profile:<name> when only the profile is set.
+	// It is empty when none of them is set or the state was restored from the identity
+	Account string
+
+	// identity is URL with scheme s3, e.g. s3://bucket/key?region=eu-west-1
+	identity string
+}
+
+// String implements [terradep.State]
+func (s S3State) String() string {
+	return s.identity
+}
+
+// Backend implements [terradep.State]
+func (s S3State) Backend() string {
+	return S3Backend
+}
+
+// Identity implements [terradep.State]
+func (s S3State) Identity() string {
+	return s.identity
 }
 
-type s3StateURL string
+// WorkspaceKey returns the key of the object storing the state of the workspace
+func (s S3State) WorkspaceKey(workspace string) string {
+	if workspace == "" || workspace == terradep.DefaultWorkspace {
+		return s.Key
+	}
+	prefix := s.WorkspaceKeyPrefix
+	if prefix == "" {
+		prefix = terradep.S3WorkspaceKeyPrefix
+	}
+
+	return prefix + "/" + workspace + "/" + s.Key
+}
+
+// MarshalJSON implements [json.Marshaler]
+func (s S3State) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{"bucket": s.Bucket, "key": s.Key, "region": s.Region, "encrypt": s.Encrypt}
+	optional := map[string]string{"workspace_key_prefix": s.WorkspaceKeyPrefix, "role_arn": s.RoleARN, "profile": s.Profile, "account": s.Account}
+	for name, value := range optional {
+		if value != "" {
+			fields[name] = value
+		}
+	}
 
-// String implements State
-func (s s3StateURL) String() string {
-	return string(s)
+	return terradep.MarshalState(s, fields)
 }
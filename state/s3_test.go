@@ -0,0 +1,68 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep/state"
+)
+
+func TestS3StaterRemoteState(t *testing.T) {
+	base := map[string]cty.Value{
+		"bucket": cty.StringVal("tf-state"),
+		"key":    cty.StringVal("network/terraform.tfstate"),
+		"region": cty.StringVal("eu-west-1"),
+	}
+
+	tests := []struct {
+		name    string
+		extra   map[string]cty.Value
+		account string
+		wantErr bool
+	}{
+		{name: "without role and profile"},
+		{name: "null profile", extra: map[string]cty.Value{"profile": cty.NullVal(cty.String)}},
+		{name: "unknown profile", extra: map[string]cty.Value{"profile": cty.UnknownVal(cty.String)}},
+		{name: "null role_arn", extra: map[string]cty.Value{"role_arn": cty.NullVal(cty.String)}},
+		{name: "unknown role_arn", extra: map[string]cty.Value{"role_arn": cty.UnknownVal(cty.String)}},
+		{name: "dynamic null", extra: map[string]cty.Value{"profile": cty.NullVal(cty.DynamicPseudoType)}},
+		{name: "profile", extra: map[string]cty.Value{"profile": cty.StringVal("prod")}, account: "profile:prod"},
+		{name: "role_arn", extra: map[string]cty.Value{"role_arn": cty.StringVal("arn:aws:iam::123456789012:role/tf")}, account: "123456789012"},
+		{name: "profile not a string", extra: map[string]cty.Value{"profile": cty.NumberIntVal(1)}, wantErr: true},
+		{name: "role_arn not a string", extra: map[string]cty.Value{"role_arn": cty.ListValEmpty(cty.String)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := make(map[string]cty.Value, len(base)+len(tt.extra))
+			for key, value := range base {
+				cfg[key] = value
+			}
+			for key, value := range tt.extra {
+				cfg[key] = value
+			}
+
+			got, err := state.NewS3Stater().RemoteState(state.S3Backend, cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got state: %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			s3, ok := got.(state.S3State)
+			if !ok {
+				t.Fatalf("expected S3State, got: %T", got)
+			}
+			if s3.String() != "s3://tf-state/network/terraform.tfstate" {
+				t.Errorf("unexpected state: %s", s3)
+			}
+			if s3.Account != tt.account {
+				t.Errorf("unexpected account: %q, want: %q", s3.Account, tt.account)
+			}
+		})
+	}
+}
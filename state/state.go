@@ -2,6 +2,7 @@ package state
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
@@ -24,7 +25,7 @@ func NewByTypeStater(staters map[string]terradep.Stater) *ByBackendStater {
 func (s *ByBackendStater) BackendState(backend string, body hcl.Body) (terradep.State, error) {
 	next, ok := s.staters[backend]
 	if !ok {
-		return nil, fmt.Errorf("supported backends: %v, got: %q", s.supportedBackends(), backend)
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: s.supportedBackends()}
 	}
 
 	return next.BackendState(backend, body)
@@ -34,16 +35,57 @@ func (s *ByBackendStater) BackendState(backend string, body hcl.Body) (terradep.
 func (s *ByBackendStater) RemoteState(backend string, stateCfg map[string]cty.Value) (terradep.State, error) {
 	next, ok := s.staters[backend]
 	if !ok {
-		return nil, fmt.Errorf("supported backends: %v, got: %q", s.supportedBackends(), backend)
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: s.supportedBackends()}
 	}
 
 	return next.RemoteState(backend, stateCfg)
 }
 
+// DecodeState implements [terradep.StateDecoder], the state is decoded by the stater of its backend
+func (s *ByBackendStater) DecodeState(fields map[string]any) (terradep.State, error) {
+	backend := stringField(fields, "backend")
+	next, ok := s.staters[backend]
+	if !ok {
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: s.supportedBackends()}
+	}
+
+	return decodeState(next, fields)
+}
+
 func (s *ByBackendStater) supportedBackends() []string {
 	backends := make([]string, 0, len(s.staters))
 	for backend := range s.staters {
 		backends = append(backends, backend)
 	}
+	sort.Strings(backends)
 	return backends
 }
+
+// decodeState decodes the state with the stater, when it implements [terradep.StateDecoder]
+func decodeState(stater terradep.Stater, fields map[string]any) (terradep.State, error) {
+	decoder, ok := stater.(terradep.StateDecoder)
+	if !ok {
+		return nil, &terradep.UnsupportedBackendError{Backend: stringField(fields, "backend")}
+	}
+
+	return decoder.DecodeState(fields)
+}
+
+// stringField returns the field of the state encoded with [terradep.MarshalState], empty when it is not a string
+func stringField(fields map[string]any, name string) string {
+	value, _ := fields[name].(string)
+	return value
+}
+
+// stringAttribute returns the value of attribute of terraform_remote_state config, empty when it is null or unknown,
+// e.g. set from variable without default. Returns error when it is not a string
+func stringAttribute(name string, value cty.Value) (string, error) {
+	if value.IsNull() || !value.IsKnown() {
+		return "", nil
+	}
+	if !value.Type().Equals(cty.String) {
+		return "", fmt.Errorf("%s of remote state must be a string, got: %s", name, value.Type().FriendlyName())
+	}
+
+	return value.AsString(), nil
+}
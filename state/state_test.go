@@ -0,0 +1,95 @@
+package state_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/state"
+)
+
+func TestDecodeStateRestoresEncodedState(t *testing.T) {
+	tfcStater := state.NewTFCStater()
+	stater := state.Cached(state.NewByTypeStater(map[string]terradep.Stater{
+		state.S3Backend:     state.NewS3Stater(state.WithS3Region()),
+		state.RemoteBackend: tfcStater,
+		state.LocalBackend:  state.NewLocalStater(),
+	}))
+
+	tests := []struct {
+		name    string
+		backend string
+		cfg     map[string]cty.Value
+	}{
+		{
+			name:    "s3 with role",
+			backend: state.S3Backend,
+			cfg: map[string]cty.Value{
+				"bucket":   cty.StringVal("b"),
+				"key":      cty.StringVal("network"),
+				"region":   cty.StringVal("eu-west-1"),
+				"encrypt":  cty.True,
+				"role_arn": cty.StringVal("arn:aws:iam::111111111111:role/terraform"),
+			},
+		},
+		{
+			name:    "s3 with profile and workspace key prefix",
+			backend: state.S3Backend,
+			cfg: map[string]cty.Value{
+				"bucket":               cty.StringVal("b"),
+				"key":                  cty.StringVal("network"),
+				"profile":              cty.StringVal("reader"),
+				"workspace_key_prefix": cty.StringVal("workspaces"),
+			},
+		},
+		{
+			name:    "tfc workspace",
+			backend: state.RemoteBackend,
+			cfg: map[string]cty.Value{
+				"organization": cty.StringVal("org"),
+				"workspaces":   cty.ObjectVal(map[string]cty.Value{"name": cty.StringVal("network")}),
+			},
+		},
+		{
+			name:    "local",
+			backend: state.LocalBackend,
+			cfg:     map[string]cty.Value{"path": cty.StringVal("/project/network.tfstate")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := stater.RemoteState(tt.backend, tt.cfg)
+			if err != nil {
+				t.Fatalf("reading state: %v", err)
+			}
+
+			b, err := json.Marshal(want)
+			if err != nil {
+				t.Fatalf("encoding state: %v", err)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(b, &fields); err != nil {
+				t.Fatalf("decoding fields of state: %v", err)
+			}
+
+			got, err := stater.DecodeState(fields)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("decoded state: %#v, want: %#v", got, want)
+			}
+		})
+	}
+
+	t.Run("unsupported backend", func(t *testing.T) {
+		_, err := stater.DecodeState(map[string]any{"backend": "gcs", "identity": "gs://b/network"})
+		if !errors.Is(err, terradep.ErrUnsupportedBackend) {
+			t.Errorf("expected error: %v, got: %v", terradep.ErrUnsupportedBackend, err)
+		}
+	})
+}
@@ -0,0 +1,267 @@
+package state
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+)
+
+const (
+	// RemoteBackend is key of Terraform backend type used to access Terraform Cloud and Terraform Enterprise
+	RemoteBackend = "remote"
+	// TFCDefaultHostname is hostname of Terraform Cloud used when none is configured
+	TFCDefaultHostname = "app.terraform.io"
+
+	tfcScheme           = "tfc"
+	tfcHostnameEnv      = "TF_CLOUD_HOSTNAME"
+	tfcOrganizationEnv  = "TF_CLOUD_ORGANIZATION"
+	tfcWorkspaceNameEnv = "TF_WORKSPACE"
+)
+
+// TFCStater is a [terradep.Stater] supporting [terradep.CloudBackend] and backend type [RemoteBackend].
+// State is identified by Terraform Cloud hostname, organization and workspace, e.g. tfc://app.terraform.io/my-org/my-workspace
+type TFCStater struct{}
+
+// NewTFCStater returns instance of [TFCStater]
+func NewTFCStater() *TFCStater {
+	return &TFCStater{}
+}
+
+/*
+example:
+
+	cloud {
+	  hostname     = "app.terraform.io"
+	  organization = "some-org"
+
+	  workspaces {
+		name = "some-workspace"
+	  }
+	}
+
+	backend "remote" {
+	  organization = "some-org"
+
+	  workspaces {
+		prefix = "some-"
+	  }
+	}
+*/
+type tfcBackendConfig struct {
+	Hostname     string `hcl:"hostname,optional"`
+	Organization string `hcl:"organization,optional"`
+	Workspaces   *struct {
+		Name    string   `hcl:"name,optional"`
+		Prefix  string   `hcl:"prefix,optional"`
+		Tags    []string `hcl:"tags,optional"`
+		Project string   `hcl:"project,optional"`
+
+		Remain hcl.Body `hcl:",remain"`
+	} `hcl:"workspaces,block"`
+
+	Remain hcl.Body `hcl:",remain"`
+}
+
+type tfcConfig struct {
+	Hostname     string
+	Organization string
+	Name         string
+	Prefix       string
+	Tags         []string
+}
+
+// BackendState implements [terradep.Stater]
+func (s *TFCStater) BackendState(backend string, body hcl.Body) (terradep.State, error) {
+	if backend != terradep.CloudBackend && backend != RemoteBackend {
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: []string{terradep.CloudBackend, RemoteBackend}}
+	}
+
+	cfg := &tfcBackendConfig{}
+	diags := gohcl.DecodeBody(body, nil, cfg)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("reading %s state: %w", backend, diags)
+	}
+
+	tc := tfcConfig{Hostname: cfg.Hostname, Organization: cfg.Organization}
+	if cfg.Workspaces != nil {
+		tc.Name = cfg.Workspaces.Name
+		tc.Prefix = cfg.Workspaces.Prefix
+		tc.Tags = cfg.Workspaces.Tags
+	}
+
+	if backend == terradep.CloudBackend {
+		// cloud block can be configured with environment variables, remote backend can not
+		tc = tc.withEnvDefaults()
+	}
+
+	return tfcURLFromConfig(tc)
+}
+
+// RemoteState implements [terradep.Stater]. Only backend type [RemoteBackend] is supported, because Terraform does not allow to use cloud in terraform_remote_state
+func (s *TFCStater) RemoteState(backend string, stateCfg map[string]cty.Value) (terradep.State, error) {
+	if backend != RemoteBackend {
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: []string{RemoteBackend}}
+	}
+
+	cfg := tfcConfig{}
+	for key, value := range stateCfg {
+		var err error
+		switch key {
+		case "hostname":
+			cfg.Hostname, err = stringAttribute(key, value)
+		case "organization":
+			cfg.Organization, err = stringAttribute(key, value)
+		case "workspaces":
+			if value.IsNull() || !value.IsKnown() {
+				continue
+			}
+			if !value.Type().IsObjectType() && !value.Type().IsMapType() {
+				return nil, fmt.Errorf("remote state workspaces must be an object")
+			}
+			for wsKey, wsValue := range value.AsValueMap() {
+				switch wsKey {
+				case "name":
+					cfg.Name, err = stringAttribute("workspaces."+wsKey, wsValue)
+				case "prefix":
+					cfg.Prefix, err = stringAttribute("workspaces."+wsKey, wsValue)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tfcURLFromConfig(cfg)
+}
+
+func (c tfcConfig) withEnvDefaults() tfcConfig {
+	if c.Hostname == "" {
+		c.Hostname = os.Getenv(tfcHostnameEnv)
+	}
+	if c.Organization == "" {
+		c.Organization = os.Getenv(tfcOrganizationEnv)
+	}
+	if c.Name == "" && c.Prefix == "" && len(c.Tags) == 0 {
+		c.Name = os.Getenv(tfcWorkspaceNameEnv)
+	}
+
+	return c
+}
+
+// DecodeState implements [terradep.StateDecoder]
+func (s *TFCStater) DecodeState(fields map[string]any) (terradep.State, error) {
+	if backend := stringField(fields, "backend"); backend != RemoteBackend {
+		return nil, &terradep.UnsupportedBackendError{Backend: backend, Supported: []string{RemoteBackend}}
+	}
+
+	state := TFCState{
+		Hostname:     stringField(fields, "hostname"),
+		Organization: stringField(fields, "organization"),
+		Workspace:    stringField(fields, "workspace"),
+		Prefix:       stringField(fields, "prefix"),
+		identity:     stringField(fields, "identity"),
+	}
+	tags, _ := fields["tags"].([]any)
+	for _, tag := range tags {
+		if tag, ok := tag.(string); ok {
+			state.Tags = append(state.Tags, tag)
+		}
+	}
+
+	return state, nil
+}
+
+func tfcURLFromConfig(cfg tfcConfig) (TFCState, error) {
+	if cfg.Organization == "" {
+		return TFCState{}, fmt.Errorf("organization is required")
+	}
+
+	u := url.URL{}
+	u.Scheme = tfcScheme
+	u.Host = cfg.Hostname
+	if u.Host == "" {
+		u.Host = TFCDefaultHostname
+	}
+
+	s := TFCState{Hostname: u.Host, Organization: cfg.Organization}
+	switch {
+	case cfg.Name != "":
+		u.Path = cfg.Organization + "/" + cfg.Name
+		s.Workspace = cfg.Name
+	case cfg.Prefix != "":
+		// actual workspace name is a prefix followed by the name of selected workspace
+		u.Path = cfg.Organization + "/" + cfg.Prefix + "*"
+		s.Prefix = cfg.Prefix
+	case len(cfg.Tags) != 0:
+		tags := append([]string(nil), cfg.Tags...)
+		sort.Strings(tags)
+		u.Path = cfg.Organization
+		q := u.Query()
+		q.Set("tags", strings.Join(tags, ","))
+		u.RawQuery = q.Encode()
+		s.Tags = tags
+	default:
+		return TFCState{}, fmt.Errorf("workspaces name, prefix or tags are required, organization: %q", cfg.Organization)
+	}
+	s.identity = u.String()
+
+	return s, nil
+}
+
+// TFCState represents Terraform state stored in the workspace of Terraform Cloud or Terraform Enterprise.
+// Exactly one of Workspace, Prefix and Tags is set, like in the configuration of the workspaces
+type TFCState struct {
+	Hostname     string
+	Organization string
+	// Workspace is the name of the workspace
+	Workspace string
+	// Prefix is the prefix of the names of the workspaces, the workspace is selected with terraform workspace select
+	Prefix string
+	// Tags of the workspaces, sorted
+	Tags []string
+
+	// identity is URL with scheme tfc, e.g. tfc://app.terraform.io/my-org/my-workspace
+	identity string
+}
+
+// String implements [terradep.State]
+func (s TFCState) String() string {
+	return s.identity
+}
+
+// Backend implements [terradep.State]. It is [RemoteBackend] also for the states configured with [terradep.CloudBackend],
+// because they are read with terraform_remote_state using the backend remote
+func (s TFCState) Backend() string {
+	return RemoteBackend
+}
+
+// Identity implements [terradep.State]
+func (s TFCState) Identity() string {
+	return s.identity
+}
+
+// MarshalJSON implements [json.Marshaler]
+func (s TFCState) MarshalJSON() ([]byte, error) {
+	fields := map[string]any{"hostname": s.Hostname, "organization": s.Organization}
+	switch {
+	case s.Workspace != "":
+		fields["workspace"] = s.Workspace
+	case s.Prefix != "":
+		fields["prefix"] = s.Prefix
+	default:
+		fields["tags"] = s.Tags
+	}
+
+	return terradep.MarshalState(s, fields)
+}
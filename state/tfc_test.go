@@ -0,0 +1,126 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep/state"
+)
+
+func TestTFCStaterRemoteState(t *testing.T) {
+	workspaces := func(attrs map[string]cty.Value) cty.Value {
+		return cty.ObjectVal(attrs)
+	}
+
+	tests := []struct {
+		name      string
+		cfg       map[string]cty.Value
+		hostname  string
+		workspace string
+		prefix    string
+		wantErr   bool
+	}{
+		{
+			name: "name",
+			cfg: map[string]cty.Value{
+				"hostname":     cty.StringVal("tfe.example.com"),
+				"organization": cty.StringVal("org"),
+				"workspaces":   workspaces(map[string]cty.Value{"name": cty.StringVal("network")}),
+			},
+			hostname:  "tfe.example.com",
+			workspace: "network",
+		},
+		{
+			name: "null hostname",
+			cfg: map[string]cty.Value{
+				"hostname":     cty.NullVal(cty.String),
+				"organization": cty.StringVal("org"),
+				"workspaces":   workspaces(map[string]cty.Value{"name": cty.StringVal("network")}),
+			},
+			hostname:  state.TFCDefaultHostname,
+			workspace: "network",
+		},
+		{
+			name: "unknown hostname",
+			cfg: map[string]cty.Value{
+				"hostname":     cty.UnknownVal(cty.String),
+				"organization": cty.StringVal("org"),
+				"workspaces":   workspaces(map[string]cty.Value{"name": cty.StringVal("network")}),
+			},
+			hostname:  state.TFCDefaultHostname,
+			workspace: "network",
+		},
+		{
+			name: "null name with prefix",
+			cfg: map[string]cty.Value{
+				"organization": cty.StringVal("org"),
+				"workspaces":   workspaces(map[string]cty.Value{"name": cty.NullVal(cty.String), "prefix": cty.StringVal("network-")}),
+			},
+			hostname: state.TFCDefaultHostname,
+			prefix:   "network-",
+		},
+		{
+			name: "null organization",
+			cfg: map[string]cty.Value{
+				"organization": cty.NullVal(cty.String),
+				"workspaces":   workspaces(map[string]cty.Value{"name": cty.StringVal("network")}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "null workspaces",
+			cfg: map[string]cty.Value{
+				"organization": cty.StringVal("org"),
+				"workspaces":   cty.NullVal(cty.Object(map[string]cty.Type{"name": cty.String})),
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown name",
+			cfg: map[string]cty.Value{
+				"organization": cty.StringVal("org"),
+				"workspaces":   workspaces(map[string]cty.Value{"name": cty.UnknownVal(cty.String)}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "organization not a string",
+			cfg: map[string]cty.Value{
+				"organization": cty.NumberIntVal(1),
+				"workspaces":   workspaces(map[string]cty.Value{"name": cty.StringVal("network")}),
+			},
+			wantErr: true,
+		},
+		{
+			name: "name not a string",
+			cfg: map[string]cty.Value{
+				"organization": cty.StringVal("org"),
+				"workspaces":   workspaces(map[string]cty.Value{"name": cty.True}),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := state.NewTFCStater().RemoteState(state.RemoteBackend, tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got state: %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			tfc, ok := got.(state.TFCState)
+			if !ok {
+				t.Fatalf("expected TFCState, got: %T", got)
+			}
+			if tfc.Hostname != tt.hostname || tfc.Organization != "org" || tfc.Workspace != tt.workspace || tfc.Prefix != tt.prefix {
+				t.Errorf("unexpected state: %+v", tfc)
+			}
+		})
+	}
+}
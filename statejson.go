@@ -0,0 +1,18 @@
+package terradep
+
+import "encoding/json"
+
+// MarshalState encodes the state as JSON object with fields backend, identity and the fields specific to the backend,
+// so implementations of [State] are encoded the same way, e.g. {"backend":"s3","identity":"s3://bucket/key","bucket":"bucket","key":"key"}
+func MarshalState(s State, fields map[string]any) ([]byte, error) {
+	out := make(map[string]any, len(fields)+2)
+	for key, value := range fields {
+		out[key] = value
+	}
+	if backend := s.Backend(); backend != "" {
+		out["backend"] = backend
+	}
+	out["identity"] = s.Identity()
+
+	return json.Marshal(out)
+}
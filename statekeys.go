@@ -0,0 +1,115 @@
+package terradep
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// StateKeyRule declares how the state of the deployment must be named, so states can be found by the path of the deployment.
+// Mismatches are reported by the [Scanner] as [RuleStateKeyMismatch].
+//
+// Example: deployments are in directories <env>/<deployment> and store the state in bucket tf-state-<env> under key <env>/<deployment>/terraform.tfstate:
+//
+//	StateKeyRule{
+//		Path:    regexp.MustCompile(`^(?P<env>[^/]+)/(?P<deployment>[^/]+)$`),
+//		Backend: "s3",
+//		Fields: map[string]string{
+//			"bucket": "tf-state-${env}",
+//			"key":    "${env}/${deployment}/terraform.tfstate",
+//		},
+//	}
+type StateKeyRule struct {
+	// Path must match the path of the deployment relative to the scanned root with forward slashes, otherwise the rule is ignored.
+	// Submatches can be referenced in Fields
+	Path *regexp.Regexp
+	// Backend of the state, e.g. s3. Empty matches all the backends
+	Backend string
+	// Fields are expected values of the fields of the state encoded as JSON, e.g. bucket and key of S3 state.
+	// Submatches of the Path are expanded with [regexp.Regexp.Expand] syntax, e.g. $1 or ${name}
+	Fields map[string]string
+}
+
+// WithStateKeyRules makes the [Scanner] check states of the deployments with all the matching rules
+func WithStateKeyRules(rules ...StateKeyRule) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.stateKeyRules = append(cfg.stateKeyRules, rules...)
+	}
+}
+
+// checkStateKeys reports fields of the state of the deployment at the path not matching the rules of the [Scanner]
+func (s *Scanner) checkStateKeys(sc *scan, path string, state State, backend *backendBlock) {
+	if len(s.stateKeyRules) == 0 {
+		return
+	}
+
+	rel := relativePath(sc.root, path)
+	var fields map[string]any
+	for _, rule := range s.stateKeyRules {
+		submatches := rule.Path.FindStringSubmatchIndex(rel)
+		if submatches == nil || (rule.Backend != "" && rule.Backend != state.Backend()) {
+			continue
+		}
+
+		if fields == nil {
+			var err error
+			if fields, err = stateFields(state); err != nil {
+				s.warn(sc, Diagnostic{Rule: RuleStateKeyMismatch, Summary: "cannot read fields of the state", Detail: err.Error(), Module: path, Range: rangePtr(backend.Range)})
+				return
+			}
+		}
+
+		for _, name := range sortedKeys(rule.Fields) {
+			expected := string(rule.Path.ExpandString(nil, rule.Fields[name], rel, submatches))
+			actual, ok := fields[name].(string)
+			if ok && actual == expected {
+				continue
+			}
+
+			s.warn(sc, Diagnostic{
+				Rule:    RuleStateKeyMismatch,
+				Summary: fmt.Sprintf("%s of the state does not follow the convention, expected: %q", name, expected),
+				Detail:  fmt.Sprintf("state: %s, %s: %v", state, name, fields[name]),
+				Module:  path,
+				Range:   attributeRange(backend, name),
+			})
+		}
+	}
+}
+
+// stateFields returns the state encoded as JSON object, e.g. by [MarshalState]
+func stateFields(state State) (map[string]any, error) {
+	if ws, ok := state.(WorkspaceState); ok {
+		state = ws.State
+	}
+	if _, ok := state.(json.Marshaler); !ok {
+		return map[string]any{"backend": state.Backend(), "identity": state.Identity()}, nil
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("encoding state: %s, %w", state, err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("state must be encoded as JSON object: %s, %w", state, err)
+	}
+
+	return fields, nil
+}
+
+// attributeRange returns range of the attribute of the backend block or range of the block, when the attribute is not set in it,
+// e.g. because it is set with backend config file
+func attributeRange(backend *backendBlock, name string) *hcl.Range {
+	content, _, _ := backend.Body.PartialContent(&hcl.BodySchema{Attributes: []hcl.AttributeSchema{{Name: name}}})
+	if content != nil {
+		if attr, ok := content.Attributes[name]; ok {
+			return rangePtr(attr.Range)
+		}
+	}
+
+	return rangePtr(backend.Range)
+}
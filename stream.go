@@ -0,0 +1,146 @@
+package terradep
+
+import (
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Deployment is a deployment found by [Scanner.Stream]. Unlike [Node] it does not link to other deployments,
+// it contains states of its dependencies instead
+type Deployment struct {
+	Path      string
+	Workspace string
+	Overlay   string
+	State     State
+	// Dependencies are states of the deployments this deployment depends on, which are the edges of the graph
+	Dependencies []State
+	// Modules are paths of local modules called by the deployment, set only when [Scanner] was created with [WithModuleEdges]
+	Modules []string
+	// Owner, Layer and Tags are read from the [Manifest], empty when the deployment does not have one
+	Owner string
+	Layer string
+	Tags  []string
+	// CodeOwners are owners of the deployment read from CODEOWNERS, set only when [Scanner] was created with [WithCodeOwners]
+	CodeOwners []string
+	Metadata   *ModuleMetadata
+	// Git is set only when [Scanner] was created with [WithGitMetadata] and the deployment is tracked in git repository
+	Git *GitInfo
+	// BackendRange points to the backend or cloud block of the deployment
+	BackendRange *hcl.Range
+	// Edges describe the dependencies on the states of Dependencies, keyed by [State.Identity], see [Deployment.EdgeMetadata]
+	Edges map[string]EdgeMetadata
+}
+
+// Stream scans the root like [Scanner.Scan], but instead of building the [Graph] it passes every deployment to emit
+// as soon as its module was scanned and forgets it, so even estates with thousands of modules are scanned in constant memory.
+// Deployments are emitted in walk order, except deployments declaring dependencies on other deployments by path,
+// in the [Manifest] or with [AnnotationPrefix], which are emitted at the end, when states of all the deployments are known.
+// Scan stops at the first error returned by emit
+func (s *Scanner) Stream(root string, emit func(Deployment) error) (Diagnostics, error) {
+	if err := checkDirExists(root); err != nil {
+		return nil, err
+	}
+
+	if err := s.validatePaths(); err != nil {
+		return nil, err
+	}
+
+	sc := s.newScan(root)
+	sc.stream = &stream{emit: emit}
+	if err := s.walkRoot(sc); err != nil {
+		return sc.diags, err
+	}
+
+	for _, path := range sc.stream.deferred {
+		if err := s.emitModule(sc, path); err != nil {
+			return sc.diags, err
+		}
+	}
+
+	return sc.diags, nil
+}
+
+// stream stores the state of [Scanner.Stream]
+type stream struct {
+	emit func(Deployment) error
+	// deferred are paths of the modules which will be emitted at the end of the scan
+	deferred []string
+}
+
+// moduleScanned is called when results of scanning the module were stored in the scan.
+// When the scan streams the results, it emits deployments of the module and removes them from the scan
+func (s *Scanner) moduleScanned(sc *scan, path string) error {
+	if sc.stream == nil {
+		return nil
+	}
+	sc.parser.Forget(path)
+
+	for _, declared := range sc.declaredDependencies(path) {
+		if !isDeclaredState(declared.Target) {
+			sc.stream.deferred = append(sc.stream.deferred, path)
+			return nil
+		}
+	}
+
+	return s.emitModule(sc, path)
+}
+
+// emitModule emits deployments of the module and removes everything but their states from the scan
+func (s *Scanner) emitModule(sc *scan, path string) error {
+	var deps []deployment
+	for dep := range sc.deps {
+		if dep.path == path {
+			deps = append(deps, dep)
+		}
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].less(deps[j])
+	})
+
+	var git *GitInfo
+	if s.gitMetadata && len(deps) != 0 && s.gitAvailable(sc) {
+		info, err := gitInfo(path)
+		if err != nil {
+			s.warn(sc, Diagnostic{Rule: RuleGitHistory, Summary: "cannot read git history of the deployment", Detail: err.Error(), Module: path})
+		}
+		git = info
+	}
+
+	for _, dep := range deps {
+		s.applyDeclared(sc, dep)
+
+		d := Deployment{
+			Path:         dep.path,
+			Workspace:    dep.workspace,
+			Overlay:      dep.overlay,
+			State:        sc.states[dep],
+			Dependencies: sc.deps[dep],
+			Modules:      sc.modules[dep],
+			CodeOwners:   sc.detailsOf(path).codeOwners,
+			Metadata:     sc.detailsOf(path).metadata,
+			Git:          git,
+			BackendRange: sc.detailsOf(path).backendRange,
+			Edges:        sc.detailsOf(path).edges,
+		}
+		if m := sc.detailsOf(path).manifest; m != nil {
+			d.Owner, d.Layer, d.Tags = m.Owner, m.Layer, m.Tags
+		}
+		if s.relativePaths {
+			d.Path = relativePath(sc.root, d.Path)
+			for i, module := range d.Modules {
+				d.Modules[i] = relativePath(sc.root, module)
+			}
+		}
+
+		delete(sc.deps, dep)
+		delete(sc.modules, dep)
+		if err := sc.stream.emit(d); err != nil {
+			return err
+		}
+	}
+	delete(sc.details, path)
+	delete(sc.annotations, path)
+
+	return nil
+}
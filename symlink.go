@@ -0,0 +1,134 @@
+package terradep
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/exp/slog"
+)
+
+// SymlinkPolicy controls how the [Scanner] handles symbolic links to directories
+type SymlinkPolicy string
+
+const (
+	// SymlinkSkip ignores symbolic links. It is the default policy
+	SymlinkSkip SymlinkPolicy = "skip"
+	// SymlinkFollow follows symbolic links, also these pointing outside the scanned root.
+	// Links creating a loop are detected and skipped
+	SymlinkFollow SymlinkPolicy = "follow"
+	// SymlinkFollowWithinRoot follows only symbolic links pointing to directories inside the scanned root.
+	// Links creating a loop are detected and skipped
+	SymlinkFollowWithinRoot SymlinkPolicy = "within-root"
+)
+
+// SymlinkPolicies lists all supported values of [SymlinkPolicy]
+var SymlinkPolicies = []SymlinkPolicy{SymlinkSkip, SymlinkFollow, SymlinkFollowWithinRoot}
+
+// ParseSymlinkPolicy returns [SymlinkPolicy] with the name or error, when there is no such policy
+func ParseSymlinkPolicy(name string) (SymlinkPolicy, error) {
+	for _, policy := range SymlinkPolicies {
+		if string(policy) == name {
+			return policy, nil
+		}
+	}
+
+	return "", fmt.Errorf("supported symlink policies: %v, got: %q", SymlinkPolicies, name)
+}
+
+// WithSymlinkPolicy sets how the [Scanner] handles symbolic links to directories. Defaults to [SymlinkSkip]
+func WithSymlinkPolicy(policy SymlinkPolicy) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.symlinks = policy
+	}
+}
+
+// followSymlink walks the directory pointed by the symlink, when it is allowed by the [SymlinkPolicy]
+func (s *Scanner) followSymlink(sc *scan, path string) error {
+	if s.symlinks == "" || s.symlinks == SymlinkSkip {
+		s.log.Debug("skipping symlink", slog.String("path", path))
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			sc.report(path, DirSkippedSymlink, fmt.Sprintf("symlink policy: %q", SymlinkSkip))
+		}
+		return nil
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		s.warn(sc, Diagnostic{Rule: RuleBrokenSymlink, Summary: "skipping broken symlink: " + path, Detail: err.Error()})
+		return nil
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return fmt.Errorf("stating symlink target: %s, %w", target, err)
+	}
+
+	if !info.IsDir() {
+		return nil
+	}
+
+	if s.symlinks == SymlinkFollowWithinRoot {
+		root, err := filepath.EvalSymlinks(sc.root)
+		if err != nil {
+			return fmt.Errorf("resolving scanned root: %s, %w", sc.root, err)
+		}
+
+		if !isSubPath(root, target) {
+			s.log.Debug("skipping symlink pointing outside of scanned root", slog.String("path", path), slog.String("target", target))
+			return nil
+		}
+	}
+
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("resolving parent of symlink: %s, %w", path, err)
+	}
+
+	if isSubPath(target, parent) {
+		s.warn(sc, Diagnostic{Rule: RuleSymlinkLoop, Summary: "skipping symlink creating a loop: " + path, Detail: "target: " + target})
+		return nil
+	}
+
+	if _, ok := sc.walked[target]; ok {
+		s.log.Debug("skipping symlink to already walked directory", slog.String("path", path), slog.String("target", target))
+		return nil
+	}
+	sc.walked[target] = struct{}{}
+
+	s.log.Debug("following symlink", slog.String("path", path), slog.String("target", target))
+	return s.walk(sc, target, path)
+}
+
+// alreadyScanned checks whether the module was scanned before through another path. It is possible only when symlinks are followed
+func (s *Scanner) alreadyScanned(sc *scan, path string) bool {
+	if s.symlinks == "" || s.symlinks == SymlinkSkip {
+		return false
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+
+	if _, ok := sc.scanned[resolved]; ok {
+		return true
+	}
+	sc.scanned[resolved] = struct{}{}
+
+	return false
+}
+
+// displayPath translates walkedPath inside the walkedDir to the path inside displayDir
+func displayPath(walkedDir, displayDir, walkedPath string) string {
+	if walkedDir == displayDir {
+		return walkedPath
+	}
+
+	rel, err := filepath.Rel(walkedDir, walkedPath)
+	if err != nil {
+		return walkedPath
+	}
+
+	return filepath.Join(displayDir, rel)
+}
@@ -0,0 +1,100 @@
+package terradep_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"go.interactor.dev/terradep"
+	"go.interactor.dev/terradep/terradeptest"
+)
+
+func TestScanFollowsSymlinksByPolicy(t *testing.T) {
+	backend := func(key string) string {
+		return `
+terraform {
+  backend "s3" {
+    bucket = "b"
+    key    = "` + key + `"
+  }
+}
+`
+	}
+	dir := terradeptest.Dir(t, terradeptest.Files{
+		"root/live/network/main.tf": backend("network"),
+		"root/shared/app/main.tf":   backend("app"),
+		"external/dns/main.tf":      backend("dns"),
+	})
+	root := filepath.Join(dir, "root")
+	links := map[string]string{
+		// within the root, the deployment must not be scanned twice
+		"live/app": "../shared/app",
+		// outside the root
+		"live/external": "../../external",
+		// loop to the parent
+		"live/loop":   "..",
+		"live/broken": "../missing",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(link))); err != nil {
+			t.Skipf("creating symlink: %v", err)
+		}
+	}
+
+	tests := []struct {
+		policy    terradep.SymlinkPolicy
+		want      string
+		wantRules []string
+	}{
+		{
+			policy: terradep.SymlinkSkip,
+			want: `live/network s3://?bucket=b&key=network
+shared/app s3://?bucket=b&key=app
+`,
+		},
+		{
+			policy: terradep.SymlinkFollow,
+			want: `live/app s3://?bucket=b&key=app
+live/external/dns s3://?bucket=b&key=dns
+live/network s3://?bucket=b&key=network
+`,
+			wantRules: []string{terradep.RuleBrokenSymlink, terradep.RuleSymlinkLoop},
+		},
+		{
+			policy: terradep.SymlinkFollowWithinRoot,
+			want: `live/app s3://?bucket=b&key=app
+live/network s3://?bucket=b&key=network
+`,
+			wantRules: []string{terradep.RuleBrokenSymlink, terradep.RuleSymlinkLoop},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s := terradep.NewScanner(nil, terradeptest.NewStater(), terradep.WithRelativePaths(), terradep.WithSymlinkPolicy(tt.policy))
+			graph, diags, err := s.Scan(root)
+			if err != nil {
+				t.Fatalf("scanning directory: %v", err)
+			}
+
+			if got := terradeptest.Describe(graph); got != tt.want {
+				t.Errorf("unexpected graph:\n%s\nwant:\n%s", got, tt.want)
+			}
+
+			var rules []string
+			for _, d := range diags {
+				rules = append(rules, d.Rule)
+			}
+			sort.Strings(rules)
+			if len(rules) != len(tt.wantRules) {
+				t.Fatalf("diagnostics: %v, want rules: %v", diags, tt.wantRules)
+			}
+			for i := range rules {
+				if rules[i] != tt.wantRules[i] {
+					t.Errorf("diagnostics: %v, want rules: %v", diags, tt.wantRules)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// Package terradeptest provides utilities for testing tools built on terradep: building graphs without scanning,
+// a fake [terradep.Stater] which does not need real backends, writing Terraform files defined in the test to a temporary directory
+// and comparing graphs with golden files
+package terradeptest
@@ -0,0 +1,76 @@
+package terradeptest
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"go.interactor.dev/terradep"
+)
+
+// Files are contents of the files keyed by their paths relative to the root, separated with slashes, e.g. live/app/main.tf
+type Files map[string]string
+
+// Dir writes the files to a new temporary directory removed at the end of the test and returns its path
+func Dir(tb testing.TB, files Files) string {
+	tb.Helper()
+
+	fsys := make(fstest.MapFS, len(files))
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	return DirFS(tb, fsys)
+}
+
+// DirFS copies all the files of fsys, e.g. [fstest.MapFS] or [embed.FS], to a new temporary directory removed at the end of the test and returns its path
+func DirFS(tb testing.TB, fsys fs.FS) string {
+	tb.Helper()
+
+	root := tb.TempDir()
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if d.IsDir() {
+			return os.MkdirAll(path, 0o700)
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return err
+		}
+		return os.WriteFile(path, content, 0o600)
+	})
+	if err != nil {
+		tb.Fatalf("writing files to directory: %s, %v", root, err)
+	}
+
+	return root
+}
+
+// Scan scans the directory with [Stater] and paths relative to the directory, see [terradep.WithRelativePaths].
+// Fails the test when the scan fails or reports any [terradep.Diagnostic]
+func Scan(tb testing.TB, dir string, opts ...terradep.ScannerOpt) *terradep.Graph {
+	tb.Helper()
+
+	s := terradep.NewScanner(nil, NewStater(), append([]terradep.ScannerOpt{terradep.WithRelativePaths()}, opts...)...)
+	graph, diags, err := s.Scan(dir)
+	if err != nil {
+		tb.Fatalf("scanning directory: %s, %v", dir, err)
+	}
+	for _, d := range diags {
+		tb.Errorf("unexpected diagnostic: %s", d)
+	}
+	if len(diags) != 0 {
+		tb.FailNow()
+	}
+
+	return graph
+}
@@ -0,0 +1,78 @@
+package terradeptest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.interactor.dev/terradep"
+)
+
+// UpdateGoldenEnv is the environment variable which, when set to true, makes [AssertGolden] write the golden files instead of comparing them
+const UpdateGoldenEnv = "TERRADEP_UPDATE_GOLDEN"
+
+// Describe returns the text describing deployments of the graph and their dependencies, one per line, ordered like [terradep.Graph.Nodes], e.g.
+//
+//	live/app s3://?bucket=b&key=app
+//	  -> s3://?bucket=b&key=network
+//	  -.-> module:modules/vpc
+//
+// Local modules are marked with dotted arrows. Details, like metadata or source ranges, are not described, so the text changes only when
+// the structure of the graph changes
+func Describe(graph *terradep.Graph) string {
+	sb := strings.Builder{}
+	for _, n := range graph.Nodes() {
+		if !graph.IsDeployment(n) {
+			continue
+		}
+
+		sb.WriteString(n.Path)
+		if n.Workspace != "" {
+			sb.WriteString(":" + n.Workspace)
+		}
+		if n.Overlay != "" {
+			sb.WriteString("#" + n.Overlay)
+		}
+		sb.WriteString(" " + n.State.String() + "\n")
+		for _, child := range n.Children {
+			sb.WriteString("  -> " + child.State.String() + "\n")
+		}
+		for _, module := range n.Modules {
+			sb.WriteString("  -.-> " + module.State.String() + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// AssertGolden compares [Describe] of the graph with the content of the golden file, e.g. testdata/graph.golden.
+// The file is written instead, when environment variable [UpdateGoldenEnv] is true.
+// Scan the graph with [Scan], so the paths do not depend on the temporary directory
+func AssertGolden(tb testing.TB, graph *terradep.Graph, golden string) {
+	tb.Helper()
+
+	got := Describe(graph)
+	if os.Getenv(UpdateGoldenEnv) == "true" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o700); err != nil {
+			tb.Fatalf("creating directory of golden file: %s, %v", golden, err)
+		}
+		if err := os.WriteFile(golden, []byte(got), 0o600); err != nil {
+			tb.Fatalf("writing golden file: %s, %v", golden, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if errors.Is(err, os.ErrNotExist) {
+		tb.Fatalf("golden file does not exist: %s, set %s=true to write it", golden, UpdateGoldenEnv)
+	}
+	if err != nil {
+		tb.Fatalf("reading golden file: %s, %v", golden, err)
+	}
+
+	if got != string(want) {
+		tb.Errorf("graph differs from golden file: %s, set %s=true to update it\ngot:\n%s\nwant:\n%s", golden, UpdateGoldenEnv, got, want)
+	}
+}
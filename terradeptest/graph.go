@@ -0,0 +1,73 @@
+package terradeptest
+
+import (
+	"fmt"
+	"testing"
+
+	"go.interactor.dev/terradep"
+)
+
+// GraphBuilder builds [terradep.Graph] without scanning, with [terradep.Graph.AddNode] and [terradep.Graph.AddEdge]
+type GraphBuilder struct {
+	deployments []*terradep.Node
+	// deps are states of the dependencies of the deployments, in the order of deployments
+	deps [][]string
+}
+
+// NewGraph returns empty [GraphBuilder]
+func NewGraph() *GraphBuilder {
+	return &GraphBuilder{}
+}
+
+// Deployment adds the deployment with [State] and its dependencies, which are states of other deployments added to the builder,
+// or external states, when no deployment has them
+func (b *GraphBuilder) Deployment(path, state string, dependencies ...string) *GraphBuilder {
+	return b.Node(&terradep.Node{Path: path, State: State(state)}, dependencies...)
+}
+
+// Node adds the deployment like [GraphBuilder.Deployment], but it can have workspace, overlay and details, e.g. Owner or Metadata
+func (b *GraphBuilder) Node(n *terradep.Node, dependencies ...string) *GraphBuilder {
+	b.deployments = append(b.deployments, n)
+	b.deps = append(b.deps, dependencies)
+
+	return b
+}
+
+// Build returns the graph. Returns error, e.g. [*terradep.CycleError], when the graph is invalid
+func (b *GraphBuilder) Build() (*terradep.Graph, error) {
+	graph := &terradep.Graph{}
+	byState := make(map[string]*terradep.Node)
+	for _, n := range b.deployments {
+		if err := graph.AddNode(n); err != nil {
+			return nil, err
+		}
+		byState[n.State.String()] = n
+	}
+
+	for i, n := range b.deployments {
+		for _, state := range b.deps[i] {
+			to, ok := byState[state]
+			if !ok {
+				to = &terradep.Node{State: State(state)}
+				byState[state] = to
+			}
+			if err := graph.AddEdge(n, to); err != nil {
+				return nil, fmt.Errorf("adding dependency of: %s on: %s, %w", n.Path, state, err)
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+// MustBuild returns the graph like [GraphBuilder.Build], but fails the test when the graph is invalid
+func (b *GraphBuilder) MustBuild(tb testing.TB) *terradep.Graph {
+	tb.Helper()
+
+	graph, err := b.Build()
+	if err != nil {
+		tb.Fatalf("building graph: %v", err)
+	}
+
+	return graph
+}
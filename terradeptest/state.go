@@ -0,0 +1,80 @@
+package terradeptest
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep"
+)
+
+// State is a [terradep.State] identified by the string, e.g. s3://bucket/key. Backend is the scheme of the URL
+type State string
+
+// String implements [terradep.State]
+func (s State) String() string {
+	return string(s)
+}
+
+// Backend implements [terradep.State]
+func (s State) Backend() string {
+	scheme, _, ok := strings.Cut(string(s), "://")
+	if !ok {
+		return ""
+	}
+
+	return scheme
+}
+
+// Identity implements [terradep.State]
+func (s State) Identity() string {
+	return string(s)
+}
+
+// Stater is a [terradep.Stater] accepting every backend. State is the backend type followed by sorted string attributes of the configuration,
+// e.g. backend "s3" { bucket = "b", key = "k" } and terraform_remote_state with backend = "s3" and config = { bucket = "b", key = "k" }
+// are both [State] s3://?bucket=b&key=k. Attributes which are not strings or cannot be evaluated without variables are ignored
+type Stater struct{}
+
+// NewStater returns new instance of [Stater]
+func NewStater() *Stater {
+	return &Stater{}
+}
+
+// BackendState implements [terradep.Stater]
+func (s *Stater) BackendState(backend string, body hcl.Body) (terradep.State, error) {
+	attrs, diags := body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	values := url.Values{}
+	for name, attr := range attrs {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() || !value.IsKnown() || value.IsNull() || !value.Type().Equals(cty.String) {
+			continue
+		}
+		values.Set(name, value.AsString())
+	}
+
+	return stateOf(backend, values), nil
+}
+
+// RemoteState implements [terradep.Stater]
+func (s *Stater) RemoteState(backend string, config map[string]cty.Value) (terradep.State, error) {
+	values := url.Values{}
+	for name, value := range config {
+		if !value.IsKnown() || value.IsNull() || !value.Type().Equals(cty.String) {
+			continue
+		}
+		values.Set(name, value.AsString())
+	}
+
+	return stateOf(backend, values), nil
+}
+
+func stateOf(backend string, values url.Values) State {
+	// Encode sorts the values by the name
+	return State(backend + "://?" + values.Encode())
+}
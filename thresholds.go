@@ -0,0 +1,112 @@
+package terradep
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Thresholds are structural limits of the graph checked by [CheckThresholds]. Zero or negative value means no limit
+type Thresholds struct {
+	// MaxDepth limits the depth of the deployments, i.e. length of the longest chain of dependencies on other deployments, see [Levels]
+	MaxDepth int
+	// MaxFanIn limits how many deployments depend on the state of single deployment
+	MaxFanIn int
+	// MaxDependencies limits how many states single deployment depends on
+	MaxDependencies int
+}
+
+// CheckThresholds finds deployments exceeding the limits ([RuleThreshold]), so architecture guardrails can be checked in CI.
+// Dependencies closing a cycle are ignored, cycles are reported by [CheckDeployments]. All the diagnostics have [SeverityError]
+func CheckThresholds(deployments []Deployment, limits Thresholds) Diagnostics {
+	byState := make(map[string]Deployment, len(deployments))
+	fanIn := make(map[string]int, len(deployments))
+	for _, d := range deployments {
+		byState[d.State.Identity()] = d
+	}
+	for _, d := range deployments {
+		for _, dep := range uniqueStates(d.Dependencies) {
+			fanIn[dep.Identity()]++
+		}
+	}
+
+	chains := longestChains(byState)
+
+	var diags Diagnostics
+	for _, d := range deployments {
+		identity := d.State.Identity()
+		diag := Diagnostic{Severity: SeverityError, Rule: RuleThreshold, Detail: fmt.Sprintf("state: %s", d.State), Module: d.Path, Range: d.BackendRange}
+		if d.Workspace != "" || d.Overlay != "" {
+			diag.Detail += fmt.Sprintf(", workspace: %q, overlay: %q", d.Workspace, d.Overlay)
+		}
+
+		if chain := chains[identity]; limits.MaxDepth > 0 && len(chain)-1 > limits.MaxDepth {
+			diag := diag
+			diag.Summary = fmt.Sprintf("depth of the deployment: %d exceeds the limit: %d", len(chain)-1, limits.MaxDepth)
+			diag.Detail = "chain: " + strings.Join(chain, " -> ")
+			diags = append(diags, diag)
+		}
+		if n := fanIn[identity]; limits.MaxFanIn > 0 && n > limits.MaxFanIn {
+			diag := diag
+			diag.Summary = fmt.Sprintf("deployments depending on the deployment: %d exceed the limit: %d", n, limits.MaxFanIn)
+			diags = append(diags, diag)
+		}
+		if n := len(uniqueStates(d.Dependencies)); limits.MaxDependencies > 0 && n > limits.MaxDependencies {
+			diag := diag
+			diag.Summary = fmt.Sprintf("dependencies of the deployment: %d exceed the limit: %d", n, limits.MaxDependencies)
+			diags = append(diags, diag)
+		}
+	}
+
+	return diags
+}
+
+// longestChains returns the longest chain of states of the deployments, starting with the state of every deployment.
+// Dependencies on states not produced by any deployment and dependencies closing a cycle are ignored
+func longestChains(byState map[string]Deployment) map[string][]string {
+	chains := make(map[string][]string, len(byState))
+	visiting := make(map[string]bool, len(byState))
+
+	var chain func(identity string) []string
+	chain = func(identity string) []string {
+		if c, ok := chains[identity]; ok {
+			return c
+		}
+		visiting[identity] = true
+
+		var longest []string
+		for _, dep := range byState[identity].Dependencies {
+			depIdentity := dep.Identity()
+			if _, ok := byState[depIdentity]; !ok || visiting[depIdentity] {
+				continue
+			}
+			if c := chain(depIdentity); len(c) > len(longest) {
+				longest = c
+			}
+		}
+
+		visiting[identity] = false
+		chains[identity] = append([]string{byState[identity].State.String()}, longest...)
+		return chains[identity]
+	}
+
+	for identity := range byState {
+		chain(identity)
+	}
+
+	return chains
+}
+
+// uniqueStates returns the states without duplicates by [State.Identity], e.g. read by two terraform_remote_state
+func uniqueStates(states []State) []State {
+	seen := make(map[string]struct{}, len(states))
+	out := make([]State, 0, len(states))
+	for _, s := range states {
+		if _, ok := seen[s.Identity()]; ok {
+			continue
+		}
+		seen[s.Identity()] = struct{}{}
+		out = append(out, s)
+	}
+
+	return out
+}
@@ -7,5 +7,6 @@ import (
 	_ "github.com/editorconfig-checker/editorconfig-checker/cmd/editorconfig-checker"
 	_ "github.com/golangci/golangci-lint/cmd/golangci-lint"
 	_ "golang.org/x/tools/cmd/godoc"
+	_ "golang.org/x/vuln/cmd/govulncheck"
 	_ "mvdan.cc/gofumpt"
 )
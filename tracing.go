@@ -0,0 +1,149 @@
+package terradep
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+	"go.interactor.dev/terradep/inspect"
+	"golang.org/x/exp/slog"
+)
+
+// names of the spans started by the [Scanner]
+const (
+	// SpanScan is the walk of the root passed to [Scanner.Scan], [Scanner.Stream] or [Scanner.Explain], attribute dir is the root
+	SpanScan = "terradep.scan"
+	// SpanModule is scanning of the module, attribute path is its directory
+	SpanModule = "terradep.module"
+	// SpanParse is reading and parsing of the Terraform file, attribute file is its path
+	SpanParse = "terradep.parse"
+	// SpanState is the call to the [Stater], attribute backend is the type of the backend and block is backend or terraform_remote_state
+	SpanState = "terradep.state"
+)
+
+// names of the counters increased by the [Scanner]
+const (
+	// CounterModules counts scanned modules, attribute status is ok or error
+	CounterModules = "terradep.modules"
+	// CounterFiles counts parsed Terraform files
+	CounterFiles = "terradep.files"
+	// CounterBytes counts bytes of parsed Terraform files
+	CounterBytes = "terradep.bytes"
+	// CounterStates counts calls to the [Stater], attribute backend is the type of the backend
+	CounterStates = "terradep.states"
+	// CounterDiagnostics counts reported [Diagnostics], attribute rule is [Diagnostic.Rule]
+	CounterDiagnostics = "terradep.diagnostics"
+)
+
+// Tracer records spans and counters of the work done by the [Scanner], e.g. to export them with OpenTelemetry
+// and find out where long scans spend the time. See [WithTracer]
+type Tracer interface {
+	// Start starts the span with the name, parent is nil for the span of the whole scan
+	Start(parent Span, name string, attrs ...slog.Attr) Span
+	// Add adds n to the counter with the name
+	Add(name string, n int64, attrs ...slog.Attr)
+}
+
+// Span is the operation started by [Tracer.Start]
+type Span interface {
+	// End ends the operation, err is nil when the operation succeeded
+	End(err error)
+}
+
+// WithTracer makes the [Scanner] record the scan with the tracer: [SpanScan] of every scanned root with [SpanModule] of every module,
+// which contain [SpanParse] of its files and [SpanState] of read states, and counters like [CounterFiles].
+// Tracer must be safe for concurrent use, see [WithIOConcurrency]. Nil tracer disables tracing, which is the default
+func WithTracer(tracer Tracer) ScannerOpt {
+	return func(cfg *scannerCfg) {
+		cfg.tracer = tracer
+	}
+}
+
+// noopTracer is used when [Scanner] was created without the tracer
+type noopTracer struct{}
+
+func (noopTracer) Start(Span, string, ...slog.Attr) Span { return noopSpan{} }
+
+func (noopTracer) Add(string, int64, ...slog.Attr) {}
+
+type noopSpan struct{}
+
+func (noopSpan) End(error) {}
+
+// scanModuleTraced scans the module within its own span, which is the parent of spans started while scanning the module
+func (s *Scanner) scanModuleTraced(sc *scan, path string) error {
+	parent := sc.span
+	sc.span = s.tracer.Start(parent, SpanModule, slog.String("path", path))
+	sc.moduleSpans.Store(path, sc.span)
+
+	start := time.Now()
+	err := s.scanModule(sc, path)
+	sc.recordDuration(path, time.Since(start))
+
+	sc.moduleSpans.Delete(path)
+	if errors.Is(err, fs.SkipDir) {
+		sc.span.End(nil)
+		s.tracer.Add(CounterModules, 1, slog.String("status", "ok"))
+	} else {
+		sc.span.End(err)
+		s.tracer.Add(CounterModules, 1, slog.String("status", "error"))
+	}
+	sc.span = parent
+
+	return err
+}
+
+// parseHook starts [SpanParse] of every file parsed by the scan, as a child of the span of its module
+func (s *Scanner) parseHook(sc *scan) inspect.ParseHook {
+	return func(filename string) func(*hcl.File, hcl.Diagnostics) {
+		parent := sc.span
+		if span, ok := sc.moduleSpans.Load(filepath.Dir(filename)); ok {
+			// files of the modules are parsed by the workers, so the span of the module cannot be read from the scan
+			parent = span.(Span)
+		}
+		span := s.tracer.Start(parent, SpanParse, slog.String("file", filename))
+
+		return func(file *hcl.File, diags hcl.Diagnostics) {
+			s.tracer.Add(CounterFiles, 1)
+			sc.stats.files.Add(1)
+			if file != nil {
+				s.tracer.Add(CounterBytes, int64(len(file.Bytes)))
+				sc.stats.bytes.Add(int64(len(file.Bytes)))
+			}
+			if diags.HasErrors() {
+				span.End(diags)
+			} else {
+				span.End(nil)
+			}
+		}
+	}
+}
+
+// backendState reads the state from backend block of the deployment in the dir with [Stater.BackendState] within [SpanState]
+func (s *Scanner) backendState(sc *scan, dir, backend string, body hcl.Body) (State, error) {
+	span := s.tracer.Start(sc.span, SpanState, slog.String("backend", backend), slog.String("block", "backend"))
+	state, err := s.stater.BackendState(backend, body)
+	span.End(err)
+	s.tracer.Add(CounterStates, 1, slog.String("backend", backend))
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveState(state, dir), nil
+}
+
+// remoteState reads the state from configuration of terraform_remote_state of the deployment in the dir with [Stater.RemoteState] within [SpanState]
+func (s *Scanner) remoteState(sc *scan, dir, backend string, config map[string]cty.Value) (State, error) {
+	span := s.tracer.Start(sc.span, SpanState, slog.String("backend", backend), slog.String("block", "terraform_remote_state"))
+	state, err := s.stater.RemoteState(backend, config)
+	span.End(err)
+	s.tracer.Add(CounterStates, 1, slog.String("backend", backend))
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveState(state, dir), nil
+}
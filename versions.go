@@ -0,0 +1,190 @@
+package terradep
+
+import (
+	"sort"
+	"strings"
+)
+
+// VersionPinning tells how strictly required_version of the deployment limits the version of Terraform
+type VersionPinning string
+
+const (
+	// VersionExact means that the deployment requires single version of Terraform, e.g. = 1.5.7
+	VersionExact VersionPinning = "exact"
+	// VersionBounded means that newer versions are allowed up to the upper bound, e.g. ~> 1.5.0 or >= 1.5, < 2.0
+	VersionBounded VersionPinning = "bounded"
+	// VersionUnbounded means that only the lower bound is set, e.g. >= 1.3, so any future version is allowed
+	VersionUnbounded VersionPinning = "unbounded"
+	// VersionMissing means that the deployment does not set required_version at all
+	VersionMissing VersionPinning = "missing"
+)
+
+// Pinned checks whether the version of Terraform has the upper bound
+func (p VersionPinning) Pinned() bool {
+	return p == VersionExact || p == VersionBounded
+}
+
+// DeploymentVersion describes required_version of the deployment directory, shared by all its workspaces and overlays
+type DeploymentVersion struct {
+	Path string `json:"path"`
+	// Constraint contains constraints from all required_version attributes of the deployment, normalized and sorted, e.g. ">= 1.3, ~> 1.5"
+	Constraint string         `json:"constraint"`
+	Pinning    VersionPinning `json:"pinning"`
+	// Inconsistent is true when the constraint differs from the constraint declared by the most of the deployments
+	Inconsistent bool `json:"inconsistent"`
+}
+
+// VersionConstraintUsage is a constraint declared by the deployments
+type VersionConstraintUsage struct {
+	Constraint  string `json:"constraint"`
+	Deployments int    `json:"deployments"`
+}
+
+// VersionReport is an inventory of Terraform versions required by the deployments
+type VersionReport struct {
+	// Deployments are ordered by path
+	Deployments []DeploymentVersion `json:"deployments"`
+	// Constraints are distinct constraints, the most common first. Deployments without required_version are not counted
+	Constraints []VersionConstraintUsage `json:"constraints"`
+}
+
+// NewVersionReport returns the inventory of required_version of the deployments, e.g. found by [Scanner.Stream].
+// Deployments without [Deployment.Metadata] are treated like they did not set required_version
+func NewVersionReport(deployments []Deployment) *VersionReport {
+	byPath := make(map[string]DeploymentVersion, len(deployments))
+	for _, d := range deployments {
+		if _, ok := byPath[d.Path]; ok {
+			continue
+		}
+		var required []string
+		if d.Metadata != nil {
+			required = d.Metadata.RequiredVersion
+		}
+		constraints := versionConstraints(required)
+		byPath[d.Path] = DeploymentVersion{Path: d.Path, Constraint: strings.Join(constraints, ", "), Pinning: versionPinning(constraints)}
+	}
+
+	usage := make(map[string]int)
+	for _, v := range byPath {
+		if v.Pinning != VersionMissing {
+			usage[v.Constraint]++
+		}
+	}
+
+	report := &VersionReport{Deployments: make([]DeploymentVersion, 0, len(byPath)), Constraints: make([]VersionConstraintUsage, 0, len(usage))}
+	for constraint, n := range usage {
+		report.Constraints = append(report.Constraints, VersionConstraintUsage{Constraint: constraint, Deployments: n})
+	}
+	sort.Slice(report.Constraints, func(i, j int) bool {
+		a, b := report.Constraints[i], report.Constraints[j]
+		if a.Deployments != b.Deployments {
+			return a.Deployments > b.Deployments
+		}
+		return a.Constraint < b.Constraint
+	})
+
+	for _, v := range byPath {
+		// when any deployment sets the constraint, the most common one exists
+		v.Inconsistent = v.Pinning != VersionMissing && v.Constraint != report.Constraints[0].Constraint
+		report.Deployments = append(report.Deployments, v)
+	}
+	sort.Slice(report.Deployments, func(i, j int) bool {
+		return report.Deployments[i].Path < report.Deployments[j].Path
+	})
+
+	return report
+}
+
+// VersionReport returns the inventory of required_version of the deployments of the graph, see [NewVersionReport]
+func (g *Graph) VersionReport() *VersionReport {
+	var deployments []Deployment
+	for _, n := range g.Nodes() {
+		if g.IsDeployment(n) {
+			deployments = append(deployments, Deployment{Path: n.Path, Workspace: n.Workspace, Overlay: n.Overlay, State: n.State, Metadata: n.Metadata})
+		}
+	}
+
+	return NewVersionReport(deployments)
+}
+
+// Unpinned returns deployments which do not limit the version of Terraform from above, see [VersionPinning.Pinned]
+func (r *VersionReport) Unpinned() []DeploymentVersion {
+	var out []DeploymentVersion
+	for _, d := range r.Deployments {
+		if !d.Pinning.Pinned() {
+			out = append(out, d)
+		}
+	}
+
+	return out
+}
+
+// Inconsistent returns deployments which constraint differs from the most common one
+func (r *VersionReport) Inconsistent() []DeploymentVersion {
+	var out []DeploymentVersion
+	for _, d := range r.Deployments {
+		if d.Inconsistent {
+			out = append(out, d)
+		}
+	}
+
+	return out
+}
+
+// versionConstraints splits the values of required_version into single constraints without spaces between the operator and the version,
+// so constraints written differently compare equal, e.g. "~>1.5" and "~> 1.5"
+func versionConstraints(required []string) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, value := range required {
+		for _, c := range strings.Split(value, ",") {
+			c = strings.TrimSpace(c)
+			if c == "" {
+				continue
+			}
+			op, version := splitVersionOperator(c)
+			if op != "" {
+				c = op + " " + version
+			}
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// versionOperators are operators of version constraints, longer first, so they are matched before their prefixes
+var versionOperators = []string{"~>", ">=", "<=", "!=", ">", "<", "="}
+
+func splitVersionOperator(constraint string) (string, string) {
+	for _, op := range versionOperators {
+		if strings.HasPrefix(constraint, op) {
+			return op, strings.TrimSpace(strings.TrimPrefix(constraint, op))
+		}
+	}
+
+	return "", constraint
+}
+
+func versionPinning(constraints []string) VersionPinning {
+	if len(constraints) == 0 {
+		return VersionMissing
+	}
+
+	pinning := VersionUnbounded
+	for _, c := range constraints {
+		switch op, _ := splitVersionOperator(c); op {
+		case "", "=":
+			return VersionExact
+		case "~>", "<", "<=":
+			pinning = VersionBounded
+		}
+	}
+
+	return pinning
+}
@@ -0,0 +1,74 @@
+package terradep
+
+import "errors"
+
+// SkipChildren is returned by the function passed to [Graph.Walk] to not follow the dependencies and modules of the node.
+// They are still visited, when they are reachable through other nodes
+var SkipChildren = errors.New("skip children")
+
+// Walk visits every node of the graph once, depth-first, starting from [Graph.Heads] and following [Node.Children], then [Node.Modules],
+// so every node is visited before the nodes it depends on. The walk stops at the first error returned by visit other than [SkipChildren], which is returned
+func (g *Graph) Walk(visit func(n *Node) error) error {
+	err := walkNodes(g.Heads, make(map[*Node]struct{}), visit)
+	if errors.Is(err, SkipChildren) {
+		return nil
+	}
+
+	return err
+}
+
+func walkNodes(nodes []*Node, visited map[*Node]struct{}, visit func(n *Node) error) error {
+	for _, n := range nodes {
+		if _, ok := visited[n]; ok {
+			continue
+		}
+		visited[n] = struct{}{}
+
+		err := visit(n)
+		if errors.Is(err, SkipChildren) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := walkNodes(n.Children, visited, visit); err != nil {
+			return err
+		}
+		if err := walkNodes(n.Modules, visited, visit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DFS returns the node followed by all the nodes it depends on, directly or transitively, through [Node.Children] and [Node.Modules], depth-first.
+// Every node is returned once, even when it is reachable through many paths
+func (n *Node) DFS() []*Node {
+	var out []*Node
+	// visit does not return errors
+	_ = walkNodes([]*Node{n}, make(map[*Node]struct{}), func(n *Node) error {
+		out = append(out, n)
+		return nil
+	})
+
+	return out
+}
+
+// BFS returns the same nodes as [Node.DFS], but breadth-first, so direct dependencies are returned before transitive ones
+func (n *Node) BFS() []*Node {
+	visited := map[*Node]struct{}{n: {}}
+	out := []*Node{n}
+	for i := 0; i < len(out); i++ {
+		for _, next := range append(append([]*Node(nil), out[i].Children...), out[i].Modules...) {
+			if _, ok := visited[next]; ok {
+				continue
+			}
+			visited[next] = struct{}{}
+			out = append(out, next)
+		}
+	}
+
+	return out
+}
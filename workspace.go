@@ -0,0 +1,100 @@
+package terradep
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DefaultWorkspace is the name of the workspace used by Terraform when none was selected
+const DefaultWorkspace = "default"
+
+// S3WorkspaceKeyPrefix is the default workspace_key_prefix of S3 backend, the prefix of keys of states of non-default workspaces
+const S3WorkspaceKeyPrefix = "env:"
+
+// WorkspaceState is a [State] of the deployment in non-default [Terraform workspace].
+// States of the same deployment in different workspaces are not equal.
+//
+// [Terraform workspace]: https://developer.hashicorp.com/terraform/language/state/workspaces
+type WorkspaceState struct {
+	State
+	Workspace string
+}
+
+// String implements State. Workspace is appended to the state as URL fragment
+func (s WorkspaceState) String() string {
+	return s.State.String() + "#" + s.Workspace
+}
+
+// Identity implements State, it is the same as String
+func (s WorkspaceState) Identity() string {
+	return s.String()
+}
+
+// MarshalJSON implements [json.Marshaler]. The state of the default workspace is encoded in field state
+func (s WorkspaceState) MarshalJSON() ([]byte, error) {
+	return MarshalState(s, map[string]any{"workspace": s.Workspace, "state": s.State})
+}
+
+// deployment is a module directory deployed to the workspace, optionally with the [Overlay]. It identifies single [Node] of the [Graph]
+type deployment struct {
+	path      string
+	workspace string
+	overlay   string
+}
+
+// withWorkspace wraps the state with [WorkspaceState] unless workspace is empty or default one
+func withWorkspace(state State, workspace string) State {
+	if workspace == "" || workspace == DefaultWorkspace {
+		return state
+	}
+
+	return WorkspaceState{State: state, Workspace: workspace}
+}
+
+// moduleWorkspaces returns workspaces every module is expanded into. Empty string means workspaces are not used
+func (s *Scanner) moduleWorkspaces() []string {
+	if len(s.workspaces) == 0 {
+		return []string{""}
+	}
+
+	return s.workspaces
+}
+
+// workspaceEvalContext returns context allowing to evaluate expressions referencing terraform.workspace
+func workspaceEvalContext(workspace string) *hcl.EvalContext {
+	if workspace == "" {
+		workspace = DefaultWorkspace
+	}
+
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"terraform": cty.ObjectVal(map[string]cty.Value{
+				"workspace": cty.StringVal(workspace),
+			}),
+		},
+	}
+}
+
+// parseRemoteStateWorkspace returns value of attribute workspace of terraform_remote_state or [DefaultWorkspace] if it was not set
+func parseRemoteStateWorkspace(expr hcl.Expression, ctx *hcl.EvalContext) (string, error) {
+	if expr == nil {
+		return DefaultWorkspace, nil
+	}
+
+	value, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return "", fmt.Errorf("reading value of remote state workspace, %w", diags)
+	}
+
+	if value.IsNull() {
+		return DefaultWorkspace, nil
+	}
+
+	if !value.Type().Equals(cty.String) || !value.IsKnown() {
+		return "", fmt.Errorf("terraform remote state workspace must be a string")
+	}
+
+	return value.AsString(), nil
+}